@@ -0,0 +1,179 @@
+package xsd
+
+import (
+	"fmt"
+
+	"github.com/agentflare-ai/go-xmldom"
+)
+
+// errorTypeName names xs:error, which an alternative selects to make the
+// elements it applies to invalid
+var errorTypeName = QName{Namespace: XSDNamespace, Local: "error"}
+
+// TypeAlternative is an XSD 1.1 xs:alternative of an element declaration.
+// The first alternative whose Test an instance element satisfies selects
+// the type the element is validated against; an alternative without a test
+// is the default. Test is in the restricted XPath subset of xpath.go, so it
+// only sees the element's attributes.
+type TypeAlternative struct {
+	Test                  string
+	Type                  Type
+	XPathDefaultNamespace string
+	source                xmldom.Element
+	expr                  *xpathExpression
+	err                   error // Why Test could not be compiled
+}
+
+// IsError reports whether the alternative selects xs:error
+func (a *TypeAlternative) IsError() bool {
+	return a.Type != nil && a.Type.Name() == errorTypeName
+}
+
+// parseAlternative parses an xs:alternative element, with its type given by
+// the type attribute or an anonymous type definition
+func (s *Schema) parseAlternative(elem xmldom.Element) *TypeAlternative {
+	namespaces, defaultNamespace := s.xpathNamespacesOf(elem)
+	alt := &TypeAlternative{
+		Test:                  string(elem.GetAttribute("test")),
+		XPathDefaultNamespace: defaultNamespace,
+		source:                elem,
+	}
+	if typeName := string(elem.GetAttribute("type")); typeName != "" {
+		alt.Type = s.resolveType(elem, typeName)
+	}
+
+	children := s.schemaChildren(elem)
+	for i := uint(0); i < children.Length(); i++ {
+		child := children.Item(i)
+		if child == nil || string(child.NamespaceURI()) != XSDNamespace {
+			continue
+		}
+		switch string(child.LocalName()) {
+		case "simpleType":
+			if st := s.parseInlineSimpleType(child); st != nil && alt.Type == nil {
+				alt.Type = st
+			}
+		case "complexType":
+			if ct := s.parseInlineComplexType(child); ct != nil && alt.Type == nil {
+				alt.Type = ct
+			}
+		}
+	}
+
+	if alt.Test != "" {
+		alt.expr, alt.err = compileRestrictedXPath(alt.Test, namespaces)
+	}
+	return alt
+}
+
+// selectAlternative returns the first alternative of a declaration whose
+// test an instance element satisfies, or its default alternative, or nil.
+// Tests see attribute values as untypedAtomic, and a test that cannot be
+// evaluated is false.
+func (s *Schema) selectAlternative(elem xmldom.Element, decl *ElementDecl) *TypeAlternative {
+	if decl == nil || len(decl.Alternatives) == 0 {
+		return nil
+	}
+	env := &xpathEnv{root: elem}
+	for _, alt := range decl.Alternatives {
+		if alt.Test == "" {
+			return alt
+		}
+		if alt.expr == nil {
+			continue
+		}
+		if ok, err := alt.expr.test(env, elem, nil); err == nil && ok {
+			return alt
+		}
+	}
+	return nil
+}
+
+// alternativeType returns the type an alternative selects, resolving
+// references to named types
+func (s *Schema) alternativeType(alt *TypeAlternative) Type {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if t := s.resolvePlaceholder(alt.Type); t != nil {
+		return t
+	}
+	return alt.Type
+}
+
+// withAlternative records the alternative that selected the type of an
+// element in the violations reported while validating the element and its
+// content. Content model violations that name no element are reported on
+// the element itself; violations that already name the alternative of a
+// descendant keep it.
+func withAlternative(violations []Violation, elem xmldom.Element, alt *TypeAlternative) []Violation {
+	for i := range violations {
+		if violations[i].Element == nil {
+			violations[i].Element = elem
+		}
+		if alt != nil && violations[i].Alternative == nil {
+			violations[i].Alternative = alt
+		}
+	}
+	return violations
+}
+
+// alternativeLabel describes how an alternative selects its type, for
+// messages
+func alternativeLabel(alt *TypeAlternative) string {
+	if alt.Test == "" {
+		return "the default alternative"
+	}
+	return fmt.Sprintf("the alternative '%s'", alt.Test)
+}
+
+// checkTypeAlternatives reports alternatives without exactly one type,
+// whose test is not in the restricted XPath subset, or whose type is not
+// derived from the type of their element declaration
+func (s *Schema) checkTypeAlternatives() []error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var errs []error
+	for _, decl := range s.elementDeclarations() {
+		for _, alt := range decl.Alternatives {
+			if alt.Type == nil || s.hasAnonymousType(alt.source) && alt.source.GetAttribute("type") != "" {
+				errs = append(errs, sourceError(alt.source, "src-type-alternative.3",
+					"alternative of element '%s' must have either a type attribute or an anonymous type",
+					decl.Name.Local))
+				continue
+			}
+			if alt.err != nil {
+				errs = append(errs, sourceError(alt.source, "tao-props-correct",
+					"alternative '%s' of element '%s': %v", alt.Test, decl.Name.Local, alt.err))
+			}
+			if alt.IsError() || decl.Type == nil || decl.Type.Name() == anyTypeQName {
+				continue
+			}
+			t, declared := s.resolvePlaceholder(alt.Type), s.resolvePlaceholder(decl.Type)
+			if t == nil || declared == nil {
+				continue
+			}
+			if _, ok := s.derivationMethods(t, declared); !ok {
+				errs = append(errs, sourceError(alt.source, "e-props-correct.7",
+					"type '%s' of %s is not derived from the type '%s' of element '%s'",
+					typeLabel(t), alternativeLabel(alt), typeLabel(declared), decl.Name.Local))
+			}
+		}
+	}
+	return errs
+}
+
+// hasAnonymousType reports whether a schema element has an anonymous type
+// definition child
+func (s *Schema) hasAnonymousType(elem xmldom.Element) bool {
+	children := s.schemaChildren(elem)
+	for i := uint(0); i < children.Length(); i++ {
+		child := children.Item(i)
+		if child != nil && string(child.NamespaceURI()) == XSDNamespace &&
+			(child.LocalName() == "simpleType" || child.LocalName() == "complexType") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,286 @@
+package xsd
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/agentflare-ai/go-xmldom"
+)
+
+func TestTypeAlternatives(t *testing.T) {
+	schema, err := parseTestSchema11(t, `<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"
+           xmlns:t="http://example.com/alt" targetNamespace="http://example.com/alt"
+           elementFormDefault="qualified">
+	<xs:complexType name="message">
+		<xs:sequence>
+			<xs:element name="id" type="xs:string"/>
+		</xs:sequence>
+		<xs:attribute name="kind" type="xs:string"/>
+		<xs:attribute name="version" type="xs:int"/>
+	</xs:complexType>
+	<xs:complexType name="refund">
+		<xs:complexContent>
+			<xs:extension base="t:message">
+				<xs:sequence>
+					<xs:element name="amount" type="xs:decimal"/>
+				</xs:sequence>
+			</xs:extension>
+		</xs:complexContent>
+	</xs:complexType>
+	<xs:complexType name="legacy">
+		<xs:complexContent>
+			<xs:restriction base="t:message">
+				<xs:sequence>
+					<xs:element name="id" type="xs:string"/>
+				</xs:sequence>
+				<xs:attribute name="kind" type="xs:string"/>
+				<xs:attribute name="version" type="xs:int"/>
+			</xs:restriction>
+		</xs:complexContent>
+	</xs:complexType>
+	<xs:element name="message" type="t:message">
+		<xs:alternative test="@kind = 'refund'" type="t:refund"/>
+		<xs:alternative test="@version lt 2" type="t:legacy"/>
+		<xs:alternative test="@kind = 'unknown'" type="xs:error"/>
+	</xs:element>
+	<xs:element name="batch">
+		<xs:complexType>
+			<xs:sequence>
+				<xs:element ref="t:message" maxOccurs="unbounded"/>
+				<xs:element name="note" type="xs:string" minOccurs="0">
+					<xs:alternative test="@lang" type="xs:token"/>
+					<xs:alternative type="xs:string"/>
+				</xs:element>
+			</xs:sequence>
+		</xs:complexType>
+	</xs:element>
+</xs:schema>`)
+	if err != nil {
+		t.Fatalf("Failed to parse schema: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		instance    string
+		codes       []string
+		alternative string
+	}{
+		{
+			name:     "declared type when no test passes",
+			instance: `<message xmlns="http://example.com/alt" kind="order"><id>1</id></message>`,
+		},
+		{
+			name:     "first passing alternative",
+			instance: `<message xmlns="http://example.com/alt" kind="refund"><id>1</id><amount>9.99</amount></message>`,
+		},
+		{
+			name:        "content checked against the selected type",
+			instance:    `<message xmlns="http://example.com/alt" kind="refund"><id>1</id></message>`,
+			codes:       []string{"cvc-complex-type.2.4.b"},
+			alternative: "@kind = 'refund'",
+		},
+		{
+			name: "content of nested elements checked against the selected type",
+			instance: `<batch xmlns="http://example.com/alt">
+				<message kind="refund"><id>1</id></message>
+			</batch>`,
+			codes:       []string{"cvc-complex-type.2.4.b"},
+			alternative: "@kind = 'refund'",
+		},
+		{
+			name:     "attributes compared as untyped values",
+			instance: `<message xmlns="http://example.com/alt" version="1"><id>1</id></message>`,
+		},
+		{
+			name:        "xs:error",
+			instance:    `<message xmlns="http://example.com/alt" kind="unknown"><id>1</id></message>`,
+			codes:       []string{"cvc-type.3.1.3"},
+			alternative: "@kind = 'unknown'",
+		},
+		{
+			name: "nested elements",
+			instance: `<batch xmlns="http://example.com/alt">
+				<message kind="refund"><id>1</id><amount>1</amount></message>
+				<message kind="unknown"><id>2</id></message>
+				<note lang="en">ok</note>
+			</batch>`,
+			codes:       []string{"cvc-type.3.1.3"},
+			alternative: "@kind = 'unknown'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := xmldom.Decode(strings.NewReader(tt.instance))
+			if err != nil {
+				t.Fatalf("Failed to parse instance: %v", err)
+			}
+
+			var codes []string
+			violations := NewValidator(schema).Validate(doc)
+			for _, v := range violations {
+				codes = append(codes, v.Code)
+			}
+			if strings.Join(codes, " ") != strings.Join(tt.codes, " ") {
+				t.Fatalf("Expected violations %v, got %v", tt.codes, violations)
+			}
+			for _, v := range violations {
+				if tt.alternative != "" && (v.Alternative == nil || v.Alternative.Test != tt.alternative) {
+					t.Errorf("Expected violation %s to name the alternative %q, got %+v", v.Code, tt.alternative, v.Alternative)
+				}
+			}
+		})
+	}
+}
+
+func TestSelectAlternative(t *testing.T) {
+	schema, err := parseTestSchema11(t, `<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+	<xs:simpleType name="number">
+		<xs:restriction base="xs:int"/>
+	</xs:simpleType>
+	<xs:simpleType name="day">
+		<xs:restriction base="xs:date"/>
+	</xs:simpleType>
+	<xs:complexType name="intValue">
+		<xs:sequence><xs:element name="n" type="number"/></xs:sequence>
+		<xs:attribute name="type"/>
+	</xs:complexType>
+	<xs:complexType name="anyValue">
+		<xs:sequence><xs:element name="text" type="xs:string"/></xs:sequence>
+		<xs:attribute name="type"/>
+	</xs:complexType>
+	<xs:element name="value">
+		<xs:alternative test="@type = 'int'" type="intValue"/>
+		<xs:alternative test="@type = 'date'">
+			<xs:complexType>
+				<xs:sequence><xs:element name="d" type="day"/></xs:sequence>
+				<xs:attribute name="type"/>
+			</xs:complexType>
+		</xs:alternative>
+		<xs:alternative type="anyValue"/>
+	</xs:element>
+</xs:schema>`)
+	if err != nil {
+		t.Fatalf("Failed to parse schema: %v", err)
+	}
+
+	for instance, want := range map[string][]string{
+		`<value type="int"><n>12</n></value>`:          nil,
+		`<value type="int"><n>twelve</n></value>`:      {"cvc-datatype-valid.1"},
+		`<value type="date"><d>2024-01-01</d></value>`: nil,
+		`<value type="date"><d>tomorrow</d></value>`:   {"cvc-datatype-valid.1"},
+		`<value type="text"><text>any</text></value>`:  nil,
+	} {
+		doc, err := xmldom.Decode(strings.NewReader(instance))
+		if err != nil {
+			t.Fatalf("Failed to parse instance: %v", err)
+		}
+		var codes []string
+		for _, v := range NewValidator(schema).Validate(doc) {
+			codes = append(codes, v.Code)
+		}
+		if strings.Join(codes, " ") != strings.Join(want, " ") {
+			t.Errorf("%s: expected violations %v, got %v", instance, want, codes)
+		}
+	}
+}
+
+func TestTypeAlternativesXSD10(t *testing.T) {
+	// xs:alternative is XSD 1.1; a 1.0 schema ignores it and keeps the
+	// declared type, even when the test would not compile
+	schema, err := parseTestSchema(t, `<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+	<xs:complexType name="message">
+		<xs:simpleContent>
+			<xs:extension base="xs:string">
+				<xs:attribute name="kind" type="xs:string"/>
+			</xs:extension>
+		</xs:simpleContent>
+	</xs:complexType>
+	<xs:element name="message" type="message">
+		<xs:alternative test="@kind = 'unknown'" type="xs:error"/>
+		<xs:alternative test="@kind = (" type="message"/>
+	</xs:element>
+</xs:schema>`)
+	if err != nil {
+		t.Fatalf("Failed to parse schema: %v", err)
+	}
+	decl := schema.ElementDecls[QName{Local: "message"}]
+	if len(decl.Alternatives) != 0 {
+		t.Errorf("Expected no type alternatives under XSD 1.0, got %d", len(decl.Alternatives))
+	}
+
+	doc, err := xmldom.Decode(strings.NewReader(`<message kind="unknown">hello</message>`))
+	if err != nil {
+		t.Fatalf("Failed to parse instance: %v", err)
+	}
+	if violations := NewValidator(schema).Validate(doc); len(violations) != 0 {
+		t.Errorf("Expected the declared type to apply under XSD 1.0, got %v", violations)
+	}
+}
+
+func TestTypeAlternativeSchemaErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+		code   string
+	}{
+		{
+			name: "test outside the restricted subset",
+			schema: `<xs:element name="e" type="xs:string">
+				<xs:alternative test="child = 'x'" type="xs:token"/>
+			</xs:element>`,
+			code: "tao-props-correct",
+		},
+		{
+			name: "syntax error",
+			schema: `<xs:element name="e" type="xs:string">
+				<xs:alternative test="@a =" type="xs:token"/>
+			</xs:element>`,
+			code: "tao-props-correct",
+		},
+		{
+			name: "missing type",
+			schema: `<xs:element name="e" type="xs:string">
+				<xs:alternative test="@a"/>
+			</xs:element>`,
+			code: "src-type-alternative.3",
+		},
+		{
+			name: "type not derived from the declared type",
+			schema: `<xs:element name="e" type="xs:string">
+				<xs:alternative test="@a" type="xs:int"/>
+			</xs:element>`,
+			code: "e-props-correct.7",
+		},
+		{
+			name: "local element",
+			schema: `<xs:element name="root">
+				<xs:complexType><xs:sequence>
+					<xs:element name="e" type="xs:int"><xs:alternative test="../@a" type="xs:short"/></xs:element>
+				</xs:sequence></xs:complexType>
+			</xs:element>`,
+			code: "tao-props-correct",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseTestSchema11(t, `<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">`+tt.schema+`</xs:schema>`)
+			if err == nil {
+				t.Fatal("Expected schema to be rejected")
+			}
+			var schemaErr *SchemaError
+			if !errors.As(err, &schemaErr) || !strings.Contains(err.Error(), tt.code+":") {
+				t.Fatalf("Expected a %s error, got %v", tt.code, err)
+			}
+			if schemaErr.Line == 0 {
+				t.Errorf("Expected the error to have a location: %v", err)
+			}
+		})
+	}
+}
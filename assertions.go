@@ -0,0 +1,292 @@
+package xsd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/agentflare-ai/go-xmldom"
+)
+
+// Assertion is an XSD 1.1 assertion: an xs:assert of a complex type, or an
+// xs:assertion facet of a simple type. Test is an XPath 2.0 expression in
+// the subset described in xpath.go.
+type Assertion struct {
+	Test                  string
+	XPathDefaultNamespace string
+	source                xmldom.Element
+	expr                  *xpathExpression
+	err                   error // Why Test could not be compiled
+}
+
+// AssertionError reports a value or an element that does not satisfy an
+// assertion. Err is set when the test could not be evaluated, which counts
+// as failing it.
+type AssertionError struct {
+	Test string
+	Err  error
+}
+
+func (e *AssertionError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("assertion '%s' could not be evaluated: %v", e.Test, e.Err)
+	}
+	return fmt.Sprintf("assertion '%s' is not satisfied", e.Test)
+}
+
+// AssertionFacet validates values against an xs:assertion, with the value
+// bound to $value
+type AssertionFacet struct {
+	*Assertion
+}
+
+func (f *AssertionFacet) Name() string {
+	return "assertion"
+}
+
+func (f *AssertionFacet) Validate(value string, baseType Type) error {
+	if f.expr == nil {
+		return nil
+	}
+	vars := map[string][]any{"value": assertionValue(value, baseType)}
+	ok, err := f.expr.test(&xpathEnv{}, nil, vars)
+	if err != nil || !ok {
+		return &AssertionError{Test: f.Test, Err: err}
+	}
+	return nil
+}
+
+// assertionValue returns the typed value of a value of a built-in type, or
+// the value as untypedAtomic when the type is not known
+func assertionValue(value string, baseType Type) []any {
+	if baseType != nil && baseType.Name().Namespace == XSDNamespace {
+		if typed, err := parseBuiltinValue(value, baseType.Name().Local, nil); err == nil {
+			return flattenValue(typed)
+		}
+	}
+	return []any{untypedAtomic(value)}
+}
+
+// parseAssertion parses an xs:assert or xs:assertion element and compiles
+// its test against the namespaces in scope
+func (s *Schema) parseAssertion(elem xmldom.Element) *Assertion {
+	namespaces, defaultNamespace := s.xpathNamespacesOf(elem)
+	a := &Assertion{
+		Test:                  string(elem.GetAttribute("test")),
+		XPathDefaultNamespace: defaultNamespace,
+		source:                elem,
+	}
+	if a.Test == "" {
+		a.err = fmt.Errorf("the test attribute is missing")
+		return a
+	}
+	a.expr, a.err = compileXPath(a.Test, namespaces)
+	return a
+}
+
+// xpathNamespacesOf returns the namespaces XPath expressions of a schema
+// element are compiled with, and its xpathDefaultNamespace, which defaults
+// to that of the xs:schema element
+func (s *Schema) xpathNamespacesOf(elem xmldom.Element) (xpathNamespaces, string) {
+	defaultNamespace := string(elem.GetAttribute("xpathDefaultNamespace"))
+	if defaultNamespace == "" {
+		if root := schemaElement(elem); root != nil {
+			defaultNamespace = string(root.GetAttribute("xpathDefaultNamespace"))
+		}
+	}
+	return xpathNamespaces{
+		resolve: func(prefix string) (string, bool) {
+			return lookupNamespace(elem, prefix)
+		},
+		defaultElement: s.xpathDefaultNamespace(elem, defaultNamespace),
+	}, defaultNamespace
+}
+
+// schemaElement returns the xs:schema element a schema component is in
+func schemaElement(elem xmldom.Element) xmldom.Element {
+	for elem != nil {
+		if string(elem.NamespaceURI()) == XSDNamespace && string(elem.LocalName()) == "schema" {
+			return elem
+		}
+		parent, ok := elem.ParentNode().(xmldom.Element)
+		if !ok {
+			break
+		}
+		elem = parent
+	}
+	return nil
+}
+
+// xpathDefaultNamespace resolves an xpathDefaultNamespace attribute to the
+// namespace of unprefixed element names in XPath expressions
+func (s *Schema) xpathDefaultNamespace(elem xmldom.Element, value string) string {
+	switch value {
+	case "", "##local":
+		return ""
+	case "##targetNamespace":
+		return s.TargetNamespace
+	case "##defaultNamespace":
+		namespace, _ := lookupNamespace(elem, "")
+		return namespace
+	}
+	return value
+}
+
+// declaredAssertions returns the assertions a complex type definition
+// declares itself, including those of its derivation
+func declaredAssertions(ct *ComplexType) []*Assertion {
+	assertions := append([]*Assertion(nil), ct.Assertions...)
+	switch content := declaredContent(ct).(type) {
+	case *SimpleContent:
+		if content.Extension != nil {
+			assertions = append(assertions, content.Extension.Assertions...)
+		}
+		if content.Restriction != nil {
+			assertions = append(assertions, content.Restriction.Assertions...)
+		}
+	case *ComplexContent:
+		if content.Extension != nil {
+			assertions = append(assertions, content.Extension.Assertions...)
+		}
+		if content.Restriction != nil {
+			assertions = append(assertions, content.Restriction.Assertions...)
+		}
+	}
+	return assertions
+}
+
+// Assertions returns the assertions elements of a complex type must
+// satisfy: those of its base types followed by its own
+func (s *Schema) Assertions(ct *ComplexType) []*Assertion {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var assertions []*Assertion
+	visited := make(map[*ComplexType]bool)
+	for t := ct; t != nil && !visited[t]; {
+		visited[t] = true
+		assertions = append(declaredAssertions(t), assertions...)
+		if t.BaseType.Local == "" {
+			break
+		}
+		t, _ = s.lookupTypeLocked(t.BaseType).(*ComplexType)
+	}
+	return assertions
+}
+
+// validateAssertions evaluates the assertions of a complex type against an
+// element, with the element as the root of the tree and the typed value of
+// simple content bound to $value
+func (s *Schema) validateAssertions(elem xmldom.Element, ct *ComplexType) []Violation {
+	assertions := s.Assertions(ct)
+	if len(assertions) == 0 {
+		return nil
+	}
+
+	env := &xpathEnv{root: elem, atomize: s.atomizeNode}
+	vars := map[string][]any{"value": nil}
+	if _, ok := declaredContent(ct).(*SimpleContent); ok {
+		if value, err := s.ParseValueAt(string(elem.TextContent()), ct, elem); err == nil {
+			vars["value"] = flattenValue(value)
+		} else {
+			vars["value"] = []any{untypedAtomic(NormalizeValue(string(elem.TextContent()), ct))}
+		}
+	}
+
+	var violations []Violation
+	for _, a := range assertions {
+		if a.expr == nil {
+			continue
+		}
+		ok, err := a.expr.test(env, elem, vars)
+		if err == nil && ok {
+			continue
+		}
+		violations = append(violations, Violation{
+			Element: elem,
+			Code:    "cvc-assertion",
+			Message: fmt.Sprintf("Element '%s' of type '%s': %v",
+				elem.LocalName(), typeLabel(ct), &AssertionError{Test: a.Test, Err: err}),
+			Actual: a.Test,
+		})
+	}
+	return violations
+}
+
+// atomizeNode returns the typed value of an element or attribute of the
+// instance, or nil when its type is not known, its content is not simple
+// or its value is invalid
+func (s *Schema) atomizeNode(node xmldom.Node) ([]any, error) {
+	var t Type
+	var context xmldom.Element
+	switch node.NodeType() {
+	case xmldom.ELEMENT_NODE:
+		context, _ = node.(xmldom.Element)
+		t = s.instanceType(context)
+	case xmldom.ATTRIBUTE_NODE:
+		attr, ok := node.(xmldom.Attr)
+		if !ok {
+			return nil, nil
+		}
+		context = attr.OwnerElement()
+		t = s.instanceAttributeType(s.instanceType(context), string(attr.NodeName()))
+	}
+	if t == nil || context == nil {
+		return nil, nil
+	}
+	value, err := s.ParseValueAt(nodeStringValue(node), t, context)
+	if err != nil {
+		return nil, nil
+	}
+	return flattenValue(value), nil
+}
+
+// assertionCode returns cvc-assertion for errors of assertion facets, and
+// code for other errors
+func assertionCode(err error, code string) string {
+	var assertionErr *AssertionError
+	if errors.As(err, &assertionErr) {
+		return "cvc-assertion"
+	}
+	return code
+}
+
+// checkAssertions reports assertions whose test is missing or is not an
+// expression of the supported XPath subset (as-props-correct)
+func (s *Schema) checkAssertions() []error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var errs []error
+	report := func(t Type, assertions []*Assertion) {
+		for _, a := range assertions {
+			if a.err != nil {
+				errs = append(errs, sourceError(a.source, "as-props-correct",
+					"assertion '%s' of type '%s': %v", a.Test, typeLabel(t), a.err))
+			}
+		}
+	}
+	facetAssertions := func(facets []FacetValidator) []*Assertion {
+		var assertions []*Assertion
+		for _, facet := range facets {
+			if f, ok := facet.(*AssertionFacet); ok {
+				assertions = append(assertions, f.Assertion)
+			}
+		}
+		return assertions
+	}
+
+	for _, t := range s.typeDefinitions() {
+		switch tt := t.(type) {
+		case *ComplexType:
+			report(tt, declaredAssertions(tt))
+			if sc, ok := declaredContent(tt).(*SimpleContent); ok && sc.Restriction != nil {
+				report(tt, facetAssertions(sc.Restriction.Facets))
+			}
+		case *SimpleType:
+			if tt.Restriction != nil {
+				report(tt, facetAssertions(tt.Restriction.Facets))
+			}
+		}
+	}
+	return errs
+}
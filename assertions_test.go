@@ -0,0 +1,218 @@
+package xsd
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/agentflare-ai/go-xmldom"
+)
+
+func TestAssertions(t *testing.T) {
+	schema, err := parseTestSchema11(t, `<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"
+           xmlns:t="http://example.com/assert" targetNamespace="http://example.com/assert"
+           elementFormDefault="qualified" xpathDefaultNamespace="##targetNamespace">
+	<xs:simpleType name="even">
+		<xs:restriction base="xs:int">
+			<xs:assertion test="$value mod 2 = 0"/>
+		</xs:restriction>
+	</xs:simpleType>
+	<xs:complexType name="range">
+		<xs:attribute name="min" type="xs:int"/>
+		<xs:attribute name="max" type="xs:int"/>
+		<xs:assert test="@min le @max"/>
+	</xs:complexType>
+	<xs:complexType name="namedRange">
+		<xs:complexContent>
+			<xs:extension base="t:range">
+				<xs:attribute name="label" type="xs:string"/>
+				<xs:assert test="string-length(@label) gt 0"/>
+			</xs:extension>
+		</xs:complexContent>
+	</xs:complexType>
+	<xs:complexType name="amount">
+		<xs:simpleContent>
+			<xs:extension base="xs:decimal">
+				<xs:attribute name="limit" type="xs:decimal"/>
+				<xs:assert test="empty(@limit) or $value le @limit"/>
+			</xs:extension>
+		</xs:simpleContent>
+	</xs:complexType>
+	<xs:element name="order">
+		<xs:complexType>
+			<xs:sequence>
+				<xs:element name="item" maxOccurs="unbounded">
+					<xs:complexType>
+						<xs:sequence>
+							<xs:element name="qty" type="t:even"/>
+							<xs:element name="price" type="t:amount"/>
+						</xs:sequence>
+					</xs:complexType>
+				</xs:element>
+				<xs:element name="window" type="t:range" minOccurs="0"/>
+			</xs:sequence>
+			<xs:attribute name="count" type="xs:int"/>
+			<xs:assert test="@count = count(item)"/>
+			<xs:assert test="every $i in item satisfies $i/qty > 0"/>
+		</xs:complexType>
+	</xs:element>
+	<xs:element name="range" type="t:range"/>
+	<xs:element name="namedRange" type="t:namedRange"/>
+</xs:schema>`)
+	if err != nil {
+		t.Fatalf("Failed to parse schema: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		instance string
+		codes    []string
+		message  string
+	}{
+		{
+			name:     "values compared as integers",
+			instance: `<range xmlns="http://example.com/assert" min="9" max="10"/>`,
+		},
+		{
+			name:     "failed assertion names its test",
+			instance: `<range xmlns="http://example.com/assert" min="10" max="9"/>`,
+			codes:    []string{"cvc-assertion"},
+			message:  "@min le @max",
+		},
+		{
+			name:     "assertions of the base type are inherited",
+			instance: `<namedRange xmlns="http://example.com/assert" min="3" max="1" label=""/>`,
+			codes:    []string{"cvc-assertion", "cvc-assertion"},
+		},
+		{
+			name: "nested content",
+			instance: `<order xmlns="http://example.com/assert" count="2">
+				<item><qty>2</qty><price limit="5">4.50</price></item>
+				<item><qty>4</qty><price>9</price></item>
+				<window min="1" max="2"/>
+			</order>`,
+		},
+		{
+			name: "assertions on descendants",
+			instance: `<order xmlns="http://example.com/assert" count="1">
+				<item><qty>2</qty><price limit="5">5.50</price></item>
+				<window min="3" max="2"/>
+			</order>`,
+			codes: []string{"cvc-assertion", "cvc-assertion"},
+		},
+		{
+			name: "assertion over the whole subtree",
+			instance: `<order xmlns="http://example.com/assert" count="3">
+				<item><qty>2</qty><price>1</price></item>
+			</order>`,
+			codes:   []string{"cvc-assertion"},
+			message: "@count = count(item)",
+		},
+		{
+			name: "assertion facet",
+			instance: `<order xmlns="http://example.com/assert" count="1">
+				<item><qty>3</qty><price>1</price></item>
+			</order>`,
+			codes:   []string{"cvc-assertion"},
+			message: "$value mod 2 = 0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := xmldom.Decode(strings.NewReader(tt.instance))
+			if err != nil {
+				t.Fatalf("Failed to parse instance: %v", err)
+			}
+
+			var codes, messages []string
+			for _, v := range NewValidator(schema).Validate(doc) {
+				codes = append(codes, v.Code)
+				messages = append(messages, v.Message)
+			}
+			if strings.Join(codes, " ") != strings.Join(tt.codes, " ") {
+				t.Errorf("Expected violations %v, got %v (%v)", tt.codes, codes, messages)
+			}
+			if tt.message != "" && !strings.Contains(strings.Join(messages, "\n"), tt.message) {
+				t.Errorf("Expected a message mentioning %q, got %v", tt.message, messages)
+			}
+		})
+	}
+}
+
+func TestAssertionsXSD10(t *testing.T) {
+	// xs:assert and xs:assertion are XSD 1.1; a 1.0 schema ignores them,
+	// even when their tests would not compile
+	schema, err := parseTestSchema(t, `<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+	<xs:simpleType name="even">
+		<xs:restriction base="xs:int">
+			<xs:assertion test="$value mod 2 = 0"/>
+		</xs:restriction>
+	</xs:simpleType>
+	<xs:element name="range">
+		<xs:complexType>
+			<xs:attribute name="min" type="even"/>
+			<xs:attribute name="max" type="xs:int"/>
+			<xs:assert test="@min le @max"/>
+			<xs:assert test="@min le ("/>
+		</xs:complexType>
+	</xs:element>
+</xs:schema>`)
+	if err != nil {
+		t.Fatalf("Failed to parse schema: %v", err)
+	}
+
+	doc, err := xmldom.Decode(strings.NewReader(`<range min="3" max="2"/>`))
+	if err != nil {
+		t.Fatalf("Failed to parse instance: %v", err)
+	}
+	if violations := NewValidator(schema).Validate(doc); len(violations) != 0 {
+		t.Errorf("Expected assertions to be ignored under XSD 1.0, got %v", violations)
+	}
+}
+
+func TestAssertionSchemaErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+	}{
+		{
+			name: "syntax error in assert",
+			schema: `<xs:complexType name="t">
+				<xs:attribute name="a" type="xs:int"/>
+				<xs:assert test="@a >"/>
+			</xs:complexType>`,
+		},
+		{
+			name: "undeclared prefix in assertion facet",
+			schema: `<xs:simpleType name="s">
+				<xs:restriction base="xs:string"><xs:assertion test="q:f($value)"/></xs:restriction>
+			</xs:simpleType>`,
+		},
+		{
+			name: "missing test",
+			schema: `<xs:complexType name="t">
+				<xs:complexContent><xs:restriction base="xs:anyType"><xs:assert/></xs:restriction></xs:complexContent>
+			</xs:complexType>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseTestSchema11(t, `<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">`+tt.schema+`</xs:schema>`)
+			if err == nil {
+				t.Fatal("Expected schema to be rejected")
+			}
+			var schemaErr *SchemaError
+			if !errors.As(err, &schemaErr) || !strings.Contains(err.Error(), "as-props-correct:") {
+				t.Fatalf("Expected an as-props-correct error, got %v", err)
+			}
+			if schemaErr.Line == 0 {
+				t.Errorf("Expected the error to have a location: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,359 @@
+package xsd
+
+import (
+	"slices"
+	"strings"
+)
+
+// AttributeUses returns the effective attribute uses of a complex type:
+// those it declares, directly or through attribute groups, together with
+// those inherited from its base types. Prohibited uses are left out.
+func (s *Schema) AttributeUses(ct *ComplexType) []*AttributeDecl {
+	if ct.attributesCompiled {
+		return ct.attributeUses
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	uses, _ := s.effectiveAttributeUses(ct)
+	return uses
+}
+
+// AttributeWildcard returns the effective attribute wildcard of a complex
+// type, or nil when it allows no attributes beyond its attribute uses
+func (s *Schema) AttributeWildcard(ct *ComplexType) *AnyAttribute {
+	if ct.attributesCompiled {
+		return ct.attributeWildcard
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, wildcard := s.effectiveAttributeUses(ct)
+	return wildcard
+}
+
+// effectiveAttributeUses returns the compiled attribute uses and wildcard
+// of a complex type, or those it declares itself when it was built by hand
+// rather than parsed. The caller must hold the schema's read lock.
+func (s *Schema) effectiveAttributeUses(ct *ComplexType) ([]*AttributeDecl, *AnyAttribute) {
+	if ct.attributesCompiled {
+		return ct.attributeUses, ct.attributeWildcard
+	}
+	uses, wildcard := s.declaredAttributeUses(ct)
+	kept := uses[:0:0]
+	for _, attr := range uses {
+		if attr.Use != ProhibitedUse {
+			kept = append(kept, attr)
+		}
+	}
+	return kept, wildcard
+}
+
+// compileAttributeUses computes the effective attribute uses and wildcard
+// of every complex type. The caller must hold the schema's lock.
+func (s *Schema) compileAttributeUses() {
+	compiled := make(map[*ComplexType]bool)
+	for _, t := range s.typeDefinitions() {
+		if ct, ok := t.(*ComplexType); ok {
+			s.compileComplexTypeAttributes(ct, compiled)
+		}
+	}
+}
+
+// compileComplexTypeAttributes computes the attribute uses and wildcard of
+// a complex type after those of its base. Extensions add to the base uses
+// and union the wildcards; restrictions override base uses by name, remove
+// prohibited ones and keep only their own wildcard.
+func (s *Schema) compileComplexTypeAttributes(ct *ComplexType, compiled map[*ComplexType]bool) {
+	if compiled[ct] {
+		return
+	}
+	compiled[ct] = true
+
+	own, wildcard := s.declaredAttributeUses(ct)
+
+	var base *ComplexType
+	if ct.DerivedBy != "" {
+		if baseCT, ok := s.lookupTypeLocked(ct.BaseType).(*ComplexType); ok && baseCT != ct {
+			s.compileComplexTypeAttributes(baseCT, compiled)
+			base = baseCT
+		}
+	}
+
+	var uses []*AttributeDecl
+	index := make(map[QName]int)
+	if base != nil {
+		for _, attr := range base.attributeUses {
+			index[attr.Name] = len(uses)
+			uses = append(uses, attr)
+		}
+	}
+
+	var prohibited map[QName]bool
+	for _, attr := range own {
+		if attr.Use == ProhibitedUse {
+			if ct.DerivedBy == DerivationRestriction {
+				if prohibited == nil {
+					prohibited = make(map[QName]bool)
+				}
+				prohibited[attr.Name] = true
+			}
+			continue
+		}
+		s.resolveAttributeRef(attr)
+		s.resolveAttributeType(attr)
+		if i, ok := index[attr.Name]; ok {
+			uses[i] = attr
+			continue
+		}
+		index[attr.Name] = len(uses)
+		uses = append(uses, attr)
+	}
+	if prohibited != nil {
+		kept := uses[:0:0]
+		for _, attr := range uses {
+			if !prohibited[attr.Name] {
+				kept = append(kept, attr)
+			}
+		}
+		uses = kept
+	}
+
+	if base != nil && ct.DerivedBy == DerivationExtension {
+		wildcard = s.wildcardUnion(base.attributeWildcard, wildcard)
+	}
+
+	ct.attributeUses = uses
+	ct.attributeWildcard = wildcard
+	ct.attributesCompiled = true
+}
+
+// declaredAttributeUses returns the attribute uses a complex type declares
+// itself, including prohibited ones, and its complete wildcard: the
+// intersection of its own wildcard with those of its attribute groups. The
+// default attribute group of its schema document counts as one of them.
+func (s *Schema) declaredAttributeUses(ct *ComplexType) ([]*AttributeDecl, *AnyAttribute) {
+	attrs, groups, wildcard := ct.Attributes, ct.AttributeGroup, ct.AnyAttribute
+
+	var ext *Extension
+	var restriction *Restriction
+	switch content := declaredContent(ct).(type) {
+	case *SimpleContent:
+		ext, restriction = content.Extension, content.Restriction
+	case *ComplexContent:
+		ext, restriction = content.Extension, content.Restriction
+	}
+	switch {
+	case ext != nil:
+		attrs, groups, wildcard = ext.Attributes, ext.AttributeGroup, ext.AnyAttribute
+	case restriction != nil:
+		attrs, groups, wildcard = restriction.Attributes, restriction.AttributeGroup, restriction.AnyAttribute
+	}
+
+	uses := append([]*AttributeDecl(nil), attrs...)
+	groupAttrs, wildcards := s.attributeGroupContents(ct.withDefaultAttributes(groups), make(map[QName]bool))
+	uses = append(uses, groupAttrs...)
+	if wildcard != nil {
+		wildcards = append([]*AnyAttribute{wildcard}, wildcards...)
+	}
+
+	var complete *AnyAttribute
+	for i, w := range wildcards {
+		if i == 0 {
+			complete = w
+		} else if complete != nil {
+			complete = s.wildcardIntersection(complete, w)
+		}
+	}
+	return uses, complete
+}
+
+// withDefaultAttributes adds the default attribute group of a complex
+// type's schema document to the attribute groups it refers to, unless the
+// type opts out with defaultAttributesApply="false"
+func (ct *ComplexType) withDefaultAttributes(groups []QName) []QName {
+	if !ct.DefaultAttributesApply || ct.defaultAttributes == (QName{}) || slices.Contains(groups, ct.defaultAttributes) {
+		return groups
+	}
+	return append(groups[:len(groups):len(groups)], ct.defaultAttributes)
+}
+
+// attributeGroupContents returns the attributes and wildcards of attribute
+// groups, following nested group references
+func (s *Schema) attributeGroupContents(refs []QName, visited map[QName]bool) ([]*AttributeDecl, []*AnyAttribute) {
+	var attrs []*AttributeDecl
+	var wildcards []*AnyAttribute
+	for _, ref := range refs {
+		group, ok := s.AttributeGroups[ref]
+		if !ok || visited[ref] {
+			continue
+		}
+		visited[ref] = true
+
+		attrs = append(attrs, group.Attributes...)
+		if group.AnyAttribute != nil {
+			wildcards = append(wildcards, group.AnyAttribute)
+		}
+		nestedAttrs, nestedWildcards := s.attributeGroupContents(group.AttributeGroup, visited)
+		attrs = append(attrs, nestedAttrs...)
+		wildcards = append(wildcards, nestedWildcards...)
+	}
+	return attrs, wildcards
+}
+
+// resolveAttributeRef gives an attribute use that refers to a global
+// declaration the type of that declaration, and its value constraint when
+// the use has none of its own
+func (s *Schema) resolveAttributeRef(attr *AttributeDecl) {
+	if attr.Ref == (QName{}) || attr.Type != nil {
+		return
+	}
+	decl := s.lookupAttributeLocked(attr.Ref)
+	if decl == nil {
+		return
+	}
+	s.resolveAttributeType(decl)
+	attr.Type = decl.Type
+	if attr.Default == "" && attr.Fixed == "" {
+		attr.Default, attr.Fixed = decl.Default, decl.Fixed
+	}
+}
+
+// lookupAttributeLocked finds a global attribute declaration by name in the
+// schema or its imports. The caller must hold the schema's read lock.
+func (s *Schema) lookupAttributeLocked(name QName) *AttributeDecl {
+	if decl, ok := s.AttributeDecls[name]; ok {
+		return decl
+	}
+	for _, imported := range s.ImportedSchemas {
+		imported.mu.RLock()
+		decl, ok := imported.AttributeDecls[name]
+		imported.mu.RUnlock()
+		if ok {
+			return decl
+		}
+	}
+	return nil
+}
+
+// resolveAttributeType replaces a placeholder attribute type with the
+// definition it names
+func (s *Schema) resolveAttributeType(attr *AttributeDecl) {
+	if st, ok := attr.Type.(*SimpleType); ok && st.Restriction == nil && st.List == nil && st.Union == nil {
+		if actualType, exists := s.TypeDefs[st.QName]; exists {
+			attr.Type = actualType
+		}
+	}
+}
+
+// wildcardUnion returns a wildcard allowing the namespaces of either
+// wildcard, with the process contents of the second
+func (s *Schema) wildcardUnion(a, b *AnyAttribute) *AnyAttribute {
+	if a == nil {
+		return b
+	}
+	if b == nil {
+		return a
+	}
+
+	ac, bc := ParseNamespaceConstraint(a.Namespace), ParseNamespaceConstraint(b.Namespace)
+	union := &AnyAttribute{ProcessContents: b.ProcessContents}
+	switch {
+	case ac.Mode == "##any" || bc.Mode == "##any":
+		union.Namespace = "##any"
+	case ac.Mode == "##other" && bc.Mode == "##other":
+		union.Namespace = "##other"
+	case ac.Mode == "##other" || bc.Mode == "##other":
+		// Adding the target namespace back to ##other allows everything
+		union.Namespace = "##other"
+		for _, ns := range append(s.wildcardNamespaces(ac), s.wildcardNamespaces(bc)...) {
+			if ns == s.TargetNamespace {
+				union.Namespace = "##any"
+			}
+		}
+	default:
+		union.Namespace = s.formatWildcardNamespaces(append(s.wildcardNamespaces(ac), s.wildcardNamespaces(bc)...))
+	}
+	return union
+}
+
+// wildcardIntersection returns a wildcard allowing the namespaces both
+// wildcards allow, with the process contents of the first, or nil when
+// they have no namespace in common
+func (s *Schema) wildcardIntersection(a, b *AnyAttribute) *AnyAttribute {
+	ac, bc := ParseNamespaceConstraint(a.Namespace), ParseNamespaceConstraint(b.Namespace)
+	intersection := &AnyAttribute{ProcessContents: a.ProcessContents}
+	switch {
+	case ac.Mode == "##any":
+		intersection.Namespace = b.Namespace
+	case bc.Mode == "##any":
+		intersection.Namespace = a.Namespace
+	case ac.Mode == "##other" && bc.Mode == "##other":
+		intersection.Namespace = "##other"
+	default:
+		var namespaces []string
+		for _, ns := range s.wildcardNamespaces(ac) {
+			if bc.Matches(ns, s.TargetNamespace) {
+				namespaces = append(namespaces, ns)
+			}
+		}
+		if ac.Mode == "##other" {
+			for _, ns := range s.wildcardNamespaces(bc) {
+				if ac.Matches(ns, s.TargetNamespace) {
+					namespaces = append(namespaces, ns)
+				}
+			}
+		}
+		if len(namespaces) == 0 {
+			return nil
+		}
+		intersection.Namespace = s.formatWildcardNamespaces(namespaces)
+	}
+	return intersection
+}
+
+// wildcardNamespaces lists the namespaces of an enumerated wildcard, with
+// "" for no namespace
+func (s *Schema) wildcardNamespaces(c *WildcardNamespaceConstraint) []string {
+	switch c.Mode {
+	case "##targetNamespace":
+		return []string{s.TargetNamespace}
+	case "##local":
+		return []string{""}
+	case "list":
+		var namespaces []string
+		for _, ns := range c.Namespaces {
+			switch ns {
+			case "##targetNamespace":
+				namespaces = append(namespaces, s.TargetNamespace)
+			case "##local":
+				namespaces = append(namespaces, "")
+			default:
+				namespaces = append(namespaces, ns)
+			}
+		}
+		return namespaces
+	}
+	return nil
+}
+
+// formatWildcardNamespaces writes namespaces back as a namespace attribute
+// value
+func (s *Schema) formatWildcardNamespaces(namespaces []string) string {
+	seen := make(map[string]bool)
+	var tokens []string
+	for _, ns := range namespaces {
+		if seen[ns] {
+			continue
+		}
+		seen[ns] = true
+		if ns == "" {
+			tokens = append(tokens, "##local")
+		} else {
+			tokens = append(tokens, ns)
+		}
+	}
+	return strings.Join(tokens, " ")
+}
@@ -0,0 +1,339 @@
+package xsd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/agentflare-ai/go-xmldom"
+)
+
+const attributeUsesSchema = `<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"
+           xmlns:t="http://example.com/attrs" targetNamespace="http://example.com/attrs">
+	<xs:attributeGroup name="audit">
+		<xs:attribute name="created" type="xs:date"/>
+		<xs:attributeGroup ref="t:owner"/>
+	</xs:attributeGroup>
+	<xs:attributeGroup name="owner">
+		<xs:attribute name="owner" type="xs:string"/>
+		<xs:anyAttribute namespace="http://example.com/ext http://example.com/other"/>
+	</xs:attributeGroup>
+
+	<xs:simpleType name="size">
+		<xs:restriction base="xs:string"/>
+	</xs:simpleType>
+	<xs:simpleType name="smallSize">
+		<xs:restriction base="t:size">
+			<xs:enumeration value="s"/>
+			<xs:enumeration value="m"/>
+		</xs:restriction>
+	</xs:simpleType>
+	<xs:attribute name="grade" type="t:smallSize" default="m"/>
+
+	<xs:complexType name="base">
+		<xs:sequence/>
+		<xs:attribute name="id" type="xs:string" use="required"/>
+		<xs:attribute name="size" type="t:size"/>
+		<xs:attribute name="note" type="xs:string"/>
+		<xs:anyAttribute namespace="http://example.com/ext" processContents="skip"/>
+	</xs:complexType>
+	<xs:complexType name="middle">
+		<xs:complexContent>
+			<xs:extension base="t:base">
+				<xs:attribute name="kind" type="xs:string"/>
+				<xs:attribute ref="t:grade"/>
+				<xs:attributeGroup ref="t:audit"/>
+			</xs:extension>
+		</xs:complexContent>
+	</xs:complexType>
+	<xs:complexType name="leaf">
+		<xs:complexContent>
+			<xs:restriction base="t:middle">
+				<xs:attribute name="id" type="xs:string" use="required"/>
+				<xs:attribute name="size" type="t:smallSize"/>
+				<xs:attribute name="note" use="prohibited"/>
+				<xs:anyAttribute namespace="http://example.com/ext"/>
+			</xs:restriction>
+		</xs:complexContent>
+	</xs:complexType>
+
+	<xs:element name="middle" type="t:middle"/>
+	<xs:element name="leaf" type="t:leaf"/>
+</xs:schema>`
+
+func TestAttributeUses(t *testing.T) {
+	schema, err := parseTestSchema(t, attributeUsesSchema)
+	if err != nil {
+		t.Fatalf("Failed to parse schema: %v", err)
+	}
+
+	names := func(uses []*AttributeDecl) string {
+		var names []string
+		for _, attr := range uses {
+			names = append(names, attr.Name.Local)
+		}
+		return strings.Join(names, " ")
+	}
+
+	middle := schema.TypeDefs[QName{Namespace: "http://example.com/attrs", Local: "middle"}].(*ComplexType)
+	if got := names(schema.AttributeUses(middle)); got != "id size note kind grade created owner" {
+		t.Errorf("Expected inherited, own and group attributes on middle, got %q", got)
+	}
+	// Extension unions the base wildcard with the group wildcard
+	if w := schema.AttributeWildcard(middle); w == nil || w.Namespace != "http://example.com/ext http://example.com/other" {
+		t.Errorf("Expected the union of base and group wildcards on middle, got %+v", w)
+	}
+
+	leaf := schema.TypeDefs[QName{Namespace: "http://example.com/attrs", Local: "leaf"}].(*ComplexType)
+	if got := names(schema.AttributeUses(leaf)); got != "id size kind grade created owner" {
+		t.Errorf("Expected the prohibited attribute to be removed from leaf, got %q", got)
+	}
+	for _, attr := range schema.AttributeUses(leaf) {
+		if attr.Name.Local == "size" && attr.Type.Name().Local != "smallSize" {
+			t.Errorf("Expected the restriction to override the type of size, got %s", attr.Type.Name().Local)
+		}
+	}
+	// A reference takes the type and default of the global declaration
+	for _, attr := range schema.AttributeUses(leaf) {
+		if attr.Name.Local == "grade" && (attr.Name.Namespace != "http://example.com/attrs" ||
+			attr.Type == nil || attr.Type.Name().Local != "smallSize" || attr.Default != "m") {
+			t.Errorf("Expected grade to resolve to the global declaration, got %+v", attr)
+		}
+	}
+	if w := schema.AttributeWildcard(leaf); w == nil || w.Namespace != "http://example.com/ext" {
+		t.Errorf("Expected the restriction's own wildcard on leaf, got %+v", w)
+	}
+}
+
+func TestInheritedAttributeValidation(t *testing.T) {
+	schema, err := parseTestSchema(t, attributeUsesSchema)
+	if err != nil {
+		t.Fatalf("Failed to parse schema: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		instance string
+		codes    []string
+	}{
+		{
+			name:     "inherited and group attributes",
+			instance: `<middle id="m" size="large" kind="k" created="2024-01-01" owner="o" o:x="1"/>`,
+		},
+		{
+			name:     "referenced global attribute",
+			instance: `<middle id="m" t:grade="s"/>`,
+		},
+		{
+			name:     "type of a referenced global attribute",
+			instance: `<middle id="m" t:grade="xl"/>`,
+			codes:    []string{"cvc-datatype-valid.1.2.1"},
+		},
+		{
+			name:     "required attribute from the base",
+			instance: `<middle kind="k"/>`,
+			codes:    []string{"cvc-complex-type.4"},
+		},
+		{
+			name:     "prohibited attribute",
+			instance: `<leaf id="l" note="n"/>`,
+			codes:    []string{"cvc-wildcard-attribute.2"},
+		},
+		{
+			name:     "overridden attribute type",
+			instance: `<leaf id="l" size="large"/>`,
+			codes:    []string{"cvc-datatype-valid.1.2.1"},
+		},
+		{
+			name:     "wildcard narrowed by the restriction",
+			instance: `<leaf id="l" e:x="1" o:y="1"/>`,
+			codes:    []string{"cvc-wildcard-attribute.2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := strings.Replace(tt.instance, " ",
+				` xmlns="http://example.com/attrs" xmlns:t="http://example.com/attrs" xmlns:e="http://example.com/ext" xmlns:o="http://example.com/other" `, 1)
+			doc, err := xmldom.Decode(strings.NewReader(root))
+			if err != nil {
+				t.Fatalf("Failed to parse instance: %v", err)
+			}
+
+			var codes []string
+			for _, v := range NewValidator(schema).Validate(doc) {
+				codes = append(codes, v.Code)
+			}
+			if strings.Join(codes, " ") != strings.Join(tt.codes, " ") {
+				t.Errorf("Expected violations %v, got %v", tt.codes, codes)
+			}
+		})
+	}
+}
+
+func TestDefaultAttributes(t *testing.T) {
+	schema, err := parseTestSchema11(t, `<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"
+           xmlns:t="http://example.com/attrs" targetNamespace="http://example.com/attrs"
+           defaultAttributes="t:common">
+	<xs:attributeGroup name="common">
+		<xs:attribute name="lang" type="xs:language"/>
+		<xs:attribute name="trace" type="xs:string" use="required"/>
+	</xs:attributeGroup>
+	<xs:complexType name="item">
+		<xs:attribute name="id" type="xs:string"/>
+	</xs:complexType>
+	<xs:complexType name="plain" defaultAttributesApply="false">
+		<xs:attribute name="id" type="xs:string"/>
+	</xs:complexType>
+	<xs:complexType name="terse" defaultAttributesApply="0">
+		<xs:attribute name="id" type="xs:string"/>
+	</xs:complexType>
+	<xs:complexType name="special">
+		<xs:complexContent>
+			<xs:extension base="t:plain">
+				<xs:attribute name="extra" type="xs:string"/>
+			</xs:extension>
+		</xs:complexContent>
+	</xs:complexType>
+	<xs:element name="item" type="t:item"/>
+	<xs:element name="plain" type="t:plain"/>
+	<xs:element name="terse" type="t:terse"/>
+	<xs:element name="special" type="t:special"/>
+</xs:schema>`)
+	if err != nil {
+		t.Fatalf("Failed to parse schema: %v", err)
+	}
+
+	item := schema.TypeDefs[QName{Namespace: "http://example.com/attrs", Local: "item"}].(*ComplexType)
+	if attrs := schema.ResolveAttributeGroups(item); len(attrs) != 2 || attrs[0].Name.Local != "lang" || attrs[1].Name.Local != "trace" {
+		t.Errorf("Expected the default attribute group's attributes on item, got %v", attrs)
+	}
+	plain := schema.TypeDefs[QName{Namespace: "http://example.com/attrs", Local: "plain"}].(*ComplexType)
+	if plain.DefaultAttributesApply {
+		t.Error("Expected defaultAttributesApply=\"false\" to opt plain out")
+	}
+	if attrs := schema.ResolveAttributeGroups(plain); len(attrs) != 0 {
+		t.Errorf("Expected no group attributes on plain, got %v", attrs)
+	}
+
+	tests := []struct {
+		instance string
+		codes    []string
+	}{
+		{instance: `<item xmlns="http://example.com/attrs" id="a" trace="t1" lang="en"/>`},
+		{instance: `<item xmlns="http://example.com/attrs" id="a"/>`, codes: []string{"cvc-complex-type.4"}},
+		{instance: `<plain xmlns="http://example.com/attrs" id="a"/>`},
+		{instance: `<plain xmlns="http://example.com/attrs" id="a" trace="t1"/>`, codes: []string{"cvc-complex-type.3.2.2"}},
+		{instance: `<terse xmlns="http://example.com/attrs" id="a"/>`},
+		{instance: `<special xmlns="http://example.com/attrs" id="a" extra="x"/>`, codes: []string{"cvc-complex-type.4"}},
+		{instance: `<special xmlns="http://example.com/attrs" id="a" extra="x" trace="t1"/>`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.instance, func(t *testing.T) {
+			doc, err := xmldom.Decode(strings.NewReader(tt.instance))
+			if err != nil {
+				t.Fatalf("Failed to parse instance: %v", err)
+			}
+
+			var codes []string
+			for _, v := range NewValidator(schema).Validate(doc) {
+				codes = append(codes, v.Code)
+			}
+			if strings.Join(codes, " ") != strings.Join(tt.codes, " ") {
+				t.Errorf("Expected violations %v, got %v", tt.codes, codes)
+			}
+		})
+	}
+}
+
+func TestDefaultAttributesInclude(t *testing.T) {
+	dir := t.TempDir()
+	main := `<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"
+           xmlns:t="http://example.com/attrs" targetNamespace="http://example.com/attrs"
+           defaultAttributes="t:common">
+	<xs:include schemaLocation="included.xsd"/>
+	<xs:attributeGroup name="common">
+		<xs:attribute name="trace" type="xs:string" use="required"/>
+	</xs:attributeGroup>
+	<xs:complexType name="local"/>
+	<xs:element name="local" type="t:local"/>
+	<xs:element name="shared" type="t:shared"/>
+</xs:schema>`
+	included := `<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"
+           xmlns:t="http://example.com/attrs" targetNamespace="http://example.com/attrs">
+	<xs:complexType name="shared"/>
+</xs:schema>`
+	if err := os.WriteFile(filepath.Join(dir, "main.xsd"), []byte(main), 0644); err != nil {
+		t.Fatalf("Failed to write main schema: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "included.xsd"), []byte(included), 0644); err != nil {
+		t.Fatalf("Failed to write included schema: %v", err)
+	}
+
+	loader, err := NewSchemaLoader(SchemaLoaderConfig{BaseDir: dir, Version: Version11})
+	if err != nil {
+		t.Fatalf("Failed to create loader: %v", err)
+	}
+	schema, err := loader.LoadSchemaWithImports(filepath.Join(dir, "main.xsd"))
+	if err != nil {
+		t.Fatalf("Failed to load schema: %v", err)
+	}
+
+	local := schema.TypeDefs[QName{Namespace: "http://example.com/attrs", Local: "local"}].(*ComplexType)
+	if uses := schema.AttributeUses(local); len(uses) != 1 || uses[0].Name.Local != "trace" {
+		t.Errorf("Expected the default attribute group on the including document's type, got %v", uses)
+	}
+	shared := schema.TypeDefs[QName{Namespace: "http://example.com/attrs", Local: "shared"}].(*ComplexType)
+	if uses := schema.AttributeUses(shared); len(uses) != 0 {
+		t.Errorf("Expected the including document's default not to apply to included types, got %v", uses)
+	}
+
+	doc, err := xmldom.Decode(strings.NewReader(`<shared xmlns="http://example.com/attrs"/>`))
+	if err != nil {
+		t.Fatalf("Failed to parse instance: %v", err)
+	}
+	if violations := NewValidator(schema).Validate(doc); len(violations) != 0 {
+		t.Errorf("Expected no violations for an included type, got %v", violations)
+	}
+}
+
+func TestDefaultAttributesXSD10(t *testing.T) {
+	// defaultAttributes is XSD 1.1; a 1.0 schema ignores it
+	schema, err := parseTestSchema(t, `<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"
+           xmlns:t="http://example.com/attrs" targetNamespace="http://example.com/attrs"
+           defaultAttributes="t:common">
+	<xs:attributeGroup name="common">
+		<xs:attribute name="trace" type="xs:string" use="required"/>
+	</xs:attributeGroup>
+	<xs:complexType name="item">
+		<xs:attribute name="id" type="xs:string"/>
+	</xs:complexType>
+	<xs:element name="item" type="t:item"/>
+</xs:schema>`)
+	if err != nil {
+		t.Fatalf("Failed to parse schema: %v", err)
+	}
+	if schema.DefaultAttributes != (QName{}) {
+		t.Errorf("Expected no default attribute group under XSD 1.0, got %v", schema.DefaultAttributes)
+	}
+
+	item := schema.TypeDefs[QName{Namespace: "http://example.com/attrs", Local: "item"}].(*ComplexType)
+	if uses := schema.AttributeUses(item); len(uses) != 1 || uses[0].Name.Local != "id" {
+		t.Errorf("Expected only the declared attribute on item, got %v", uses)
+	}
+
+	doc, err := xmldom.Decode(strings.NewReader(`<item xmlns="http://example.com/attrs" id="a"/>`))
+	if err != nil {
+		t.Fatalf("Failed to parse instance: %v", err)
+	}
+	if violations := NewValidator(schema).Validate(doc); len(violations) != 0 {
+		t.Errorf("Expected the default attribute group not to apply under XSD 1.0, got %v", violations)
+	}
+}
@@ -1,7 +1,6 @@
 package xsd
 
 import (
-	"encoding/base64"
 	"encoding/hex"
 	"fmt"
 	"math"
@@ -76,6 +75,61 @@ func registerBuiltinTypes() {
 	builtinTypes["unsignedShort"] = &BuiltinType{"unsignedShort", validateUnsignedShort}
 	builtinTypes["unsignedByte"] = &BuiltinType{"unsignedByte", validateUnsignedByte}
 	builtinTypes["positiveInteger"] = &BuiltinType{"positiveInteger", validatePositiveInteger}
+
+	// XSD 1.1 types
+	builtinTypes["anyAtomicType"] = &BuiltinType{"anyAtomicType", validateString}
+	builtinTypes["dateTimeStamp"] = &BuiltinType{"dateTimeStamp", validateDateTimeStamp}
+	builtinTypes["dayTimeDuration"] = &BuiltinType{"dayTimeDuration", validateDayTimeDuration}
+	builtinTypes["yearMonthDuration"] = &BuiltinType{"yearMonthDuration", validateYearMonthDuration}
+	builtinTypes["error"] = &BuiltinType{"error", validateError}
+}
+
+// builtinBaseTypes maps each built-in type to the type it is derived from
+// by restriction. xs:anyType has no base.
+var builtinBaseTypes = map[string]string{
+	"anyType":       "",
+	"anySimpleType": "anyType",
+	"anyAtomicType": "anySimpleType",
+	"error":         "anySimpleType",
+
+	"string": "anyAtomicType", "boolean": "anyAtomicType", "decimal": "anyAtomicType",
+	"float": "anyAtomicType", "double": "anyAtomicType", "duration": "anyAtomicType",
+	"dateTime": "anyAtomicType", "time": "anyAtomicType", "date": "anyAtomicType",
+	"gYearMonth": "anyAtomicType", "gYear": "anyAtomicType", "gMonthDay": "anyAtomicType",
+	"gDay": "anyAtomicType", "gMonth": "anyAtomicType", "hexBinary": "anyAtomicType",
+	"base64Binary": "anyAtomicType", "anyURI": "anyAtomicType", "QName": "anyAtomicType",
+	"NOTATION": "anyAtomicType",
+
+	"dateTimeStamp":     "dateTime",
+	"dayTimeDuration":   "duration",
+	"yearMonthDuration": "duration",
+
+	"normalizedString": "string",
+	"token":            "normalizedString",
+	"language":         "token",
+	"Name":             "token",
+	"NMTOKEN":          "token",
+	"NCName":           "Name",
+	"ID":               "NCName",
+	"IDREF":            "NCName",
+	"ENTITY":           "NCName",
+	"IDREFS":           "anySimpleType",
+	"ENTITIES":         "anySimpleType",
+	"NMTOKENS":         "anySimpleType",
+
+	"integer":            "decimal",
+	"nonPositiveInteger": "integer",
+	"negativeInteger":    "nonPositiveInteger",
+	"long":               "integer",
+	"int":                "long",
+	"short":              "int",
+	"byte":               "short",
+	"nonNegativeInteger": "integer",
+	"unsignedLong":       "nonNegativeInteger",
+	"unsignedInt":        "unsignedLong",
+	"unsignedShort":      "unsignedInt",
+	"unsignedByte":       "unsignedShort",
+	"positiveInteger":    "nonNegativeInteger",
 }
 
 // GetBuiltinType returns a built-in type validator
@@ -191,6 +245,38 @@ func validateDateTime(value string) error {
 	return fmt.Errorf("invalid dateTime value: %s", value)
 }
 
+func validateDateTimeStamp(value string) error {
+	if err := validateDateTime(value); err != nil {
+		return fmt.Errorf("invalid dateTimeStamp value: %s", value)
+	}
+	if !timezoneSuffix.MatchString(value) {
+		return fmt.Errorf("dateTimeStamp value must have a timezone: %s", value)
+	}
+	return nil
+}
+
+func validateDayTimeDuration(value string) error {
+	if err := validateDuration(value); err != nil {
+		return fmt.Errorf("invalid dayTimeDuration value: %s", value)
+	}
+	// No year or month components
+	if strings.ContainsAny(strings.SplitN(value, "T", 2)[0], "YM") {
+		return fmt.Errorf("dayTimeDuration value must not have years or months: %s", value)
+	}
+	return nil
+}
+
+func validateYearMonthDuration(value string) error {
+	if err := validateDuration(value); err != nil {
+		return fmt.Errorf("invalid yearMonthDuration value: %s", value)
+	}
+	// No day or time components
+	if strings.ContainsAny(value, "DT") {
+		return fmt.Errorf("yearMonthDuration value must not have days or a time: %s", value)
+	}
+	return nil
+}
+
 func validateTime(value string) error {
 	// Time pattern: hh:mm:ss[.sss][Z|(+|-)hh:mm]
 	pattern := regexp.MustCompile(`^\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})?$`)
@@ -365,11 +451,8 @@ func validateHexBinary(value string) error {
 
 func validateBase64Binary(value string) error {
 	// Try decoding
-	_, err := base64.StdEncoding.DecodeString(value)
-	if err != nil {
-		return fmt.Errorf("invalid base64Binary value: %s", value)
-	}
-	return nil
+	_, err := decodeBase64Binary(value)
+	return err
 }
 
 func validateAnyURI(value string) error {
@@ -399,6 +482,11 @@ func validateNOTATION(value string) error {
 	return validateQName(value)
 }
 
+func validateError(value string) error {
+	// xs:error has an empty value space
+	return fmt.Errorf("no value is valid for xs:error: %s", value)
+}
+
 // String derived type validators
 
 func validateNormalizedString(value string) error {
@@ -0,0 +1,133 @@
+package xsd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/agentflare-ai/go-xmldom"
+)
+
+func TestXSD11BuiltinTypes(t *testing.T) {
+	tests := []struct {
+		typ   string
+		value string
+		valid bool
+	}{
+		{"anyAtomicType", "anything", true},
+		{"dateTimeStamp", "2024-01-01T12:00:00Z", true},
+		{"dateTimeStamp", "2024-01-01T12:00:00.5+05:30", true},
+		{"dateTimeStamp", "2024-01-01T12:00:00", false},
+		{"dateTimeStamp", "2024-01-01Z", false},
+		{"dayTimeDuration", "P3DT4H", true},
+		{"dayTimeDuration", "-PT1.5S", true},
+		{"dayTimeDuration", "P1M", false},
+		{"dayTimeDuration", "P1Y2D", false},
+		{"yearMonthDuration", "P1Y6M", true},
+		{"yearMonthDuration", "-P18M", true},
+		{"yearMonthDuration", "P1D", false},
+		{"yearMonthDuration", "P1YT1H", false},
+		{"error", "", false},
+		{"error", "x", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.typ+" "+tt.value, func(t *testing.T) {
+			validator := GetBuiltinTypeValidator(tt.typ)
+			if validator == nil {
+				t.Fatalf("Expected xs:%s to be a built-in type", tt.typ)
+			}
+			if err := validator(tt.value); (err == nil) != tt.valid {
+				t.Errorf("Validating %q against xs:%s: got %v, want valid %v", tt.value, tt.typ, err, tt.valid)
+			}
+		})
+	}
+
+	for name, ancestor := range map[string]string{
+		"string":            "anyAtomicType",
+		"dateTimeStamp":     "dateTime",
+		"dayTimeDuration":   "duration",
+		"yearMonthDuration": "duration",
+		"error":             "anySimpleType",
+		"anyAtomicType":     "anySimpleType",
+	} {
+		if !builtinDerivesFrom(name, ancestor) {
+			t.Errorf("Expected xs:%s to derive from xs:%s", name, ancestor)
+		}
+	}
+	for name, primitive := range map[string]string{
+		"string": "string", "int": "decimal", "dateTimeStamp": "dateTime",
+		"dayTimeDuration": "duration", "anyAtomicType": "", "error": "",
+	} {
+		if got := primitiveType(name); got != primitive {
+			t.Errorf("primitiveType(%s) = %q, want %q", name, got, primitive)
+		}
+	}
+}
+
+func TestExplicitTimezone(t *testing.T) {
+	schema, err := parseTestSchema(t, `<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+	<xs:element name="local">
+		<xs:simpleType>
+			<xs:restriction base="xs:date">
+				<xs:explicitTimezone value="prohibited"/>
+			</xs:restriction>
+		</xs:simpleType>
+	</xs:element>
+	<xs:element name="stamp">
+		<xs:simpleType>
+			<xs:restriction base="xs:dateTimeStamp">
+				<xs:minInclusive value="2024-01-01T00:00:00Z"/>
+			</xs:restriction>
+		</xs:simpleType>
+	</xs:element>
+	<xs:element name="shift">
+		<xs:simpleType>
+			<xs:restriction base="xs:dayTimeDuration">
+				<xs:maxInclusive value="PT8H"/>
+			</xs:restriction>
+		</xs:simpleType>
+	</xs:element>
+	<xs:element name="term">
+		<xs:simpleType>
+			<xs:restriction base="xs:yearMonthDuration">
+				<xs:minExclusive value="P1Y"/>
+			</xs:restriction>
+		</xs:simpleType>
+	</xs:element>
+</xs:schema>`)
+	if err != nil {
+		t.Fatalf("Failed to parse schema: %v", err)
+	}
+
+	tests := []struct {
+		instance string
+		codes    []string
+	}{
+		{instance: `<local>2024-01-01</local>`},
+		{instance: `<local>2024-01-01Z</local>`, codes: []string{"cvc-datatype-valid.1", "cvc-facet-valid"}},
+		{instance: `<stamp>2024-01-01T01:00:00+01:00</stamp>`},
+		{instance: `<stamp>2023-12-31T23:00:00Z</stamp>`, codes: []string{"cvc-datatype-valid.1", "cvc-facet-valid"}},
+		{instance: `<shift>PT480M</shift>`},
+		{instance: `<shift>P1D</shift>`, codes: []string{"cvc-datatype-valid.1", "cvc-facet-valid"}},
+		{instance: `<term>P13M</term>`},
+		{instance: `<term>P12M</term>`, codes: []string{"cvc-datatype-valid.1", "cvc-facet-valid"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.instance, func(t *testing.T) {
+			doc, err := xmldom.Decode(strings.NewReader(tt.instance))
+			if err != nil {
+				t.Fatalf("Failed to parse instance: %v", err)
+			}
+
+			var codes []string
+			for _, v := range NewValidator(schema).Validate(doc) {
+				codes = append(codes, v.Code)
+			}
+			if strings.Join(codes, " ") != strings.Join(tt.codes, " ") {
+				t.Errorf("Expected violations %v, got %v", tt.codes, codes)
+			}
+		})
+	}
+}
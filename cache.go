@@ -1,38 +1,119 @@
 package xsd
 
 import (
+	"container/list"
+	"crypto/sha256"
 	"fmt"
-	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/agentflare-ai/go-xmldom"
 )
 
-// SchemaCache manages cached XSD schemas using sync.OnceValue per project rules
+// Default limits applied by NewSchemaCache
+const (
+	DefaultCacheMaxEntries    = 256
+	DefaultCacheMaxBytes      = 256 << 20 // 256 MiB
+	DefaultCacheNegativeTTL   = 5 * time.Second
+	DefaultCacheCheckInterval = time.Second
+)
+
+// SchemaCacheConfig configures the bounds and expiry policy of a SchemaCache
+type SchemaCacheConfig struct {
+	// Base path for resolving relative schema locations
+	BasePath string
+
+	// Maximum number of cached schemas (0 means unlimited)
+	MaxEntries int
+
+	// Maximum estimated memory of all cached schemas in bytes (0 means unlimited)
+	MaxBytes int64
+
+	// Time after which a successfully loaded schema is reloaded (0 means never)
+	TTL time.Duration
+
+	// Time for which a failed load is remembered before it is retried
+	// (0 means failures are not cached)
+	NegativeTTL time.Duration
+
+	// Minimum time between checks of the contributing files for changes
+	// (0 checks on every lookup, negative disables file checks)
+	CheckInterval time.Duration
+}
+
+// CacheStats holds counters describing the behavior of a SchemaCache
+type CacheStats struct {
+	Hits          uint64 // Lookups served from the cache
+	Misses        uint64 // Lookups that had to load the schema
+	Evictions     uint64 // Entries dropped to honor MaxEntries/MaxBytes
+	Expirations   uint64 // Entries dropped because their TTL or NegativeTTL passed
+	Invalidations uint64 // Entries dropped because a contributing file changed
+	Entries       int    // Current number of entries
+	Bytes         int64  // Current estimated memory of all entries
+}
+
+// SchemaCache manages cached XSD schemas. Each entry is loaded once by the
+// first caller that waits on it while concurrent callers for the same location
+// block on that load; settling a loaded entry records its size and enforces the
+// bounds. Entries are kept in least-recently-used order and evicted when the
+// configured entry count or memory bounds are exceeded.
 type SchemaCache struct {
 	mu       sync.RWMutex
-	schemas  map[string]*schemaEntry
+	schemas  map[string]*list.Element // location -> element holding a *schemaEntry
+	lru      *list.List               // front is most recently used
+	bytes    int64
+	stats    CacheStats
+	config   SchemaCacheConfig
 	BasePath string // Base path for resolving relative schema locations
 }
 
 // schemaEntry holds a schema and its loader
 type schemaEntry struct {
-	loader func() (*Schema, error)
-	once   sync.Once
-	schema *Schema
-	err    error
+	key      string
+	loader   func() (*Schema, []fileStamp, error)
+	once     sync.Once
+	schema   *Schema
+	err      error
+	sources  []fileStamp // Files that contributed to the schema
+	size     int64       // Estimated memory footprint
+	loadedAt time.Time
+	checked  time.Time // Last time sources were checked for changes
+}
+
+// fileStamp records the state of a contributing file at load time
+type fileStamp struct {
+	Path    string
+	ModTime time.Time
+	Size    int64
+	Hash    [sha256.Size]byte
 }
 
 // GlobalCache is the singleton schema cache
 var GlobalCache = NewSchemaCache("")
 
-// NewSchemaCache creates a new schema cache
+// NewSchemaCache creates a new schema cache with the default bounds
 func NewSchemaCache(basePath string) *SchemaCache {
+	return NewSchemaCacheWithConfig(SchemaCacheConfig{
+		BasePath:      basePath,
+		MaxEntries:    DefaultCacheMaxEntries,
+		MaxBytes:      DefaultCacheMaxBytes,
+		NegativeTTL:   DefaultCacheNegativeTTL,
+		CheckInterval: DefaultCacheCheckInterval,
+	})
+}
+
+// NewSchemaCacheWithConfig creates a new schema cache with the given configuration
+func NewSchemaCacheWithConfig(config SchemaCacheConfig) *SchemaCache {
 	return &SchemaCache{
-		schemas:  make(map[string]*schemaEntry),
-		BasePath: basePath,
+		schemas:  make(map[string]*list.Element),
+		lru:      list.New(),
+		config:   config,
+		BasePath: config.BasePath,
 	}
 }
 
@@ -43,43 +124,36 @@ func (sc *SchemaCache) SetBasePath(path string) {
 	sc.BasePath = path
 }
 
+// Stats returns a snapshot of the cache statistics
+func (sc *SchemaCache) Stats() CacheStats {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	stats := sc.stats
+	stats.Entries = sc.lru.Len()
+	stats.Bytes = sc.bytes
+	return stats
+}
+
 // Get retrieves a schema from cache or loads it if not present
 func (sc *SchemaCache) Get(location string) (*Schema, error) {
-	// Resolve path
-	resolvedPath := sc.resolvePath(location)
+	return sc.wait(sc.entry(sc.resolvePath(location)))
+}
 
+// entry returns the live entry for a resolved location, adding one that
+// loads the schema from disk when there is none
+func (sc *SchemaCache) entry(key string) *schemaEntry {
 	// Check cache
-	sc.mu.RLock()
-	entry, exists := sc.schemas[resolvedPath]
-	sc.mu.RUnlock()
-
-	if exists {
-		// Use sync.Once to ensure single loading
-		entry.once.Do(func() {
-			if entry.loader != nil {
-				entry.schema, entry.err = entry.loader()
-			}
-		})
-		return entry.schema, entry.err
+	if entry := sc.lookup(key); entry != nil {
+		return entry
 	}
 
-	// Create new entry with loader
-	entry = &schemaEntry{
-		loader: func() (*Schema, error) {
-			return sc.loadSchema(resolvedPath)
+	// Create new entry with loader; a concurrent caller may have won the race
+	return sc.insert(&schemaEntry{
+		key: key,
+		loader: func() (*Schema, []fileStamp, error) {
+			return sc.loadSchema(key)
 		},
-	}
-
-	// Store in cache
-	sc.mu.Lock()
-	sc.schemas[resolvedPath] = entry
-	sc.mu.Unlock()
-
-	// Load and return
-	entry.once.Do(func() {
-		entry.schema, entry.err = entry.loader()
 	})
-	return entry.schema, entry.err
 }
 
 // GetOrLoad gets a schema from cache or loads from provided document
@@ -95,13 +169,8 @@ func (sc *SchemaCache) GetOrLoad(location string, doc xmldom.Document) (*Schema,
 		return nil, err
 	}
 
-	// Cache it
-	sc.mu.Lock()
-	sc.schemas[location] = &schemaEntry{
-		schema: schema,
-		err:    nil,
-	}
-	sc.mu.Unlock()
+	// Cache it, replacing the failed entry left behind by Get
+	sc.store(sc.resolvePath(location), schema, nil)
 
 	return schema, nil
 }
@@ -110,7 +179,9 @@ func (sc *SchemaCache) GetOrLoad(location string, doc xmldom.Document) (*Schema,
 func (sc *SchemaCache) Clear() {
 	sc.mu.Lock()
 	defer sc.mu.Unlock()
-	sc.schemas = make(map[string]*schemaEntry)
+	sc.schemas = make(map[string]*list.Element)
+	sc.lru.Init()
+	sc.bytes = 0
 }
 
 // Remove removes a specific schema from cache
@@ -118,7 +189,155 @@ func (sc *SchemaCache) Remove(location string) {
 	resolvedPath := sc.resolvePath(location)
 	sc.mu.Lock()
 	defer sc.mu.Unlock()
-	delete(sc.schemas, resolvedPath)
+	if elem, ok := sc.schemas[resolvedPath]; ok {
+		sc.removeElement(elem)
+	}
+}
+
+// lookup returns the live entry for a key, dropping it first if it has expired
+// or one of its contributing files has changed
+func (sc *SchemaCache) lookup(key string) *schemaEntry {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	elem, ok := sc.schemas[key]
+	if !ok {
+		sc.stats.Misses++
+		return nil
+	}
+	entry := elem.Value.(*schemaEntry)
+
+	// Entries that are still loading are always shared
+	if !entry.loadedAt.IsZero() {
+		now := time.Now()
+		switch {
+		case entry.err != nil && now.Sub(entry.loadedAt) >= sc.config.NegativeTTL:
+			sc.stats.Expirations++
+			sc.removeElement(elem)
+			sc.stats.Misses++
+			return nil
+		case entry.err == nil && sc.config.TTL > 0 && now.Sub(entry.loadedAt) >= sc.config.TTL:
+			sc.stats.Expirations++
+			sc.removeElement(elem)
+			sc.stats.Misses++
+			return nil
+		case sc.config.CheckInterval >= 0 && now.Sub(entry.checked) >= sc.config.CheckInterval:
+			entry.checked = now
+			if sourcesChanged(entry.sources) {
+				sc.stats.Invalidations++
+				sc.removeElement(elem)
+				sc.stats.Misses++
+				return nil
+			}
+		}
+	}
+
+	sc.stats.Hits++
+	sc.lru.MoveToFront(elem)
+	return entry
+}
+
+// insert adds an entry unless another caller already stored one for the same key,
+// in which case the existing entry is returned
+func (sc *SchemaCache) insert(entry *schemaEntry) *schemaEntry {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	if elem, ok := sc.schemas[entry.key]; ok {
+		return elem.Value.(*schemaEntry)
+	}
+	sc.schemas[entry.key] = sc.lru.PushFront(entry)
+	return entry
+}
+
+// wait runs the entry's loader exactly once and returns its result
+func (sc *SchemaCache) wait(entry *schemaEntry) (*Schema, error) {
+	entry.once.Do(func() {
+		if entry.loader != nil {
+			entry.schema, entry.sources, entry.err = entry.loader()
+		}
+		sc.settle(entry)
+	})
+	return entry.schema, entry.err
+}
+
+// settle records the size of a freshly loaded entry and enforces the cache bounds
+func (sc *SchemaCache) settle(entry *schemaEntry) {
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	entry.loadedAt = time.Now()
+	entry.checked = entry.loadedAt
+	entry.size = estimateEntrySize(entry)
+
+	elem, ok := sc.schemas[entry.key]
+	if !ok || elem.Value.(*schemaEntry) != entry {
+		// Removed or replaced while loading
+		return
+	}
+	sc.bytes += entry.size
+
+	// Failures are only remembered for NegativeTTL
+	if entry.err != nil && sc.config.NegativeTTL <= 0 {
+		sc.removeElement(elem)
+		return
+	}
+
+	sc.evict(elem)
+}
+
+// evict drops least recently used entries until the bounds are satisfied,
+// never evicting keep (the entry that was just loaded)
+func (sc *SchemaCache) evict(keep *list.Element) {
+	for {
+		overEntries := sc.config.MaxEntries > 0 && sc.lru.Len() > sc.config.MaxEntries
+		overBytes := sc.config.MaxBytes > 0 && sc.bytes > sc.config.MaxBytes
+		if !overEntries && !overBytes {
+			return
+		}
+
+		victim := sc.lru.Back()
+		for victim != nil && (victim == keep || victim.Value.(*schemaEntry).loadedAt.IsZero()) {
+			victim = victim.Prev()
+		}
+		if victim == nil {
+			return
+		}
+		sc.removeElement(victim)
+		sc.stats.Evictions++
+	}
+}
+
+// store replaces the entry for a key with an already loaded schema
+func (sc *SchemaCache) store(key string, schema *Schema, sources []fileStamp) {
+	sc.mu.Lock()
+	if elem, ok := sc.schemas[key]; ok {
+		sc.removeElement(elem)
+	}
+	sc.mu.Unlock()
+
+	entry := &schemaEntry{key: key, schema: schema, sources: sources}
+	entry.once.Do(func() {})
+	sc.insert(entry)
+	sc.settle(entry)
+}
+
+// sourceStamps returns a copy of the file stamps recorded for a loaded entry,
+// which stay with the entry even after it leaves the cache
+func (sc *SchemaCache) sourceStamps(entry *schemaEntry) []fileStamp {
+	sc.mu.RLock()
+	defer sc.mu.RUnlock()
+	return slices.Clone(entry.sources)
+}
+
+// removeElement unlinks an entry; must be called with sc.mu held
+func (sc *SchemaCache) removeElement(elem *list.Element) {
+	entry := elem.Value.(*schemaEntry)
+	sc.lru.Remove(elem)
+	delete(sc.schemas, entry.key)
+	if !entry.loadedAt.IsZero() {
+		sc.bytes -= entry.size
+	}
 }
 
 // resolvePath resolves a schema location to an absolute path
@@ -137,41 +356,106 @@ func (sc *SchemaCache) resolvePath(location string) string {
 	return abs
 }
 
-// loadSchema loads a schema from disk
-func (sc *SchemaCache) loadSchema(path string) (*Schema, error) {
-	// Read file
-	data, err := os.ReadFile(path)
-	if err != nil {
-		return nil, fmt.Errorf("failed to read schema file %s: %w", path, err)
+// loadSchema loads a schema and its imports/includes from disk and records
+// the state of every file that contributed to it
+func (sc *SchemaCache) loadSchema(path string) (*Schema, []fileStamp, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, nil, fmt.Errorf("failed to read schema file %s: %w", path, err)
+	}
+
+	loader := NewSchemaLoaderSimple(filepath.Dir(path))
+	schema, err := loader.LoadSchemaWithImports(path)
+
+	// Stamp sources even on failure so a fixed file is picked up before NegativeTTL
+	sources := loader.Sources()
+	if len(sources) == 0 {
+		sources = []string{path}
+	}
+	stamps := make([]fileStamp, 0, len(sources))
+	for _, source := range sources {
+		if stamp, ok := stampFile(source); ok {
+			stamps = append(stamps, stamp)
+		}
 	}
 
-	// Parse XML
-	decoder := xmldom.NewDecoderFromBytes(data)
-	doc, err := decoder.Decode()
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse schema file %s: %w", path, err)
+		return nil, stamps, fmt.Errorf("failed to load XSD schema %s: %w", path, err)
 	}
+	return schema, stamps, nil
+}
 
-	// Parse schema
-	schema, err := Parse(doc)
+// stampFile records the modification time, size and content hash of a local file
+func stampFile(path string) (fileStamp, bool) {
+	info, err := os.Stat(path)
+	if err != nil || info.IsDir() {
+		return fileStamp{}, false
+	}
+	data, err := os.ReadFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse XSD schema %s: %w", path, err)
-	}
-
-	// Handle imports
-	for _, imp := range schema.Imports {
-		if imp.SchemaLocation != "" {
-			// Recursively load imported schemas
-			importPath := sc.resolvePath(imp.SchemaLocation)
-			if _, err := sc.Get(importPath); err != nil {
-				// Log warning but don't fail
-				// Imports might be optional or resolved differently
-				slog.Warn("failed to load imported schema", "location", importPath, "error", err)
-			}
+		return fileStamp{}, false
+	}
+	return fileStamp{
+		Path:    path,
+		ModTime: info.ModTime(),
+		Size:    info.Size(),
+		Hash:    sha256.Sum256(data),
+	}, true
+}
+
+// sourcesChanged reports whether any contributing file was modified or removed
+func sourcesChanged(stamps []fileStamp) bool {
+	return len(changedSources(stamps)) > 0
+}
+
+// changedSources returns the contributing files that were modified or removed.
+// The content hash is only recomputed when the modification time or size differ,
+// so touching a file without changing it only refreshes its stamp.
+func changedSources(stamps []fileStamp) []string {
+	var changed []string
+	for i := range stamps {
+		stamp := &stamps[i]
+		info, err := os.Stat(stamp.Path)
+		if err != nil {
+			changed = append(changed, stamp.Path)
+			continue
 		}
+		if info.ModTime().Equal(stamp.ModTime) && info.Size() == stamp.Size {
+			continue
+		}
+		current, ok := stampFile(stamp.Path)
+		if !ok || current.Hash != stamp.Hash {
+			changed = append(changed, stamp.Path)
+			continue
+		}
+		*stamp = current
+	}
+	return changed
+}
+
+// estimateEntrySize estimates the memory held by a cache entry.
+// The parsed source documents dominate, so the estimate is driven by
+// their size plus a fixed cost per schema component.
+func estimateEntrySize(entry *schemaEntry) int64 {
+	const (
+		entryOverhead     = 512
+		documentFactor    = 8 // DOM nodes are several times larger than the source text
+		componentOverhead = 256
+	)
+
+	size := int64(entryOverhead)
+	for _, source := range entry.sources {
+		size += source.Size * documentFactor
 	}
 
-	return schema, nil
+	if schema := entry.schema; schema != nil {
+		schema.mu.RLock()
+		components := len(schema.ElementDecls) + len(schema.TypeDefs) +
+			len(schema.AttributeGroups) + len(schema.Groups)
+		schema.mu.RUnlock()
+		size += int64(components) * componentOverhead
+	}
+
+	return size
 }
 
 // PreloadCommonTypes preloads commonly used XSD types for performance
@@ -186,6 +470,10 @@ func (sc *SchemaCache) PreloadCommonTypes() {
 	}
 
 	// Create simple type definitions for built-ins
+	builtinSchema := &Schema{
+		TargetNamespace: XSDNamespace,
+		TypeDefs:        make(map[QName]Type, len(commonTypes)),
+	}
 	for _, typeName := range commonTypes {
 		qname := QName{
 			Namespace: XSDNamespace,
@@ -193,37 +481,30 @@ func (sc *SchemaCache) PreloadCommonTypes() {
 		}
 		// These would be properly defined with their restrictions
 		// For now, create placeholder types
-		st := &SimpleType{
+		builtinSchema.TypeDefs[qname] = &SimpleType{
 			QName: qname,
 		}
-
-		// Store in a special built-in schema
-		builtinSchema := &Schema{
-			TargetNamespace: XSDNamespace,
-			TypeDefs:        map[QName]Type{qname: st},
-		}
-
-		sc.mu.Lock()
-		sc.schemas["builtin:"+XSDNamespace] = &schemaEntry{
-			schema: builtinSchema,
-			err:    nil,
-		}
-		sc.mu.Unlock()
 	}
+
+	// Store in a special built-in schema
+	sc.store("builtin:"+XSDNamespace, builtinSchema, nil)
 }
 
 // SchemaRegistry manages multiple schemas for different namespaces
 type SchemaRegistry struct {
 	mu            sync.RWMutex
 	namespaces    map[string]*Schema
+	files         map[string]string // namespace -> resolved schema location
 	defaultSchema *Schema
 	cache         *SchemaCache
+	watcher       *SchemaWatcher
 }
 
 // NewSchemaRegistry creates a new schema registry
 func NewSchemaRegistry() *SchemaRegistry {
 	return &SchemaRegistry{
 		namespaces: make(map[string]*Schema),
+		files:      make(map[string]string),
 		cache:      NewSchemaCache(""),
 	}
 }
@@ -233,18 +514,81 @@ func (sr *SchemaRegistry) Register(namespace string, schema *Schema) {
 	sr.mu.Lock()
 	defer sr.mu.Unlock()
 	sr.namespaces[namespace] = schema
+	delete(sr.files, namespace)
 }
 
 // RegisterFile registers a schema from a file
 func (sr *SchemaRegistry) RegisterFile(namespace, location string) error {
-	schema, err := sr.cache.Get(location)
-	if err != nil {
-		return err
+	sr.mu.RLock()
+	watcher := sr.watcher
+	sr.mu.RUnlock()
+
+	var schema *Schema
+	if watcher != nil {
+		ws, err := watcher.Add(location)
+		if err != nil {
+			return err
+		}
+		schema = ws.Schema()
+	} else {
+		var err error
+		if schema, err = sr.cache.Get(location); err != nil {
+			return err
+		}
 	}
-	sr.Register(namespace, schema)
+
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	sr.namespaces[namespace] = schema
+	sr.files[namespace] = sr.cache.resolvePath(location)
 	return nil
 }
 
+// Watch enables hot reload for every schema registered with RegisterFile,
+// including ones registered later. When a contributing file changes, the
+// schema is rebuilt in the background and swapped in for its namespaces;
+// validations already running finish on the previous version. The returned
+// watcher can be used to subscribe to reload outcomes and must be closed
+// to stop polling.
+func (sr *SchemaRegistry) Watch(interval time.Duration) (*SchemaWatcher, error) {
+	sr.mu.Lock()
+	if sr.watcher != nil {
+		watcher := sr.watcher
+		sr.mu.Unlock()
+		return watcher, nil
+	}
+	watcher := sr.cache.Watch(interval)
+	sr.watcher = watcher
+	locations := make([]string, 0, len(sr.files))
+	for _, location := range sr.files {
+		locations = append(locations, location)
+	}
+	sr.mu.Unlock()
+
+	watcher.Subscribe(sr.applyReload)
+	for _, location := range locations {
+		if _, err := watcher.Add(location); err != nil {
+			return watcher, err
+		}
+	}
+	return watcher, nil
+}
+
+// applyReload swaps a rebuilt schema in for every namespace loaded from its location
+func (sr *SchemaRegistry) applyReload(event ReloadEvent) {
+	if event.Err != nil {
+		return
+	}
+
+	sr.mu.Lock()
+	defer sr.mu.Unlock()
+	for namespace, location := range sr.files {
+		if location == event.Location {
+			sr.namespaces[namespace] = event.Schema
+		}
+	}
+}
+
 // SetDefault sets the default schema for elements without namespaces
 func (sr *SchemaRegistry) SetDefault(schema *Schema) {
 	sr.mu.Lock()
@@ -265,9 +609,21 @@ func (sr *SchemaRegistry) GetForNamespace(namespace string) (*Schema, bool) {
 	return schema, ok
 }
 
-// ValidateWithRegistry validates a document using multiple namespace schemas
+// Validate validates a document using multiple namespace schemas. The
+// document element is validated against the schema for its namespace;
+// every subtree whose namespace differs from the schema governing its
+// parent and has a schema of its own, either registered or imported by the
+// governing schema, is validated in full against that schema, including
+// attributes, IDs, fixed and default values and further nested subtrees.
+// ID and IDREF values are checked across the whole document and violations
+// are returned in document order.
 func (sr *SchemaRegistry) Validate(doc xmldom.Document) []Violation {
-	violations := []Violation{}
+	if doc == nil {
+		return []Violation{{
+			Code:    "xsd-null-document",
+			Message: "Document is null",
+		}}
+	}
 
 	// Get root element namespace
 	root := doc.DocumentElement()
@@ -282,8 +638,10 @@ func (sr *SchemaRegistry) Validate(doc xmldom.Document) []Violation {
 
 	// Get appropriate schema
 	schema, ok := sr.GetForNamespace(rootNS)
-	if !ok && sr.defaultSchema != nil {
+	if !ok {
+		sr.mu.RLock()
 		schema = sr.defaultSchema
+		sr.mu.RUnlock()
 	}
 
 	if schema == nil {
@@ -293,67 +651,164 @@ func (sr *SchemaRegistry) Validate(doc xmldom.Document) []Violation {
 		}}
 	}
 
-	// Validate with primary schema
-	validator := NewValidator(schema)
-	violations = append(violations, validator.Validate(doc)...)
+	return sr.validateIslands(root, schema)
+}
+
+// namespaceIsland is a subtree validated against the schema for its namespace
+type namespaceIsland struct {
+	root   xmldom.Element
+	schema *Schema
+	parent int // Index of the enclosing island, -1 for the document element
+}
 
-	// Handle mixed namespace validation
-	// Validate elements from imported namespaces against their respective schemas
-	if len(schema.ImportedSchemas) > 0 {
-		violations = append(violations, sr.validateImportedNamespaces(doc, schema)...)
+// validateIslands splits a document into namespace islands, validates each
+// with its own validator and merges the results
+func (sr *SchemaRegistry) validateIslands(root xmldom.Element, primary *Schema) []Violation {
+	islands := []namespaceIsland{{root: root, schema: primary, parent: -1}}
+	owner := make(map[xmldom.Element]int) // element -> index of the island validating it
+	order := make(map[xmldom.Element]int) // element -> position in document order
+
+	var walk func(elem xmldom.Element, current int)
+	walk = func(elem xmldom.Element, current int) {
+		order[elem] = len(order)
+		if elem != root {
+			if schema := sr.islandSchema(string(elem.NamespaceURI()), islands[current].schema); schema != nil {
+				islands = append(islands, namespaceIsland{root: elem, schema: schema, parent: current})
+				current = len(islands) - 1
+			}
+		}
+		owner[elem] = current
+
+		children := elem.Children()
+		for i := uint(0); i < children.Length(); i++ {
+			if child := children.Item(i); child != nil {
+				walk(child, current)
+			}
+		}
 	}
+	walk(root, 0)
 
-	return violations
-}
+	// Violations paired with their position in document order; ones without
+	// an element belong to the start of their island
+	type located struct {
+		violation Violation
+		position  int
+	}
+	found := []located{}
+	add := func(violation Violation, fallback xmldom.Element) {
+		elem := violation.Element
+		if elem == nil {
+			elem = fallback
+		}
+		found = append(found, located{violation: violation, position: order[elem]})
+	}
 
-// validateImportedNamespaces validates elements from imported namespaces
-func (sr *SchemaRegistry) validateImportedNamespaces(doc xmldom.Document, primarySchema *Schema) []Violation {
-	violations := []Violation{}
+	ids := make(map[string]xmldom.Element)
+	idRefs := make(map[string]xmldom.Element)
+	for index, island := range islands {
+		validator := NewValidator(island.schema)
+		validator.deferIDREFs = true
+		validator.skip = func(elem xmldom.Element) bool {
+			other := owner[elem]
+			return other != index && islands[other].root == elem
+		}
 
-	// Build namespace to schema mapping
-	nsMap := make(map[string]*Schema)
-	for _, importedSchema := range primarySchema.ImportedSchemas {
-		if importedSchema.TargetNamespace != "" {
-			nsMap[importedSchema.TargetNamespace] = importedSchema
+		for _, violation := range validator.ValidateElement(island.root) {
+			if islandReports(violation, index, islands, owner) {
+				add(violation, island.root)
+			}
+		}
+
+		// IDs are unique across the whole document, whichever schema declares them
+		for _, id := range sortedKeys(validator.ids) {
+			elem := validator.ids[id]
+			if _, exists := ids[id]; exists {
+				add(Violation{
+					Element: elem,
+					Code:    "cvc-id.2",
+					Message: fmt.Sprintf("Duplicate ID value '%s'", id),
+					Actual:  id,
+				}, elem)
+				continue
+			}
+			ids[id] = elem
+		}
+		for idref, elem := range validator.idRefs {
+			idRefs[idref] = elem
 		}
 	}
 
-	// Recursively validate elements against their namespace schemas
-	root := doc.DocumentElement()
-	if root != nil {
-		violations = append(violations, sr.validateElementNamespace(root, nsMap, primarySchema)...)
+	for _, idref := range sortedKeys(idRefs) {
+		if _, exists := ids[idref]; !exists {
+			add(Violation{
+				Element: idRefs[idref],
+				Code:    "cvc-id.1",
+				Message: fmt.Sprintf("There is no ID/IDREF binding for IDREF '%s'", idref),
+				Actual:  idref,
+			}, idRefs[idref])
+		}
 	}
 
+	sort.SliceStable(found, func(i, j int) bool {
+		return found[i].position < found[j].position
+	})
+	violations := make([]Violation, len(found))
+	for i, entry := range found {
+		violations[i] = entry.violation
+	}
 	return violations
 }
 
-// validateElementNamespace validates an element against the schema for its namespace
-func (sr *SchemaRegistry) validateElementNamespace(elem xmldom.Element, nsMap map[string]*Schema, primarySchema *Schema) []Violation {
-	violations := []Violation{}
+// islandSchema returns the schema for an element that starts a new island
+// inside a subtree governed by current, or nil when the element belongs to
+// the current island. Unqualified elements are always local to their parent.
+func (sr *SchemaRegistry) islandSchema(namespace string, current *Schema) *Schema {
+	if namespace == "" || namespace == current.TargetNamespace {
+		return nil
+	}
 
-	elemNS := string(elem.NamespaceURI())
+	sr.mu.RLock()
+	schema, ok := sr.namespaces[namespace]
+	sr.mu.RUnlock()
+	if ok && schema != nil && schema != current {
+		return schema
+	}
 
-	// If element is from an imported namespace, validate against that schema
-	if elemNS != "" && elemNS != primarySchema.TargetNamespace {
-		if importedSchema, exists := nsMap[elemNS]; exists {
-			// Validate this element against the imported schema
-			// Note: We don't need the validator variable here, just validate the type directly
-			qname := QName{Namespace: elemNS, Local: string(elem.LocalName())}
-			if decl, found := importedSchema.ElementDecls[qname]; found {
-				if decl.Type != nil {
-					violations = append(violations, decl.Type.Validate(elem, importedSchema)...)
-				}
-			}
+	current.mu.RLock()
+	defer current.mu.RUnlock()
+	for _, location := range sortedKeys(current.ImportedSchemas) {
+		if imported := current.ImportedSchemas[location]; imported.TargetNamespace == namespace {
+			return imported
 		}
 	}
+	return nil
+}
 
-	// Recursively validate children
-	children := elem.Children()
-	for i := uint(0); i < children.Length(); i++ {
-		if child := children.Item(i); child != nil {
-			violations = append(violations, sr.validateElementNamespace(child, nsMap, primarySchema)...)
-		}
+// islandReports reports whether a violation found by the validator for one
+// island is that island's to report. Nested islands are validated by their
+// own validator, so the enclosing one only keeps violations about where a
+// nested island root may appear in its content model.
+func islandReports(violation Violation, index int, islands []namespaceIsland, owner map[xmldom.Element]int) bool {
+	if violation.Element == nil {
+		return true
 	}
+	other, ok := owner[violation.Element]
+	if !ok || other == index {
+		return true
+	}
+	if islands[other].root != violation.Element || islands[other].parent != index {
+		return false
+	}
+	return strings.HasPrefix(violation.Code, "cvc-complex-type.2.4") ||
+		strings.HasPrefix(violation.Code, "cvc-wildcard.2")
+}
 
-	return violations
+// sortedKeys returns the keys of a string-keyed map in sorted order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
 }
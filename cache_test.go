@@ -0,0 +1,259 @@
+package xsd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/agentflare-ai/go-xmldom"
+)
+
+const cacheTestSchema = `<?xml version="1.0" encoding="UTF-8"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+	<xs:element name="%s" type="xs:string"/>
+</xs:schema>`
+
+func writeCacheTestSchema(t *testing.T, dir, name, element string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	content := []byte(fmt.Sprintf(cacheTestSchema, element))
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatalf("Failed to write schema: %v", err)
+	}
+	return path
+}
+
+func TestSchemaCacheLRUEviction(t *testing.T) {
+	dir := t.TempDir()
+	a := writeCacheTestSchema(t, dir, "a.xsd", "a")
+	b := writeCacheTestSchema(t, dir, "b.xsd", "b")
+	c := writeCacheTestSchema(t, dir, "c.xsd", "c")
+
+	cache := NewSchemaCacheWithConfig(SchemaCacheConfig{MaxEntries: 2, CheckInterval: -1})
+
+	for _, path := range []string{a, b, a, c} {
+		if _, err := cache.Get(path); err != nil {
+			t.Fatalf("Get(%s) failed: %v", path, err)
+		}
+	}
+
+	stats := cache.Stats()
+	if stats.Entries != 2 {
+		t.Errorf("Expected 2 entries, got %d", stats.Entries)
+	}
+	if stats.Evictions != 1 {
+		t.Errorf("Expected 1 eviction, got %d", stats.Evictions)
+	}
+	if stats.Hits != 1 || stats.Misses != 3 {
+		t.Errorf("Expected 1 hit and 3 misses, got %d hits and %d misses", stats.Hits, stats.Misses)
+	}
+
+	// b was least recently used and must have been evicted, a must still be cached
+	if _, err := cache.Get(a); err != nil {
+		t.Fatalf("Get(a) failed: %v", err)
+	}
+	if got := cache.Stats().Hits; got != 2 {
+		t.Errorf("Expected a to be a hit, hits = %d", got)
+	}
+	if _, err := cache.Get(b); err != nil {
+		t.Fatalf("Get(b) failed: %v", err)
+	}
+	if got := cache.Stats().Misses; got != 4 {
+		t.Errorf("Expected b to be a miss, misses = %d", got)
+	}
+}
+
+func TestSchemaCacheMemoryBound(t *testing.T) {
+	dir := t.TempDir()
+	a := writeCacheTestSchema(t, dir, "a.xsd", "a")
+	b := writeCacheTestSchema(t, dir, "b.xsd", "b")
+
+	cache := NewSchemaCacheWithConfig(SchemaCacheConfig{CheckInterval: -1})
+	if _, err := cache.Get(a); err != nil {
+		t.Fatalf("Get(a) failed: %v", err)
+	}
+	single := cache.Stats().Bytes
+	if single <= 0 {
+		t.Fatalf("Expected a positive size estimate, got %d", single)
+	}
+
+	bounded := NewSchemaCacheWithConfig(SchemaCacheConfig{MaxBytes: single + single/2, CheckInterval: -1})
+	for _, path := range []string{a, b} {
+		if _, err := bounded.Get(path); err != nil {
+			t.Fatalf("Get(%s) failed: %v", path, err)
+		}
+	}
+	stats := bounded.Stats()
+	if stats.Entries != 1 || stats.Evictions != 1 {
+		t.Errorf("Expected 1 entry after 1 eviction, got %d entries and %d evictions", stats.Entries, stats.Evictions)
+	}
+	if stats.Bytes > single+single/2 {
+		t.Errorf("Cache exceeds its memory bound: %d bytes", stats.Bytes)
+	}
+}
+
+func TestSchemaCacheExpiry(t *testing.T) {
+	dir := t.TempDir()
+	a := writeCacheTestSchema(t, dir, "a.xsd", "a")
+	missing := filepath.Join(dir, "missing.xsd")
+
+	cache := NewSchemaCacheWithConfig(SchemaCacheConfig{
+		TTL:           20 * time.Millisecond,
+		NegativeTTL:   20 * time.Millisecond,
+		CheckInterval: -1,
+	})
+
+	first, err := cache.Get(a)
+	if err != nil {
+		t.Fatalf("Get(a) failed: %v", err)
+	}
+	if _, err := cache.Get(missing); err == nil {
+		t.Fatal("Expected an error for a missing schema")
+	}
+
+	// Failures are remembered until NegativeTTL passes
+	writeCacheTestSchema(t, dir, "missing.xsd", "missing")
+	if _, err := cache.Get(missing); err == nil {
+		t.Error("Expected the cached failure to be returned before NegativeTTL")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+
+	if _, err := cache.Get(missing); err != nil {
+		t.Errorf("Expected the failure to expire, got %v", err)
+	}
+	second, err := cache.Get(a)
+	if err != nil {
+		t.Fatalf("Get(a) failed: %v", err)
+	}
+	if first == second {
+		t.Error("Expected the schema to be reloaded after TTL")
+	}
+	if got := cache.Stats().Expirations; got != 2 {
+		t.Errorf("Expected 2 expirations, got %d", got)
+	}
+}
+
+func TestSchemaCacheInvalidation(t *testing.T) {
+	dir := t.TempDir()
+	types := filepath.Join(dir, "types.xsd")
+	if err := os.WriteFile(types, []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+	<xs:simpleType name="code"><xs:restriction base="xs:string"/></xs:simpleType>
+</xs:schema>`), 0644); err != nil {
+		t.Fatalf("Failed to write types schema: %v", err)
+	}
+	main := filepath.Join(dir, "main.xsd")
+	if err := os.WriteFile(main, []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+	<xs:include schemaLocation="types.xsd"/>
+	<xs:element name="root" type="code"/>
+</xs:schema>`), 0644); err != nil {
+		t.Fatalf("Failed to write main schema: %v", err)
+	}
+
+	cache := NewSchemaCacheWithConfig(SchemaCacheConfig{CheckInterval: 0})
+	first, err := cache.Get(main)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+
+	// Touching a file without changing it keeps the entry
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(types, later, later); err != nil {
+		t.Fatalf("Chtimes failed: %v", err)
+	}
+	if again, _ := cache.Get(main); again != first {
+		t.Error("Expected an unchanged include to keep the cached schema")
+	}
+
+	// Changing an included file invalidates the entry
+	if err := os.WriteFile(types, []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+	<xs:simpleType name="code"><xs:restriction base="xs:token"/></xs:simpleType>
+</xs:schema>`), 0644); err != nil {
+		t.Fatalf("Failed to rewrite types schema: %v", err)
+	}
+	second, err := cache.Get(main)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if second == first {
+		t.Error("Expected a changed include to invalidate the cached schema")
+	}
+	if got := cache.Stats().Invalidations; got != 1 {
+		t.Errorf("Expected 1 invalidation, got %d", got)
+	}
+}
+
+func TestSchemaRegistryNamespaceIslands(t *testing.T) {
+	host, err := LoadSchemaFromString(`<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"
+           targetNamespace="urn:host" elementFormDefault="qualified">
+	<xs:element name="page">
+		<xs:complexType>
+			<xs:sequence>
+				<xs:any namespace="##other" processContents="lax" maxOccurs="unbounded"/>
+			</xs:sequence>
+			<xs:attribute name="id" type="xs:ID"/>
+		</xs:complexType>
+	</xs:element>
+	<xs:element name="note">
+		<xs:complexType>
+			<xs:attribute name="lang" type="xs:language" use="required"/>
+		</xs:complexType>
+	</xs:element>
+</xs:schema>`, "")
+	if err != nil {
+		t.Fatalf("Failed to parse host schema: %v", err)
+	}
+	widget, err := LoadSchemaFromString(`<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"
+           targetNamespace="urn:widget" elementFormDefault="qualified">
+	<xs:element name="widget">
+		<xs:complexType>
+			<xs:sequence>
+				<xs:any namespace="##other" processContents="strict" minOccurs="0"/>
+			</xs:sequence>
+			<xs:attribute name="size" type="xs:int" use="required"/>
+			<xs:attribute name="for" type="xs:IDREF"/>
+			<xs:attribute name="kind" type="xs:string" fixed="button"/>
+		</xs:complexType>
+	</xs:element>
+</xs:schema>`, "")
+	if err != nil {
+		t.Fatalf("Failed to parse widget schema: %v", err)
+	}
+
+	registry := NewSchemaRegistry()
+	registry.Register("urn:host", host)
+	registry.Register("urn:widget", widget)
+
+	doc, err := xmldom.Decode(strings.NewReader(`<page xmlns="urn:host" xmlns:w="urn:widget" id="main">
+	<w:widget size="1" for="main" kind="link"/>
+	<w:widget size="2" for="missing">
+		<note/>
+	</w:widget>
+</page>`))
+	if err != nil {
+		t.Fatalf("Failed to parse document: %v", err)
+	}
+
+	violations := registry.Validate(doc)
+	var got []string
+	for _, v := range violations {
+		got = append(got, fmt.Sprintf("%s %s@%s", v.Code, v.Element.LocalName(), v.Attribute))
+	}
+
+	expected := []string{
+		"cvc-attribute.4 widget@",
+		"cvc-id.1 widget@",
+		"cvc-complex-type.4 note@lang",
+	}
+	if strings.Join(got, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("Unexpected violations:\ngot:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(expected, "\n"))
+	}
+}
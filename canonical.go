@@ -0,0 +1,299 @@
+package xsd
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Canonicalize returns the canonical lexical representation of a value of
+// a simple type, or of a complex type with simple content. The value is
+// processed for whitespace and validated against the type first. Canonical
+// forms follow the XSD 1.1 canonical mappings: decimals and integers lose
+// their plus sign and redundant zeros (1.50 becomes 1.5, +01 becomes 1),
+// floats use the 1.5E2 form, dateTime and time values are normalized to
+// UTC, binary values are re-encoded, list items are canonicalized one by
+// one and union values use the first member type they are valid for.
+func (s *Schema) Canonicalize(value string, t Type) (string, error) {
+	typed, err := s.resolveLexical(value, t, make(map[Type]bool))
+	if err != nil {
+		return "", err
+	}
+	return typed.canonical()
+}
+
+// canonical returns the canonical representation of a resolved lexical
+// value
+func (tl *typedLexical) canonical() (string, error) {
+	switch {
+	case tl.isList:
+		items := make([]string, 0, len(tl.items))
+		for _, item := range tl.items {
+			canonical, err := item.canonical()
+			if err != nil {
+				return "", err
+			}
+			items = append(items, canonical)
+		}
+		return strings.Join(items, " "), nil
+	case tl.member != nil:
+		return tl.memberValue.canonical()
+	}
+	return canonicalBuiltin(tl.lexical, tl.builtin)
+}
+
+// canonicalBuiltin returns the canonical representation of a valid,
+// whitespace-processed value of a built-in type
+func canonicalBuiltin(value, name string) (string, error) {
+	if builtinListTypes[name] {
+		return strings.Join(strings.Fields(value), " "), nil
+	}
+
+	switch primitiveType(name) {
+	case "boolean":
+		switch value {
+		case "1":
+			return "true", nil
+		case "0":
+			return "false", nil
+		}
+		return value, nil
+	case "decimal":
+		return canonicalDecimal(value)
+	case "float":
+		return canonicalFloat(value, 32)
+	case "double":
+		return canonicalFloat(value, 64)
+	case "duration":
+		return canonicalDuration(value)
+	case "dateTime", "time", "date", "gYearMonth", "gYear", "gMonthDay", "gDay", "gMonth":
+		d, err := parseDateTime(value, primitiveType(name))
+		if err != nil {
+			return "", err
+		}
+		return d.canonical(), nil
+	case "hexBinary":
+		return strings.ToUpper(value), nil
+	case "base64Binary":
+		data, err := decodeBase64Binary(value)
+		if err != nil {
+			return "", err
+		}
+		return base64.StdEncoding.EncodeToString(data), nil
+	}
+	return value, nil
+}
+
+// canonicalDecimal removes the plus sign, leading and trailing zeros and a
+// trailing decimal point from a decimal; integral values have no fraction
+func canonicalDecimal(value string) (string, error) {
+	if err := validateDecimal(value); err != nil {
+		return "", err
+	}
+	negative := strings.HasPrefix(value, "-")
+	value = strings.TrimLeft(value, "+-")
+
+	intPart, fracPart, _ := strings.Cut(value, ".")
+	intPart = strings.TrimLeft(intPart, "0")
+	fracPart = strings.TrimRight(fracPart, "0")
+	if intPart == "" {
+		intPart = "0"
+	}
+
+	canonical := intPart
+	if fracPart != "" {
+		canonical += "." + fracPart
+	}
+	if negative && canonical != "0" {
+		canonical = "-" + canonical
+	}
+	return canonical, nil
+}
+
+// canonicalFloat writes a float or double as a mantissa with one digit
+// before the point and an exponent: 1.5E2, 0.0E0, INF, -INF, NaN
+func canonicalFloat(value string, bitSize int) (string, error) {
+	switch value {
+	case "INF", "+INF":
+		return "INF", nil
+	case "-INF":
+		return "-INF", nil
+	case "NaN":
+		return "NaN", nil
+	}
+
+	f, err := strconv.ParseFloat(value, bitSize)
+	if err != nil {
+		return "", fmt.Errorf("invalid floating-point value: %s", value)
+	}
+	if f == 0 {
+		if math.Signbit(f) {
+			return "-0.0E0", nil
+		}
+		return "0.0E0", nil
+	}
+
+	mantissa, exponent, _ := strings.Cut(strconv.FormatFloat(f, 'E', -1, bitSize), "E")
+	if !strings.Contains(mantissa, ".") {
+		mantissa += ".0"
+	}
+	exp, _ := strconv.Atoi(exponent)
+	return mantissa + "E" + strconv.Itoa(exp), nil
+}
+
+// canonicalDuration writes a duration with years and months, and days,
+// hours, minutes and seconds, carried into their largest units
+func canonicalDuration(value string) (string, error) {
+	d, err := parseDuration(value)
+	if err != nil {
+		return "", err
+	}
+	return d.String(), nil
+}
+
+// String returns the canonical representation of a duration
+func (d Duration) String() string {
+	if d.Months.Sign() == 0 && d.Seconds.Sign() == 0 {
+		return "PT0S"
+	}
+
+	var b strings.Builder
+	if d.Negative {
+		b.WriteByte('-')
+	}
+	b.WriteByte('P')
+
+	years, months := new(big.Int).QuoRem(d.Months, big.NewInt(12), new(big.Int))
+	if years.Sign() > 0 {
+		b.WriteString(years.String() + "Y")
+	}
+	if months.Sign() > 0 {
+		b.WriteString(months.String() + "M")
+	}
+
+	whole := new(big.Int).Quo(d.Seconds.Num(), d.Seconds.Denom())
+	fraction := new(big.Rat).Sub(d.Seconds, new(big.Rat).SetInt(whole))
+	minutes, seconds := new(big.Int).QuoRem(whole, big.NewInt(60), new(big.Int))
+	hours, minutes := new(big.Int).QuoRem(minutes, big.NewInt(60), new(big.Int))
+	days, hours := new(big.Int).QuoRem(hours, big.NewInt(24), new(big.Int))
+	if days.Sign() > 0 {
+		b.WriteString(days.String() + "D")
+	}
+	if hours.Sign() > 0 || minutes.Sign() > 0 || seconds.Sign() > 0 || fraction.Sign() > 0 {
+		b.WriteByte('T')
+		if hours.Sign() > 0 {
+			b.WriteString(hours.String() + "H")
+		}
+		if minutes.Sign() > 0 {
+			b.WriteString(minutes.String() + "M")
+		}
+		if seconds.Sign() > 0 || fraction.Sign() > 0 {
+			b.WriteString(formatSeconds(seconds, fraction) + "S")
+		}
+	}
+	return b.String()
+}
+
+// formatSeconds writes whole seconds and a decimal fraction of a second
+// without trailing zeros
+func formatSeconds(whole *big.Int, fraction *big.Rat) string {
+	s := whole.String()
+	if fraction.Sign() == 0 {
+		return s
+	}
+	// Lexical fractions are decimal, so the denominator divides a power of
+	// 10; count the digits that power takes to make the fraction whole. A
+	// denominator of n digits needs at most 4n, which bounds other fractions.
+	digits, limit := 0, 4*len(fraction.Denom().String())
+	ten := big.NewRat(10, 1)
+	for scaled := new(big.Rat).Set(fraction); !scaled.IsInt() && digits < limit; scaled.Mul(scaled, ten) {
+		digits++
+	}
+	frac := strings.TrimRight(strings.TrimPrefix(fraction.FloatString(digits), "0."), "0")
+	return s + "." + frac
+}
+
+// normalized returns dateTime and time values with a timezone converted to
+// UTC; other values are returned as they are
+func (d DateTime) normalized() DateTime {
+	if d.Timezone == nil || *d.Timezone == 0 || (d.Kind != "dateTime" && d.Kind != "time") {
+		return d
+	}
+	t := d.instant(*d.Timezone)
+
+	utc := 0
+	n := d
+	n.Hour, n.Minute, n.Timezone = t.Hour(), t.Minute(), &utc
+	if d.Kind == "dateTime" {
+		n.Year, n.Month, n.Day = t.Year(), int(t.Month()), t.Day()
+	}
+	return n
+}
+
+// canonical returns the canonical representation of a date or time value
+func (d DateTime) canonical() string {
+	return d.normalized().String()
+}
+
+// String returns the lexical representation of a date or time value in its
+// own timezone, with fractional seconds but no trailing zeros
+func (d DateTime) String() string {
+	year := fmt.Sprintf("%04d", d.Year)
+	if d.Year < 0 {
+		year = fmt.Sprintf("-%04d", -d.Year)
+	}
+	clock := fmt.Sprintf("%02d:%02d:%02d", d.Hour, d.Minute, d.Second)
+	if d.Fraction != nil && d.Fraction.Sign() != 0 {
+		clock += strings.TrimPrefix(formatSeconds(new(big.Int), d.Fraction), "0")
+	}
+
+	var s string
+	switch d.Kind {
+	case "dateTime":
+		s = fmt.Sprintf("%s-%02d-%02dT%s", year, d.Month, d.Day, clock)
+	case "time":
+		s = clock
+	case "date":
+		s = fmt.Sprintf("%s-%02d-%02d", year, d.Month, d.Day)
+	case "gYearMonth":
+		s = fmt.Sprintf("%s-%02d", year, d.Month)
+	case "gYear":
+		s = year
+	case "gMonthDay":
+		s = fmt.Sprintf("--%02d-%02d", d.Month, d.Day)
+	case "gDay":
+		s = fmt.Sprintf("---%02d", d.Day)
+	case "gMonth":
+		s = fmt.Sprintf("--%02d", d.Month)
+	}
+
+	if d.Timezone != nil {
+		s += formatTimezone(*d.Timezone)
+	}
+	return s
+}
+
+// formatTimezone writes a timezone offset in minutes as Z or ±hh:mm
+func formatTimezone(offset int) string {
+	if offset == 0 {
+		return "Z"
+	}
+	sign := "+"
+	if offset < 0 {
+		sign, offset = "-", -offset
+	}
+	return fmt.Sprintf("%s%02d:%02d", sign, offset/60, offset%60)
+}
+
+// decodeBase64Binary decodes a base64Binary value, which may contain
+// spaces between its characters
+func decodeBase64Binary(value string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(strings.ReplaceAll(value, " ", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid base64Binary value: %s", value)
+	}
+	return data, nil
+}
@@ -0,0 +1,104 @@
+package xsd
+
+import (
+	"testing"
+)
+
+func TestCanonicalize(t *testing.T) {
+	schema, err := parseTestSchema(t, `<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"
+           xmlns:t="http://example.com/canonical" targetNamespace="http://example.com/canonical">
+	<xs:simpleType name="price">
+		<xs:restriction base="xs:decimal"><xs:fractionDigits value="2"/></xs:restriction>
+	</xs:simpleType>
+	<xs:simpleType name="prices"><xs:list itemType="t:price"/></xs:simpleType>
+	<xs:simpleType name="amount">
+		<xs:union memberTypes="xs:int t:word"/>
+	</xs:simpleType>
+	<xs:simpleType name="word">
+		<xs:restriction base="xs:token"><xs:enumeration value="none"/></xs:restriction>
+	</xs:simpleType>
+	<xs:complexType name="money">
+		<xs:simpleContent><xs:extension base="t:price"/></xs:simpleContent>
+	</xs:complexType>
+</xs:schema>`)
+	if err != nil {
+		t.Fatalf("Failed to parse schema: %v", err)
+	}
+
+	builtin := func(local string) Type {
+		return &SimpleType{QName: QName{Namespace: XSDNamespace, Local: local}}
+	}
+	typ := func(local string) Type {
+		return schema.TypeDefs[QName{Namespace: "http://example.com/canonical", Local: local}]
+	}
+
+	tests := []struct {
+		value string
+		t     Type
+		want  string
+	}{
+		{"1.50", builtin("decimal"), "1.5"},
+		{"+01", builtin("decimal"), "1"},
+		{"-0.0", builtin("decimal"), "0"},
+		{".5", builtin("decimal"), "0.5"},
+		{"+01", builtin("int"), "1"},
+		{" 007 ", builtin("unsignedByte"), "7"},
+		{"150", builtin("double"), "1.5E2"},
+		{"0.001", builtin("float"), "1.0E-3"},
+		{"-0", builtin("double"), "-0.0E0"},
+		{"+INF", builtin("float"), "INF"},
+		{"NaN", builtin("double"), "NaN"},
+		{"1", builtin("boolean"), "true"},
+		{"0", builtin("boolean"), "false"},
+		{"2024-01-31T23:30:00.500-01:00", builtin("dateTime"), "2024-02-01T00:30:00.5Z"},
+		{"2024-06-01T12:00:00", builtin("dateTime"), "2024-06-01T12:00:00"},
+		{"2024-06-01T12:00:00.1234567890120-02:00", builtin("dateTime"), "2024-06-01T14:00:00.123456789012Z"},
+		{"12:00:00.0000000000", builtin("time"), "12:00:00"},
+		{"23:00:00+02:00", builtin("time"), "21:00:00Z"},
+		{"2024-06-01+00:00", builtin("date"), "2024-06-01Z"},
+		{"--05-01-05:00", builtin("gMonthDay"), "--05-01-05:00"},
+		{"P0Y13M", builtin("duration"), "P1Y1M"},
+		{"PT36H90M", builtin("duration"), "P1DT13H30M"},
+		{"PT1.50S", builtin("duration"), "PT1.5S"},
+		{"PT1.125S", builtin("duration"), "PT1.125S"},
+		{"PT0.25S", builtin("duration"), "PT0.25S"},
+		{"P1DT0.75S", builtin("duration"), "P1DT0.75S"},
+		{"-P0D", builtin("duration"), "PT0S"},
+		{"0fa1", builtin("hexBinary"), "0FA1"},
+		{"QUJD\n RA==", builtin("base64Binary"), "QUJDRA=="},
+		{"  a \n b  ", builtin("token"), "a b"},
+		{"a  b", builtin("NMTOKENS"), "a b"},
+		{"10.50", typ("price"), "10.5"},
+		{" 1.10\n +2 ", typ("prices"), "1.1 2"},
+		{"+05", typ("amount"), "5"},
+		{" none ", typ("amount"), "none"},
+		{"3.00", typ("money"), "3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, err := schema.Canonicalize(tt.value, tt.t)
+			if err != nil {
+				t.Fatalf("Canonicalize(%q) failed: %v", tt.value, err)
+			}
+			if got != tt.want {
+				t.Errorf("Canonicalize(%q) = %q, want %q", tt.value, got, tt.want)
+			}
+		})
+	}
+
+	for _, invalid := range []struct {
+		value string
+		t     Type
+	}{
+		{"1.5", builtin("int")},
+		{"abc", builtin("decimal")},
+		{"1.234", typ("price")},
+		{"some", typ("amount")},
+	} {
+		if got, err := schema.Canonicalize(invalid.value, invalid.t); err == nil {
+			t.Errorf("Expected Canonicalize(%q) to fail, got %q", invalid.value, got)
+		}
+	}
+}
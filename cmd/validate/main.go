@@ -56,6 +56,11 @@ func main() {
 
 	// Validate document
 	validator := xsd.NewValidator(schema)
+	if entities, err := xsd.ParseUnparsedEntities(xmlData); err == nil {
+		validator.SetUnparsedEntities(entities)
+	} else {
+		fmt.Printf("Warning: Could not read DTD unparsed entities: %v\n", err)
+	}
 	violations := validator.Validate(doc)
 
 	// Convert to diagnostics
@@ -0,0 +1,165 @@
+package xsd
+
+import (
+	"math/big"
+	"strings"
+
+	"github.com/agentflare-ai/go-xmldom"
+)
+
+// VersioningNamespace is the namespace of the vc: attributes that guard
+// conditional inclusion in XSD 1.1 schema documents
+const VersioningNamespace = "http://www.w3.org/2007/XMLSchema-versioning"
+
+// xsd11BuiltinTypes are the built-in types only XSD 1.1 processors support
+var xsd11BuiltinTypes = map[string]bool{
+	"anyAtomicType":     true,
+	"dateTimeStamp":     true,
+	"dayTimeDuration":   true,
+	"yearMonthDuration": true,
+	"error":             true,
+}
+
+// supportedFacets are the constraining facets of each version
+var supportedFacets = map[Version][]string{
+	Version10: {"length", "minLength", "maxLength", "pattern", "enumeration", "whiteSpace",
+		"maxInclusive", "maxExclusive", "minInclusive", "minExclusive", "totalDigits", "fractionDigits"},
+	Version11: {"length", "minLength", "maxLength", "pattern", "enumeration", "whiteSpace",
+		"maxInclusive", "maxExclusive", "minInclusive", "minExclusive", "totalDigits", "fractionDigits",
+		"assertion", "explicitTimezone"},
+}
+
+// typeAvailable reports whether a processor of the given version knows the
+// named type without a schema defining it
+func typeAvailable(name QName, version Version) bool {
+	if name.Namespace != XSDNamespace {
+		return false
+	}
+	if _, ok := builtinBaseTypes[name.Local]; !ok {
+		return false
+	}
+	return version == Version11 || !xsd11BuiltinTypes[name.Local]
+}
+
+// facetAvailable reports whether a processor of the given version supports
+// the named constraining facet
+func facetAvailable(name QName, version Version) bool {
+	if name.Namespace != XSDNamespace {
+		return false
+	}
+	facets, ok := supportedFacets[version]
+	if !ok {
+		facets = supportedFacets[Version10]
+	}
+	for _, facet := range facets {
+		if facet == name.Local {
+			return true
+		}
+	}
+	return false
+}
+
+// conditionallyIncluded evaluates the vc: attributes of a schema document
+// element: it is kept when the processor version is at least vc:minVersion
+// and below vc:maxVersion, every QName in vc:typeAvailable and
+// vc:facetAvailable is supported and at least one in vc:typeUnavailable and
+// vc:facetUnavailable is not. Malformed versions exclude the element.
+func conditionallyIncluded(elem xmldom.Element, version Version) bool {
+	processor, ok := new(big.Rat).SetString(string(version))
+	if !ok {
+		processor, _ = new(big.Rat).SetString(string(Version10))
+	}
+
+	if v := strings.TrimSpace(string(elem.GetAttributeNS(VersioningNamespace, "minVersion"))); v != "" {
+		minVersion, ok := new(big.Rat).SetString(v)
+		if !ok || processor.Cmp(minVersion) < 0 {
+			return false
+		}
+	}
+	if v := strings.TrimSpace(string(elem.GetAttributeNS(VersioningNamespace, "maxVersion"))); v != "" {
+		maxVersion, ok := new(big.Rat).SetString(v)
+		if !ok || processor.Cmp(maxVersion) >= 0 {
+			return false
+		}
+	}
+
+	checks := []struct {
+		attr      string
+		available func(QName, Version) bool
+		want      bool // Whether every name must be available, or at least one unavailable
+	}{
+		{"typeAvailable", typeAvailable, true},
+		{"typeUnavailable", typeAvailable, false},
+		{"facetAvailable", facetAvailable, true},
+		{"facetUnavailable", facetAvailable, false},
+	}
+	for _, check := range checks {
+		if !elem.HasAttributeNS(VersioningNamespace, xmldom.DOMString(check.attr)) {
+			continue
+		}
+		allAvailable := true
+		for _, name := range strings.Fields(string(elem.GetAttributeNS(VersioningNamespace, xmldom.DOMString(check.attr)))) {
+			qname, ok := versioningQName(elem, name)
+			if !ok || !check.available(qname, version) {
+				allAvailable = false
+				break
+			}
+		}
+		if allAvailable != check.want {
+			return false
+		}
+	}
+	return true
+}
+
+// versioningQName resolves a QName of a vc: attribute in the scope of elem
+func versioningQName(elem xmldom.Element, name string) (QName, bool) {
+	prefix, local, prefixed := strings.Cut(name, ":")
+	if !prefixed {
+		ns, _ := lookupNamespace(elem, "")
+		return QName{Namespace: ns, Local: name}, true
+	}
+	ns, ok := lookupNamespace(elem, prefix)
+	return QName{Namespace: ns, Local: local}, ok
+}
+
+// elementList is a list of child elements with the accessors of
+// xmldom.Element.Children
+type elementList []xmldom.Element
+
+func (l elementList) Length() uint {
+	return uint(len(l))
+}
+
+func (l elementList) Item(i uint) xmldom.Element {
+	if i >= uint(len(l)) {
+		return nil
+	}
+	return l[i]
+}
+
+// includedChildren returns the child elements of a schema document element
+// whose vc: conditions hold for the processor version. Excluded sections are
+// skipped while components are parsed rather than removed, so the document
+// stays intact for parsing with another version. An excluded xs:schema
+// element has no children, which leaves the document without components.
+func includedChildren(elem xmldom.Element, version Version) elementList {
+	var included elementList
+	if string(elem.NamespaceURI()) == XSDNamespace && string(elem.LocalName()) == "schema" &&
+		!conditionallyIncluded(elem, version) {
+		return included
+	}
+	children := elem.Children()
+	for i := uint(0); i < children.Length(); i++ {
+		if child := children.Item(i); child != nil && conditionallyIncluded(child, version) {
+			included = append(included, child)
+		}
+	}
+	return included
+}
+
+// schemaChildren returns the child elements of a schema document element
+// that apply to the version of the schema
+func (s *Schema) schemaChildren(elem xmldom.Element) elementList {
+	return includedChildren(elem, s.Version)
+}
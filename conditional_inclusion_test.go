@@ -0,0 +1,126 @@
+package xsd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/agentflare-ai/go-xmldom"
+)
+
+const conditionalSchema = `<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"
+           xmlns:vc="http://www.w3.org/2007/XMLSchema-versioning"
+           xmlns:t="http://example.com/vc" targetNamespace="http://example.com/vc">
+	<xs:element name="stamp" type="xs:dateTimeStamp" vc:minVersion="1.1"/>
+	<xs:element name="stamp" type="t:legacyStamp" vc:maxVersion="1.1"/>
+	<xs:simpleType name="legacyStamp" vc:maxVersion="1.1">
+		<xs:restriction base="xs:dateTime">
+			<xs:pattern value=".*(Z|[+-]\d{2}:\d{2})"/>
+		</xs:restriction>
+	</xs:simpleType>
+	<xs:simpleType name="duration" vc:typeAvailable="xs:dayTimeDuration">
+		<xs:restriction base="xs:dayTimeDuration"/>
+	</xs:simpleType>
+	<xs:simpleType name="duration" vc:typeUnavailable="xs:dayTimeDuration">
+		<xs:restriction base="xs:duration"/>
+	</xs:simpleType>
+	<xs:simpleType name="local">
+		<xs:restriction base="xs:dateTime">
+			<xs:explicitTimezone value="prohibited" vc:facetAvailable="xs:explicitTimezone"/>
+		</xs:restriction>
+	</xs:simpleType>
+	<xs:element name="future" type="xs:string" vc:minVersion="2.0"/>
+	<xs:element name="broken" type="xs:string" vc:minVersion="one"/>
+</xs:schema>`
+
+func TestConditionalInclusion(t *testing.T) {
+	// Both versions parse the same document, which must stay intact
+	doc, err := xmldom.Decode(strings.NewReader(conditionalSchema))
+	if err != nil {
+		t.Fatalf("Failed to parse schema XML: %v", err)
+	}
+	parse := func(version Version) *Schema {
+		schema, err := ParseVersion(doc, version)
+		if err != nil {
+			t.Fatalf("Failed to parse schema for %s: %v", version, err)
+		}
+		return schema
+	}
+	name := func(local string) QName { return QName{Namespace: "http://example.com/vc", Local: local} }
+
+	v10 := parse(Version10)
+	if got := v10.ElementDecls[name("stamp")].Type.Name(); got != name("legacyStamp") {
+		t.Errorf("Expected the 1.0 declaration of stamp, got type %v", got)
+	}
+	if got := v10.TypeDefs[name("duration")].(*SimpleType).Restriction.Base.Local; got != "duration" {
+		t.Errorf("Expected the fallback duration type under 1.0, got base %s", got)
+	}
+	if facets := v10.TypeDefs[name("local")].(*SimpleType).Restriction.Facets; len(facets) != 0 {
+		t.Errorf("Expected the explicitTimezone facet to be excluded under 1.0, got %v", facets)
+	}
+
+	v11 := parse(Version11)
+	if got := v11.ElementDecls[name("stamp")].Type.Name(); got.Local != "dateTimeStamp" {
+		t.Errorf("Expected the 1.1 declaration of stamp, got type %v", got)
+	}
+	if _, ok := v11.TypeDefs[name("legacyStamp")]; ok {
+		t.Error("Expected legacyStamp to be excluded under 1.1")
+	}
+	if got := v11.TypeDefs[name("duration")].(*SimpleType).Restriction.Base.Local; got != "dayTimeDuration" {
+		t.Errorf("Expected the dayTimeDuration type under 1.1, got base %s", got)
+	}
+	if facets := v11.TypeDefs[name("local")].(*SimpleType).Restriction.Facets; len(facets) != 1 {
+		t.Errorf("Expected the explicitTimezone facet under 1.1, got %v", facets)
+	}
+
+	for _, schema := range []*Schema{v10, v11} {
+		for _, local := range []string{"future", "broken"} {
+			if _, ok := schema.ElementDecls[name(local)]; ok {
+				t.Errorf("Expected %s to be excluded under %s", local, schema.Version)
+			}
+		}
+	}
+}
+
+func TestConditionalInclude(t *testing.T) {
+	dir := t.TempDir()
+	main := `<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"
+           xmlns:vc="http://www.w3.org/2007/XMLSchema-versioning"
+           targetNamespace="http://example.com/vc">
+	<xs:include schemaLocation="modern.xsd" vc:minVersion="1.1"/>
+	<xs:element name="base" type="xs:string"/>
+</xs:schema>`
+	modern := `<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema" targetNamespace="http://example.com/vc">
+	<xs:element name="modern" type="xs:string"/>
+</xs:schema>`
+	if err := os.WriteFile(filepath.Join(dir, "main.xsd"), []byte(main), 0644); err != nil {
+		t.Fatalf("Failed to write main schema: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "modern.xsd"), []byte(modern), 0644); err != nil {
+		t.Fatalf("Failed to write included schema: %v", err)
+	}
+
+	for _, tt := range []struct {
+		version Version
+		modern  bool
+	}{
+		{Version10, false},
+		{Version11, true},
+	} {
+		loader, err := NewSchemaLoader(SchemaLoaderConfig{BaseDir: dir, Version: tt.version})
+		if err != nil {
+			t.Fatalf("Failed to create loader: %v", err)
+		}
+		schema, err := loader.LoadSchemaWithImports(filepath.Join(dir, "main.xsd"))
+		if err != nil {
+			t.Fatalf("Failed to load schema for %s: %v", tt.version, err)
+		}
+		if _, ok := schema.ElementDecls[QName{Namespace: "http://example.com/vc", Local: "modern"}]; ok != tt.modern {
+			t.Errorf("Expected the guarded include to be loaded under %s: %v, got %v", tt.version, tt.modern, ok)
+		}
+	}
+}
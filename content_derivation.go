@@ -0,0 +1,719 @@
+package xsd
+
+import (
+	"errors"
+	"fmt"
+)
+
+// anyTypeQName is the root of the type hierarchy
+var anyTypeQName = QName{Namespace: XSDNamespace, Local: "anyType"}
+
+// contentKind is the content type variety of a complex type
+type contentKind int
+
+const (
+	emptyContent contentKind = iota
+	simpleContentKind
+	elementOnlyContent
+	mixedContent
+)
+
+// checkComplexDerivations reports complex type extensions and restrictions
+// that are not valid derivations of their base type (cos-ct-extends and
+// derivation-ok-restriction), together with simple and complex content
+// derivations whose base cannot be used that way (src-ct)
+func (s *Schema) checkComplexDerivations() []error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var errs []error
+	for _, t := range s.typeDefinitions() {
+		ct, ok := t.(*ComplexType)
+		if !ok || ct.DerivedBy == "" || ct.BaseType == anyTypeQName {
+			continue
+		}
+		base := s.lookupTypeLocked(ct.BaseType)
+		if base == nil {
+			continue
+		}
+
+		switch content := declaredContent(ct).(type) {
+		case *SimpleContent:
+			errs = append(errs, s.checkSimpleContentBase(ct, content, base)...)
+		case *ComplexContent:
+			baseCT, ok := base.(*ComplexType)
+			if !ok {
+				errs = append(errs, typeError(ct, "src-ct.1",
+					"complex type '%s' has complex content, but its base '%s' is a simple type",
+					typeLabel(ct), ct.BaseType.Local))
+				continue
+			}
+			if content.Restriction != nil {
+				errs = append(errs, s.checkRestriction(ct, content, baseCT)...)
+			} else if content.Extension != nil {
+				errs = append(errs, s.checkExtension(ct, content, baseCT)...)
+			}
+		}
+	}
+	return errs
+}
+
+// checkSimpleContentBase checks that the base of a simpleContent derivation
+// has simple content, or is a simple type
+func (s *Schema) checkSimpleContentBase(ct *ComplexType, content *SimpleContent, base Type) []error {
+	baseCT, ok := base.(*ComplexType)
+	if !ok || baseCT.QName == anyTypeQName {
+		return nil
+	}
+
+	switch s.contentKindOf(baseCT) {
+	case simpleContentKind:
+		return nil
+	case mixedContent:
+		// A restriction may narrow emptiable mixed content to text
+		if content.Restriction != nil && s.emptiable(s.contentParticle(baseCT)) {
+			return nil
+		}
+	}
+	return []error{typeError(ct, "src-ct.2.1",
+		"complex type '%s' has simple content, but its base '%s' does not", typeLabel(ct), typeLabel(baseCT))}
+}
+
+// checkExtension checks the particles a complexContent extension adds to
+// its base
+func (s *Schema) checkExtension(ct *ComplexType, content *ComplexContent, base *ComplexType) []error {
+	return s.checkExtensionContent(ct, s.restrictionParticle(content.Extension.Content), base)
+}
+
+// checkExtensionContent checks the particles an extension adds against the
+// content type of its base (cos-ct-extends.1.4)
+func (s *Schema) checkExtensionContent(ct *ComplexType, added Particle, base *ComplexType) []error {
+	if added == nil || s.emptyGroup(added) {
+		return nil
+	}
+
+	baseKind := s.contentKindOf(base)
+	switch {
+	case baseKind == simpleContentKind:
+		return []error{typeError(ct, "cos-ct-extends.1.4",
+			"complex type '%s' cannot add element content to '%s', which has simple content",
+			typeLabel(ct), typeLabel(base))}
+	case baseKind != emptyContent && s.declaredMixed(ct) != (baseKind == mixedContent):
+		return []error{typeError(ct, "cos-ct-extends.1.4.3.2.2.1",
+			"complex type '%s' and its base '%s' must both be mixed or both be element-only",
+			typeLabel(ct), typeLabel(base))}
+	}
+	return nil
+}
+
+// checkRestriction checks a complexContent restriction against its base
+// (derivation-ok-restriction)
+func (s *Schema) checkRestriction(ct *ComplexType, content *ComplexContent, base *ComplexType) []error {
+	var errs []error
+	restriction := content.Restriction
+	fail := func(code, format string, args ...any) {
+		errs = append(errs, typeError(ct, code, "complex type '%s' is not a valid restriction of '%s': %s",
+			typeLabel(ct), typeLabel(base), fmt.Sprintf(format, args...)))
+	}
+
+	// Attribute uses
+	baseUses, baseWildcard := s.effectiveAttributeUses(base)
+	baseAttrs := make(map[QName]*AttributeDecl, len(baseUses))
+	for _, attr := range baseUses {
+		baseAttrs[attr.Name] = attr
+	}
+	attrs, wildcard := s.declaredAttributeUses(ct)
+	for _, attr := range attrs {
+		baseAttr := baseAttrs[attr.Name]
+		if baseAttr == nil {
+			if attr.Use != ProhibitedUse &&
+				(baseWildcard == nil || !ParseNamespaceConstraint(baseWildcard.Namespace).Matches(attr.Name.Namespace, s.TargetNamespace)) {
+				fail("derivation-ok-restriction.2.2", "attribute '%s' is not allowed by the base type", attr.Name.Local)
+			}
+			continue
+		}
+
+		if attr.Use == ProhibitedUse {
+			if baseAttr.Use == RequiredUse {
+				fail("derivation-ok-restriction.3", "required attribute '%s' cannot be prohibited", attr.Name.Local)
+			}
+			continue
+		}
+		if baseAttr.Use == RequiredUse && attr.Use != RequiredUse {
+			fail("derivation-ok-restriction.2.1.1", "required attribute '%s' cannot be made optional", attr.Name.Local)
+		}
+		if !s.restrictsType(attr.Type, baseAttr.Type) {
+			fail("derivation-ok-restriction.2.1.2", "type of attribute '%s' is not derived from '%s'",
+				attr.Name.Local, baseAttr.Type.Name().Local)
+		}
+		if baseAttr.Fixed != "" && attr.Fixed != baseAttr.Fixed {
+			fail("derivation-ok-restriction.2.1.3", "attribute '%s' must keep the fixed value '%s'",
+				attr.Name.Local, baseAttr.Fixed)
+		}
+	}
+	if wildcard != nil {
+		if baseWildcard == nil {
+			fail("derivation-ok-restriction.4.1", "the base type has no attribute wildcard to restrict")
+		} else if !s.wildcardSubset(wildcard.Namespace, baseWildcard.Namespace) {
+			fail("derivation-ok-restriction.4.2", "attribute wildcard '%s' is not a subset of '%s'",
+				wildcard.Namespace, baseWildcard.Namespace)
+		}
+	}
+
+	// Content type
+	particle := s.restrictionParticle(restriction.Content)
+	baseParticle := s.contentParticle(base)
+	baseKind := s.contentKindOf(base)
+	switch {
+	case particle == nil || s.emptyGroup(particle):
+		if baseKind == simpleContentKind || (baseKind != emptyContent && !s.emptiable(baseParticle)) {
+			fail("derivation-ok-restriction.5.3.2", "empty content cannot restrict content that is not emptiable")
+		}
+	case baseKind == simpleContentKind || baseKind == emptyContent:
+		fail("derivation-ok-restriction.5.4.1.1", "element content cannot restrict %s content",
+			map[contentKind]string{simpleContentKind: "simple", emptyContent: "empty"}[baseKind])
+	case s.declaredMixed(ct) && baseKind != mixedContent:
+		fail("derivation-ok-restriction.5.4.1.2", "mixed content cannot restrict element-only content")
+	default:
+		if err := s.restrictsParticle(particle, s.restrictionParticle(baseParticle)); err != nil {
+			fail("derivation-ok-restriction.5.4.2", "%v", err)
+		}
+	}
+
+	return errs
+}
+
+// declaredContent returns the content of a complex type as declared, as
+// extensions replace it with the content merged with their base
+func declaredContent(ct *ComplexType) Content {
+	if ct.declared != nil {
+		return ct.declared
+	}
+	return ct.Content
+}
+
+// contentParticle returns the particle of a complex type's content model,
+// or nil when it has simple or empty content
+func (s *Schema) contentParticle(ct *ComplexType) Particle {
+	switch content := ct.Content.(type) {
+	case *ModelGroup:
+		return content
+	case *GroupRef:
+		return content
+	case *ComplexContent:
+		if content.Restriction != nil {
+			if p, ok := content.Restriction.Content.(Particle); ok {
+				return p
+			}
+		}
+		if content.Extension != nil {
+			if p, ok := content.Extension.Content.(Particle); ok {
+				return p
+			}
+		}
+	}
+	return nil
+}
+
+// contentKindOf returns the content type variety of a complex type
+func (s *Schema) contentKindOf(ct *ComplexType) contentKind {
+	if _, ok := declaredContent(ct).(*SimpleContent); ok {
+		return simpleContentKind
+	}
+	if ct.QName == anyTypeQName {
+		return mixedContent
+	}
+	particle := s.restrictionParticle(s.contentParticle(ct))
+	if particle == nil || s.emptyGroup(particle) {
+		if s.declaredMixed(ct) || ct.Mixed {
+			return mixedContent
+		}
+		return emptyContent
+	}
+	if s.declaredMixed(ct) || ct.Mixed {
+		return mixedContent
+	}
+	return elementOnlyContent
+}
+
+// declaredMixed reports whether a complex type is declared mixed itself,
+// before an extension inherits mixed content from its base
+func (s *Schema) declaredMixed(ct *ComplexType) bool {
+	if cc, ok := ct.Content.(*ComplexContent); ok && cc.Mixed {
+		return true
+	}
+	if ct.source != nil {
+		if string(ct.source.GetAttribute("mixed")) == "true" {
+			return true
+		}
+		children := s.schemaChildren(ct.source)
+		for i := uint(0); i < children.Length(); i++ {
+			child := children.Item(i)
+			if child != nil && string(child.NamespaceURI()) == XSDNamespace &&
+				string(child.LocalName()) == "complexContent" && string(child.GetAttribute("mixed")) == "true" {
+				return true
+			}
+		}
+		return false
+	}
+	return ct.Mixed
+}
+
+// restrictsType reports whether a declared type is the base declaration's
+// type or derived from it by restriction. Unknown types are not reported.
+func (s *Schema) restrictsType(t, base Type) bool {
+	t, base = s.resolvePlaceholder(t), s.resolvePlaceholder(base)
+	if t == nil || base == nil || sameType(t, base) {
+		return true
+	}
+	if base.Name() == anyTypeQName {
+		return true
+	}
+	methods, ok := s.derivationMethods(t, base)
+	if !ok {
+		return false
+	}
+	for _, method := range methods {
+		if method == DerivationExtension {
+			return false
+		}
+	}
+	return true
+}
+
+// resolvePlaceholder returns the definition a placeholder type stands for,
+// or nil when it cannot be found
+func (s *Schema) resolvePlaceholder(t Type) Type {
+	st, ok := t.(*SimpleType)
+	if !ok || st.Restriction != nil || st.List != nil || st.Union != nil {
+		return t
+	}
+	return s.lookupTypeLocked(st.QName)
+}
+
+// restrictionParticle resolves the references in a particle and removes
+// pointless groups, as particle restriction compares the normalized forms
+func (s *Schema) restrictionParticle(content Content) Particle {
+	return s.normalizeParticle(content, make(map[QName]bool))
+}
+
+// normalizeParticle normalizes a particle; circular group references are
+// dropped, as they are reported separately (mg-props-correct.2)
+func (s *Schema) normalizeParticle(content Content, visiting map[QName]bool) Particle {
+	switch p := content.(type) {
+	case *ElementRef:
+		decl := &ElementDecl{Name: p.Ref}
+		if global, ok := s.ElementDecls[p.Ref]; ok {
+			copied := *global
+			decl = &copied
+		}
+		decl.MinOcc, decl.MaxOcc = p.MinOcc, p.MaxOcc
+		return decl
+	case *GroupRef:
+		group, ok := s.Groups[p.Ref]
+		if !ok || visiting[p.Ref] {
+			return nil
+		}
+		visiting[p.Ref] = true
+		defer delete(visiting, p.Ref)
+		return s.normalizeParticle(&ModelGroup{
+			Kind:      group.Kind,
+			Particles: group.Particles,
+			MinOcc:    p.MinOcc,
+			MaxOcc:    p.MaxOcc,
+		}, visiting)
+	case *ModelGroup:
+		normalized := &ModelGroup{Kind: p.Kind, MinOcc: p.MinOcc, MaxOcc: p.MaxOcc}
+		for _, particle := range p.Particles {
+			child := s.normalizeParticle(particle, visiting)
+			if child == nil {
+				continue
+			}
+			// A group inside a group of the same kind that occurs once adds nothing
+			if mg, ok := child.(*ModelGroup); ok && mg.Kind == p.Kind && mg.Kind != AllGroup && mg.MinOcc == 1 && mg.MaxOcc == 1 {
+				normalized.Particles = append(normalized.Particles, mg.Particles...)
+				continue
+			}
+			normalized.Particles = append(normalized.Particles, child)
+		}
+		if len(normalized.Particles) == 1 && normalized.MinOcc == 1 && normalized.MaxOcc == 1 {
+			return normalized.Particles[0]
+		}
+		return normalized
+	case *ElementDecl:
+		return p
+	case *AnyElement:
+		return p
+	}
+	return nil
+}
+
+// emptyGroup reports whether a particle is a model group without particles
+func (s *Schema) emptyGroup(p Particle) bool {
+	mg, ok := p.(*ModelGroup)
+	return ok && len(mg.Particles) == 0
+}
+
+// emptiable reports whether a particle can match no elements at all
+func (s *Schema) emptiable(p Particle) bool {
+	if p == nil || p.MinOccurs() == 0 {
+		return true
+	}
+	mg, ok := p.(*ModelGroup)
+	if !ok {
+		return false
+	}
+	if mg.Kind == ChoiceGroup {
+		for _, child := range mg.Particles {
+			if s.emptiable(child) {
+				return true
+			}
+		}
+		return len(mg.Particles) == 0
+	}
+	for _, child := range mg.Particles {
+		if !s.emptiable(child) {
+			return false
+		}
+	}
+	return true
+}
+
+// restrictsParticle checks that a normalized particle of a restriction is
+// a valid restriction of a normalized particle of its base
+// (cos-particle-restrict)
+func (s *Schema) restrictsParticle(r, b Particle) error {
+	if b == nil {
+		return fmt.Errorf("%s is not allowed by the empty base content", describeParticle(r))
+	}
+
+	switch rp := r.(type) {
+	case *ElementDecl:
+		switch bp := b.(type) {
+		case *ElementDecl:
+			return s.nameAndTypeOK(rp, bp)
+		case *AnyElement:
+			return s.nsCompat(rp, bp)
+		case *ModelGroup:
+			// Recurse as if the element were a group of the base's kind
+			return s.restrictsParticle(&ModelGroup{Kind: bp.Kind, MinOcc: 1, MaxOcc: 1, Particles: []Particle{rp}}, bp)
+		}
+	case *AnyElement:
+		if bp, ok := b.(*AnyElement); ok {
+			if !occursWithin(rp, bp) {
+				return occursError(rp, bp)
+			}
+			if !s.wildcardSubset(rp.Namespace, bp.Namespace) {
+				return fmt.Errorf("wildcard '%s' is not a subset of '%s'", rp.Namespace, bp.Namespace)
+			}
+			return nil
+		}
+		return fmt.Errorf("%s cannot restrict %s", describeParticle(rp), describeParticle(b))
+	case *ModelGroup:
+		switch bp := b.(type) {
+		case *AnyElement:
+			return s.nsRecurseCheckCardinality(rp, bp)
+		case *ElementDecl:
+			return fmt.Errorf("%s cannot restrict %s", describeParticle(rp), describeParticle(bp))
+		case *ModelGroup:
+			switch {
+			case rp.Kind == bp.Kind && rp.Kind != ChoiceGroup:
+				return s.recurse(rp, bp)
+			case rp.Kind == ChoiceGroup && bp.Kind == ChoiceGroup:
+				return s.recurseLax(rp, bp)
+			case rp.Kind == SequenceGroup && bp.Kind == AllGroup:
+				return s.recurseUnordered(rp, bp)
+			case rp.Kind == SequenceGroup && bp.Kind == ChoiceGroup:
+				return s.mapAndSum(rp, bp)
+			}
+			return fmt.Errorf("%s cannot restrict %s", describeParticle(rp), describeParticle(bp))
+		}
+	}
+	return fmt.Errorf("%s cannot restrict %s", describeParticle(r), describeParticle(b))
+}
+
+// nameAndTypeOK checks an element against a base element
+func (s *Schema) nameAndTypeOK(r, b *ElementDecl) error {
+	if r.Name != b.Name {
+		return fmt.Errorf("element '%s' does not match base element '%s'", r.Name.Local, b.Name.Local)
+	}
+	if !occursWithin(r, b) {
+		return occursError(r, b)
+	}
+	if r.Nillable && !b.Nillable {
+		return fmt.Errorf("element '%s' cannot be nillable when the base element is not", r.Name.Local)
+	}
+	if b.Fixed != "" && r.Fixed != b.Fixed {
+		return fmt.Errorf("element '%s' must keep the fixed value '%s'", r.Name.Local, b.Fixed)
+	}
+	if !s.restrictsType(r.Type, b.Type) {
+		return fmt.Errorf("type of element '%s' is not derived by restriction from '%s'",
+			r.Name.Local, b.Type.Name().Local)
+	}
+	return nil
+}
+
+// nsCompat checks an element against a base wildcard
+func (s *Schema) nsCompat(r *ElementDecl, b *AnyElement) error {
+	if !ParseNamespaceConstraint(b.Namespace).Matches(r.Name.Namespace, s.TargetNamespace) {
+		return fmt.Errorf("element '%s' is not allowed by the base wildcard '%s'", r.Name.Local, b.Namespace)
+	}
+	if !occursWithin(r, b) {
+		return occursError(r, b)
+	}
+	return nil
+}
+
+// nsRecurseCheckCardinality checks a group against a base wildcard
+func (s *Schema) nsRecurseCheckCardinality(r *ModelGroup, b *AnyElement) error {
+	for _, particle := range r.Particles {
+		if err := s.restrictsParticle(particle, &AnyElement{
+			Namespace: b.Namespace, MinOcc: 0, MaxOcc: -1,
+		}); err != nil {
+			return err
+		}
+	}
+	min, max := effectiveTotalRange(r)
+	if !rangeWithin(min, max, b.MinOcc, b.MaxOcc) {
+		return fmt.Errorf("%s occurs %s times, more than the base wildcard allows (%s)",
+			describeParticle(r), formatRange(min, max), formatRange(b.MinOcc, b.MaxOcc))
+	}
+	return nil
+}
+
+// recurse maps the particles of a group in order onto those of a base
+// group of the same kind; base particles left out must be emptiable
+func (s *Schema) recurse(r, b *ModelGroup) error {
+	if !occursWithin(r, b) {
+		return occursError(r, b)
+	}
+
+	i := 0
+	for _, bp := range b.Particles {
+		if i < len(r.Particles) {
+			err := s.restrictsParticle(r.Particles[i], bp)
+			if err == nil {
+				i++
+				continue
+			}
+			if !s.emptiable(bp) {
+				return err
+			}
+			continue
+		}
+		if !s.emptiable(bp) {
+			return fmt.Errorf("%s of the base content is required", describeParticle(bp))
+		}
+	}
+	if i < len(r.Particles) {
+		return fmt.Errorf("%s is not allowed by the base content model", describeParticle(r.Particles[i]))
+	}
+	return nil
+}
+
+// recurseLax maps the particles of a choice in order onto those of a base
+// choice
+func (s *Schema) recurseLax(r, b *ModelGroup) error {
+	if !occursWithin(r, b) {
+		return occursError(r, b)
+	}
+
+	j := 0
+	for _, rp := range r.Particles {
+		var lastErr error
+		for ; j < len(b.Particles); j++ {
+			if lastErr = s.restrictsParticle(rp, b.Particles[j]); lastErr == nil {
+				break
+			}
+		}
+		if j == len(b.Particles) {
+			if lastErr == nil {
+				lastErr = fmt.Errorf("%s is not allowed by the base content model", describeParticle(rp))
+			}
+			return lastErr
+		}
+		j++
+	}
+	return nil
+}
+
+// recurseUnordered maps the particles of a sequence onto those of a base
+// all group in any order
+func (s *Schema) recurseUnordered(r, b *ModelGroup) error {
+	if !occursWithin(r, b) {
+		return occursError(r, b)
+	}
+
+	used := make([]bool, len(b.Particles))
+	for _, rp := range r.Particles {
+		matched := false
+		for j, bp := range b.Particles {
+			if !used[j] && s.restrictsParticle(rp, bp) == nil {
+				used[j], matched = true, true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("%s is not allowed by the base content model", describeParticle(rp))
+		}
+	}
+	for j, bp := range b.Particles {
+		if !used[j] && !s.emptiable(bp) {
+			return fmt.Errorf("%s of the base content is required", describeParticle(bp))
+		}
+	}
+	return nil
+}
+
+// mapAndSum maps each particle of a sequence onto some particle of a base
+// choice
+func (s *Schema) mapAndSum(r, b *ModelGroup) error {
+	min, max := r.MinOcc*len(r.Particles), -1
+	if r.MaxOcc != -1 {
+		max = r.MaxOcc * len(r.Particles)
+	}
+	if !rangeWithin(min, max, b.MinOcc, b.MaxOcc) {
+		return fmt.Errorf("%s occurs %s times, outside the base range %s",
+			describeParticle(r), formatRange(min, max), formatRange(b.MinOcc, b.MaxOcc))
+	}
+
+	for _, rp := range r.Particles {
+		var errs []error
+		for _, bp := range b.Particles {
+			err := s.restrictsParticle(rp, bp)
+			if err == nil {
+				errs = nil
+				break
+			}
+			errs = append(errs, err)
+		}
+		if len(errs) > 0 {
+			return fmt.Errorf("%s is not allowed by the base choice: %w", describeParticle(rp), errors.Join(errs...))
+		}
+	}
+	return nil
+}
+
+// wildcardSubset reports whether every namespace a wildcard allows is
+// allowed by a base wildcard
+func (s *Schema) wildcardSubset(namespace, base string) bool {
+	r, b := ParseNamespaceConstraint(namespace), ParseNamespaceConstraint(base)
+	switch {
+	case b.Mode == "##any":
+		return true
+	case r.Mode == "##any":
+		return false
+	case r.Mode == "##other":
+		return b.Mode == "##other"
+	}
+
+	var namespaces []string
+	switch r.Mode {
+	case "##targetNamespace":
+		namespaces = []string{s.TargetNamespace}
+	case "##local":
+		namespaces = []string{""}
+	default:
+		for _, ns := range r.Namespaces {
+			switch ns {
+			case "##targetNamespace":
+				namespaces = append(namespaces, s.TargetNamespace)
+			case "##local":
+				namespaces = append(namespaces, "")
+			default:
+				namespaces = append(namespaces, ns)
+			}
+		}
+	}
+	for _, ns := range namespaces {
+		if !b.Matches(ns, s.TargetNamespace) {
+			return false
+		}
+	}
+	return true
+}
+
+// effectiveTotalRange returns the minimum and maximum number of elements
+// and wildcard matches a group allows, -1 for unbounded
+func effectiveTotalRange(p Particle) (int, int) {
+	mg, ok := p.(*ModelGroup)
+	if !ok {
+		return p.MinOccurs(), p.MaxOccurs()
+	}
+
+	min, max := 0, 0
+	for i, child := range mg.Particles {
+		childMin, childMax := effectiveTotalRange(child)
+		if mg.Kind == ChoiceGroup {
+			if i == 0 || childMin < min {
+				min = childMin
+			}
+			if max != -1 && (childMax == -1 || childMax > max) {
+				max = childMax
+			}
+			continue
+		}
+		min += childMin
+		if max != -1 {
+			if childMax == -1 {
+				max = -1
+			} else {
+				max += childMax
+			}
+		}
+	}
+
+	min *= mg.MinOcc
+	if max != -1 {
+		if mg.MaxOcc == -1 {
+			if max > 0 {
+				max = -1
+			}
+		} else {
+			max *= mg.MaxOcc
+		}
+	}
+	return min, max
+}
+
+// occursWithin reports whether the occurrence range of a particle lies
+// within that of a base particle
+func occursWithin(r, b Particle) bool {
+	return rangeWithin(r.MinOccurs(), r.MaxOccurs(), b.MinOccurs(), b.MaxOccurs())
+}
+
+func rangeWithin(min, max, baseMin, baseMax int) bool {
+	if min < baseMin {
+		return false
+	}
+	return baseMax == -1 || (max != -1 && max <= baseMax)
+}
+
+func occursError(r, b Particle) error {
+	return fmt.Errorf("%s occurs %s times, outside the base range %s",
+		describeParticle(r), formatRange(r.MinOccurs(), r.MaxOccurs()), formatRange(b.MinOccurs(), b.MaxOccurs()))
+}
+
+func formatRange(min, max int) string {
+	if max == -1 {
+		return fmt.Sprintf("[%d, unbounded]", min)
+	}
+	return fmt.Sprintf("[%d, %d]", min, max)
+}
+
+// describeParticle names a particle in error messages
+func describeParticle(p Particle) string {
+	switch pp := p.(type) {
+	case *ElementDecl:
+		return fmt.Sprintf("element '%s'", pp.Name.Local)
+	case *ElementRef:
+		return fmt.Sprintf("element '%s'", pp.Ref.Local)
+	case *GroupRef:
+		return fmt.Sprintf("group '%s'", pp.Ref.Local)
+	case *AnyElement:
+		return "wildcard"
+	case *ModelGroup:
+		return fmt.Sprintf("%s group", pp.Kind)
+	}
+	return "particle"
+}
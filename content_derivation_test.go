@@ -0,0 +1,236 @@
+package xsd
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestComplexDerivations(t *testing.T) {
+	const base = `<xs:complexType name="base">
+			<xs:sequence>
+				<xs:element name="a" type="xs:string"/>
+				<xs:element name="b" type="xs:string" minOccurs="0" maxOccurs="3"/>
+			</xs:sequence>
+			<xs:attribute name="id" type="xs:string" use="required"/>
+			<xs:attribute name="lang" type="xs:string"/>
+		</xs:complexType>
+		<xs:complexType name="text">
+			<xs:simpleContent><xs:extension base="xs:string"/></xs:simpleContent>
+		</xs:complexType>`
+
+	tests := []struct {
+		name string
+		body string
+		code string
+	}{
+		{
+			name: "restriction adding a particle",
+			body: `<xs:complexType name="derived">
+					<xs:complexContent><xs:restriction base="t:base">
+						<xs:sequence>
+							<xs:element name="a" type="xs:string"/>
+							<xs:element name="c" type="xs:string"/>
+						</xs:sequence>
+						<xs:attribute name="id" type="xs:string" use="required"/>
+					</xs:restriction></xs:complexContent>
+				</xs:complexType>`,
+			code: "derivation-ok-restriction.5.4.2",
+		},
+		{
+			name: "restriction widening occurrence bounds",
+			body: `<xs:complexType name="derived">
+					<xs:complexContent><xs:restriction base="t:base">
+						<xs:sequence>
+							<xs:element name="a" type="xs:string"/>
+							<xs:element name="b" type="xs:string" minOccurs="0" maxOccurs="unbounded"/>
+						</xs:sequence>
+						<xs:attribute name="id" type="xs:string" use="required"/>
+					</xs:restriction></xs:complexContent>
+				</xs:complexType>`,
+			code: "derivation-ok-restriction.5.4.2",
+		},
+		{
+			name: "restriction making a required attribute optional",
+			body: `<xs:complexType name="derived">
+					<xs:complexContent><xs:restriction base="t:base">
+						<xs:sequence>
+							<xs:element name="a" type="xs:string"/>
+						</xs:sequence>
+						<xs:attribute name="id" type="xs:string" use="optional"/>
+					</xs:restriction></xs:complexContent>
+				</xs:complexType>`,
+			code: "derivation-ok-restriction.2.1.1",
+		},
+		{
+			name: "restriction prohibiting a required attribute",
+			body: `<xs:complexType name="derived">
+					<xs:complexContent><xs:restriction base="t:base">
+						<xs:sequence>
+							<xs:element name="a" type="xs:string"/>
+						</xs:sequence>
+						<xs:attribute name="id" use="prohibited"/>
+					</xs:restriction></xs:complexContent>
+				</xs:complexType>`,
+			code: "derivation-ok-restriction.3",
+		},
+		{
+			name: "restriction adding an attribute",
+			body: `<xs:complexType name="derived">
+					<xs:complexContent><xs:restriction base="t:base">
+						<xs:sequence>
+							<xs:element name="a" type="xs:string"/>
+						</xs:sequence>
+						<xs:attribute name="extra" type="xs:string"/>
+					</xs:restriction></xs:complexContent>
+				</xs:complexType>`,
+			code: "derivation-ok-restriction.2.2",
+		},
+		{
+			name: "restriction widening an attribute type",
+			body: `<xs:complexType name="narrow">
+					<xs:attribute name="n" type="xs:int"/>
+				</xs:complexType>
+				<xs:complexType name="derived">
+					<xs:complexContent><xs:restriction base="t:narrow">
+						<xs:attribute name="n" type="xs:string"/>
+					</xs:restriction></xs:complexContent>
+				</xs:complexType>`,
+			code: "derivation-ok-restriction.2.1.2",
+		},
+		{
+			name: "restriction emptying required content",
+			body: `<xs:complexType name="derived">
+					<xs:complexContent><xs:restriction base="t:base"/></xs:complexContent>
+				</xs:complexType>`,
+			code: "derivation-ok-restriction.5.3.2",
+		},
+		{
+			name: "restriction narrowing a wildcard to an element",
+			body: `<xs:complexType name="open">
+					<xs:sequence><xs:any namespace="http://example.com/other" maxOccurs="2"/></xs:sequence>
+				</xs:complexType>
+				<xs:complexType name="derived">
+					<xs:complexContent><xs:restriction base="t:open">
+						<xs:sequence><xs:element name="local" type="xs:string"/></xs:sequence>
+					</xs:restriction></xs:complexContent>
+				</xs:complexType>`,
+			code: "derivation-ok-restriction.5.4.2",
+		},
+		{
+			name: "extension of simple content with elements",
+			body: `<xs:complexType name="derived">
+					<xs:complexContent><xs:extension base="t:text">
+						<xs:sequence><xs:element name="c" type="xs:string"/></xs:sequence>
+					</xs:extension></xs:complexContent>
+				</xs:complexType>`,
+			code: "cos-ct-extends.1.4",
+		},
+		{
+			name: "mixed extension of element-only content",
+			body: `<xs:complexType name="derived" mixed="true">
+					<xs:complexContent><xs:extension base="t:base">
+						<xs:sequence><xs:element name="c" type="xs:string"/></xs:sequence>
+					</xs:extension></xs:complexContent>
+				</xs:complexType>`,
+			code: "cos-ct-extends.1.4.3.2.2.1",
+		},
+		{
+			name: "simple content derived from element content",
+			body: `<xs:complexType name="derived">
+					<xs:simpleContent><xs:extension base="t:base"/></xs:simpleContent>
+				</xs:complexType>`,
+			code: "src-ct.2.1",
+		},
+		{
+			name: "valid restriction",
+			body: `<xs:complexType name="derived">
+					<xs:complexContent><xs:restriction base="t:base">
+						<xs:sequence>
+							<xs:element name="a" type="xs:string" fixed="x"/>
+							<xs:element name="b" type="xs:string" maxOccurs="2"/>
+						</xs:sequence>
+						<xs:attribute name="id" type="xs:ID" use="required"/>
+						<xs:attribute name="lang" use="prohibited"/>
+					</xs:restriction></xs:complexContent>
+				</xs:complexType>`,
+		},
+		{
+			name: "valid restriction of a choice and a wildcard",
+			body: `<xs:complexType name="open">
+					<xs:choice maxOccurs="unbounded">
+						<xs:element name="x" type="xs:string"/>
+						<xs:element name="y" type="xs:string"/>
+						<xs:any namespace="##any" processContents="lax"/>
+					</xs:choice>
+					<xs:anyAttribute namespace="##any"/>
+				</xs:complexType>
+				<xs:complexType name="derived">
+					<xs:complexContent><xs:restriction base="t:open">
+						<xs:sequence>
+							<xs:element name="y" type="xs:string"/>
+							<xs:element name="z" type="xs:string"/>
+						</xs:sequence>
+						<xs:attribute name="extra" type="xs:string"/>
+						<xs:anyAttribute namespace="##targetNamespace"/>
+					</xs:restriction></xs:complexContent>
+				</xs:complexType>`,
+		},
+		{
+			name: "valid extension",
+			body: `<xs:complexType name="derived">
+					<xs:complexContent><xs:extension base="t:base">
+						<xs:sequence><xs:element name="c" type="xs:string"/></xs:sequence>
+						<xs:attribute name="extra" type="xs:string"/>
+					</xs:extension></xs:complexContent>
+				</xs:complexType>
+				<xs:complexType name="note">
+					<xs:simpleContent><xs:extension base="t:text">
+						<xs:attribute name="lang" type="xs:string"/>
+					</xs:extension></xs:simpleContent>
+				</xs:complexType>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseTestSchema(t, `<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"
+           xmlns:t="http://example.com/derive" targetNamespace="http://example.com/derive">
+	`+base+`
+	`+tt.body+`
+</xs:schema>`)
+			if tt.code == "" {
+				if err != nil {
+					t.Errorf("Expected schema to compile, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.code+":") {
+				t.Errorf("Expected %s error, got %v", tt.code, err)
+			}
+		})
+	}
+}
+
+func TestComplexDerivationLocation(t *testing.T) {
+	_, err := parseTestSchema(t, `<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+	<xs:complexType name="base">
+		<xs:sequence><xs:element name="a" type="xs:string"/></xs:sequence>
+	</xs:complexType>
+	<xs:complexType name="derived">
+		<xs:complexContent><xs:restriction base="base">
+			<xs:sequence><xs:element name="a" type="xs:string" maxOccurs="2"/></xs:sequence>
+		</xs:restriction></xs:complexContent>
+	</xs:complexType>
+</xs:schema>`)
+
+	var schemaErr *SchemaError
+	if !errors.As(err, &schemaErr) {
+		t.Fatalf("Expected a SchemaError, got %v", err)
+	}
+	if schemaErr.Code != "derivation-ok-restriction.5.4.2" || schemaErr.Line != 6 {
+		t.Errorf("Expected derivation-ok-restriction.5.4.2 at line 6, got %+v", schemaErr)
+	}
+}
@@ -10,7 +10,7 @@ import (
 func TestExtensionParsing(t *testing.T) {
 	schemaDoc := `<?xml version="1.0"?>
 <xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"
-           targetNamespace="http://example.com/vehicle"
+           targetNamespace="http://example.com/vehicle" elementFormDefault="qualified"
            xmlns:v="http://example.com/vehicle">
 
   <xs:complexType name="VehicleType">
@@ -0,0 +1,577 @@
+package xsd
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/agentflare-ai/go-xmldom"
+)
+
+// xsiNamespace is the XML Schema instance namespace
+const xsiNamespace = "http://www.w3.org/2001/XMLSchema-instance"
+
+// DerivationMethod names a way of deriving a type or substituting an element
+type DerivationMethod string
+
+const (
+	DerivationExtension    DerivationMethod = "extension"
+	DerivationRestriction  DerivationMethod = "restriction"
+	DerivationSubstitution DerivationMethod = "substitution"
+	DerivationList         DerivationMethod = "list"
+	DerivationUnion        DerivationMethod = "union"
+)
+
+// DerivationSet is the value of a block or final attribute
+type DerivationSet []DerivationMethod
+
+// Contains reports whether the set contains a method
+func (ds DerivationSet) Contains(method DerivationMethod) bool {
+	return slices.Contains(ds, method)
+}
+
+// ParseDerivationSet parses a block or final attribute value. "#all" stands
+// for every allowed method; methods that are not allowed are ignored.
+func ParseDerivationSet(value string, allowed ...DerivationMethod) DerivationSet {
+	var set DerivationSet
+	for _, token := range strings.Fields(value) {
+		if token == "#all" {
+			return append(DerivationSet(nil), allowed...)
+		}
+		if method := DerivationMethod(token); slices.Contains(allowed, method) && !set.Contains(method) {
+			set = append(set, method)
+		}
+	}
+	return set
+}
+
+// only returns the methods of the set that are allowed
+func (ds DerivationSet) only(allowed []DerivationMethod) DerivationSet {
+	var set DerivationSet
+	for _, method := range ds {
+		if slices.Contains(allowed, method) {
+			set = append(set, method)
+		}
+	}
+	return set
+}
+
+// parseBlock returns the block attribute of a declaration, or the schema's
+// blockDefault when it has none
+func (s *Schema) parseBlock(elem xmldom.Element, allowed ...DerivationMethod) DerivationSet {
+	if elem.HasAttribute("block") {
+		return ParseDerivationSet(string(elem.GetAttribute("block")), allowed...)
+	}
+	return s.BlockDefault.only(allowed)
+}
+
+// parseFinal returns the final attribute of a declaration, or the schema's
+// finalDefault when it has none
+func (s *Schema) parseFinal(elem xmldom.Element, allowed ...DerivationMethod) DerivationSet {
+	if elem.HasAttribute("final") {
+		return ParseDerivationSet(string(elem.GetAttribute("final")), allowed...)
+	}
+	return s.FinalDefault.only(allowed)
+}
+
+// typeBlock returns the derivations a type prohibits in its place
+func typeBlock(t Type) DerivationSet {
+	if ct, ok := t.(*ComplexType); ok {
+		return ct.Block
+	}
+	return nil
+}
+
+// typeFinal returns the derivations a type prohibits from itself
+func typeFinal(t Type) DerivationSet {
+	switch tt := t.(type) {
+	case *ComplexType:
+		return tt.Final
+	case *SimpleType:
+		return tt.Final
+	}
+	return nil
+}
+
+// lookupTypeLocked finds a type by name in the schema, its imports or the
+// built-in types. The caller must hold the schema's read lock.
+func (s *Schema) lookupTypeLocked(name QName) Type {
+	if t, ok := s.TypeDefs[name]; ok {
+		return t
+	}
+	for _, imported := range s.ImportedSchemas {
+		imported.mu.RLock()
+		t, ok := imported.TypeDefs[name]
+		imported.mu.RUnlock()
+		if ok {
+			return t
+		}
+	}
+	if name.Namespace == XSDNamespace {
+		if _, ok := builtinBaseTypes[name.Local]; ok {
+			return &SimpleType{QName: name}
+		}
+	}
+	return nil
+}
+
+// derivationStep returns the base type name of a type and the method it is
+// derived by
+func derivationStep(t Type) (QName, DerivationMethod, bool) {
+	switch tt := t.(type) {
+	case *ComplexType:
+		if tt.DerivedBy != "" {
+			return tt.BaseType, tt.DerivedBy, true
+		}
+		// Every other complex type restricts xs:anyType
+		if tt.QName != (QName{Namespace: XSDNamespace, Local: "anyType"}) {
+			return QName{Namespace: XSDNamespace, Local: "anyType"}, DerivationRestriction, true
+		}
+	case *SimpleType:
+		if tt.QName.Namespace == XSDNamespace {
+			if base := builtinBaseTypes[tt.QName.Local]; base != "" {
+				return QName{Namespace: XSDNamespace, Local: base}, DerivationRestriction, true
+			}
+			return QName{}, "", false
+		}
+		if tt.Restriction != nil && tt.Restriction.Base.Local != "" {
+			return tt.Restriction.Base, DerivationRestriction, true
+		}
+		if tt.List != nil || tt.Union != nil {
+			return QName{Namespace: XSDNamespace, Local: "anySimpleType"}, DerivationRestriction, true
+		}
+	}
+	return QName{}, "", false
+}
+
+// contentDerivation returns the base type and method of a simpleContent or
+// complexContent derivation. Extensions later merge the base content into
+// the derived type, so the derivation is recorded when the type is parsed.
+func contentDerivation(content Content) (QName, DerivationMethod) {
+	switch c := content.(type) {
+	case *ComplexContent:
+		if c.Extension != nil {
+			return c.Extension.Base, DerivationExtension
+		}
+		if c.Restriction != nil {
+			return c.Restriction.Base, DerivationRestriction
+		}
+	case *SimpleContent:
+		if c.Extension != nil {
+			return c.Extension.Base, DerivationExtension
+		}
+		if c.Restriction != nil {
+			return c.Restriction.Base, DerivationRestriction
+		}
+	}
+	return QName{}, ""
+}
+
+// sameType reports whether two types are the same definition. Placeholders
+// share the name of the type they stand for; anonymous types only equal
+// themselves.
+func sameType(a, b Type) bool {
+	if a == b {
+		return true
+	}
+	name := a.Name()
+	return name == b.Name() && name.Local != "_anonymous"
+}
+
+// derivationMethods walks the derivation chain from derived up to base and
+// returns the methods used along the way. ok is false when derived does not
+// derive from base. The caller must hold the schema's read lock.
+func (s *Schema) derivationMethods(derived, base Type) (methods []DerivationMethod, ok bool) {
+	visited := make(map[Type]bool)
+	for t := derived; t != nil && !visited[t]; {
+		if sameType(t, base) {
+			return methods, true
+		}
+		visited[t] = true
+
+		baseName, method, ok := derivationStep(t)
+		if !ok {
+			return nil, false
+		}
+		methods = append(methods, method)
+		t = s.lookupTypeLocked(baseName)
+	}
+	return nil, false
+}
+
+// substitutionBlocked explains why member may not substitute for head in
+// instances, or returns "" when it may. The caller must hold the schema's
+// read lock.
+func (s *Schema) substitutionBlocked(member, head *ElementDecl) string {
+	if head.Block.Contains(DerivationSubstitution) {
+		return fmt.Sprintf("element '%s' blocks substitution", head.Name.Local)
+	}
+	if member.Type == nil || head.Type == nil {
+		return ""
+	}
+
+	methods, ok := s.derivationMethods(member.Type, head.Type)
+	if !ok {
+		return ""
+	}
+	blocked := append(append(DerivationSet(nil), head.Block...), typeBlock(head.Type)...)
+	for _, method := range methods {
+		if blocked.Contains(method) {
+			return fmt.Sprintf("element '%s' blocks substitution by %s", head.Name.Local, method)
+		}
+	}
+	return ""
+}
+
+// elementType returns the type an instance element is validated against:
+// the type of its declaration, or the one its type alternatives select, or
+// the type named by its xsi:type attribute when that type may take the
+// place of the declared or selected one. Unknown or blocked xsi:type values
+// are reported and the declared type is used instead. An element whose
+// alternatives select xs:error is reported and has no type.
+func (s *Schema) elementType(elem xmldom.Element, decl *ElementDecl) (Type, []Violation) {
+	return s.selectedElementType(elem, decl, s.selectAlternative(elem, decl))
+}
+
+// selectedElementType is elementType for an element whose type alternative
+// has already been selected; alt is nil when none applies
+func (s *Schema) selectedElementType(elem xmldom.Element, decl *ElementDecl, alt *TypeAlternative) (Type, []Violation) {
+	declared := decl.Type
+	if alt != nil {
+		if alt.IsError() {
+			return nil, []Violation{{
+				Element: elem,
+				Code:    "cvc-type.3.1.3",
+				Message: fmt.Sprintf("Element '%s' is invalid: %s selects the type xs:error",
+					decl.Name.Local, alternativeLabel(alt)),
+				Actual:      alt.Test,
+				Alternative: alt,
+			}}
+		}
+		declared = s.alternativeType(alt)
+	}
+
+	value := strings.TrimSpace(string(elem.GetAttributeNS(xsiNamespace, "type")))
+	if value == "" {
+		return declared, nil
+	}
+
+	prefix, local, prefixed := strings.Cut(value, ":")
+	if !prefixed {
+		prefix, local = "", value
+	}
+	namespace, bound := lookupNamespace(elem, prefix)
+	if !bound && prefixed {
+		return declared, []Violation{{
+			Element:   elem,
+			Attribute: "xsi:type",
+			Code:      "cvc-elt.4.1",
+			Message:   fmt.Sprintf("xsi:type '%s' uses an undeclared prefix '%s'", value, prefix),
+			Actual:    value,
+		}}
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	t := s.lookupTypeLocked(QName{Namespace: namespace, Local: local})
+	if t == nil {
+		return declared, []Violation{{
+			Element:   elem,
+			Attribute: "xsi:type",
+			Code:      "cvc-elt.4.2",
+			Message:   fmt.Sprintf("Cannot resolve xsi:type '%s' to a type definition", value),
+			Actual:    value,
+		}}
+	}
+	if declared == nil {
+		return t, nil
+	}
+
+	methods, ok := s.derivationMethods(t, declared)
+	if !ok {
+		return declared, []Violation{{
+			Element:   elem,
+			Attribute: "xsi:type",
+			Code:      "cvc-elt.4.3",
+			Message: fmt.Sprintf("xsi:type '%s' is not derived from the type '%s' of element '%s'",
+				value, typeLabel(declared), decl.Name.Local),
+			Actual: value,
+		}}
+	}
+	blocked := append(append(DerivationSet(nil), decl.Block...), typeBlock(declared)...)
+	for _, method := range methods {
+		if blocked.Contains(method) {
+			return declared, []Violation{{
+				Element:   elem,
+				Attribute: "xsi:type",
+				Code:      "cvc-elt.4.3",
+				Message: fmt.Sprintf("xsi:type '%s' is derived by %s, which element '%s' blocks",
+					value, method, decl.Name.Local),
+				Actual: value,
+			}}
+		}
+	}
+	return t, nil
+}
+
+// validateElementType validates an instance element against the type
+// assigned by its declaration, its type alternatives or its xsi:type
+// attribute
+func (s *Schema) validateElementType(elem xmldom.Element, decl *ElementDecl) []Violation {
+	alt := s.selectAlternative(elem, decl)
+	t, violations := s.selectedElementType(elem, decl, alt)
+	if t != nil {
+		violations = append(violations, t.Validate(elem, s)...)
+	}
+	return withAlternative(violations, elem, alt)
+}
+
+// checkFinalDerivations reports type derivations and substitution group
+// memberships that a final attribute disallows
+func (s *Schema) checkFinalDerivations() []error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var errs []error
+	for _, t := range s.typeDefinitions() {
+		errs = append(errs, s.checkTypeFinal(t)...)
+	}
+
+	names := make([]QName, 0, len(s.ElementDecls))
+	for name := range s.ElementDecls {
+		names = append(names, name)
+	}
+	sortQNames(names)
+	for _, name := range names {
+		decl := s.ElementDecls[name]
+		if decl.SubstitutionGroup.Local == "" || decl.Type == nil {
+			continue
+		}
+		head, ok := s.ElementDecls[decl.SubstitutionGroup]
+		if !ok || head.Type == nil || len(head.Final) == 0 {
+			continue
+		}
+		methods, ok := s.derivationMethods(decl.Type, head.Type)
+		if !ok {
+			continue
+		}
+		for _, method := range methods {
+			if head.Final.Contains(method) {
+				errs = append(errs, fmt.Errorf(
+					"e-props-correct.4: element '%s' cannot join the substitution group of '%s', which is final for %s",
+					decl.Name.Local, head.Name.Local, method))
+				break
+			}
+		}
+	}
+
+	return errs
+}
+
+// typeDefinitions returns the named type definitions in name order, followed
+// by the anonymous types of declarations reachable from them and from the
+// global elements. The caller must hold the schema's read lock.
+func (s *Schema) typeDefinitions() []Type {
+	types, _ := s.components()
+	return types
+}
+
+// elementDeclarations returns the global element declarations in name
+// order, followed by the local ones reachable from the type definitions.
+// The caller must hold the schema's read lock.
+func (s *Schema) elementDeclarations() []*ElementDecl {
+	_, decls := s.components()
+	return decls
+}
+
+// components walks the type definitions and element declarations of the
+// schema, including anonymous and local ones
+func (s *Schema) components() ([]Type, []*ElementDecl) {
+	var types []Type
+	var decls []*ElementDecl
+	seen := make(map[any]bool)
+
+	var visitType func(t Type)
+	var visitContent func(content Content)
+	visitAnonymous := func(t Type) {
+		if t != nil && t.Name().Local == "_anonymous" {
+			visitType(t)
+		}
+	}
+	visitAttributes := func(attrs []*AttributeDecl) {
+		for _, attr := range attrs {
+			visitAnonymous(attr.Type)
+		}
+	}
+	visitElement := func(decl *ElementDecl) {
+		if seen[decl] {
+			return
+		}
+		seen[decl] = true
+		decls = append(decls, decl)
+		visitAnonymous(decl.Type)
+		for _, alt := range decl.Alternatives {
+			visitAnonymous(alt.Type)
+		}
+	}
+	visitType = func(t Type) {
+		if t == nil || seen[t] {
+			return
+		}
+		seen[t] = true
+		types = append(types, t)
+		if ct, ok := t.(*ComplexType); ok {
+			visitAttributes(ct.Attributes)
+			visitContent(ct.Content)
+		}
+	}
+	visitContent = func(content Content) {
+		switch c := content.(type) {
+		case *ModelGroup:
+			if seen[c] {
+				return
+			}
+			seen[c] = true
+			for _, particle := range c.Particles {
+				switch p := particle.(type) {
+				case *ElementDecl:
+					visitElement(p)
+				case *ModelGroup:
+					visitContent(p)
+				}
+			}
+		case *ComplexContent:
+			if c.Extension != nil {
+				visitAttributes(c.Extension.Attributes)
+				visitContent(c.Extension.Content)
+			}
+			if c.Restriction != nil {
+				visitAttributes(c.Restriction.Attributes)
+				visitContent(c.Restriction.Content)
+			}
+		}
+	}
+
+	names := make([]QName, 0, len(s.ElementDecls))
+	for name := range s.ElementDecls {
+		names = append(names, name)
+	}
+	sortQNames(names)
+	globals := make([]*ElementDecl, 0, len(names))
+	for _, name := range names {
+		globals = append(globals, s.ElementDecls[name])
+	}
+
+	names = names[:0]
+	for name := range s.TypeDefs {
+		names = append(names, name)
+	}
+	sortQNames(names)
+	for _, name := range names {
+		visitType(s.TypeDefs[name])
+	}
+	for _, decl := range globals {
+		visitElement(decl)
+	}
+
+	// Globals come first in the declaration order
+	sorted := make([]*ElementDecl, 0, len(decls))
+	sorted = append(sorted, globals...)
+	for _, decl := range decls {
+		if s.ElementDecls[decl.Name] != decl {
+			sorted = append(sorted, decl)
+		}
+	}
+	return types, sorted
+}
+
+// typeSource returns the definition of a type in its schema document
+func typeSource(t Type) xmldom.Element {
+	switch tt := t.(type) {
+	case *ComplexType:
+		return tt.source
+	case *SimpleType:
+		return tt.source
+	}
+	return nil
+}
+
+// typeError returns a SchemaError located at the definition of a type
+func typeError(t Type, code, format string, args ...any) error {
+	return sourceError(typeSource(t), code, format, args...)
+}
+
+// sourceError returns a SchemaError located at a schema element, or without
+// a location when source is nil
+func sourceError(source xmldom.Element, code, format string, args ...any) error {
+	err := &SchemaError{Code: code, Message: fmt.Sprintf(format, args...)}
+	if source != nil {
+		err.Line, err.Column, _ = source.Position()
+	}
+	return err
+}
+
+// typeLabel names a type in error messages
+func typeLabel(t Type) string {
+	if name := t.Name().Local; name != "_anonymous" {
+		return name
+	}
+	return "(anonymous)"
+}
+
+// checkTypeFinal reports a type whose definition derives from a type that
+// is final for the method used
+func (s *Schema) checkTypeFinal(t Type) []error {
+	name := typeLabel(t)
+
+	final := func(base QName, method DerivationMethod) bool {
+		baseType := s.lookupTypeLocked(base)
+		return baseType != nil && typeFinal(baseType).Contains(method)
+	}
+
+	var errs []error
+	switch tt := t.(type) {
+	case *ComplexType:
+		base, method, ok := derivationStep(tt)
+		if !ok || !final(base, method) {
+			break
+		}
+		if method == DerivationExtension {
+			errs = append(errs, typeError(t, "cos-ct-extends.1.1",
+				"complex type '%s' cannot extend '%s', which is final for extension", name, base.Local))
+		} else {
+			errs = append(errs, typeError(t, "derivation-ok-restriction.1",
+				"complex type '%s' cannot restrict '%s', which is final for restriction", name, base.Local))
+		}
+	case *SimpleType:
+		if tt.Restriction != nil && final(tt.Restriction.Base, DerivationRestriction) {
+			errs = append(errs, typeError(t, "st-props-correct.3",
+				"simple type '%s' cannot restrict '%s', which is final for restriction", name, tt.Restriction.Base.Local))
+		}
+		if tt.List != nil && final(tt.List.ItemType, DerivationList) {
+			errs = append(errs, typeError(t, "cos-st-restricts.2.3.1.2",
+				"simple type '%s' cannot use '%s' as item type, which is final for list", name, tt.List.ItemType.Local))
+		}
+		if tt.Union != nil {
+			for _, member := range tt.Union.MemberTypes {
+				if final(member, DerivationUnion) {
+					errs = append(errs, typeError(t, "cos-st-restricts.3.3.1.2",
+						"simple type '%s' cannot use '%s' as member type, which is final for union", name, member.Local))
+				}
+			}
+		}
+	}
+	return errs
+}
+
+// sortQNames sorts names by namespace, then local name
+func sortQNames(names []QName) {
+	sort.Slice(names, func(i, j int) bool {
+		if names[i].Namespace != names[j].Namespace {
+			return names[i].Namespace < names[j].Namespace
+		}
+		return names[i].Local < names[j].Local
+	})
+}
@@ -0,0 +1,224 @@
+package xsd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/agentflare-ai/go-xmldom"
+)
+
+func parseTestSchema(t *testing.T, content string) (*Schema, error) {
+	t.Helper()
+	doc, err := xmldom.Decode(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("Failed to parse schema XML: %v", err)
+	}
+	return Parse(doc)
+}
+
+// parseTestSchema11 parses a schema document under the rules of XSD 1.1
+func parseTestSchema11(t *testing.T, content string) (*Schema, error) {
+	t.Helper()
+	doc, err := xmldom.Decode(strings.NewReader(content))
+	if err != nil {
+		t.Fatalf("Failed to parse schema XML: %v", err)
+	}
+	return ParseVersion(doc, Version11)
+}
+
+func TestFinalDerivations(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		code string
+	}{
+		{
+			name: "extension of type final for extension",
+			body: `<xs:complexType name="base" final="extension"><xs:sequence/></xs:complexType>
+				<xs:complexType name="derived">
+					<xs:complexContent><xs:extension base="t:base"/></xs:complexContent>
+				</xs:complexType>`,
+			code: "cos-ct-extends.1.1",
+		},
+		{
+			name: "restriction of type final for all derivations",
+			body: `<xs:complexType name="base" final="#all"><xs:sequence/></xs:complexType>
+				<xs:complexType name="derived">
+					<xs:complexContent><xs:restriction base="t:base"/></xs:complexContent>
+				</xs:complexType>`,
+			code: "derivation-ok-restriction.1",
+		},
+		{
+			name: "anonymous restriction of final simple type",
+			body: `<xs:simpleType name="code" final="restriction">
+					<xs:restriction base="xs:string"/>
+				</xs:simpleType>
+				<xs:element name="e">
+					<xs:simpleType><xs:restriction base="t:code"/></xs:simpleType>
+				</xs:element>`,
+			code: "st-props-correct.3",
+		},
+		{
+			name: "list of type final for list",
+			body: `<xs:simpleType name="code" final="list">
+					<xs:restriction base="xs:string"/>
+				</xs:simpleType>
+				<xs:simpleType name="codes"><xs:list itemType="t:code"/></xs:simpleType>`,
+			code: "cos-st-restricts.2.3.1.2",
+		},
+		{
+			name: "substitution group head final for extension",
+			body: `<xs:complexType name="base"><xs:sequence/></xs:complexType>
+				<xs:complexType name="derived">
+					<xs:complexContent><xs:extension base="t:base"/></xs:complexContent>
+				</xs:complexType>
+				<xs:element name="head" type="t:base" final="extension"/>
+				<xs:element name="member" type="t:derived" substitutionGroup="t:head"/>`,
+			code: "e-props-correct.4",
+		},
+		{
+			name: "restriction allowed by final extension",
+			body: `<xs:complexType name="base" final="extension"><xs:sequence/></xs:complexType>
+				<xs:complexType name="derived">
+					<xs:complexContent><xs:restriction base="t:base"/></xs:complexContent>
+				</xs:complexType>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseTestSchema(t, `<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"
+           xmlns:t="http://example.com/final" targetNamespace="http://example.com/final">
+	`+tt.body+`
+</xs:schema>`)
+			if tt.code == "" {
+				if err != nil {
+					t.Errorf("Expected schema to compile, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.code) {
+				t.Errorf("Expected %s error, got %v", tt.code, err)
+			}
+		})
+	}
+
+	// finalDefault applies to types without a final attribute
+	_, err := parseTestSchema(t, `<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema" finalDefault="restriction">
+	<xs:simpleType name="code"><xs:restriction base="xs:string"/></xs:simpleType>
+	<xs:simpleType name="short"><xs:restriction base="code"/></xs:simpleType>
+</xs:schema>`)
+	if err == nil || !strings.Contains(err.Error(), "st-props-correct.3") {
+		t.Errorf("Expected finalDefault to forbid the restriction, got %v", err)
+	}
+}
+
+func TestBlockedSubstitutions(t *testing.T) {
+	schema, err := parseTestSchema(t, `<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"
+           xmlns:b="http://example.com/block" targetNamespace="http://example.com/block"
+           elementFormDefault="qualified">
+	<xs:complexType name="shape">
+		<xs:sequence><xs:element name="label" type="xs:string" minOccurs="0"/></xs:sequence>
+	</xs:complexType>
+	<xs:complexType name="circle">
+		<xs:complexContent>
+			<xs:extension base="b:shape">
+				<xs:attribute name="radius" type="xs:int"/>
+			</xs:extension>
+		</xs:complexContent>
+	</xs:complexType>
+	<xs:complexType name="plain">
+		<xs:complexContent>
+			<xs:restriction base="b:shape"><xs:sequence/></xs:restriction>
+		</xs:complexContent>
+	</xs:complexType>
+	<xs:complexType name="sealed" block="extension">
+		<xs:sequence><xs:element name="label" type="xs:string" minOccurs="0"/></xs:sequence>
+	</xs:complexType>
+	<xs:complexType name="opened">
+		<xs:complexContent><xs:extension base="b:sealed"/></xs:complexContent>
+	</xs:complexType>
+
+	<xs:element name="shape" type="b:shape" block="extension"/>
+	<xs:element name="circle" type="b:circle" substitutionGroup="b:shape"/>
+	<xs:element name="plain" type="b:plain" substitutionGroup="b:shape"/>
+	<xs:element name="fixed" type="b:shape" block="substitution"/>
+	<xs:element name="other" type="b:shape" substitutionGroup="b:fixed"/>
+
+	<xs:element name="drawing">
+		<xs:complexType>
+			<xs:sequence>
+				<xs:element ref="b:shape" minOccurs="0" maxOccurs="unbounded"/>
+				<xs:element ref="b:fixed" minOccurs="0"/>
+				<xs:element name="item" type="b:shape" minOccurs="0" maxOccurs="unbounded"/>
+				<xs:element name="part" type="b:sealed" minOccurs="0"/>
+			</xs:sequence>
+		</xs:complexType>
+	</xs:element>
+</xs:schema>`)
+	if err != nil {
+		t.Fatalf("Failed to parse schema: %v", err)
+	}
+
+	tests := []struct {
+		name  string
+		body  string
+		codes []string
+	}{
+		{
+			name: "substitution by restriction",
+			body: `<plain/>`,
+		},
+		{
+			name:  "substitution by blocked extension",
+			body:  `<circle radius="1"/>`,
+			codes: []string{"cvc-complex-type.2.4.d"},
+		},
+		{
+			name:  "substitution blocked outright",
+			body:  `<other/>`,
+			codes: []string{"cvc-complex-type.2.4.d"},
+		},
+		{
+			name: "xsi:type derived by extension",
+			body: `<item xsi:type="b:circle"/>`,
+		},
+		{
+			name:  "xsi:type blocked by the type",
+			body:  `<part xsi:type="b:opened"/>`,
+			codes: []string{"cvc-elt.4.3"},
+		},
+		{
+			name:  "xsi:type not derived from declared type",
+			body:  `<part xsi:type="b:circle"/>`,
+			codes: []string{"cvc-elt.4.3"},
+		},
+		{
+			name:  "xsi:type naming an unknown type",
+			body:  `<item xsi:type="b:square"/>`,
+			codes: []string{"cvc-elt.4.2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := xmldom.Decode(strings.NewReader(`<drawing xmlns="http://example.com/block"
+				xmlns:b="http://example.com/block"
+				xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance">` + tt.body + `</drawing>`))
+			if err != nil {
+				t.Fatalf("Failed to parse instance: %v", err)
+			}
+
+			var codes []string
+			for _, v := range NewValidator(schema).Validate(doc) {
+				codes = append(codes, v.Code)
+			}
+			if strings.Join(codes, " ") != strings.Join(tt.codes, " ") {
+				t.Errorf("Expected violations %v, got %v", tt.codes, codes)
+			}
+		})
+	}
+}
@@ -83,7 +83,7 @@ func (dc *DiagnosticConverter) convertViolation(v Violation) Diagnostic {
 	}
 
 	// Add related information if available
-	if len(v.Expected) > 0 {
+	if len(v.Expected) > 0 || v.Related != nil {
 		diag.Related = dc.generateRelated(v)
 	}
 
@@ -280,6 +280,12 @@ func (dc *DiagnosticConverter) generateHints(v Violation) []string {
 		hints = append(hints, fmt.Sprintf("Expected: %s", strings.Join(v.Expected, ", ")))
 	}
 
+	// Explain where the type of the element came from
+	if v.Alternative != nil && v.Alternative.Type != nil && !v.Alternative.IsError() {
+		hints = append(hints, fmt.Sprintf("The element was checked against type '%s', selected by %s",
+			typeLabel(v.Alternative.Type), alternativeLabel(v.Alternative)))
+	}
+
 	return hints
 }
 
@@ -287,6 +293,17 @@ func (dc *DiagnosticConverter) generateHints(v Violation) []string {
 func (dc *DiagnosticConverter) generateRelated(v Violation) []Related {
 	related := []Related{}
 
+	if v.Related != nil {
+		label := "related element"
+		switch v.Code {
+		case "cvc-identity-constraint.4.1", "cvc-identity-constraint.4.2.2":
+			label = "first element with this key"
+		case "cvc-identity-constraint.4.2.1", "cvc-identity-constraint.4.3":
+			label = "identity constraint scope"
+		}
+		related = append(related, Related{Label: label, Position: dc.getPosition(v.Related, "")})
+	}
+
 	// For duplicate IDs, we might want to show where it was first defined
 	// This would require tracking in the validator
 
@@ -0,0 +1,351 @@
+package xsd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/agentflare-ai/go-xmldom"
+)
+
+// UnparsedEntity is an unparsed entity declared in a document's DTD
+type UnparsedEntity struct {
+	Name     string
+	PublicID string
+	SystemID string
+	Notation string // Name given by the NDATA keyword
+}
+
+// ParseUnparsedEntities reads the unparsed entity declarations from the
+// internal DTD subset of an XML document. xmldom keeps only the name and
+// external identifiers of a DOCTYPE, so ENTITY and ENTITIES values can only
+// be checked against declarations read from the document source. A document
+// without a DOCTYPE yields an empty map.
+func ParseUnparsedEntities(data []byte) (map[string]*UnparsedEntity, error) {
+	sc := &dtdScanner{s: strings.TrimPrefix(string(data), "\ufeff")}
+	entities := make(map[string]*UnparsedEntity)
+
+	// Skip the prolog up to the DOCTYPE
+	for {
+		sc.skipSpace()
+		switch {
+		case sc.hasPrefix("<?"):
+			if err := sc.skipPast("?>"); err != nil {
+				return nil, err
+			}
+		case sc.hasPrefix("<!--"):
+			if err := sc.skipPast("-->"); err != nil {
+				return nil, err
+			}
+		case sc.hasPrefix("<!DOCTYPE"):
+			sc.pos += len("<!DOCTYPE")
+			return entities, sc.doctype(entities)
+		default:
+			return entities, nil
+		}
+	}
+}
+
+// dtdScanner scans the DOCTYPE declaration of a document
+type dtdScanner struct {
+	s   string
+	pos int
+}
+
+func (sc *dtdScanner) hasPrefix(prefix string) bool {
+	return strings.HasPrefix(sc.s[sc.pos:], prefix)
+}
+
+func (sc *dtdScanner) skipSpace() {
+	for sc.pos < len(sc.s) && strings.IndexByte(" \t\r\n", sc.s[sc.pos]) >= 0 {
+		sc.pos++
+	}
+}
+
+// skipPast moves past the next occurrence of end
+func (sc *dtdScanner) skipPast(end string) error {
+	i := strings.Index(sc.s[sc.pos:], end)
+	if i < 0 {
+		return fmt.Errorf("malformed DOCTYPE: missing '%s'", end)
+	}
+	sc.pos += i + len(end)
+	return nil
+}
+
+// name reads a name token
+func (sc *dtdScanner) name() string {
+	start := sc.pos
+	for sc.pos < len(sc.s) && strings.IndexByte(" \t\r\n>[]'\"%;", sc.s[sc.pos]) < 0 {
+		sc.pos++
+	}
+	return sc.s[start:sc.pos]
+}
+
+// quoted reads a quoted literal
+func (sc *dtdScanner) quoted() (string, error) {
+	if sc.pos >= len(sc.s) || (sc.s[sc.pos] != '"' && sc.s[sc.pos] != '\'') {
+		return "", fmt.Errorf("malformed DOCTYPE: expected a quoted literal at offset %d", sc.pos)
+	}
+	quote := sc.s[sc.pos : sc.pos+1]
+	sc.pos++
+	end := strings.Index(sc.s[sc.pos:], quote)
+	if end < 0 {
+		return "", fmt.Errorf("malformed DOCTYPE: unterminated literal")
+	}
+	value := sc.s[sc.pos : sc.pos+end]
+	sc.pos += end + 1
+	return value, nil
+}
+
+// skipDecl moves past the '>' that closes a markup declaration, stepping
+// over quoted literals that may contain one
+func (sc *dtdScanner) skipDecl() error {
+	for sc.pos < len(sc.s) {
+		switch sc.s[sc.pos] {
+		case '"', '\'':
+			if _, err := sc.quoted(); err != nil {
+				return err
+			}
+		case '>':
+			sc.pos++
+			return nil
+		default:
+			sc.pos++
+		}
+	}
+	return fmt.Errorf("malformed DOCTYPE: unterminated declaration")
+}
+
+// doctype reads the rest of a DOCTYPE declaration after its keyword
+func (sc *dtdScanner) doctype(entities map[string]*UnparsedEntity) error {
+	// Root element name and external identifier
+	for sc.pos < len(sc.s) {
+		switch sc.s[sc.pos] {
+		case '"', '\'':
+			if _, err := sc.quoted(); err != nil {
+				return err
+			}
+		case '>':
+			return nil
+		case '[':
+			sc.pos++
+			return sc.internalSubset(entities)
+		default:
+			sc.pos++
+		}
+	}
+	return fmt.Errorf("malformed DOCTYPE: unterminated declaration")
+}
+
+// internalSubset reads the markup declarations of the internal subset
+func (sc *dtdScanner) internalSubset(entities map[string]*UnparsedEntity) error {
+	// The first declaration of an entity is binding, whether parsed or not
+	declared := make(map[string]bool)
+
+	for {
+		sc.skipSpace()
+		switch {
+		case sc.pos >= len(sc.s):
+			return fmt.Errorf("malformed DOCTYPE: unterminated internal subset")
+		case sc.hasPrefix("]"):
+			return nil
+		case sc.hasPrefix("<!--"):
+			if err := sc.skipPast("-->"); err != nil {
+				return err
+			}
+		case sc.hasPrefix("<?"):
+			if err := sc.skipPast("?>"); err != nil {
+				return err
+			}
+		case sc.hasPrefix("%"):
+			// Parameter entity reference
+			if err := sc.skipPast(";"); err != nil {
+				return err
+			}
+		case sc.hasPrefix("<!ENTITY"):
+			sc.pos += len("<!ENTITY")
+			entity, err := sc.entityDecl()
+			if err != nil {
+				return err
+			}
+			if entity != nil && !declared[entity.Name] {
+				declared[entity.Name] = true
+				if entity.Notation != "" {
+					entities[entity.Name] = entity
+				}
+			}
+		case sc.hasPrefix("<!"):
+			if err := sc.skipDecl(); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("malformed DOCTYPE: unexpected content at offset %d", sc.pos)
+		}
+	}
+}
+
+// entityDecl reads an entity declaration after its keyword. Parameter
+// entities yield nil; internal and parsed external entities have no
+// notation.
+func (sc *dtdScanner) entityDecl() (*UnparsedEntity, error) {
+	sc.skipSpace()
+	if sc.hasPrefix("%") {
+		return nil, sc.skipDecl()
+	}
+
+	entity := &UnparsedEntity{Name: sc.name()}
+	if entity.Name == "" {
+		return nil, fmt.Errorf("malformed DOCTYPE: entity declaration without a name")
+	}
+	sc.skipSpace()
+
+	var err error
+	switch keyword := sc.name(); keyword {
+	case "":
+		// Internal entity with a literal value
+		if _, err = sc.quoted(); err != nil {
+			return nil, err
+		}
+	case "SYSTEM":
+		sc.skipSpace()
+		if entity.SystemID, err = sc.quoted(); err != nil {
+			return nil, err
+		}
+	case "PUBLIC":
+		sc.skipSpace()
+		if entity.PublicID, err = sc.quoted(); err != nil {
+			return nil, err
+		}
+		sc.skipSpace()
+		if entity.SystemID, err = sc.quoted(); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("malformed DOCTYPE: unexpected '%s' in declaration of entity '%s'", keyword, entity.Name)
+	}
+
+	sc.skipSpace()
+	if sc.name() == "NDATA" {
+		sc.skipSpace()
+		entity.Notation = sc.name()
+	}
+	return entity, sc.skipDecl()
+}
+
+// SetUnparsedEntities sets the unparsed entities declared in the DTD of the
+// documents being validated, as returned by ParseUnparsedEntities. Values of
+// ENTITY and ENTITIES types are only checked against them once they are set.
+func (v *Validator) SetUnparsedEntities(entities map[string]*UnparsedEntity) {
+	v.entities = entities
+}
+
+// checkEntities reports ENTITY values of a typed value that do not name an
+// unparsed entity
+func (v *Validator) checkEntities(elem xmldom.Element, attrName string, t Type, value string) {
+	if v.entities == nil {
+		return
+	}
+	for _, name := range v.entityNames(t, value, 0) {
+		if _, ok := v.entities[name]; !ok {
+			v.addViolation(elem, attrName, "cvc-entity",
+				fmt.Sprintf("ENTITY value '%s' does not name an unparsed entity declared in the DTD", name),
+				nil, name)
+		}
+	}
+}
+
+// hasSimpleValue reports whether elements of a type have a simple value:
+// the type is simple, or complex with simple content
+func hasSimpleValue(t Type) bool {
+	switch tt := t.(type) {
+	case *SimpleType:
+		return true
+	case *ComplexType:
+		_, ok := declaredContent(tt).(*SimpleContent)
+		return ok
+	}
+	return false
+}
+
+// entityNames returns the parts of a value that are typed ENTITY, looking
+// through restrictions, list item types, the union member a value belongs
+// to and the base of simple content
+func (v *Validator) entityNames(t Type, value string, depth int) []string {
+	if depth > 32 {
+		return nil
+	}
+	if ct, ok := t.(*ComplexType); ok {
+		sc, ok := declaredContent(ct).(*SimpleContent)
+		switch {
+		case !ok:
+			return nil
+		case sc.Restriction != nil:
+			return v.entityNames(v.simpleTypeNamed(sc.Restriction.Base), value, depth+1)
+		case sc.Extension != nil:
+			return v.entityNames(v.simpleTypeNamed(sc.Extension.Base), value, depth+1)
+		}
+		return nil
+	}
+	st, ok := t.(*SimpleType)
+	if !ok {
+		return nil
+	}
+
+	switch st.QName {
+	case QName{Namespace: XSDNamespace, Local: "ENTITY"}:
+		return []string{strings.TrimSpace(value)}
+	case QName{Namespace: XSDNamespace, Local: "ENTITIES"}:
+		return strings.Fields(value)
+	}
+
+	switch {
+	case st.List != nil:
+		itemType := v.simpleTypeNamed(st.List.ItemType)
+		var names []string
+		for _, item := range strings.Fields(value) {
+			names = append(names, v.entityNames(itemType, item, depth+1)...)
+		}
+		return names
+	case st.Union != nil:
+		// The first member type that accepts the value types it
+		for _, member := range st.Union.MemberTypes {
+			memberType := v.simpleTypeNamed(member)
+			if memberType == nil {
+				continue
+			}
+			var err error
+			if member.Namespace == XSDNamespace {
+				if validator := GetBuiltinTypeValidator(member.Local); validator != nil {
+					err = validator(value)
+				}
+			} else {
+				err = validateValueAgainstType(value, memberType, v.schema)
+			}
+			if err == nil {
+				return v.entityNames(memberType, value, depth+1)
+			}
+		}
+		return nil
+	case st.Restriction != nil:
+		return v.entityNames(v.simpleTypeNamed(st.Restriction.Base), value, depth+1)
+	case st.QName.Namespace != XSDNamespace:
+		// Placeholder for a type declared after its use
+		if resolved := v.simpleTypeNamed(st.QName); resolved != t {
+			return v.entityNames(resolved, value, depth+1)
+		}
+	}
+	return nil
+}
+
+// simpleTypeNamed resolves a type name to a schema type or a built-in type
+func (v *Validator) simpleTypeNamed(name QName) Type {
+	v.schema.mu.RLock()
+	t, ok := v.schema.TypeDefs[name]
+	v.schema.mu.RUnlock()
+	if ok {
+		return t
+	}
+	if name.Namespace == XSDNamespace {
+		return &SimpleType{QName: name}
+	}
+	return nil
+}
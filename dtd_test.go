@@ -0,0 +1,193 @@
+package xsd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/agentflare-ai/go-xmldom"
+)
+
+func TestParseUnparsedEntities(t *testing.T) {
+	entities, err := ParseUnparsedEntities([]byte(`<?xml version="1.0"?>
+<!-- <!DOCTYPE ignored [<!ENTITY fake SYSTEM "fake.gif" NDATA gif>]> -->
+<!DOCTYPE book SYSTEM "book.dtd" [
+	<!NOTATION gif SYSTEM "image/gif">
+	<!ENTITY % common SYSTEM "common.ent">
+	%common;
+	<!-- <!ENTITY commented SYSTEM "c.gif" NDATA gif> -->
+	<!ENTITY cover SYSTEM "cover.gif" NDATA gif>
+	<!ENTITY logo PUBLIC "-//Example//Logo" 'logo.gif' NDATA gif>
+	<!ENTITY chapter SYSTEM "chapter1.xml">
+	<!ENTITY title "A title with > inside">
+	<!ENTITY cover SYSTEM "other.gif" NDATA gif>
+	<!ATTLIST book cover ENTITY #IMPLIED>
+]>
+<book/>`))
+	if err != nil {
+		t.Fatalf("Failed to read DTD: %v", err)
+	}
+
+	if len(entities) != 2 {
+		t.Fatalf("Expected 2 unparsed entities, got %v", entities)
+	}
+	if cover := entities["cover"]; cover == nil || cover.SystemID != "cover.gif" || cover.Notation != "gif" {
+		t.Errorf("Expected the first declaration of cover to bind, got %+v", cover)
+	}
+	if logo := entities["logo"]; logo == nil || logo.PublicID != "-//Example//Logo" || logo.SystemID != "logo.gif" {
+		t.Errorf("Expected logo with public and system identifiers, got %+v", logo)
+	}
+
+	entities, err = ParseUnparsedEntities([]byte(`<?xml version="1.0"?><book/>`))
+	if err != nil || entities == nil || len(entities) != 0 {
+		t.Errorf("Expected no entities without a DOCTYPE, got %v, %v", entities, err)
+	}
+
+	if _, err := ParseUnparsedEntities([]byte(`<!DOCTYPE book [<!ENTITY cover SYSTEM "cover.gif"`)); err == nil {
+		t.Error("Expected an error for an unterminated internal subset")
+	}
+}
+
+func TestEntityValues(t *testing.T) {
+	schema, err := parseTestSchema(t, `<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+	<xs:simpleType name="image">
+		<xs:restriction base="xs:ENTITY"/>
+	</xs:simpleType>
+	<xs:simpleType name="images">
+		<xs:list itemType="image"/>
+	</xs:simpleType>
+	<xs:simpleType name="imageOrNone">
+		<xs:union memberTypes="xs:boolean xs:ENTITY"/>
+	</xs:simpleType>
+	<xs:simpleType name="flag">
+		<xs:union>
+			<xs:simpleType>
+				<xs:restriction base="xs:boolean"/>
+			</xs:simpleType>
+		</xs:union>
+	</xs:simpleType>
+	<xs:simpleType name="imageOrCount">
+		<xs:union>
+			<xs:simpleType>
+				<xs:restriction base="xs:ENTITY"/>
+			</xs:simpleType>
+			<xs:simpleType>
+				<xs:restriction base="xs:integer"/>
+			</xs:simpleType>
+		</xs:union>
+	</xs:simpleType>
+
+	<xs:element name="book">
+		<xs:complexType>
+			<xs:sequence>
+				<xs:element name="plate" minOccurs="0" maxOccurs="unbounded">
+					<xs:complexType>
+						<xs:simpleContent>
+							<xs:extension base="image">
+								<xs:attribute name="thumb" type="xs:ENTITY"/>
+								<xs:attribute name="mark" type="imageOrCount"/>
+							</xs:extension>
+						</xs:simpleContent>
+					</xs:complexType>
+				</xs:element>
+				<xs:element name="coloured" type="flag" minOccurs="0"/>
+			</xs:sequence>
+			<xs:attribute name="cover" type="xs:ENTITY"/>
+			<xs:attribute name="plates" type="xs:ENTITIES"/>
+			<xs:attribute name="gallery" type="images"/>
+			<xs:attribute name="back" type="imageOrNone"/>
+		</xs:complexType>
+	</xs:element>
+	<xs:element name="figure" type="image"/>
+</xs:schema>`)
+	if err != nil {
+		t.Fatalf("Failed to parse schema: %v", err)
+	}
+
+	const doctype = `<!DOCTYPE book [
+	<!NOTATION gif SYSTEM "image/gif">
+	<!ENTITY cover SYSTEM "cover.gif" NDATA gif>
+	<!ENTITY plate1 SYSTEM "plate1.gif" NDATA gif>
+	<!ENTITY chapter SYSTEM "chapter1.xml">
+]>
+`
+
+	tests := []struct {
+		name     string
+		instance string
+		actual   []string
+	}{
+		{
+			name:     "declared entities",
+			instance: doctype + `<book cover="cover" plates="cover plate1" gallery="plate1" back="false"/>`,
+		},
+		{
+			name:     "undeclared entity",
+			instance: doctype + `<book cover="missing"/>`,
+			actual:   []string{"missing"},
+		},
+		{
+			name:     "parsed entity is not unparsed",
+			instance: doctype + `<book plates="plate1 chapter"/>`,
+			actual:   []string{"chapter"},
+		},
+		{
+			name:     "list and union members",
+			instance: doctype + `<book gallery="cover plate2" back="plate3"/>`,
+			actual:   []string{"plate2", "plate3"},
+		},
+		{
+			name:     "element content",
+			instance: doctype + `<figure>plate9</figure>`,
+			actual:   []string{"plate9"},
+		},
+		{
+			name:     "local element",
+			instance: doctype + `<book><plate>cover</plate><plate>plate4</plate></book>`,
+			actual:   []string{"plate4"},
+		},
+		{
+			name:     "attribute of a local element",
+			instance: doctype + `<book><plate thumb="plate5">cover</plate></book>`,
+			actual:   []string{"plate5"},
+		},
+		{
+			name:     "anonymous union members",
+			instance: doctype + `<book><plate mark="7">cover</plate><plate mark="plate6">plate1</plate><coloured>true</coloured></book>`,
+			actual:   []string{"plate6"},
+		},
+		{
+			name:     "no DOCTYPE",
+			instance: `<book cover="cover"/>`,
+			actual:   []string{"cover"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := xmldom.Decode(strings.NewReader(tt.instance))
+			if err != nil {
+				t.Fatalf("Failed to parse instance: %v", err)
+			}
+			entities, err := ParseUnparsedEntities([]byte(tt.instance))
+			if err != nil {
+				t.Fatalf("Failed to read DTD: %v", err)
+			}
+
+			validator := NewValidator(schema)
+			validator.SetUnparsedEntities(entities)
+
+			var actual []string
+			for _, v := range validator.Validate(doc) {
+				if v.Code != "cvc-entity" {
+					t.Errorf("Unexpected violation %s: %s", v.Code, v.Message)
+					continue
+				}
+				actual = append(actual, v.Actual)
+			}
+			if strings.Join(actual, " ") != strings.Join(tt.actual, " ") {
+				t.Errorf("Expected cvc-entity for %v, got %v", tt.actual, actual)
+			}
+		})
+	}
+}
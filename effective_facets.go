@@ -0,0 +1,614 @@
+package xsd
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Facet names accepted by each kind of simple type (cos-applicable-facets)
+var (
+	lengthFacetNames  = []string{"length", "minLength", "maxLength", "pattern", "enumeration", "whiteSpace", "assertion"}
+	orderedFacetNames = []string{"pattern", "enumeration", "whiteSpace", "maxInclusive", "maxExclusive", "minInclusive", "minExclusive", "assertion"}
+	decimalFacetNames = append(append([]string(nil), orderedFacetNames...), "totalDigits", "fractionDigits")
+	dateFacetNames    = append(append([]string(nil), orderedFacetNames...), "explicitTimezone")
+)
+
+// primitiveFacets lists the facets applicable to each primitive type
+var primitiveFacets = map[string][]string{
+	"string": lengthFacetNames, "hexBinary": lengthFacetNames, "base64Binary": lengthFacetNames,
+	"anyURI": lengthFacetNames, "QName": lengthFacetNames, "NOTATION": lengthFacetNames,
+	"boolean": {"pattern", "whiteSpace", "assertion"},
+	"decimal": decimalFacetNames,
+	"float":   orderedFacetNames, "double": orderedFacetNames, "duration": orderedFacetNames,
+	"dateTime": dateFacetNames, "time": dateFacetNames, "date": dateFacetNames,
+	"gYearMonth": dateFacetNames, "gYear": dateFacetNames, "gMonthDay": dateFacetNames,
+	"gDay": dateFacetNames, "gMonth": dateFacetNames,
+}
+
+// builtinListTypes are the built-in types with list variety
+var builtinListTypes = map[string]bool{"IDREFS": true, "ENTITIES": true, "NMTOKENS": true}
+
+// builtinBounds holds the value range built-in integer types restrict
+// their base to, as minInclusive and maxInclusive ("" for none)
+var builtinBounds = map[string][2]string{
+	"nonPositiveInteger": {"", "0"},
+	"negativeInteger":    {"", "-1"},
+	"long":               {"-9223372036854775808", "9223372036854775807"},
+	"int":                {"-2147483648", "2147483647"},
+	"short":              {"-32768", "32767"},
+	"byte":               {"-128", "127"},
+	"nonNegativeInteger": {"0", ""},
+	"unsignedLong":       {"0", "18446744073709551615"},
+	"unsignedInt":        {"0", "4294967295"},
+	"unsignedShort":      {"0", "65535"},
+	"unsignedByte":       {"0", "255"},
+	"positiveInteger":    {"1", ""},
+}
+
+// primitiveType returns the primitive type a built-in type derives from,
+// or "" for anyType, anySimpleType, anyAtomicType, error and the built-in
+// list types
+func primitiveType(name string) string {
+	for name != "" && !builtinListTypes[name] {
+		base, ok := builtinBaseTypes[name]
+		if !ok || base == "anySimpleType" || base == "anyType" || base == "" {
+			return ""
+		}
+		if base == "anyAtomicType" {
+			return name
+		}
+		name = base
+	}
+	return ""
+}
+
+// builtinDerivesFrom reports whether a built-in type is ancestor or one of
+// its ancestors
+func builtinDerivesFrom(name, ancestor string) bool {
+	for ; name != ""; name = builtinBaseTypes[name] {
+		if name == ancestor {
+			return true
+		}
+	}
+	return false
+}
+
+// builtinWhiteSpace returns the whiteSpace facet value of a built-in type
+func builtinWhiteSpace(name string) string {
+	switch {
+	case builtinDerivesFrom(name, "token"):
+		return "collapse"
+	case builtinDerivesFrom(name, "normalizedString"):
+		return "replace"
+	case builtinDerivesFrom(name, "string"):
+		return "preserve"
+	}
+	return "collapse"
+}
+
+// builtinFacets returns the facets a built-in type applies on top of its
+// primitive type
+func builtinFacets(name string) []FacetValidator {
+	facets := []FacetValidator{&WhiteSpaceFacet{Value: builtinWhiteSpace(name)}}
+	if builtinDerivesFrom(name, "integer") {
+		facets = append(facets, &FractionDigitsFacet{Value: 0})
+	}
+	if builtinDerivesFrom(name, "dateTimeStamp") {
+		facets = append(facets, &ExplicitTimezoneFacet{Value: "required"})
+	}
+	for t := name; t != ""; t = builtinBaseTypes[t] {
+		if bounds, ok := builtinBounds[t]; ok {
+			if bounds[0] != "" {
+				facets = append(facets, &MinInclusiveFacet{Value: bounds[0]})
+			}
+			if bounds[1] != "" {
+				facets = append(facets, &MaxInclusiveFacet{Value: bounds[1]})
+			}
+			break
+		}
+	}
+	return facets
+}
+
+// compileFacets computes the effective facets of every atomic restriction
+// chain down to the built-in type it starts from. The caller must hold the
+// schema's lock.
+func (s *Schema) compileFacets() {
+	compiled := make(map[*SimpleType]bool)
+	for _, t := range s.typeDefinitions() {
+		if st, ok := t.(*SimpleType); ok {
+			s.compileSimpleTypeFacets(st, compiled)
+		}
+	}
+}
+
+// compileSimpleTypeFacets computes the effective facets of a restriction
+// after those of its base. Restrictions of list and union types, and of
+// types that cannot be found, are left uncompiled.
+func (s *Schema) compileSimpleTypeFacets(st *SimpleType, compiled map[*SimpleType]bool) {
+	if compiled[st] {
+		return
+	}
+	compiled[st] = true
+	st.facets, st.builtin, st.facetsCompiled = nil, QName{}, false
+
+	if st.Restriction == nil {
+		return
+	}
+
+	base := st.Restriction.Base
+	var baseFacets []FacetValidator
+	if base.Namespace == XSDNamespace {
+		if primitiveType(base.Local) == "" {
+			return
+		}
+		st.builtin = base
+	} else {
+		baseST, ok := s.lookupTypeLocked(base).(*SimpleType)
+		if !ok || baseST == st {
+			return
+		}
+		s.compileSimpleTypeFacets(baseST, compiled)
+		if !baseST.facetsCompiled {
+			return
+		}
+		baseFacets, st.builtin = baseST.facets, baseST.builtin
+	}
+
+	st.facets = mergeFacets(baseFacets, st.Restriction.Facets)
+	st.facetsCompiled = true
+}
+
+// mergeFacets adds the facets of a restriction step to those of its base.
+// Values must satisfy the facets of every step, except that a step's
+// whiteSpace replaces its base's and the patterns of one step are
+// alternatives.
+func mergeFacets(base, own []FacetValidator) []FacetValidator {
+	var patterns []string
+	var whiteSpace *WhiteSpaceFacet
+	var rest []FacetValidator
+	for _, facet := range own {
+		switch f := facet.(type) {
+		case *PatternFacet:
+			patterns = append(patterns, f.Pattern)
+		case *WhiteSpaceFacet:
+			whiteSpace = f
+		default:
+			rest = append(rest, f)
+		}
+	}
+
+	merged := make([]FacetValidator, 0, len(base)+len(own))
+	for _, facet := range base {
+		if _, ok := facet.(*WhiteSpaceFacet); ok && whiteSpace != nil {
+			continue
+		}
+		merged = append(merged, facet)
+	}
+	if whiteSpace != nil {
+		merged = append(merged, whiteSpace)
+	}
+	switch len(patterns) {
+	case 0:
+	case 1:
+		merged = append(merged, &PatternFacet{Pattern: patterns[0]})
+	default:
+		merged = append(merged, &PatternFacet{Pattern: "(" + strings.Join(patterns, ")|(") + ")"})
+	}
+	return append(merged, rest...)
+}
+
+// EffectiveFacets returns the facets a value of a simple type must satisfy,
+// gathered along its restriction chain, and the built-in type the chain
+// starts from. ok is false for list and union types and for chains that
+// cannot be resolved.
+func (s *Schema) EffectiveFacets(st *SimpleType) (facets []FacetValidator, builtin QName, ok bool) {
+	return st.facets, st.builtin, st.facetsCompiled
+}
+
+// validateEffectiveFacets validates a value of a compiled restriction
+// against its built-in type and every facet of the chain
+func validateEffectiveFacets(value string, st *SimpleType) error {
+	if err := validateBuiltinValue(value, st.builtin); err != nil {
+		return err
+	}
+	base := &SimpleType{QName: st.builtin}
+	for _, facet := range st.facets {
+		if err := facet.Validate(value, base); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateBuiltinValue validates a value against a built-in type, accepting
+// any value for types without a validator
+func validateBuiltinValue(value string, builtin QName) error {
+	if validator := GetBuiltinTypeValidator(builtin.Local); validator != nil {
+		return validator(value)
+	}
+	return nil
+}
+
+// facetBounds holds the tightest value of each bounding facet in a set
+type facetBounds struct {
+	length, minLength, maxLength, totalDigits, fractionDigits *int
+	minInclusive, minExclusive, maxInclusive, maxExclusive    *string
+	whiteSpace, explicitTimezone                              string
+}
+
+// collectBounds gathers the tightest bounds of a set of facets; ranges are
+// compared in the value space of the built-in type
+func collectBounds(facets []FacetValidator, builtin *SimpleType) facetBounds {
+	var b facetBounds
+	tighter := func(current *string, value string, lower bool) *string {
+		if current == nil {
+			return &value
+		}
+		cmp, err := compareValues(value, *current, builtin)
+		if err == nil && ((lower && cmp > 0) || (!lower && cmp < 0)) {
+			return &value
+		}
+		return current
+	}
+	lowest := func(current *int, value int) *int {
+		if current == nil || value < *current {
+			return &value
+		}
+		return current
+	}
+
+	for _, facet := range facets {
+		switch f := facet.(type) {
+		case *LengthFacet:
+			b.length = &f.Value
+		case *MinLengthFacet:
+			if b.minLength == nil || f.Value > *b.minLength {
+				b.minLength = &f.Value
+			}
+		case *MaxLengthFacet:
+			b.maxLength = lowest(b.maxLength, f.Value)
+		case *TotalDigitsFacet:
+			b.totalDigits = lowest(b.totalDigits, f.Value)
+		case *FractionDigitsFacet:
+			b.fractionDigits = lowest(b.fractionDigits, f.Value)
+		case *MinInclusiveFacet:
+			b.minInclusive = tighter(b.minInclusive, f.Value, true)
+		case *MinExclusiveFacet:
+			b.minExclusive = tighter(b.minExclusive, f.Value, true)
+		case *MaxInclusiveFacet:
+			b.maxInclusive = tighter(b.maxInclusive, f.Value, false)
+		case *MaxExclusiveFacet:
+			b.maxExclusive = tighter(b.maxExclusive, f.Value, false)
+		case *WhiteSpaceFacet:
+			b.whiteSpace = f.Value
+		case *ExplicitTimezoneFacet:
+			b.explicitTimezone = f.Value
+		}
+	}
+	return b
+}
+
+// checkFacets reports facets that do not apply to the type they restrict
+// (cos-applicable-facets) and facets that widen the value space of their
+// base instead of narrowing it
+func (s *Schema) checkFacets() []error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var errs []error
+	for _, t := range s.typeDefinitions() {
+		st, ok := t.(*SimpleType)
+		if !ok || st.Restriction == nil || len(st.Restriction.Facets) == 0 {
+			continue
+		}
+		errs = append(errs, s.checkApplicableFacets(st)...)
+		if st.facetsCompiled {
+			errs = append(errs, s.checkFacetRestriction(st)...)
+		}
+	}
+	return errs
+}
+
+// checkApplicableFacets checks the facets of a restriction against the
+// variety and primitive type of its base
+func (s *Schema) checkApplicableFacets(st *SimpleType) []error {
+	var allowed []string
+	var baseLabel string
+	switch variety, primitive := s.restrictionVariety(st.Restriction.Base, make(map[QName]bool)); variety {
+	case "list":
+		allowed, baseLabel = lengthFacetNames, "list types"
+	case "union":
+		allowed, baseLabel = []string{"pattern", "enumeration", "assertion"}, "union types"
+	case "atomic":
+		allowed, baseLabel = primitiveFacets[primitive], "'"+primitive+"'"
+	default:
+		return nil
+	}
+
+	var errs []error
+	reported := make(map[string]bool)
+	for _, facet := range st.Restriction.Facets {
+		name := facet.Name()
+		if reported[name] || slices.Contains(allowed, name) {
+			continue
+		}
+		reported[name] = true
+		errs = append(errs, typeError(st, "cos-applicable-facets",
+			"simple type '%s': facet '%s' does not apply to %s", typeLabel(st), name, baseLabel))
+	}
+	return errs
+}
+
+// fixedFacet returns the value of a facet that a restriction chain declares
+// fixed, looking from st towards the built-in type it starts from
+func (s *Schema) fixedFacet(st *SimpleType, name string) (string, bool) {
+	visited := make(map[*SimpleType]bool)
+	for st != nil && st.Restriction != nil && !visited[st] {
+		visited[st] = true
+		if f, ok := st.Restriction.facetValues[name]; ok && f.fixed {
+			return f.value, true
+		}
+		if st.Restriction.Base.Namespace == XSDNamespace {
+			break
+		}
+		st, _ = s.lookupTypeLocked(st.Restriction.Base).(*SimpleType)
+	}
+	return "", false
+}
+
+// sameFacetValue reports whether two values of a facet are equal: numbers
+// for the length and digit facets, values of the type for the range facets
+func sameFacetValue(name, a, b string, builtin Type) bool {
+	a, b = strings.TrimSpace(a), strings.TrimSpace(b)
+	if a == b {
+		return true
+	}
+	switch name {
+	case "length", "minLength", "maxLength", "totalDigits", "fractionDigits":
+		x, errX := strconv.Atoi(a)
+		y, errY := strconv.Atoi(b)
+		return errX == nil && errY == nil && x == y
+	case "minInclusive", "minExclusive", "maxInclusive", "maxExclusive":
+		cmp, err := compareValues(a, b, builtin)
+		return err == nil && cmp == 0
+	}
+	return false
+}
+
+// restrictsList reports whether a simple type is a restriction of a list type
+func (s *Schema) restrictsList(st *SimpleType) bool {
+	if st.Restriction == nil {
+		return false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	variety, _ := s.restrictionVariety(st.Restriction.Base, make(map[QName]bool))
+	return variety == "list"
+}
+
+// restrictionVariety returns the variety of a simple type and, for atomic
+// types, its primitive type. It returns "" when the type cannot be found.
+func (s *Schema) restrictionVariety(name QName, visited map[QName]bool) (variety, primitive string) {
+	if name.Namespace == XSDNamespace {
+		if builtinListTypes[name.Local] {
+			return "list", ""
+		}
+		if name.Local == "error" {
+			// xs:error is a union without member types
+			return "union", ""
+		}
+		if primitive := primitiveType(name.Local); primitive != "" {
+			return "atomic", primitive
+		}
+		return "", ""
+	}
+	if visited[name] {
+		return "", ""
+	}
+	visited[name] = true
+
+	st, ok := s.lookupTypeLocked(name).(*SimpleType)
+	switch {
+	case !ok:
+		return "", ""
+	case st.List != nil:
+		return "list", ""
+	case st.Union != nil:
+		return "union", ""
+	case st.Restriction != nil:
+		return s.restrictionVariety(st.Restriction.Base, visited)
+	}
+	return "", ""
+}
+
+// checkFacetRestriction checks that the facets of a restriction step narrow
+// those of its base
+func (s *Schema) checkFacetRestriction(st *SimpleType) []error {
+	builtin := &SimpleType{QName: st.builtin}
+	baseFacets := builtinFacets(st.builtin.Local)
+	var baseST *SimpleType
+	if st.Restriction.Base.Namespace != XSDNamespace {
+		baseST, _ = s.lookupTypeLocked(st.Restriction.Base).(*SimpleType)
+		if baseST == nil {
+			return nil
+		}
+		baseFacets = append(baseFacets, baseST.facets...)
+	}
+	base := collectBounds(baseFacets, builtin)
+	own := collectBounds(st.Restriction.Facets, builtin)
+	effective := collectBounds(append(append([]FacetValidator(nil), baseFacets...), st.Restriction.Facets...), builtin)
+
+	var errs []error
+	fail := func(code, format string, args ...any) {
+		errs = append(errs, typeError(st, code, "simple type '%s': %s", typeLabel(st), fmt.Sprintf(format, args...)))
+	}
+
+	// Length facets
+	if own.length != nil {
+		switch {
+		case base.length != nil && *own.length != *base.length:
+			fail("length-valid-restriction", "length %d differs from the base length %d", *own.length, *base.length)
+		case base.minLength != nil && *own.length < *base.minLength:
+			fail("length-valid-restriction", "length %d is less than the base minLength %d", *own.length, *base.minLength)
+		case base.maxLength != nil && *own.length > *base.maxLength:
+			fail("length-valid-restriction", "length %d is greater than the base maxLength %d", *own.length, *base.maxLength)
+		}
+	}
+	if own.minLength != nil && base.minLength != nil && *own.minLength < *base.minLength {
+		fail("minLength-valid-restriction", "minLength %d is less than the base minLength %d", *own.minLength, *base.minLength)
+	}
+	if own.maxLength != nil && base.maxLength != nil && *own.maxLength > *base.maxLength {
+		fail("maxLength-valid-restriction", "maxLength %d is greater than the base maxLength %d", *own.maxLength, *base.maxLength)
+	}
+	if effective.minLength != nil && effective.maxLength != nil && *effective.minLength > *effective.maxLength {
+		fail("minLength-less-than-equal-to-maxLength", "minLength %d is greater than maxLength %d",
+			*effective.minLength, *effective.maxLength)
+	}
+
+	// Digit facets
+	if own.totalDigits != nil && base.totalDigits != nil && *own.totalDigits > *base.totalDigits {
+		fail("totalDigits-valid-restriction", "totalDigits %d is greater than the base totalDigits %d",
+			*own.totalDigits, *base.totalDigits)
+	}
+	if own.fractionDigits != nil && base.fractionDigits != nil && *own.fractionDigits > *base.fractionDigits {
+		fail("fractionDigits-valid-restriction", "fractionDigits %d is greater than the base fractionDigits %d",
+			*own.fractionDigits, *base.fractionDigits)
+	}
+	if effective.fractionDigits != nil && effective.totalDigits != nil && *effective.fractionDigits > *effective.totalDigits {
+		fail("fractionDigits-totalDigits", "fractionDigits %d is greater than totalDigits %d",
+			*effective.fractionDigits, *effective.totalDigits)
+	}
+
+	// Range facets, for the types whose values are ordered
+	if orderedPrimitives[primitiveType(st.builtin.Local)] {
+		errs = append(errs, checkRangeRestriction(st, own, base, builtin)...)
+	}
+
+	// whiteSpace can only get stricter
+	if own.whiteSpace != "" {
+		switch {
+		case base.whiteSpace == "collapse" && own.whiteSpace != "collapse":
+			fail("whiteSpace-valid-restriction.1", "whiteSpace '%s' cannot relax the base whiteSpace 'collapse'", own.whiteSpace)
+		case base.whiteSpace == "replace" && own.whiteSpace == "preserve":
+			fail("whiteSpace-valid-restriction.2", "whiteSpace 'preserve' cannot relax the base whiteSpace 'replace'")
+		}
+	}
+
+	// Facets fixed in a base type keep their value
+	names := make([]string, 0, len(st.Restriction.facetValues))
+	for name := range st.Restriction.facetValues {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		value := st.Restriction.facetValues[name].value
+		if fixed, ok := s.fixedFacet(baseST, name); ok && !sameFacetValue(name, value, fixed, builtin) {
+			fail(name+"-valid-restriction", "%s '%s' cannot change the value '%s' the base type fixes", name, value, fixed)
+		}
+	}
+
+	// A required or prohibited timezone is fixed
+	if own.explicitTimezone != "" && base.explicitTimezone != "" && base.explicitTimezone != "optional" &&
+		own.explicitTimezone != base.explicitTimezone {
+		fail("explicitTimezone-valid-restriction", "explicitTimezone '%s' cannot change the base explicitTimezone '%s'",
+			own.explicitTimezone, base.explicitTimezone)
+	}
+
+	// Enumeration values must belong to the base type
+	for _, facet := range st.Restriction.Facets {
+		enum, ok := facet.(*EnumerationFacet)
+		if !ok {
+			continue
+		}
+		for _, value := range enum.Values {
+			var err error
+			if baseST != nil {
+				err = validateEffectiveFacets(value, baseST)
+			} else {
+				err = validateBuiltinValue(value, st.builtin)
+			}
+			if err != nil {
+				fail("enumeration-valid-restriction", "enumeration value '%s' is not valid for the base type: %v", value, err)
+			}
+		}
+	}
+
+	return errs
+}
+
+// rangeRule rejects a bounding facet whose comparison with a base bound
+// gives one of the listed results
+type rangeRule struct {
+	name   string
+	bound  *string
+	reject []int
+}
+
+// checkRangeRestriction checks the bounding facets of a restriction step
+// against those of its base and against each other
+func checkRangeRestriction(st *SimpleType, own, base facetBounds, builtin *SimpleType) []error {
+	const less, equal, greater = -1, 0, 1
+
+	// compare reports whether a and b are both set and a compares to b as
+	// one of the given results
+	compare := func(a, b *string, results ...int) bool {
+		if a == nil || b == nil {
+			return false
+		}
+		cmp, err := compareValues(*a, *b, builtin)
+		return err == nil && slices.Contains(results, cmp)
+	}
+
+	var errs []error
+	fail := func(code, format string, args ...any) {
+		errs = append(errs, typeError(st, code, "simple type '%s': %s", typeLabel(st), fmt.Sprintf(format, args...)))
+	}
+	check := func(facet string, value *string, rules ...rangeRule) {
+		for _, rule := range rules {
+			if compare(value, rule.bound, rule.reject...) {
+				fail(facet+"-valid-restriction", "%s %s is outside the base %s %s", facet, *value, rule.name, *rule.bound)
+				return
+			}
+		}
+	}
+
+	check("maxInclusive", own.maxInclusive,
+		rangeRule{"maxInclusive", base.maxInclusive, []int{greater}},
+		rangeRule{"maxExclusive", base.maxExclusive, []int{greater, equal}},
+		rangeRule{"minInclusive", base.minInclusive, []int{less}},
+		rangeRule{"minExclusive", base.minExclusive, []int{less, equal}})
+	check("maxExclusive", own.maxExclusive,
+		rangeRule{"maxExclusive", base.maxExclusive, []int{greater}},
+		rangeRule{"maxInclusive", base.maxInclusive, []int{greater}},
+		rangeRule{"minInclusive", base.minInclusive, []int{less, equal}},
+		rangeRule{"minExclusive", base.minExclusive, []int{less, equal}})
+	check("minInclusive", own.minInclusive,
+		rangeRule{"minInclusive", base.minInclusive, []int{less}},
+		rangeRule{"minExclusive", base.minExclusive, []int{less, equal}},
+		rangeRule{"maxInclusive", base.maxInclusive, []int{greater}},
+		rangeRule{"maxExclusive", base.maxExclusive, []int{greater, equal}})
+	check("minExclusive", own.minExclusive,
+		rangeRule{"minExclusive", base.minExclusive, []int{less}},
+		rangeRule{"minInclusive", base.minInclusive, []int{less}},
+		rangeRule{"maxInclusive", base.maxInclusive, []int{greater, equal}},
+		rangeRule{"maxExclusive", base.maxExclusive, []int{greater, equal}})
+
+	switch {
+	case compare(own.minInclusive, own.maxInclusive, greater):
+		fail("minInclusive-less-than-equal-to-maxInclusive", "minInclusive %s is greater than maxInclusive %s",
+			*own.minInclusive, *own.maxInclusive)
+	case compare(own.minExclusive, own.maxExclusive, greater):
+		fail("minExclusive-less-than-equal-to-maxExclusive", "minExclusive %s is greater than maxExclusive %s",
+			*own.minExclusive, *own.maxExclusive)
+	case compare(own.minInclusive, own.maxExclusive, greater, equal):
+		fail("minInclusive-less-than-maxExclusive", "minInclusive %s is not less than maxExclusive %s",
+			*own.minInclusive, *own.maxExclusive)
+	case compare(own.minExclusive, own.maxInclusive, greater, equal):
+		fail("minExclusive-less-than-maxInclusive", "minExclusive %s is not less than maxInclusive %s",
+			*own.minExclusive, *own.maxInclusive)
+	}
+	return errs
+}
@@ -0,0 +1,275 @@
+package xsd
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/agentflare-ai/go-xmldom"
+)
+
+func TestFacetConstraints(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		code string
+	}{
+		{
+			name: "length on an integer type",
+			body: `<xs:simpleType name="s"><xs:restriction base="xs:int"><xs:length value="3"/></xs:restriction></xs:simpleType>`,
+			code: "cos-applicable-facets",
+		},
+		{
+			name: "fractionDigits on a string type",
+			body: `<xs:simpleType name="s"><xs:restriction base="xs:string"><xs:fractionDigits value="2"/></xs:restriction></xs:simpleType>`,
+			code: "cos-applicable-facets",
+		},
+		{
+			name: "range facet through a user-defined string type",
+			body: `<xs:simpleType name="code"><xs:restriction base="xs:token"/></xs:simpleType>
+				<xs:simpleType name="s"><xs:restriction base="t:code"><xs:maxInclusive value="9"/></xs:restriction></xs:simpleType>`,
+			code: "cos-applicable-facets",
+		},
+		{
+			name: "length facet on a list type",
+			body: `<xs:simpleType name="ints"><xs:list itemType="xs:int"/></xs:simpleType>
+				<xs:simpleType name="s"><xs:restriction base="t:ints"><xs:maxLength value="3"/></xs:restriction></xs:simpleType>`,
+		},
+		{
+			name: "maxLength larger than the base",
+			body: `<xs:simpleType name="name"><xs:restriction base="xs:string"><xs:maxLength value="5"/></xs:restriction></xs:simpleType>
+				<xs:simpleType name="s"><xs:restriction base="t:name"><xs:maxLength value="10"/></xs:restriction></xs:simpleType>`,
+			code: "maxLength-valid-restriction",
+		},
+		{
+			name: "minLength greater than maxLength",
+			body: `<xs:simpleType name="name"><xs:restriction base="xs:string"><xs:maxLength value="5"/></xs:restriction></xs:simpleType>
+				<xs:simpleType name="s"><xs:restriction base="t:name"><xs:minLength value="6"/></xs:restriction></xs:simpleType>`,
+			code: "minLength-less-than-equal-to-maxLength",
+		},
+		{
+			name: "length differing from the base",
+			body: `<xs:simpleType name="pin"><xs:restriction base="xs:string"><xs:length value="4"/></xs:restriction></xs:simpleType>
+				<xs:simpleType name="s"><xs:restriction base="t:pin"><xs:length value="6"/></xs:restriction></xs:simpleType>`,
+			code: "length-valid-restriction",
+		},
+		{
+			name: "maxInclusive above the base",
+			body: `<xs:simpleType name="percent"><xs:restriction base="xs:int"><xs:maxInclusive value="100"/></xs:restriction></xs:simpleType>
+				<xs:simpleType name="s"><xs:restriction base="t:percent"><xs:maxInclusive value="200"/></xs:restriction></xs:simpleType>`,
+			code: "maxInclusive-valid-restriction",
+		},
+		{
+			name: "maxInclusive above a built-in bound",
+			body: `<xs:simpleType name="s"><xs:restriction base="xs:byte"><xs:maxInclusive value="200"/></xs:restriction></xs:simpleType>`,
+			code: "maxInclusive-valid-restriction",
+		},
+		{
+			name: "minInclusive above maxInclusive",
+			body: `<xs:simpleType name="s"><xs:restriction base="xs:decimal">
+					<xs:minInclusive value="10"/><xs:maxInclusive value="9.5"/>
+				</xs:restriction></xs:simpleType>`,
+			code: "minInclusive-less-than-equal-to-maxInclusive",
+		},
+		{
+			name: "totalDigits larger than the base",
+			body: `<xs:simpleType name="amount"><xs:restriction base="xs:decimal"><xs:totalDigits value="5"/></xs:restriction></xs:simpleType>
+				<xs:simpleType name="s"><xs:restriction base="t:amount"><xs:totalDigits value="8"/></xs:restriction></xs:simpleType>`,
+			code: "totalDigits-valid-restriction",
+		},
+		{
+			name: "fractionDigits larger than totalDigits",
+			body: `<xs:simpleType name="amount"><xs:restriction base="xs:decimal"><xs:totalDigits value="3"/></xs:restriction></xs:simpleType>
+				<xs:simpleType name="s"><xs:restriction base="t:amount"><xs:fractionDigits value="4"/></xs:restriction></xs:simpleType>`,
+			code: "fractionDigits-totalDigits",
+		},
+		{
+			name: "whiteSpace relaxing collapse",
+			body: `<xs:simpleType name="s"><xs:restriction base="xs:token"><xs:whiteSpace value="preserve"/></xs:restriction></xs:simpleType>`,
+			code: "whiteSpace-valid-restriction.1",
+		},
+		{
+			name: "explicitTimezone on a string type",
+			body: `<xs:simpleType name="s"><xs:restriction base="xs:string"><xs:explicitTimezone value="required"/></xs:restriction></xs:simpleType>`,
+			code: "cos-applicable-facets",
+		},
+		{
+			name: "explicitTimezone changing a required timezone",
+			body: `<xs:simpleType name="s"><xs:restriction base="xs:dateTimeStamp"><xs:explicitTimezone value="optional"/></xs:restriction></xs:simpleType>`,
+			code: "explicitTimezone-valid-restriction",
+		},
+		{
+			name: "enumeration value outside the base",
+			body: `<xs:simpleType name="percent"><xs:restriction base="xs:int"><xs:maxInclusive value="100"/></xs:restriction></xs:simpleType>
+				<xs:simpleType name="s"><xs:restriction base="t:percent">
+					<xs:enumeration value="50"/><xs:enumeration value="150"/>
+				</xs:restriction></xs:simpleType>`,
+			code: "enumeration-valid-restriction",
+		},
+		{
+			name: "changed fixed facet",
+			body: `<xs:simpleType name="name"><xs:restriction base="xs:string"><xs:maxLength value="10" fixed="true"/></xs:restriction></xs:simpleType>
+				<xs:simpleType name="s"><xs:restriction base="t:name"><xs:maxLength value="5"/></xs:restriction></xs:simpleType>`,
+			code: "maxLength-valid-restriction",
+		},
+		{
+			name: "changed fixed facet further up the chain",
+			body: `<xs:simpleType name="small"><xs:restriction base="xs:int"><xs:maxInclusive value="10" fixed="1"/></xs:restriction></xs:simpleType>
+				<xs:simpleType name="smaller"><xs:restriction base="t:small"><xs:minInclusive value="0"/></xs:restriction></xs:simpleType>
+				<xs:simpleType name="s"><xs:restriction base="t:smaller"><xs:maxInclusive value="5"/></xs:restriction></xs:simpleType>`,
+			code: "maxInclusive-valid-restriction",
+		},
+		{
+			name: "valid narrowing chain",
+			body: `<xs:simpleType name="percent"><xs:restriction base="xs:int">
+					<xs:minInclusive value="0"/><xs:maxInclusive value="100"/>
+				</xs:restriction></xs:simpleType>
+				<xs:simpleType name="s"><xs:restriction base="t:percent">
+					<xs:minExclusive value="0"/><xs:maxInclusive value="50"/><xs:enumeration value="25"/>
+				</xs:restriction></xs:simpleType>
+				<xs:simpleType name="name"><xs:restriction base="xs:string"><xs:maxLength value="10"/></xs:restriction></xs:simpleType>
+				<xs:simpleType name="shortName"><xs:restriction base="t:name">
+					<xs:minLength value="1"/><xs:maxLength value="5"/><xs:whiteSpace value="collapse"/>
+				</xs:restriction></xs:simpleType>
+				<xs:simpleType name="day"><xs:restriction base="xs:date"><xs:explicitTimezone value="optional"/></xs:restriction></xs:simpleType>
+				<xs:simpleType name="localDay"><xs:restriction base="t:day"><xs:explicitTimezone value="prohibited"/></xs:restriction></xs:simpleType>
+				<xs:simpleType name="stamp"><xs:restriction base="xs:dateTimeStamp"><xs:explicitTimezone value="required"/></xs:restriction></xs:simpleType>
+				<xs:simpleType name="code"><xs:restriction base="xs:string"><xs:length value="3" fixed="true"/></xs:restriction></xs:simpleType>
+				<xs:simpleType name="upperCode"><xs:restriction base="t:code"><xs:length value="03"/><xs:pattern value="[A-Z]*"/></xs:restriction></xs:simpleType>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseTestSchema(t, `<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"
+           xmlns:t="http://example.com/facets" targetNamespace="http://example.com/facets">
+	`+tt.body+`
+</xs:schema>`)
+			if tt.code == "" {
+				if err != nil {
+					t.Errorf("Expected schema to compile, got %v", err)
+				}
+				return
+			}
+			var schemaErr *SchemaError
+			if !errors.As(err, &schemaErr) || !strings.Contains(err.Error(), tt.code+":") {
+				t.Errorf("Expected %s error, got %v", tt.code, err)
+			} else if schemaErr.Line == 0 {
+				t.Errorf("Expected %s error to have a location, got %+v", tt.code, schemaErr)
+			}
+		})
+	}
+}
+
+func TestInheritedFacets(t *testing.T) {
+	schema, err := parseTestSchema(t, `<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"
+           xmlns:t="http://example.com/facets" targetNamespace="http://example.com/facets"
+           elementFormDefault="qualified">
+	<xs:simpleType name="percent">
+		<xs:restriction base="xs:int">
+			<xs:minInclusive value="0"/>
+			<xs:maxInclusive value="100"/>
+		</xs:restriction>
+	</xs:simpleType>
+	<xs:simpleType name="smallPercent">
+		<xs:restriction base="t:percent">
+			<xs:maxExclusive value="20"/>
+		</xs:restriction>
+	</xs:simpleType>
+	<xs:simpleType name="code">
+		<xs:restriction base="xs:string">
+			<xs:pattern value="[A-Z]+"/>
+			<xs:pattern value="[0-9]+"/>
+		</xs:restriction>
+	</xs:simpleType>
+	<xs:simpleType name="shortCode">
+		<xs:restriction base="t:code">
+			<xs:maxLength value="3"/>
+		</xs:restriction>
+	</xs:simpleType>
+
+	<xs:simpleType name="numbers">
+		<xs:list itemType="xs:int"/>
+	</xs:simpleType>
+	<xs:simpleType name="pair">
+		<xs:restriction base="t:numbers">
+			<xs:maxLength value="2"/>
+		</xs:restriction>
+	</xs:simpleType>
+	<xs:simpleType name="fewTokens">
+		<xs:restriction base="xs:NMTOKENS">
+			<xs:maxLength value="2"/>
+		</xs:restriction>
+	</xs:simpleType>
+
+	<xs:element name="root">
+		<xs:complexType>
+			<xs:sequence>
+				<xs:element name="p" type="t:smallPercent" minOccurs="0"/>
+				<xs:element name="c" type="t:shortCode" minOccurs="0"/>
+			</xs:sequence>
+			<xs:attribute name="p" type="t:smallPercent"/>
+			<xs:attribute name="l" type="t:pair"/>
+			<xs:attribute name="n" type="t:fewTokens"/>
+		</xs:complexType>
+	</xs:element>
+</xs:schema>`)
+	if err != nil {
+		t.Fatalf("Failed to parse schema: %v", err)
+	}
+
+	smallPercent := schema.TypeDefs[QName{Namespace: "http://example.com/facets", Local: "smallPercent"}].(*SimpleType)
+	facets, builtin, ok := schema.EffectiveFacets(smallPercent)
+	if !ok || builtin.Local != "int" || len(facets) != 3 {
+		t.Errorf("Expected three facets inherited down to xs:int, got %v %v %v", facets, builtin, ok)
+	}
+
+	tests := []struct {
+		name  string
+		body  string
+		attr  string
+		attrs string // Other attributes
+		valid bool
+	}{
+		{name: "value within the chain", body: `<p>9</p><c>AB</c>`, attr: "19", valid: true},
+		{name: "numeric comparison through the chain", body: `<p>5</p>`, valid: true},
+		{name: "inherited maximum", body: `<p>150</p>`},
+		{name: "inherited minimum", body: `<p>-1</p>`},
+		{name: "own maximum", body: `<p>20</p>`},
+		{name: "primitive lexical space", body: `<p>ten</p>`},
+		{name: "either pattern of one step", body: `<c>123</c>`, valid: true},
+		{name: "inherited pattern", body: `<c>ab</c>`},
+		{name: "alternatives anchored as a whole", body: `<c>AB1</c>`},
+		{name: "own maxLength", body: `<c>ABCD</c>`},
+		{name: "inherited maximum on an attribute", attr: "100"},
+		{name: "primitive lexical space on an attribute", attr: "x"},
+		{name: "list items counted on an attribute", attrs: ` l="1 2" n="a b"`, valid: true},
+		{name: "list restriction on an attribute", attrs: ` l="1 2 3"`},
+		{name: "built-in list restriction on an attribute", attrs: ` n="a b c"`},
+		{name: "list item type on an attribute", attrs: ` l="1 x"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attr := ""
+			if tt.attr != "" {
+				attr = ` p="` + tt.attr + `"`
+			}
+			attr += tt.attrs
+			doc, err := xmldom.Decode(strings.NewReader(
+				`<root xmlns="http://example.com/facets"` + attr + `>` + tt.body + `</root>`))
+			if err != nil {
+				t.Fatalf("Failed to parse instance: %v", err)
+			}
+
+			violations := NewValidator(schema).Validate(doc)
+			if tt.valid && len(violations) > 0 {
+				t.Errorf("Expected valid instance, got %v", violations)
+			} else if !tt.valid && len(violations) == 0 {
+				t.Error("Expected violations, got none")
+			}
+		})
+	}
+}
@@ -2,10 +2,11 @@ package xsd
 
 import (
 	"fmt"
-	"math/big"
 	"regexp"
 	"strconv"
 	"strings"
+
+	"github.com/agentflare-ai/go-xmldom"
 )
 
 // FacetValidator validates a value against a facet constraint
@@ -28,7 +29,7 @@ func (f *PatternFacet) Validate(value string, baseType Type) error {
 	if f.regex == nil {
 		// Compile XSD regex pattern to Go regex
 		// XSD patterns are anchored by default
-		pattern := "^" + convertXSDRegex(f.Pattern) + "$"
+		pattern := "^(?:" + convertXSDRegex(f.Pattern) + ")$"
 		var err error
 		f.regex, err = regexp.Compile(pattern)
 		if err != nil {
@@ -63,6 +64,9 @@ func convertXSDRegex(pattern string) string {
 // EnumerationFacet validates against a set of allowed values
 type EnumerationFacet struct {
 	Values []string
+
+	// Schema elements declaring each value, which resolve QName values
+	contexts []xmldom.Element
 }
 
 func (f *EnumerationFacet) Name() string {
@@ -75,9 +79,44 @@ func (f *EnumerationFacet) Validate(value string, baseType Type) error {
 			return nil
 		}
 	}
+
+	// Values of other types than strings can be equal with different
+	// lexical forms, such as 1.0 and 1 or true and 1
+	if baseType != nil {
+		switch primitive := primitiveType(baseType.Name().Local); primitive {
+		case "", "string", "anyURI", "QName":
+		case "NOTATION":
+			// Compared as QNames by checkNotationValue, which has the
+			// instance element whose namespaces the value resolves against
+			return nil
+		default:
+			if v, err := parseBuiltinValue(value, primitive, nil); err == nil {
+				for _, allowed := range f.Values {
+					if a, err := parseBuiltinValue(allowed, primitive, nil); err == nil && Equal(v, a) {
+						return nil
+					}
+				}
+			}
+		}
+	}
 	return fmt.Errorf("value '%s' is not in enumeration %v", value, f.Values)
 }
 
+// containsQName reports whether an enumeration value resolves, against the
+// namespaces of the schema element declaring it, to the given QName
+func (f *EnumerationFacet) containsQName(name QName) bool {
+	for i, allowed := range f.Values {
+		var context xmldom.Element
+		if i < len(f.contexts) {
+			context = f.contexts[i]
+		}
+		if resolved, err := resolveQNameValue(strings.TrimSpace(allowed), context); err == nil && resolved == name {
+			return true
+		}
+	}
+	return false
+}
+
 // LengthFacet validates exact length
 type LengthFacet struct {
 	Value int
@@ -131,21 +170,24 @@ func (f *MaxLengthFacet) Validate(value string, baseType Type) error {
 
 // getLength returns the length of a value based on its type
 func getLength(value string, baseType Type) int {
+	var typeName string
+	if baseType != nil {
+		typeName = baseType.Name().Local
+	}
+
 	// For list types, length is number of items
-	if strings.Contains(value, " ") {
-		// Check if it's a SimpleType with a List component
-		if st, ok := baseType.(*SimpleType); ok && st.List != nil {
-			return len(strings.Fields(value))
-		}
+	if st, ok := baseType.(*SimpleType); ok && (st.List != nil ||
+		st.QName.Namespace == XSDNamespace && builtinListTypes[typeName]) {
+		return len(strings.Fields(value))
 	}
-	
+
 	// For hexBinary, length is number of octets (bytes)
-	if IsBuiltinType("hexBinary") {
+	if builtinDerivesFrom(typeName, "hexBinary") {
 		return len(value) / 2
 	}
 	
 	// For base64Binary, we need to decode to get actual byte length
-	if IsBuiltinType("base64Binary") {
+	if builtinDerivesFrom(typeName, "base64Binary") {
 		// Approximate - not exact but good enough for validation
 		n := len(value)
 		// Remove padding
@@ -308,6 +350,30 @@ func (f *WhiteSpaceFacet) Validate(value string, baseType Type) error {
 	return nil
 }
 
+// ExplicitTimezoneFacet requires or prohibits a timezone in date and time
+// values (XSD 1.1)
+type ExplicitTimezoneFacet struct {
+	Value string // "required", "prohibited", or "optional"
+}
+
+func (f *ExplicitTimezoneFacet) Name() string {
+	return "explicitTimezone"
+}
+
+func (f *ExplicitTimezoneFacet) Validate(value string, baseType Type) error {
+	hasTimezone := timezoneSuffix.MatchString(value)
+	switch {
+	case f.Value == "required" && !hasTimezone:
+		return fmt.Errorf("value '%s' must have a timezone", value)
+	case f.Value == "prohibited" && hasTimezone:
+		return fmt.Errorf("value '%s' must not have a timezone", value)
+	}
+	return nil
+}
+
+// timezoneSuffix matches the timezone of a date or time lexical value
+var timezoneSuffix = regexp.MustCompile(`(Z|[+-]\d{2}:\d{2})$`)
+
 // NormalizeWhiteSpace normalizes whitespace according to the facet value
 func NormalizeWhiteSpace(value string, whiteSpace string) string {
 	switch whiteSpace {
@@ -330,81 +396,33 @@ func NormalizeWhiteSpace(value string, whiteSpace string) string {
 	}
 }
 
-// compareValues compares two values based on their type
+// compareValues compares two values based on their type. Values of the
+// ordered primitive types are compared in their value space, so that 1.0
+// equals 1 and 2024-01-01T12:00:00Z equals 2024-01-01T13:00:00+01:00;
+// other values are compared as strings.
 func compareValues(v1, v2 string, baseType Type) (int, error) {
-	// Get the base type name for comparison
 	typeName := ""
 	if baseType != nil {
 		typeName = baseType.Name().Local
 	}
-	
-	// Numeric comparisons
-	if isNumericType(typeName) {
-		// Use big.Float for arbitrary precision
-		f1 := new(big.Float)
-		f2 := new(big.Float)
-		
-		if _, _, err := f1.Parse(v1, 10); err != nil {
-			// Try parsing as integer
-			i1 := new(big.Int)
-			if _, ok := i1.SetString(v1, 10); !ok {
-				return 0, fmt.Errorf("invalid numeric value: %s", v1)
-			}
-			f1.SetInt(i1)
+
+	if primitive := primitiveType(typeName); orderedPrimitives[primitive] {
+		a, err := parseBuiltinValue(v1, primitive, nil)
+		if err != nil {
+			return 0, err
 		}
-		
-		if _, _, err := f2.Parse(v2, 10); err != nil {
-			// Try parsing as integer
-			i2 := new(big.Int)
-			if _, ok := i2.SetString(v2, 10); !ok {
-				return 0, fmt.Errorf("invalid numeric value: %s", v2)
-			}
-			f2.SetInt(i2)
+		b, err := parseBuiltinValue(v2, primitive, nil)
+		if err != nil {
+			return 0, err
 		}
-		
-		return f1.Cmp(f2), nil
-	}
-	
-	// Date/time comparisons
-	if isDateTimeType(typeName) {
-		// For simplicity, use string comparison (not fully correct for all cases)
-		// A complete implementation would parse and compare as time values
-		return strings.Compare(v1, v2), nil
-	}
-	
-	// String comparison as fallback
-	return strings.Compare(v1, v2), nil
-}
-
-func isNumericType(typeName string) bool {
-	numericTypes := []string{
-		"decimal", "integer", "float", "double",
-		"nonPositiveInteger", "negativeInteger",
-		"long", "int", "short", "byte",
-		"nonNegativeInteger", "positiveInteger",
-		"unsignedLong", "unsignedInt", "unsignedShort", "unsignedByte",
-	}
-	
-	for _, t := range numericTypes {
-		if typeName == t {
-			return true
+		cmp, ok := Compare(a, b)
+		if !ok {
+			return 0, fmt.Errorf("values %s and %s are incomparable", v1, v2)
 		}
+		return cmp, nil
 	}
-	return false
-}
 
-func isDateTimeType(typeName string) bool {
-	dateTimeTypes := []string{
-		"dateTime", "date", "time",
-		"gYear", "gYearMonth", "gMonth", "gMonthDay", "gDay",
-	}
-	
-	for _, t := range dateTimeTypes {
-		if typeName == t {
-			return true
-		}
-	}
-	return false
+	return strings.Compare(v1, v2), nil
 }
 
 // ParseFacet parses a facet element and returns the appropriate FacetValidator
@@ -444,6 +462,11 @@ func ParseFacet(name string, value string) FacetValidator {
 		}
 	case "whiteSpace":
 		return &WhiteSpaceFacet{Value: value}
+	case "explicitTimezone":
+		switch value {
+		case "required", "prohibited", "optional":
+			return &ExplicitTimezoneFacet{Value: value}
+		}
 	}
 	return nil
 }
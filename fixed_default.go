@@ -45,8 +45,10 @@ func ValidateElementFixedDefault(elem xmldom.Element, decl *ElementDecl) []Viola
 		return violations
 	}
 
-	// Get element content
-	content := strings.TrimSpace(string(elem.TextContent()))
+	// Get element content; the fixed value is compared after whitespace
+	// processing of both
+	content := NormalizeValue(string(elem.TextContent()), decl.Type)
+	fixed := NormalizeValue(decl.Fixed, decl.Type)
 
 	// Check fixed value
 	if decl.Fixed != "" {
@@ -62,7 +64,7 @@ func ValidateElementFixedDefault(elem xmldom.Element, decl *ElementDecl) []Viola
 
 		if !hasChildElements {
 			// Only validate fixed value for simple content
-			if violation := ValidateFixedValue(content, decl.Fixed, true, decl.Name.Local); violation != nil {
+			if violation := ValidateFixedValue(content, fixed, true, decl.Name.Local); violation != nil {
 				violation.Element = elem
 				violations = append(violations, *violation)
 			}
@@ -100,7 +102,7 @@ func ValidateAttributeFixedDefault(attr xmldom.Node, decl *AttributeDecl, elem x
 			value = decl.Fixed
 		}
 
-		if value != decl.Fixed {
+		if NormalizeValue(value, decl.Type) != NormalizeValue(decl.Fixed, decl.Type) {
 			violation := &Violation{
 				Element: elem,
 				Code:    "cvc-attribute.4",
@@ -11,7 +11,7 @@ func TestElementFixedValues(t *testing.T) {
 	// Schema with fixed element values
 	schemaXML := `<?xml version="1.0" encoding="UTF-8"?>
 	<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema" 
-	           targetNamespace="http://example.com" 
+	           targetNamespace="http://example.com" elementFormDefault="qualified" 
 	           xmlns:ex="http://example.com">
 		<xs:element name="config">
 			<xs:complexType>
@@ -117,7 +117,7 @@ func TestElementDefaultValues(t *testing.T) {
 	// Schema with default element values
 	schemaXML := `<?xml version="1.0" encoding="UTF-8"?>
 	<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema" 
-	           targetNamespace="http://example.com" 
+	           targetNamespace="http://example.com" elementFormDefault="qualified" 
 	           xmlns:ex="http://example.com">
 		<xs:element name="settings">
 			<xs:complexType>
@@ -215,7 +215,7 @@ func TestAttributeFixedValues(t *testing.T) {
 	// Schema with fixed attribute values
 	schemaXML := `<?xml version="1.0" encoding="UTF-8"?>
 	<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema" 
-	           targetNamespace="http://example.com" 
+	           targetNamespace="http://example.com" elementFormDefault="qualified" 
 	           xmlns:ex="http://example.com">
 		<xs:element name="product">
 			<xs:complexType>
@@ -317,7 +317,7 @@ func TestAttributeDefaultValues(t *testing.T) {
 	// Schema with default attribute values
 	schemaXML := `<?xml version="1.0" encoding="UTF-8"?>
 	<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema" 
-	           targetNamespace="http://example.com" 
+	           targetNamespace="http://example.com" elementFormDefault="qualified" 
 	           xmlns:ex="http://example.com">
 		<xs:element name="item">
 			<xs:complexType>
@@ -411,7 +411,7 @@ func TestFixedAndDefaultCombination(t *testing.T) {
 	// Schema with both fixed and default values
 	schemaXML := `<?xml version="1.0" encoding="UTF-8"?>
 	<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema" 
-	           targetNamespace="http://example.com" 
+	           targetNamespace="http://example.com" elementFormDefault="qualified" 
 	           xmlns:ex="http://example.com">
 		<xs:element name="system">
 			<xs:complexType>
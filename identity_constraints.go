@@ -2,6 +2,7 @@ package xsd
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/agentflare-ai/go-xmldom"
@@ -23,295 +24,597 @@ type IdentityConstraint struct {
 	Selector *Selector
 	Fields   []*Field
 	Refer    QName // For keyref, refers to a key or unique constraint
+
+	namespace string // Target namespace of the schema document declaring it
 }
 
 // Selector represents the xs:selector element
 type Selector struct {
-	XPath string // XPath expression to select nodes
+	XPath  string // XPath expression to select nodes
+	source xmldom.Element
+	expr   *xpathExpression
+	err    error // Why XPath could not be compiled
 }
 
 // Field represents the xs:field element
 type Field struct {
-	XPath string // XPath expression to select field value
+	XPath  string // XPath expression to select field value
+	source xmldom.Element
+	expr   *xpathExpression
+	err    error // Why XPath could not be compiled
+}
+
+// compiled returns the compiled selector. Selectors built by hand rather
+// than parsed from a schema are compiled on first use, with no prefixes in
+// scope.
+func (s *Selector) compiled() (*xpathExpression, error) {
+	if s.expr == nil && s.err == nil {
+		s.expr, s.err = compileIdentityXPath(s.XPath, xpathNamespaces{}, false)
+	}
+	return s.expr, s.err
+}
+
+// compiled returns the compiled field, like Selector.compiled
+func (f *Field) compiled() (*xpathExpression, error) {
+	if f.expr == nil && f.err == nil {
+		f.expr, f.err = compileIdentityXPath(f.XPath, xpathNamespaces{}, true)
+	}
+	return f.expr, f.err
 }
 
 // IdentityConstraintValidator validates identity constraints in XML documents
 type IdentityConstraintValidator struct {
-	constraints map[string]*IdentityConstraint         // Map of constraint name to constraint
-	keyValues   map[string]map[string][]xmldom.Element // constraint name -> concatenated field values -> elements
+	constraints map[string]*IdentityConstraint // Added with AddConstraint, evaluated at the validation root
+
+	// schema resolves the declarations of instance elements, whose
+	// constraints are evaluated within each instance of the declaring
+	// element; only added constraints apply when nil
+	schema *Schema
+	byName map[QName]*IdentityConstraint // Key and unique constraints keyrefs can refer to
+	types  map[xmldom.Element]Type       // Types of the elements visited by the current run
+}
+
+// keySequence is the tuple of field values of a node a constraint selects.
+// Values are in the value space of the types of the fields' declarations,
+// or strings for undeclared elements and attributes.
+type keySequence struct {
+	values  []Value
+	lexical string // Whitespace-processed field values, for messages
+}
+
+// equal reports whether two key-sequences are pairwise Equal
+func (k keySequence) equal(other keySequence) bool {
+	if len(k.values) != len(other.values) {
+		return false
+	}
+	for i := range k.values {
+		if !Equal(k.values[i], other.values[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// hash returns the same string for equal key-sequences
+func (k keySequence) hash() string {
+	keys := make([]string, len(k.values))
+	for i, value := range k.values {
+		keys[i] = valueKey(value)
+	}
+	return strings.Join(keys, "\x00")
+}
+
+// nodeTable maps the key-sequences of a key or unique constraint to the
+// node that has each of them, bucketed by hash
+type nodeTable map[string][]nodeTableEntry
+
+type nodeTableEntry struct {
+	key  keySequence
+	node xmldom.Element
+}
+
+// lookup returns the node with a key-sequence equal to key
+func (t nodeTable) lookup(key keySequence) (xmldom.Element, bool) {
+	for _, entry := range t[key.hash()] {
+		if entry.key.equal(key) {
+			return entry.node, true
+		}
+	}
+	return nil, false
+}
+
+// add adds a node with a key-sequence not in the table
+func (t nodeTable) add(key keySequence, node xmldom.Element) {
+	hash := key.hash()
+	t[hash] = append(t[hash], nodeTableEntry{key: key, node: node})
+}
+
+// remove removes the entry with a key-sequence equal to key
+func (t nodeTable) remove(key keySequence) {
+	hash := key.hash()
+	for i, entry := range t[hash] {
+		if entry.key.equal(key) {
+			t[hash] = append(t[hash][:i:i], t[hash][i+1:]...)
+			return
+		}
+	}
 }
 
 // NewIdentityConstraintValidator creates a new identity constraint validator
 func NewIdentityConstraintValidator() *IdentityConstraintValidator {
 	return &IdentityConstraintValidator{
 		constraints: make(map[string]*IdentityConstraint),
-		keyValues:   make(map[string]map[string][]xmldom.Element),
 	}
 }
 
-// AddConstraint adds an identity constraint to the validator
+// AddConstraint adds an identity constraint to the validator. Added
+// constraints are evaluated within the element passed to ValidateElement.
 func (v *IdentityConstraintValidator) AddConstraint(constraint *IdentityConstraint) {
 	v.constraints[constraint.Name] = constraint
-	v.keyValues[constraint.Name] = make(map[string][]xmldom.Element)
 }
 
 // Validate validates all identity constraints in the document
 func (v *IdentityConstraintValidator) Validate(doc xmldom.Document) []Violation {
-	violations := []Violation{}
+	return v.ValidateElement(doc.DocumentElement())
+}
 
-	// First pass: collect all key values
-	for name, constraint := range v.constraints {
-		if constraint.Kind == KeyConstraint || constraint.Kind == UniqueConstraint {
-			selectedNodes := v.evaluateSelector(doc, constraint.Selector)
+// ValidateElement validates all identity constraints within the subtree
+// rooted at root. The constraints of an element declaration are evaluated
+// within every instance of the element, with the node tables of key and
+// unique constraints propagated to ancestors so that keyrefs can refer to
+// keys declared on descendants.
+func (v *IdentityConstraintValidator) ValidateElement(root xmldom.Element) []Violation {
+	violations := []Violation{}
+	if root == nil {
+		return violations
+	}
 
-			for _, node := range selectedNodes {
-				fieldValues := v.extractFieldValues(node, constraint.Fields)
-				if len(fieldValues) == 0 {
-					continue // Skip if no field values found
-				}
+	var added []*IdentityConstraint
+	for _, constraint := range v.constraints {
+		added = append(added, constraint)
+	}
+	// Key and unique constraints first, so that keyrefs see their tables
+	sort.SliceStable(added, func(i, j int) bool {
+		return added[i].Kind != KeyRefConstraint && added[j].Kind == KeyRefConstraint ||
+			added[i].Kind == added[j].Kind && added[i].Name < added[j].Name
+	})
+
+	env := &xpathEnv{root: root}
+	v.types = make(map[xmldom.Element]Type)
+	v.evaluateScopes(env, root, nil, added, &violations)
+	v.types = nil
+	return violations
+}
 
-				// Concatenate field values to create a unique key
-				keyValue := strings.Join(fieldValues, "|")
-
-				// Check for duplicates
-				if existingNodes, exists := v.keyValues[name][keyValue]; exists {
-					// For key and unique, duplicates are not allowed
-					violations = append(violations, Violation{
-						Element: node,
-						Code:    "cvc-identity-constraint.4.1",
-						Message: fmt.Sprintf("Duplicate %s constraint '%s' value: %s",
-							constraint.Kind, name, keyValue),
-					})
-					// Still add it to track all duplicates
-					v.keyValues[name][keyValue] = append(existingNodes, node)
-				} else {
-					v.keyValues[name][keyValue] = []xmldom.Element{node}
-				}
+// evaluateScopes evaluates the constraints declared for elem and its
+// descendants, and returns the node tables of elem: its own key and unique
+// constraints, and those propagated from its children. Key-sequences two
+// children propagate for different nodes are dropped, and elem's own
+// entries take precedence over propagated ones.
+func (v *IdentityConstraintValidator) evaluateScopes(env *xpathEnv, elem xmldom.Element, parentType Type,
+	constraints []*IdentityConstraint, violations *[]Violation) map[*IdentityConstraint]nodeTable {
+	var t Type
+	if v.schema != nil {
+		if decl := v.schema.childDeclaration(parentType, elem); decl != nil {
+			t, _ = v.schema.elementType(elem, decl)
+			constraints = append(constraints, decl.Constraints...)
+		}
+		v.types[elem] = t
+	}
 
-				// For key constraints, all fields must be non-null
-				if constraint.Kind == KeyConstraint {
-					for i, fieldValue := range fieldValues {
-						if fieldValue == "" {
-							violations = append(violations, Violation{
-								Element: node,
-								Code:    "cvc-identity-constraint.4.2.2",
-								Message: fmt.Sprintf("Key constraint '%s' field %d cannot be null",
-									name, i+1),
-							})
+	tables := make(map[*IdentityConstraint]nodeTable)
+	conflicts := make(map[*IdentityConstraint]nodeTable)
+	children := elem.Children()
+	for i := uint(0); i < children.Length(); i++ {
+		child := children.Item(i)
+		if child == nil {
+			continue
+		}
+		for constraint, table := range v.evaluateScopes(env, child, t, nil, violations) {
+			if tables[constraint] == nil {
+				tables[constraint] = make(nodeTable)
+				conflicts[constraint] = make(nodeTable)
+			}
+			for _, bucket := range table {
+				for _, entry := range bucket {
+					if _, conflicting := conflicts[constraint].lookup(entry.key); conflicting {
+						continue
+					}
+					if existing, ok := tables[constraint].lookup(entry.key); ok {
+						if existing != entry.node {
+							tables[constraint].remove(entry.key)
+							conflicts[constraint].add(entry.key, nil)
 						}
+						continue
 					}
+					tables[constraint].add(entry.key, entry.node)
 				}
 			}
 		}
 	}
 
-	// Second pass: validate keyrefs
-	for name, constraint := range v.constraints {
+	for _, constraint := range constraints {
 		if constraint.Kind == KeyRefConstraint {
-			selectedNodes := v.evaluateSelector(doc, constraint.Selector)
-
-			// Find the referenced key/unique constraint
-			referencedConstraint, exists := v.constraints[constraint.Refer.Local]
-			if !exists {
-				violations = append(violations, Violation{
-					Code: "src-identity-constraint.2.2.2",
-					Message: fmt.Sprintf("Keyref '%s' refers to unknown constraint '%s'",
-						name, constraint.Refer.Local),
+			continue
+		}
+		own := v.ownTable(env, elem, constraint, violations)
+		for _, bucket := range tables[constraint] {
+			for _, entry := range bucket {
+				if _, ok := own.lookup(entry.key); !ok {
+					own.add(entry.key, entry.node)
+				}
+			}
+		}
+		tables[constraint] = own
+	}
+	for _, constraint := range constraints {
+		if constraint.Kind == KeyRefConstraint {
+			v.checkKeyRef(env, elem, constraint, tables, violations)
+		}
+	}
+	return tables
+}
+
+// ownTable evaluates a key or unique constraint within an instance of its
+// declaring element, reporting duplicate key-sequences and, for keys,
+// selected nodes without a value for every field
+func (v *IdentityConstraintValidator) ownTable(env *xpathEnv, scope xmldom.Element, constraint *IdentityConstraint,
+	violations *[]Violation) nodeTable {
+	table := make(nodeTable)
+	for _, node := range v.evaluateSelector(env, scope, constraint.Selector) {
+		key, missing := v.keySequence(env, node, constraint, violations)
+		if missing > 0 {
+			if constraint.Kind == KeyConstraint {
+				*violations = append(*violations, Violation{
+					Element: node,
+					Code:    "cvc-identity-constraint.4.2.1",
+					Message: fmt.Sprintf("Key constraint '%s' field %d cannot be null", constraint.Name, missing),
+					Related: scope,
 				})
-				continue
 			}
+			continue
+		}
 
-			for _, node := range selectedNodes {
-				fieldValues := v.extractFieldValues(node, constraint.Fields)
-				if len(fieldValues) == 0 {
-					continue
-				}
+		if first, exists := table.lookup(key); exists {
+			code := "cvc-identity-constraint.4.1"
+			if constraint.Kind == KeyConstraint {
+				code = "cvc-identity-constraint.4.2.2"
+			}
+			line, column, _ := first.Position()
+			*violations = append(*violations, Violation{
+				Element: node,
+				Code:    code,
+				Message: fmt.Sprintf("Duplicate %s constraint '%s' value: %s (first at line %d, column %d)",
+					constraint.Kind, constraint.Name, key.lexical, line, column),
+				Actual:  key.lexical,
+				Related: first,
+			})
+			continue
+		}
+		table.add(key, node)
+	}
+	return table
+}
 
-				keyValue := strings.Join(fieldValues, "|")
+// checkKeyRef reports the nodes a keyref selects within an instance of its
+// declaring element whose key-sequence is not in the node table of the key
+// or unique constraint it refers to
+func (v *IdentityConstraintValidator) checkKeyRef(env *xpathEnv, scope xmldom.Element, keyref *IdentityConstraint,
+	tables map[*IdentityConstraint]nodeTable, violations *[]Violation) {
+	referenced := v.referencedConstraint(keyref)
+	if referenced == nil {
+		*violations = append(*violations, Violation{
+			Element: scope,
+			Code:    "src-identity-constraint.2.2.2",
+			Message: fmt.Sprintf("Keyref '%s' refers to unknown constraint '%s'",
+				keyref.Name, keyref.Refer.Local),
+		})
+		return
+	}
 
-				// Check if this keyref value exists in the referenced constraint
-				if _, exists := v.keyValues[constraint.Refer.Local][keyValue]; !exists {
-					violations = append(violations, Violation{
-						Element: node,
-						Code:    "cvc-identity-constraint.4.3",
-						Message: fmt.Sprintf("Keyref '%s' value '%s' does not match any %s '%s'",
-							name, keyValue, referencedConstraint.Kind, constraint.Refer.Local),
-					})
-				}
-			}
+	for _, node := range v.evaluateSelector(env, scope, keyref.Selector) {
+		key, missing := v.keySequence(env, node, keyref, violations)
+		if missing > 0 {
+			continue
+		}
+		if _, exists := tables[referenced].lookup(key); !exists {
+			line, column, _ := scope.Position()
+			*violations = append(*violations, Violation{
+				Element: node,
+				Code:    "cvc-identity-constraint.4.3",
+				Message: fmt.Sprintf("Keyref '%s' value '%s' does not match any %s '%s' within '%s' at line %d, column %d",
+					keyref.Name, key.lexical, referenced.Kind, referenced.Name, scope.LocalName(), line, column),
+				Actual:  key.lexical,
+				Related: scope,
+			})
 		}
 	}
+}
 
-	return violations
+// referencedConstraint returns the key or unique constraint a keyref
+// refers to, or nil when there is none
+func (v *IdentityConstraintValidator) referencedConstraint(keyref *IdentityConstraint) *IdentityConstraint {
+	if v.schema != nil {
+		if v.byName == nil {
+			v.byName = v.schema.identityConstraints()
+		}
+		if constraint, ok := v.byName[keyref.Refer]; ok {
+			return constraint
+		}
+	}
+	if constraint, ok := v.constraints[keyref.Refer.Local]; ok && constraint.Kind != KeyRefConstraint {
+		return constraint
+	}
+	return nil
 }
 
-// evaluateSelector evaluates the selector XPath to find matching nodes
-func (v *IdentityConstraintValidator) evaluateSelector(doc xmldom.Document, selector *Selector) []xmldom.Element {
+// evaluateSelector returns the elements a constraint's selector selects
+// from an instance of its declaring element, in document order
+func (v *IdentityConstraintValidator) evaluateSelector(env *xpathEnv, scope xmldom.Element, selector *Selector) []xmldom.Element {
 	if selector == nil || selector.XPath == "" {
 		return nil
 	}
-
-	// Simplified XPath evaluation for common patterns
-	// This handles basic paths like "employee", ".//employee", "department/employee"
-	return v.evaluateSimpleXPath(doc.DocumentElement(), selector.XPath)
+	expr, err := selector.compiled()
+	if err != nil {
+		return nil
+	}
+	result, err := expr.evaluate(env, scope, nil)
+	if err != nil {
+		return nil
+	}
+	var elements []xmldom.Element
+	for _, item := range result {
+		if elem, ok := item.(xmldom.Element); ok {
+			elements = append(elements, elem)
+		}
+	}
+	return elements
 }
 
-// extractFieldValues extracts field values from a node using field XPaths
-func (v *IdentityConstraintValidator) extractFieldValues(node xmldom.Element, fields []*Field) []string {
-	values := make([]string, 0, len(fields))
+// keySequence evaluates the fields of a constraint on a selected node. It
+// returns the 1-based index of the first field that selects no node when
+// the node is not qualified; fields that select more than one node or an
+// element with complex content are reported and count as selecting none.
+func (v *IdentityConstraintValidator) keySequence(env *xpathEnv, node xmldom.Element, constraint *IdentityConstraint,
+	violations *[]Violation) (keySequence, int) {
+	key := keySequence{values: make([]Value, 0, len(constraint.Fields))}
+	lexicals := make([]string, 0, len(constraint.Fields))
+	for i, field := range constraint.Fields {
+		nodes := v.evaluateField(env, node, field)
+		if len(nodes) > 1 {
+			*violations = append(*violations, Violation{
+				Element: node,
+				Code:    "cvc-identity-constraint.3",
+				Message: fmt.Sprintf("Field '%s' of %s constraint '%s' selects %d nodes, at most one is allowed",
+					field.XPath, constraint.Kind, constraint.Name, len(nodes)),
+			})
+		}
+		if len(nodes) != 1 {
+			return keySequence{}, i + 1
+		}
 
-	for _, field := range fields {
-		value := v.evaluateFieldXPath(node, field.XPath)
-		values = append(values, value)
+		value, lexical, err := v.fieldValue(nodes[0])
+		if err != nil {
+			*violations = append(*violations, Violation{
+				Element: node,
+				Code:    "cvc-identity-constraint.3",
+				Message: fmt.Sprintf("Field '%s' of %s constraint '%s' selects %v",
+					field.XPath, constraint.Kind, constraint.Name, err),
+			})
+			return keySequence{}, i + 1
+		}
+		key.values = append(key.values, value)
+		lexicals = append(lexicals, lexical)
 	}
-
-	return values
+	key.lexical = strings.Join(lexicals, "|")
+	return key, 0
 }
 
-// evaluateSimpleXPath evaluates a simplified XPath expression
-func (v *IdentityConstraintValidator) evaluateSimpleXPath(root xmldom.Element, xpath string) []xmldom.Element {
-	results := []xmldom.Element{}
-
-	// Remove leading/trailing whitespace
-	xpath = strings.TrimSpace(xpath)
-
-	// Remove namespace prefixes from the XPath for simplicity
-	// Convert "ex:element" to "element"
-	xpath = removeNamespacePrefixes(xpath)
-
-	// Handle absolute paths (starting with /)
-	// For now, treat absolute paths as starting from root
-	xpath = strings.TrimPrefix(xpath, "/")
-
-	// Handle descendant-or-self axis (.// or //)
-	searchDescendants := false
-	if strings.HasPrefix(xpath, ".//") {
-		searchDescendants = true
-		xpath = strings.TrimPrefix(xpath, ".//")
-	} else if strings.HasPrefix(xpath, "//") {
-		searchDescendants = true
-		xpath = strings.TrimPrefix(xpath, "//")
+// evaluateField returns the elements and attributes a field selects from a
+// selected node
+func (v *IdentityConstraintValidator) evaluateField(env *xpathEnv, node xmldom.Element, field *Field) []xmldom.Node {
+	expr, err := field.compiled()
+	if err != nil {
+		return nil
 	}
-
-	// Split path into steps
-	steps := strings.Split(xpath, "/")
-
-	if searchDescendants {
-		// Search all descendants
-		v.findMatchingDescendants(root, steps[0], &results)
-
-		// For multi-step paths after //, continue from each result
-		if len(steps) > 1 {
-			newResults := []xmldom.Element{}
-			for _, elem := range results {
-				v.findMatchingChildren(elem, steps[1:], &newResults)
-			}
-			results = newResults
+	result, err := expr.evaluate(env, node, nil)
+	if err != nil {
+		return nil
+	}
+	var nodes []xmldom.Node
+	for _, item := range result {
+		if n, ok := item.(xmldom.Node); ok {
+			nodes = append(nodes, n)
 		}
-	} else {
-		// Direct path navigation
-		v.findMatchingChildren(root, steps, &results)
 	}
-
-	return results
+	return nodes
 }
 
-// findMatchingChildren finds child elements matching the path steps
-func (v *IdentityConstraintValidator) findMatchingChildren(elem xmldom.Element, steps []string, results *[]xmldom.Element) {
-	if len(steps) == 0 {
-		*results = append(*results, elem)
-		return
+// fieldValue returns the value of a node a field selects in the value space
+// of the type of its element or attribute declaration, and its
+// whitespace-processed lexical form. Values of undeclared nodes, and values
+// that are not valid for their type, which the validator reports
+// separately, are compared as strings. Elements must have a simple type or
+// simple content.
+func (v *IdentityConstraintValidator) fieldValue(node xmldom.Node) (Value, string, error) {
+	var elem xmldom.Element
+	var attrName, lexical string
+	switch n := node.(type) {
+	case xmldom.Attr:
+		elem, attrName, lexical = n.OwnerElement(), string(n.NodeName()), string(n.NodeValue())
+	case xmldom.Element:
+		elem, lexical = n, getElementTextContent(n)
+	default:
+		return nil, "", fmt.Errorf("a node that is neither an element nor an attribute")
 	}
-
-	currentStep := steps[0]
-	remainingSteps := steps[1:]
-
-	// Handle self (.)
-	if currentStep == "." {
-		v.findMatchingChildren(elem, remainingSteps, results)
-		return
+	if v.schema == nil {
+		return lexical, lexical, nil
 	}
 
-	// Find matching children
-	children := elem.Children()
-	for i := uint(0); i < children.Length(); i++ {
-		child := children.Item(i)
-		if child != nil && string(child.LocalName()) == currentStep {
-			if len(remainingSteps) == 0 {
-				*results = append(*results, child)
-			} else {
-				v.findMatchingChildren(child, remainingSteps, results)
-			}
+	t, visited := v.types[elem]
+	if !visited {
+		t = v.schema.instanceType(elem)
+	}
+	if attrName != "" {
+		t = v.schema.instanceAttributeType(t, attrName)
+	} else if ct, ok := t.(*ComplexType); ok {
+		if _, simple := ct.Content.(*SimpleContent); !simple {
+			return nil, "", fmt.Errorf("element '%s', whose type '%s' does not have simple content",
+				elem.LocalName(), typeLabel(ct))
 		}
 	}
-}
+	if t == nil {
+		return lexical, lexical, nil
+	}
 
-// findMatchingDescendants finds all descendant elements matching the name
-func (v *IdentityConstraintValidator) findMatchingDescendants(elem xmldom.Element, name string, results *[]xmldom.Element) {
-	// Check current element
-	if string(elem.LocalName()) == name {
-		*results = append(*results, elem)
+	normalized := NormalizeValue(lexical, t)
+	value, err := v.schema.ParseValueAt(lexical, t, elem)
+	if err != nil {
+		return normalized, normalized, nil
 	}
+	return value, normalized, nil
+}
 
-	// Check all children recursively
-	children := elem.Children()
-	for i := uint(0); i < children.Length(); i++ {
-		child := children.Item(i)
-		if child != nil {
-			v.findMatchingDescendants(child, name, results)
+// compileIdentityXPath compiles a selector or field expression. These use
+// the restricted XPath grammar of XSD Part 1 §3.11.6:
+//
+//	Selector ::= Path ( '|' Path )*
+//	Path     ::= ('.//')? Step ( '/' Step )*
+//	Field    ::= Path ( '|' Path )*
+//	Path     ::= ('.//')? ( Step '/' )* ( Step | AttributeStep )
+//	Step     ::= '.' | ('child::')? NameTest
+//	AttributeStep ::= ('@' | 'attribute::') NameTest
+//	NameTest ::= QName | '*' | NCName ':*'
+//
+// Only fields may end in an attribute step.
+func compileIdentityXPath(source string, namespaces xpathNamespaces, field bool) (*xpathExpression, error) {
+	tokens, err := tokenizeXPath(source)
+	if err != nil {
+		return nil, err
+	}
+	p := &xpathParser{tokens: tokens, namespaces: namespaces}
+	root, err := p.parseIdentityPath(field)
+	if err != nil {
+		return nil, err
+	}
+	for p.isSymbol("|") {
+		p.next()
+		right, err := p.parseIdentityPath(field)
+		if err != nil {
+			return nil, err
 		}
+		root = &xpathBinary{op: "|", left: root, right: right}
+	}
+	if tok := p.peek(); tok.kind != xpathTokenEOF {
+		return nil, fmt.Errorf("unexpected '%s' at offset %d", tok.value, tok.pos)
 	}
+	return &xpathExpression{source: source, root: root}, nil
 }
 
-// evaluateFieldXPath evaluates a field XPath expression to get a string value
-func (v *IdentityConstraintValidator) evaluateFieldXPath(node xmldom.Element, xpath string) string {
-	xpath = strings.TrimSpace(xpath)
-
-	// Handle attribute selection (@attributeName)
-	if strings.HasPrefix(xpath, "@") {
-		attrName := strings.TrimPrefix(xpath, "@")
-		attr := node.GetAttribute(xmldom.DOMString(attrName))
-		return string(attr)
+// parseIdentityPath parses one path of a selector or field expression
+func (p *xpathParser) parseIdentityPath(field bool) (xpathExpr, error) {
+	path := &xpathPath{}
+	if p.isSymbol("/", "//") {
+		return nil, fmt.Errorf("absolute paths are not allowed, found '%s' at offset %d", p.peek().value, p.peek().pos)
 	}
-
-	// Handle text() function
-	if xpath == "." || xpath == "text()" {
-		return getElementTextContent(node)
+	if p.isSymbol(".") && p.peekAt(1).kind == xpathTokenSymbol && p.peekAt(1).value == "//" {
+		p.next()
+		p.next()
+		path.steps = append(path.steps, descendantOrSelfStep())
 	}
 
-	// Handle child element path
-	if !strings.Contains(xpath, "@") && !strings.Contains(xpath, "()") {
-		// It's an element path, find the element and get its text content
-		elements := v.evaluateSimpleXPath(node, xpath)
-		if len(elements) > 0 {
-			return getElementTextContent(elements[0])
+	for {
+		tok, after := p.peek(), p.peekAt(1)
+		axis := ""
+		switch {
+		case tok.kind == xpathTokenSymbol && tok.value == ".":
+			p.next()
+			path.steps = append(path.steps, &xpathStep{axis: "self", test: xpathNodeTest{kind: "node"}})
+		case tok.kind == xpathTokenSymbol && tok.value == "@":
+			p.next()
+			axis = "attribute"
+		case tok.kind == xpathTokenName && after.kind == xpathTokenSymbol && after.value == "::":
+			if tok.value != "child" && tok.value != "attribute" {
+				return nil, fmt.Errorf("unsupported axis '%s' at offset %d", tok.value, tok.pos)
+			}
+			p.next()
+			p.next()
+			axis = tok.value
+		case tok.kind == xpathTokenName || tok.kind == xpathTokenSymbol && tok.value == "*":
+			axis = "child"
+		default:
+			return nil, fmt.Errorf("expected a step at offset %d, found '%s'", tok.pos, tok.value)
+		}
+
+		if axis != "" {
+			nameTok := p.peek()
+			test, err := p.parseNodeTest(axis == "attribute")
+			if err != nil {
+				return nil, err
+			}
+			if test.kind != "name" || (test.namespace == "*" && test.local != "*") {
+				return nil, fmt.Errorf("expected a name test at offset %d, found '%s'", nameTok.pos, nameTok.value)
+			}
+			path.steps = append(path.steps, &xpathStep{axis: axis, test: test})
 		}
-	}
 
-	// Handle paths like element/@attribute
-	if strings.Contains(xpath, "/@") {
-		parts := strings.Split(xpath, "/@")
-		if len(parts) == 2 {
-			elements := v.evaluateSimpleXPath(node, parts[0])
-			if len(elements) > 0 {
-				attr := elements[0].GetAttribute(xmldom.DOMString(parts[1]))
-				return string(attr)
+		if axis == "attribute" {
+			if !field {
+				return nil, fmt.Errorf("a selector cannot select attributes, found '%s' at offset %d", tok.value, tok.pos)
 			}
+			if p.isSymbol("/", "//") {
+				return nil, fmt.Errorf("an attribute step must be the last step, found '%s' at offset %d",
+					p.peek().value, p.peek().pos)
+			}
+			return path, nil
+		}
+		if p.isSymbol("//") {
+			return nil, fmt.Errorf("'//' is only allowed at the start of a path, found at offset %d", p.peek().pos)
 		}
+		if !p.isSymbol("/") {
+			return path, nil
+		}
+		p.next()
 	}
+}
 
-	return ""
+// checkIdentityConstraints reports selectors and fields whose XPath is not
+// in the restricted grammar or uses undeclared prefixes
+func (s *Schema) checkIdentityConstraints() []error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var errs []error
+	for _, decl := range s.elementDeclarations() {
+		for _, constraint := range decl.Constraints {
+			if sel := constraint.Selector; sel != nil && sel.err != nil {
+				errs = append(errs, sourceError(sel.source, "c-selector-xpath",
+					"selector '%s' of %s '%s': %v", sel.XPath, constraint.Kind, constraint.Name, sel.err))
+			}
+			for _, field := range constraint.Fields {
+				if field.err != nil {
+					errs = append(errs, sourceError(field.source, "c-fields-xpaths",
+						"field '%s' of %s '%s': %v", field.XPath, constraint.Kind, constraint.Name, field.err))
+				}
+			}
+		}
+	}
+	return errs
 }
 
-// removeNamespacePrefixes removes namespace prefixes from XPath expressions
-func removeNamespacePrefixes(xpath string) string {
-	// Remove namespace prefixes like "ex:" from element names
-	parts := strings.Split(xpath, "/")
-	for i, part := range parts {
-		if idx := strings.Index(part, ":"); idx > 0 && !strings.HasPrefix(part, "@") {
-			// Remove prefix but keep the local name
-			parts[i] = part[idx+1:]
+// identityConstraints returns the key and unique constraints of the
+// element declarations, by name
+func (s *Schema) identityConstraints() map[QName]*IdentityConstraint {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	constraints := make(map[QName]*IdentityConstraint)
+	for _, decl := range s.elementDeclarations() {
+		for _, constraint := range decl.Constraints {
+			if constraint.Kind != KeyRefConstraint {
+				constraints[QName{Namespace: constraint.namespace, Local: constraint.Name}] = constraint
+			}
 		}
 	}
-	return strings.Join(parts, "/")
+	return constraints
 }
@@ -2,6 +2,8 @@ package xsd
 
 import (
 	"bytes"
+	"errors"
+	"strings"
 	"testing"
 
 	"github.com/agentflare-ai/go-xmldom"
@@ -11,7 +13,7 @@ func TestUniqueConstraint(t *testing.T) {
 	// Create a schema with a unique constraint on employee ID
 	schemaXML := `<?xml version="1.0" encoding="UTF-8"?>
 	<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema" 
-	           targetNamespace="http://example.com" 
+	           targetNamespace="http://example.com" elementFormDefault="qualified" 
 	           xmlns:ex="http://example.com">
 		<xs:element name="company">
 			<xs:complexType>
@@ -104,7 +106,7 @@ func TestKeyConstraint(t *testing.T) {
 	// Create a schema with a key constraint
 	schemaXML := `<?xml version="1.0" encoding="UTF-8"?>
 	<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema" 
-	           targetNamespace="http://example.com" 
+	           targetNamespace="http://example.com" elementFormDefault="qualified" 
 	           xmlns:ex="http://example.com">
 		<xs:element name="database">
 			<xs:complexType>
@@ -229,7 +231,7 @@ func TestKeyRefConstraint(t *testing.T) {
 	// Create a schema with key and keyref constraints
 	schemaXML := `<?xml version="1.0" encoding="UTF-8"?>
 	<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema" 
-	           targetNamespace="http://example.com" 
+	           targetNamespace="http://example.com" elementFormDefault="qualified" 
 	           xmlns:ex="http://example.com">
 		<xs:element name="library">
 			<xs:complexType>
@@ -371,7 +373,7 @@ func TestComplexIdentityConstraints(t *testing.T) {
 	// Test with multiple fields in constraints
 	schemaXML := `<?xml version="1.0" encoding="UTF-8"?>
 	<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema" 
-	           targetNamespace="http://example.com" 
+	           targetNamespace="http://example.com" elementFormDefault="qualified" 
 	           xmlns:ex="http://example.com">
 		<xs:element name="school">
 			<xs:complexType>
@@ -472,3 +474,378 @@ func contains(str, substr string) bool {
 	return len(str) > 0 && len(substr) > 0 &&
 		bytes.Contains([]byte(str), []byte(substr))
 }
+
+func TestIdentityConstraintXPath(t *testing.T) {
+	schema, err := parseTestSchema(t, `<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"
+           xmlns:t="http://example.com/ic" xmlns:o="http://example.com/other"
+           targetNamespace="http://example.com/ic" elementFormDefault="qualified">
+	<xs:element name="root">
+		<xs:complexType>
+			<xs:sequence>
+				<xs:any namespace="##any" processContents="skip" minOccurs="0" maxOccurs="unbounded"/>
+			</xs:sequence>
+		</xs:complexType>
+		<xs:unique name="uniqueId">
+			<xs:selector xpath="t:group/t:item | child::t:single | .//t:deep"/>
+			<xs:field xpath="attribute::num | t:num"/>
+		</xs:unique>
+		<xs:unique name="uniqueOther">
+			<xs:selector xpath="o:*"/>
+			<xs:field xpath="@o:code"/>
+		</xs:unique>
+		<xs:unique name="uniqueAny">
+			<xs:selector xpath="t:any/*"/>
+			<xs:field xpath="./@key"/>
+		</xs:unique>
+	</xs:element>
+</xs:schema>`)
+	if err != nil {
+		t.Fatalf("Failed to parse schema: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		instance string
+		codes    []string
+	}{
+		{
+			name:     "distinct values across union branches",
+			instance: `<group><item num="1"/><item num="2"/></group><single><num>3</num></single><x><deep num="4"/></x>`,
+		},
+		{
+			name:     "duplicate across union branches",
+			instance: `<group><item num="1"/></group><single num="1"/>`,
+			codes:    []string{"cvc-identity-constraint.4.1"},
+		},
+		{
+			name:     "descendant step",
+			instance: `<a><b><deep num="1"/></b></a><deep num="1"/>`,
+			codes:    []string{"cvc-identity-constraint.4.1"},
+		},
+		{
+			name:     "names are matched by namespace",
+			instance: `<group><item num="1"/><o:item num="1"/></group>`,
+		},
+		{
+			name:     "namespace wildcard and qualified attribute",
+			instance: `<o:a o:code="x"/><o:b o:code="x"/><item o:code="x"/>`,
+			codes:    []string{"cvc-identity-constraint.4.1"},
+		},
+		{
+			name:     "any element wildcard",
+			instance: `<any><a key="k"/><o:b key="k"/></any>`,
+			codes:    []string{"cvc-identity-constraint.4.1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			instance := `<root xmlns="http://example.com/ic" xmlns:o="http://example.com/other">` + tt.instance + `</root>`
+			doc, err := xmldom.Decode(strings.NewReader(instance))
+			if err != nil {
+				t.Fatalf("Failed to parse instance: %v", err)
+			}
+
+			var codes []string
+			for _, v := range NewValidator(schema).Validate(doc) {
+				codes = append(codes, v.Code)
+			}
+			if strings.Join(codes, " ") != strings.Join(tt.codes, " ") {
+				t.Errorf("Expected violations %v, got %v", tt.codes, codes)
+			}
+		})
+	}
+}
+
+func TestIdentityConstraintXPathErrors(t *testing.T) {
+	tests := []struct {
+		selector string
+		field    string
+		code     string
+	}{
+		{selector: "/t:item", field: "@id", code: "c-selector-xpath"},
+		{selector: "t:group//t:item", field: "@id", code: "c-selector-xpath"},
+		{selector: "t:item/@id", field: "@id", code: "c-selector-xpath"},
+		{selector: "parent::t:item", field: "@id", code: "c-selector-xpath"},
+		{selector: "t:item[1]", field: "@id", code: "c-selector-xpath"},
+		{selector: "q:item", field: "@id", code: "c-selector-xpath"},
+		{selector: "*:item", field: "@id", code: "c-selector-xpath"},
+		{selector: "t:item", field: "@id/t:x", code: "c-fields-xpaths"},
+		{selector: "t:item", field: "text()", code: "c-fields-xpaths"},
+		{selector: "t:item", field: "string(@id)", code: "c-fields-xpaths"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.selector+" "+tt.field, func(t *testing.T) {
+			_, err := parseTestSchema(t, `<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"
+           xmlns:t="http://example.com/ic" targetNamespace="http://example.com/ic">
+	<xs:element name="root">
+		<xs:unique name="u">
+			<xs:selector xpath="`+tt.selector+`"/>
+			<xs:field xpath="`+tt.field+`"/>
+		</xs:unique>
+	</xs:element>
+</xs:schema>`)
+			if err == nil {
+				t.Fatal("Expected schema to be rejected")
+			}
+			var schemaErr *SchemaError
+			if !errors.As(err, &schemaErr) || !strings.Contains(err.Error(), tt.code+":") {
+				t.Fatalf("Expected a %s error, got %v", tt.code, err)
+			}
+			if schemaErr.Line == 0 {
+				t.Errorf("Expected the error to have a location: %v", err)
+			}
+		})
+	}
+}
+
+func TestScopedIdentityConstraints(t *testing.T) {
+	schema, err := parseTestSchema(t, `<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"
+           xmlns:t="http://example.com/ic" targetNamespace="http://example.com/ic"
+           elementFormDefault="qualified">
+	<xs:element name="library">
+		<xs:complexType>
+			<xs:sequence>
+				<xs:element name="shelf" maxOccurs="unbounded">
+					<xs:complexType>
+						<xs:sequence>
+							<xs:element name="book" minOccurs="0" maxOccurs="unbounded">
+								<xs:complexType>
+									<xs:attribute name="isbn" type="xs:string"/>
+								</xs:complexType>
+							</xs:element>
+						</xs:sequence>
+					</xs:complexType>
+					<xs:key name="shelfBook">
+						<xs:selector xpath="t:book"/>
+						<xs:field xpath="@isbn"/>
+					</xs:key>
+				</xs:element>
+				<xs:element name="loan" minOccurs="0" maxOccurs="unbounded">
+					<xs:complexType>
+						<xs:sequence>
+							<xs:element name="isbn" type="xs:string" minOccurs="0"/>
+						</xs:sequence>
+						<xs:attribute name="isbn" type="xs:string"/>
+					</xs:complexType>
+				</xs:element>
+			</xs:sequence>
+		</xs:complexType>
+		<xs:keyref name="loanBook" refer="t:shelfBook">
+			<xs:selector xpath="t:loan"/>
+			<xs:field xpath="@isbn | t:isbn"/>
+		</xs:keyref>
+	</xs:element>
+</xs:schema>`)
+	if err != nil {
+		t.Fatalf("Failed to parse schema: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		instance string
+		codes    []string
+	}{
+		{
+			name:     "keys are unique per shelf",
+			instance: `<shelf><book isbn="1"/><book isbn="2"/></shelf><shelf><book isbn="1"/></shelf>`,
+		},
+		{
+			name:     "duplicate key within a shelf",
+			instance: `<shelf><book isbn="1"/><book isbn="1"/></shelf>`,
+			codes:    []string{"cvc-identity-constraint.4.2.2"},
+		},
+		{
+			name:     "missing key field",
+			instance: `<shelf><book/></shelf>`,
+			codes:    []string{"cvc-identity-constraint.4.2.1"},
+		},
+		{
+			name:     "keyref to a key declared on a descendant",
+			instance: `<shelf><book isbn="1"/></shelf><shelf><book isbn="2"/></shelf><loan isbn="2"/><loan><isbn>1</isbn></loan>`,
+		},
+		{
+			name:     "keyref without a matching key",
+			instance: `<shelf><book isbn="1"/></shelf><loan isbn="3"/>`,
+			codes:    []string{"cvc-identity-constraint.4.3"},
+		},
+		{
+			name:     "key-sequences propagated from two shelves are dropped",
+			instance: `<shelf><book isbn="1"/></shelf><shelf><book isbn="1"/></shelf><loan isbn="1"/>`,
+			codes:    []string{"cvc-identity-constraint.4.3"},
+		},
+		{
+			name:     "field selecting more than one node",
+			instance: `<shelf><book isbn="1"/></shelf><loan isbn="1"><isbn>1</isbn></loan>`,
+			codes:    []string{"cvc-identity-constraint.3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := xmldom.Decode(strings.NewReader(`<library xmlns="http://example.com/ic">` + tt.instance + `</library>`))
+			if err != nil {
+				t.Fatalf("Failed to parse instance: %v", err)
+			}
+
+			var codes []string
+			for _, v := range NewValidator(schema).Validate(doc) {
+				codes = append(codes, v.Code)
+				if strings.HasPrefix(v.Code, "cvc-identity-constraint.4") && v.Related == nil {
+					t.Errorf("Expected %s to name a related element", v.Code)
+				}
+			}
+			if strings.Join(codes, " ") != strings.Join(tt.codes, " ") {
+				t.Errorf("Expected violations %v, got %v", tt.codes, codes)
+			}
+		})
+	}
+}
+
+func TestTypedIdentityConstraintValues(t *testing.T) {
+	schema, err := parseTestSchema(t, `<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"
+           xmlns:t="http://example.com/ic" targetNamespace="http://example.com/ic"
+           elementFormDefault="qualified">
+	<xs:simpleType name="amount">
+		<xs:restriction base="xs:decimal"/>
+	</xs:simpleType>
+	<xs:simpleType name="code">
+		<xs:restriction base="xs:token"/>
+	</xs:simpleType>
+	<xs:simpleType name="label">
+		<xs:restriction base="xs:string"/>
+	</xs:simpleType>
+	<xs:simpleType name="stamp">
+		<xs:restriction base="xs:dateTime"/>
+	</xs:simpleType>
+	<xs:simpleType name="name">
+		<xs:restriction base="xs:QName"/>
+	</xs:simpleType>
+	<xs:element name="root">
+		<xs:complexType>
+			<xs:sequence>
+				<xs:element name="entry" maxOccurs="unbounded">
+					<xs:complexType>
+						<xs:sequence>
+							<xs:element name="when" type="t:stamp" minOccurs="0"/>
+							<xs:element name="detail" minOccurs="0">
+								<xs:complexType>
+									<xs:sequence>
+										<xs:element name="note" type="t:label"/>
+									</xs:sequence>
+								</xs:complexType>
+							</xs:element>
+						</xs:sequence>
+						<xs:attribute name="amount" type="t:amount"/>
+						<xs:attribute name="code" type="t:code"/>
+						<xs:attribute name="label" type="t:label"/>
+						<xs:attribute name="name" type="t:name"/>
+					</xs:complexType>
+				</xs:element>
+				<xs:element name="ref" minOccurs="0" maxOccurs="unbounded">
+					<xs:complexType>
+						<xs:attribute name="code" type="t:code"/>
+					</xs:complexType>
+				</xs:element>
+			</xs:sequence>
+		</xs:complexType>
+		<xs:unique name="byAmount">
+			<xs:selector xpath="t:entry"/>
+			<xs:field xpath="@amount"/>
+		</xs:unique>
+		<xs:key name="byCode">
+			<xs:selector xpath="t:entry"/>
+			<xs:field xpath="@code"/>
+		</xs:key>
+		<xs:keyref name="toCode" refer="t:byCode">
+			<xs:selector xpath="t:ref"/>
+			<xs:field xpath="@code"/>
+		</xs:keyref>
+		<xs:unique name="byLabel">
+			<xs:selector xpath="t:entry"/>
+			<xs:field xpath="@label"/>
+		</xs:unique>
+		<xs:unique name="byTime">
+			<xs:selector xpath="t:entry"/>
+			<xs:field xpath="t:when"/>
+		</xs:unique>
+		<xs:unique name="byName">
+			<xs:selector xpath="t:entry"/>
+			<xs:field xpath="@name"/>
+		</xs:unique>
+		<xs:unique name="byDetail">
+			<xs:selector xpath="t:entry"/>
+			<xs:field xpath="t:detail"/>
+		</xs:unique>
+	</xs:element>
+</xs:schema>`)
+	if err != nil {
+		t.Fatalf("Failed to parse schema: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		instance string
+		codes    []string
+	}{
+		{
+			name:     "decimal values are compared by value",
+			instance: `<entry code="a" amount="1.0"/><entry code="b" amount="1"/>`,
+			codes:    []string{"cvc-identity-constraint.4.1"},
+		},
+		{
+			name:     "string values are compared as written",
+			instance: `<entry code="a" label="1.0"/><entry code="b" label="1"/>`,
+		},
+		{
+			name:     "keyref matches after whitespace collapsing",
+			instance: `<entry code="a  b"/><ref code=" a b "/>`,
+		},
+		{
+			name:     "keys are equal after whitespace collapsing",
+			instance: `<entry code="a b"/><entry code=" a  b"/>`,
+			codes:    []string{"cvc-identity-constraint.4.2.2"},
+		},
+		{
+			name:     "dateTime values are compared as instants",
+			instance: `<entry code="a"><when>2024-01-01T12:00:00Z</when></entry><entry code="b"><when>2024-01-01T13:00:00+01:00</when></entry>`,
+			codes:    []string{"cvc-identity-constraint.4.1"},
+		},
+		{
+			name:     "QName values are compared by namespace",
+			instance: `<entry code="a" name="p:x" xmlns:p="urn:n"/><entry code="b" name="q:x" xmlns:q="urn:n"/>`,
+			codes:    []string{"cvc-identity-constraint.4.1"},
+		},
+		{
+			name:     "QName values in different namespaces",
+			instance: `<entry code="a" name="p:x" xmlns:p="urn:n"/><entry code="b" name="p:x" xmlns:p="urn:m"/>`,
+		},
+		{
+			name:     "field selecting an element with complex content",
+			instance: `<entry code="a"><detail><note>n</note></detail></entry>`,
+			codes:    []string{"cvc-identity-constraint.3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := xmldom.Decode(strings.NewReader(`<root xmlns="http://example.com/ic">` + tt.instance + `</root>`))
+			if err != nil {
+				t.Fatalf("Failed to parse instance: %v", err)
+			}
+
+			var codes []string
+			for _, v := range NewValidator(schema).Validate(doc) {
+				codes = append(codes, v.Code)
+			}
+			if strings.Join(codes, " ") != strings.Join(tt.codes, " ") {
+				t.Errorf("Expected violations %v, got %v", tt.codes, codes)
+			}
+		})
+	}
+}
@@ -0,0 +1,290 @@
+package xsd
+
+// checkModelGroups reports content models that the spec rejects: circular
+// named groups (mg-props-correct.2), misplaced or repeating all groups and
+// all groups extended by other content (cos-all-limited), and same-named
+// elements with different types in one content model
+// (cos-element-consistent)
+func (s *Schema) checkModelGroups() []error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var errs []error
+
+	names := make([]QName, 0, len(s.Groups))
+	for name := range s.Groups {
+		names = append(names, name)
+	}
+	sortQNames(names)
+	for _, name := range names {
+		group := s.Groups[name]
+		if s.groupReaches(group, name, make(map[QName]bool)) {
+			errs = append(errs, sourceError(group.source, "mg-props-correct.2",
+				"group '%s' refers to itself through group references", name.Local))
+			continue
+		}
+		for _, msg := range s.allGroupProblems(group, true) {
+			errs = append(errs, sourceError(group.source, msg.code, "group '%s': %s", name.Local, msg.text))
+		}
+	}
+
+	for _, t := range s.typeDefinitions() {
+		ct, ok := t.(*ComplexType)
+		if !ok {
+			continue
+		}
+		if top := declaredParticle(ct); top != nil {
+			for _, msg := range s.allGroupProblems(top, true) {
+				errs = append(errs, typeError(ct, msg.code, "complex type '%s': %s", typeLabel(ct), msg.text))
+			}
+			if msg := s.allExtensionProblem(ct, top); msg != nil {
+				errs = append(errs, typeError(ct, msg.code, "complex type '%s': %s", typeLabel(ct), msg.text))
+			}
+		}
+		if err := s.checkElementsConsistent(ct); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs
+}
+
+// declaredParticle returns the particle a complex type declares itself,
+// before an extension merged it with the content of its base
+func declaredParticle(ct *ComplexType) Particle {
+	content := declaredContent(ct)
+	if cc, ok := content.(*ComplexContent); ok {
+		switch {
+		case cc.Restriction != nil:
+			content = cc.Restriction.Content
+		case cc.Extension != nil:
+			content = cc.Extension.Content
+		}
+	}
+	switch p := content.(type) {
+	case *ModelGroup:
+		return p
+	case *GroupRef:
+		return p
+	}
+	return nil
+}
+
+// declaredParticles returns the particles of a model group as declared
+func declaredParticles(mg *ModelGroup) []Particle {
+	if mg.declared != nil {
+		return mg.declared
+	}
+	return mg.Particles
+}
+
+// groupReaches reports whether the declared particles of a model group
+// refer to the named group, directly or through other groups. Element
+// declarations are not followed, as recursion through them is allowed.
+func (s *Schema) groupReaches(mg *ModelGroup, target QName, visited map[QName]bool) bool {
+	for _, particle := range declaredParticles(mg) {
+		switch p := particle.(type) {
+		case *GroupRef:
+			if p.Ref == target {
+				return true
+			}
+			if visited[p.Ref] {
+				continue
+			}
+			visited[p.Ref] = true
+			if group, ok := s.Groups[p.Ref]; ok && s.groupReaches(group, target, visited) {
+				return true
+			}
+		case *ModelGroup:
+			if s.groupReaches(p, target, visited) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// modelGroupProblem is a constraint violation found in a content model
+type modelGroupProblem struct {
+	code string
+	text string
+}
+
+// allGroupProblems checks the all groups in a particle: they must make up
+// a whole content model and occur at most once (cos-all-limited). In XSD
+// 1.0 they contain only element declarations that occur at most once; XSD
+// 1.1 also allows wildcards, references to other all groups and elements
+// that repeat. Referenced groups are checked where they are defined.
+func (s *Schema) allGroupProblems(particle Particle, top bool) []modelGroupProblem {
+	var problems []modelGroupProblem
+
+	switch p := particle.(type) {
+	case *GroupRef:
+		group, ok := s.Groups[p.Ref]
+		if !ok || group.Kind != AllGroup {
+			break
+		}
+		if !top {
+			problems = append(problems, modelGroupProblem{"cos-all-limited.1.2",
+				"group '" + p.Ref.Local + "' is an all group and must be the whole content model"})
+		} else if p.MaxOcc != 1 {
+			problems = append(problems, modelGroupProblem{"cos-all-limited.1.2",
+				"reference to all group '" + p.Ref.Local + "' must have maxOccurs 1"})
+		}
+	case *ModelGroup:
+		if p.Kind != AllGroup {
+			for _, child := range declaredParticles(p) {
+				problems = append(problems, s.allGroupProblems(child, false)...)
+			}
+			break
+		}
+
+		if !top {
+			problems = append(problems, modelGroupProblem{"cos-all-limited.1.2",
+				"all group must be the whole content model"})
+		} else if p.MaxOcc != 1 {
+			problems = append(problems, modelGroupProblem{"cos-all-limited.1.2",
+				"all group must have maxOccurs 1"})
+		}
+		for _, child := range declaredParticles(p) {
+			if problem := s.allGroupMemberProblem(child); problem != nil {
+				problems = append(problems, *problem)
+			}
+		}
+	}
+
+	return problems
+}
+
+// allGroupMemberProblem checks a particle of an all group
+func (s *Schema) allGroupMemberProblem(particle Particle) *modelGroupProblem {
+	repeats := particle.MaxOccurs() != 0 && particle.MaxOccurs() != 1
+	switch p := particle.(type) {
+	case *ElementDecl:
+		if repeats && !s.xsd11() {
+			return &modelGroupProblem{"cos-all-limited.2",
+				"element '" + p.Name.Local + "' in an all group must have maxOccurs 0 or 1"}
+		}
+		return nil
+	case *ElementRef:
+		if repeats && !s.xsd11() {
+			return &modelGroupProblem{"cos-all-limited.2",
+				"element '" + p.Ref.Local + "' in an all group must have maxOccurs 0 or 1"}
+		}
+		return nil
+	case *AnyElement:
+		if s.xsd11() {
+			return nil
+		}
+	case *GroupRef:
+		if !s.xsd11() {
+			break
+		}
+		if group, ok := s.Groups[p.Ref]; ok && group.Kind != AllGroup || p.MinOcc != 1 || p.MaxOcc != 1 {
+			return &modelGroupProblem{"cos-all-limited.2",
+				"group '" + p.Ref.Local + "' in an all group must be an all group with minOccurs and maxOccurs 1"}
+		}
+		return nil
+	}
+
+	if s.xsd11() {
+		return &modelGroupProblem{"cos-all-limited.2",
+			"all group can only contain element declarations, wildcards and references to all groups"}
+	}
+	return &modelGroupProblem{"cos-all-limited.2", "all group can only contain element declarations"}
+}
+
+// allExtensionProblem checks the particle a complexContent extension adds
+// against an all group in its base type, and an all group it adds against
+// the content of its base. XSD 1.1 allows an all group to be extended by
+// another all group; XSD 1.0 allows neither.
+func (s *Schema) allExtensionProblem(ct *ComplexType, added Particle) *modelGroupProblem {
+	if ct.DerivedBy != DerivationExtension || s.emptyGroup(added) {
+		return nil
+	}
+	base, ok := s.lookupTypeLocked(ct.BaseType).(*ComplexType)
+	if !ok {
+		return nil
+	}
+	baseParticle := s.contentParticle(base)
+	if baseParticle == nil || s.emptyGroup(baseParticle) {
+		return nil
+	}
+
+	baseAll, addedAll := s.isAllGroup(baseParticle), s.isAllGroup(added)
+	switch {
+	case !baseAll && !addedAll, baseAll && addedAll && s.xsd11():
+		return nil
+	case baseAll && s.xsd11():
+		return &modelGroupProblem{"cos-all-limited.1.2",
+			"the all group of base type '" + typeLabel(base) + "' can only be extended by an all group"}
+	case baseAll:
+		return &modelGroupProblem{"cos-all-limited.1.2",
+			"the all group of base type '" + typeLabel(base) + "' cannot be extended"}
+	}
+	return &modelGroupProblem{"cos-all-limited.1.2",
+		"an all group cannot extend the content of base type '" + typeLabel(base) + "'"}
+}
+
+// isAllGroup reports whether a particle is an all group or a reference to
+// one
+func (s *Schema) isAllGroup(particle Particle) bool {
+	switch p := particle.(type) {
+	case *ModelGroup:
+		return p.Kind == AllGroup
+	case *GroupRef:
+		group, ok := s.Groups[p.Ref]
+		return ok && group.Kind == AllGroup
+	}
+	return false
+}
+
+// checkElementsConsistent reports elements with the same name but different
+// types in the content model of a complex type (cos-element-consistent)
+func (s *Schema) checkElementsConsistent(ct *ComplexType) error {
+	particle := s.restrictionParticle(s.contentParticle(ct))
+	if particle == nil {
+		return nil
+	}
+
+	types := make(map[QName]Type)
+	var walk func(p Particle) error
+	walk = func(p Particle) error {
+		switch pp := p.(type) {
+		case *ElementDecl:
+			declared, seen := types[pp.Name]
+			if !seen {
+				types[pp.Name] = pp.Type
+				return nil
+			}
+			if !s.sameElementType(declared, pp.Type) {
+				return typeError(ct, "cos-element-consistent",
+					"complex type '%s' declares element '%s' more than once with different types",
+					typeLabel(ct), pp.Name.Local)
+			}
+		case *ModelGroup:
+			for _, child := range pp.Particles {
+				if err := walk(child); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	return walk(particle)
+}
+
+// sameElementType reports whether two element declarations share a type
+// definition; elements without a type have xs:anyType
+func (s *Schema) sameElementType(a, b Type) bool {
+	if a == nil || b == nil {
+		name := func(t Type) QName {
+			if t == nil {
+				return anyTypeQName
+			}
+			return t.Name()
+		}
+		return name(a) == name(b)
+	}
+	return sameType(a, b)
+}
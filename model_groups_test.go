@@ -0,0 +1,361 @@
+package xsd
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/agentflare-ai/go-xmldom"
+)
+
+func TestModelGroupConstraints(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		code string
+	}{
+		{
+			name: "circular group",
+			body: `<xs:group name="a"><xs:sequence><xs:group ref="t:b"/></xs:sequence></xs:group>
+				<xs:group name="b"><xs:choice><xs:element name="x" type="xs:string"/><xs:group ref="t:a"/></xs:choice></xs:group>`,
+			code: "mg-props-correct.2",
+		},
+		{
+			name: "group recursing through an element",
+			body: `<xs:group name="tree">
+					<xs:sequence>
+						<xs:element name="node">
+							<xs:complexType><xs:group ref="t:tree" minOccurs="0"/></xs:complexType>
+						</xs:element>
+					</xs:sequence>
+				</xs:group>`,
+		},
+		{
+			name: "all group nested in a sequence",
+			body: `<xs:complexType name="c">
+					<xs:sequence>
+						<xs:all><xs:element name="x" type="xs:string"/></xs:all>
+					</xs:sequence>
+				</xs:complexType>`,
+			code: "cos-all-limited.1.2",
+		},
+		{
+			name: "reference to an all group in a choice",
+			body: `<xs:group name="g"><xs:all><xs:element name="x" type="xs:string"/></xs:all></xs:group>
+				<xs:complexType name="c">
+					<xs:choice><xs:group ref="t:g"/><xs:element name="y" type="xs:string"/></xs:choice>
+				</xs:complexType>`,
+			code: "cos-all-limited.1.2",
+		},
+		{
+			name: "repeating all group",
+			body: `<xs:group name="g"><xs:all><xs:element name="x" type="xs:string"/></xs:all></xs:group>
+				<xs:complexType name="c"><xs:group ref="t:g" maxOccurs="2"/></xs:complexType>`,
+			code: "cos-all-limited.1.2",
+		},
+		{
+			name: "repeating element in an all group",
+			body: `<xs:complexType name="c">
+					<xs:all><xs:element name="x" type="xs:string" maxOccurs="unbounded"/></xs:all>
+				</xs:complexType>`,
+			code: "cos-all-limited.2",
+		},
+		{
+			name: "valid all group",
+			body: `<xs:group name="g">
+					<xs:all>
+						<xs:element name="x" type="xs:string"/>
+						<xs:element name="y" type="xs:string" minOccurs="0"/>
+					</xs:all>
+				</xs:group>
+				<xs:complexType name="c"><xs:group ref="t:g"/></xs:complexType>
+				<xs:complexType name="d">
+					<xs:all minOccurs="0"><xs:element name="x" type="xs:int"/></xs:all>
+				</xs:complexType>`,
+		},
+		{
+			name: "wildcard in an all group",
+			body: `<xs:complexType name="c">
+					<xs:all><xs:element name="x" type="xs:string"/><xs:any namespace="##other"/></xs:all>
+				</xs:complexType>`,
+			code: "cos-all-limited.2",
+		},
+		{
+			name: "all group extended by an all group",
+			body: `<xs:complexType name="base"><xs:all><xs:element name="x" type="xs:string"/></xs:all></xs:complexType>
+				<xs:complexType name="c">
+					<xs:complexContent><xs:extension base="t:base">
+						<xs:all><xs:element name="y" type="xs:string"/></xs:all>
+					</xs:extension></xs:complexContent>
+				</xs:complexType>`,
+			code: "cos-all-limited.1.2",
+		},
+		{
+			name: "same element with different types",
+			body: `<xs:complexType name="c">
+					<xs:choice>
+						<xs:element name="x" type="xs:string"/>
+						<xs:sequence><xs:element name="x" type="xs:int"/></xs:sequence>
+					</xs:choice>
+				</xs:complexType>`,
+			code: "cos-element-consistent",
+		},
+		{
+			name: "same element with different types through a group",
+			body: `<xs:group name="g"><xs:sequence><xs:element name="x" type="xs:int"/></xs:sequence></xs:group>
+				<xs:complexType name="c">
+					<xs:sequence>
+						<xs:element name="x" type="xs:string"/>
+						<xs:group ref="t:g"/>
+					</xs:sequence>
+				</xs:complexType>`,
+			code: "cos-element-consistent",
+		},
+		{
+			name: "extension redeclaring an inherited element",
+			body: `<xs:complexType name="base">
+					<xs:sequence><xs:element name="x" type="xs:string"/></xs:sequence>
+				</xs:complexType>
+				<xs:complexType name="c">
+					<xs:complexContent><xs:extension base="t:base">
+						<xs:sequence><xs:element name="x" type="xs:int"/></xs:sequence>
+					</xs:extension></xs:complexContent>
+				</xs:complexType>`,
+			code: "cos-element-consistent",
+		},
+		{
+			name: "same element with the same type",
+			body: `<xs:complexType name="c">
+					<xs:sequence>
+						<xs:element name="x" type="xs:string"/>
+						<xs:element name="y" type="xs:string"/>
+						<xs:element name="x" type="xs:string"/>
+					</xs:sequence>
+				</xs:complexType>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseTestSchema(t, `<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"
+           xmlns:t="http://example.com/groups" targetNamespace="http://example.com/groups">
+	`+tt.body+`
+</xs:schema>`)
+			if tt.code == "" {
+				if err != nil {
+					t.Errorf("Expected schema to compile, got %v", err)
+				}
+				return
+			}
+			var schemaErr *SchemaError
+			if !errors.As(err, &schemaErr) || !strings.Contains(err.Error(), tt.code+":") {
+				t.Errorf("Expected %s error, got %v", tt.code, err)
+			} else if schemaErr.Line == 0 {
+				t.Errorf("Expected %s error to have a location, got %+v", tt.code, schemaErr)
+			}
+		})
+	}
+}
+
+func TestAllGroupConstraintsXSD11(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		code string
+	}{
+		{
+			name: "repeating elements, wildcards and all group references",
+			body: `<xs:group name="extra"><xs:all><xs:element name="z" type="xs:string"/></xs:all></xs:group>
+				<xs:complexType name="c">
+					<xs:all>
+						<xs:element name="x" type="xs:string" maxOccurs="unbounded"/>
+						<xs:any namespace="##other" processContents="lax" minOccurs="0" maxOccurs="2"/>
+						<xs:group ref="t:extra"/>
+					</xs:all>
+				</xs:complexType>`,
+		},
+		{
+			name: "reference to a sequence group in an all group",
+			body: `<xs:group name="extra"><xs:sequence><xs:element name="z" type="xs:string"/></xs:sequence></xs:group>
+				<xs:complexType name="c">
+					<xs:all><xs:element name="x" type="xs:string"/><xs:group ref="t:extra"/></xs:all>
+				</xs:complexType>`,
+			code: "cos-all-limited.2",
+		},
+		{
+			name: "optional reference to an all group in an all group",
+			body: `<xs:group name="extra"><xs:all><xs:element name="z" type="xs:string"/></xs:all></xs:group>
+				<xs:complexType name="c">
+					<xs:all><xs:element name="x" type="xs:string"/><xs:group ref="t:extra" minOccurs="0"/></xs:all>
+				</xs:complexType>`,
+			code: "cos-all-limited.2",
+		},
+		{
+			name: "all group nested in a sequence",
+			body: `<xs:complexType name="c">
+					<xs:sequence><xs:all><xs:element name="x" type="xs:string"/></xs:all></xs:sequence>
+				</xs:complexType>`,
+			code: "cos-all-limited.1.2",
+		},
+		{
+			name: "all group extended by an all group",
+			body: `<xs:complexType name="base"><xs:all><xs:element name="x" type="xs:string"/></xs:all></xs:complexType>
+				<xs:complexType name="c">
+					<xs:complexContent><xs:extension base="t:base">
+						<xs:all><xs:element name="y" type="xs:string"/></xs:all>
+					</xs:extension></xs:complexContent>
+				</xs:complexType>`,
+		},
+		{
+			name: "all group extended by a sequence",
+			body: `<xs:complexType name="base"><xs:all><xs:element name="x" type="xs:string"/></xs:all></xs:complexType>
+				<xs:complexType name="c">
+					<xs:complexContent><xs:extension base="t:base">
+						<xs:sequence><xs:element name="y" type="xs:string"/></xs:sequence>
+					</xs:extension></xs:complexContent>
+				</xs:complexType>`,
+			code: "cos-all-limited.1.2",
+		},
+		{
+			name: "sequence extended by an all group",
+			body: `<xs:complexType name="base"><xs:sequence><xs:element name="x" type="xs:string"/></xs:sequence></xs:complexType>
+				<xs:complexType name="c">
+					<xs:complexContent><xs:extension base="t:base">
+						<xs:all><xs:element name="y" type="xs:string"/></xs:all>
+					</xs:extension></xs:complexContent>
+				</xs:complexType>`,
+			code: "cos-all-limited.1.2",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := xmldom.Decode(strings.NewReader(`<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"
+           xmlns:t="http://example.com/groups" targetNamespace="http://example.com/groups">
+	` + tt.body + `
+</xs:schema>`))
+			if err != nil {
+				t.Fatalf("Failed to parse schema XML: %v", err)
+			}
+			_, err = ParseVersion(doc, Version11)
+			if tt.code == "" {
+				if err != nil {
+					t.Errorf("Expected schema to compile, got %v", err)
+				}
+				return
+			}
+			var schemaErr *SchemaError
+			if !errors.As(err, &schemaErr) || !strings.Contains(err.Error(), tt.code+":") {
+				t.Errorf("Expected %s error, got %v", tt.code, err)
+			} else if schemaErr.Line == 0 {
+				t.Errorf("Expected %s error to have a location, got %+v", tt.code, schemaErr)
+			}
+		})
+	}
+}
+
+func TestAllGroupValidationXSD11(t *testing.T) {
+	doc, err := xmldom.Decode(strings.NewReader(`<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"
+           xmlns:t="http://example.com/groups" targetNamespace="http://example.com/groups"
+           elementFormDefault="qualified">
+	<xs:group name="audit">
+		<xs:all>
+			<xs:element name="created" type="xs:string"/>
+		</xs:all>
+	</xs:group>
+	<xs:complexType name="item">
+		<xs:all>
+			<xs:element name="name" type="xs:string"/>
+			<xs:element name="tag" type="xs:string" minOccurs="0" maxOccurs="3"/>
+			<xs:any namespace="##other" processContents="skip" minOccurs="0"/>
+			<xs:group ref="t:audit"/>
+		</xs:all>
+	</xs:complexType>
+	<xs:complexType name="pricedItem">
+		<xs:complexContent>
+			<xs:extension base="t:item">
+				<xs:all>
+					<xs:element name="price" type="xs:string"/>
+				</xs:all>
+			</xs:extension>
+		</xs:complexContent>
+	</xs:complexType>
+	<xs:element name="item" type="t:item"/>
+	<xs:element name="pricedItem" type="t:pricedItem"/>
+</xs:schema>`))
+	if err != nil {
+		t.Fatalf("Failed to parse schema XML: %v", err)
+	}
+	schema, err := ParseVersion(doc, Version11)
+	if err != nil {
+		t.Fatalf("Failed to parse schema: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		instance string
+		codes    []string
+		message  string
+	}{
+		{
+			name:     "repeated elements in any order",
+			instance: `<item xmlns="http://example.com/groups"><tag/><created/><name/><tag/></item>`,
+		},
+		{
+			name:     "wildcard and referenced all group",
+			instance: `<item xmlns="http://example.com/groups" xmlns:x="urn:x"><x:note/><name/><created/></item>`,
+		},
+		{
+			name:     "too many occurrences",
+			instance: `<item xmlns="http://example.com/groups"><name/><created/><tag/><tag/><tag/><tag/></item>`,
+			codes:    []string{"cvc-complex-type.2.4.a"},
+			message:  "at most 3 occurrence(s) of element 'tag'",
+		},
+		{
+			name:     "missing element of a referenced all group",
+			instance: `<item xmlns="http://example.com/groups"><name/></item>`,
+			codes:    []string{"cvc-complex-type.2.4.a"},
+			message:  "at least 1 occurrence(s) of element 'created'",
+		},
+		{
+			name:     "wildcard bound",
+			instance: `<item xmlns="http://example.com/groups" xmlns:x="urn:x"><x:a/><name/><created/><x:b/></item>`,
+			codes:    []string{"cvc-complex-type.2.4.a"},
+			message:  "at most 1 occurrence(s) of wildcard",
+		},
+		{
+			name:     "all group extended by an all group",
+			instance: `<pricedItem xmlns="http://example.com/groups"><price/><created/><name/></pricedItem>`,
+		},
+		{
+			name:     "missing element of the extension",
+			instance: `<pricedItem xmlns="http://example.com/groups"><created/><name/></pricedItem>`,
+			codes:    []string{"cvc-complex-type.2.4.a"},
+			message:  "element 'price'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := xmldom.Decode(strings.NewReader(tt.instance))
+			if err != nil {
+				t.Fatalf("Failed to parse instance: %v", err)
+			}
+
+			var codes []string
+			violations := NewValidator(schema).Validate(doc)
+			for _, v := range violations {
+				codes = append(codes, v.Code)
+			}
+			if strings.Join(codes, " ") != strings.Join(tt.codes, " ") {
+				t.Fatalf("Expected violations %v, got %v", tt.codes, violations)
+			}
+			if tt.message != "" && !strings.Contains(violations[0].Message, tt.message) {
+				t.Errorf("Expected the violation to mention %q, got %q", tt.message, violations[0].Message)
+			}
+		})
+	}
+}
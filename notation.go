@@ -0,0 +1,293 @@
+package xsd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/agentflare-ai/go-xmldom"
+)
+
+// Notation represents an xs:notation declaration
+type Notation struct {
+	Name   QName
+	Public string
+	System string
+}
+
+// notationQName is the built-in NOTATION type
+var notationQName = QName{Namespace: XSDNamespace, Local: "NOTATION"}
+
+// parseNotation parses a notation declaration
+func (s *Schema) parseNotation(elem xmldom.Element) error {
+	name := string(elem.GetAttribute("name"))
+	if name == "" {
+		return nil
+	}
+
+	notation := &Notation{
+		Name: QName{
+			Namespace: s.TargetNamespace,
+			Local:     name,
+		},
+		Public: string(elem.GetAttribute("public")),
+		System: string(elem.GetAttribute("system")),
+	}
+
+	s.mu.Lock()
+	s.Notations[notation.Name] = notation
+	s.mu.Unlock()
+
+	return nil
+}
+
+// lookupNotation finds a notation declaration in the schema or its imports
+func (s *Schema) lookupNotation(name QName) *Notation {
+	if notation, ok := s.Notations[name]; ok {
+		return notation
+	}
+	for _, imported := range s.ImportedSchemas {
+		if notation, ok := imported.Notations[name]; ok {
+			return notation
+		}
+	}
+	return nil
+}
+
+// derivesFromNotation reports whether a simple type is derived from
+// xs:NOTATION by restriction
+func (s *Schema) derivesFromNotation(t Type) bool {
+	_, ok := s.notationRestrictions(t)
+	return ok
+}
+
+// notationRestrictions returns the restriction steps from a simple type up to
+// xs:NOTATION, and whether the type derives from it at all
+func (s *Schema) notationRestrictions(t Type) ([]*Restriction, bool) {
+	var steps []*Restriction
+	visited := make(map[QName]bool)
+	for t != nil {
+		name := t.Name()
+		if name == notationQName {
+			return steps, true
+		}
+		if visited[name] {
+			return nil, false
+		}
+		visited[name] = true
+
+		st, ok := t.(*SimpleType)
+		if !ok {
+			return nil, false
+		}
+		if st.Restriction == nil && st.List == nil && st.Union == nil {
+			// Placeholder for a type that was declared after its use
+			resolved, ok := s.TypeDefs[name]
+			if !ok || resolved == t {
+				return nil, false
+			}
+			t = resolved
+			continue
+		}
+		if st.Restriction == nil {
+			return nil, false
+		}
+		steps = append(steps, st.Restriction)
+
+		base := st.Restriction.Base
+		if base == notationQName {
+			return steps, true
+		}
+		if base.Namespace == XSDNamespace {
+			return nil, false
+		}
+		t = s.TypeDefs[base]
+	}
+	return nil, false
+}
+
+// checkNotationValue resolves a NOTATION value as a QName against the
+// namespaces in scope on the instance element, compares it with the
+// enumeration values of the type resolved against the schema elements that
+// declare them, and checks that it names a declared notation
+func (s *Schema) checkNotationValue(elem xmldom.Element, value string, t Type) error {
+	value = strings.TrimSpace(value)
+	prefix, local, prefixed := strings.Cut(value, ":")
+	if !prefixed {
+		prefix, local = "", value
+	}
+
+	namespace, bound := lookupNamespace(elem, prefix)
+	if !bound && prefixed {
+		return fmt.Errorf("NOTATION value '%s' uses an undeclared prefix '%s'", value, prefix)
+	}
+	name := QName{Namespace: namespace, Local: local}
+
+	steps, _ := s.notationRestrictions(t)
+	for _, step := range steps {
+		for _, facet := range step.Facets {
+			if enum, ok := facet.(*EnumerationFacet); ok && !enum.containsQName(name) {
+				return fmt.Errorf("enumeration constraint violated: value '%s' is not in enumeration %v", value, enum.Values)
+			}
+		}
+	}
+
+	if s.lookupNotation(name) == nil {
+		return fmt.Errorf("NOTATION value '%s' does not name a declared notation", value)
+	}
+	return nil
+}
+
+// notationChecker walks the declarations of a schema looking for uses of
+// xs:NOTATION that lack an enumeration facet
+type notationChecker struct {
+	schema *Schema
+	seen   map[any]bool
+	errs   []error
+}
+
+// checkNotations reports declarations and type definitions that use
+// xs:NOTATION directly, which the spec only allows through a restriction
+// with an enumeration facet
+func (s *Schema) checkNotations() []error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	c := &notationChecker{schema: s, seen: make(map[any]bool)}
+
+	names := make([]QName, 0, len(s.TypeDefs))
+	for name := range s.TypeDefs {
+		names = append(names, name)
+	}
+	sortQNames(names)
+	for _, name := range names {
+		c.checkDefinition(s.TypeDefs[name])
+	}
+
+	names = names[:0]
+	for name := range s.ElementDecls {
+		names = append(names, name)
+	}
+	sortQNames(names)
+	for _, name := range names {
+		c.checkElement(s.ElementDecls[name])
+	}
+
+	names = names[:0]
+	for name := range s.AttributeGroups {
+		names = append(names, name)
+	}
+	sortQNames(names)
+	for _, name := range names {
+		for _, attr := range s.AttributeGroups[name].Attributes {
+			c.checkAttribute(attr)
+		}
+	}
+
+	return c.errs
+}
+
+// checkDefinition checks a type definition and the declarations in it
+func (c *notationChecker) checkDefinition(t Type) {
+	if t == nil || c.seen[t] {
+		return
+	}
+	c.seen[t] = true
+
+	name := typeLabel(t)
+
+	switch tt := t.(type) {
+	case *SimpleType:
+		if tt.Restriction != nil && tt.Restriction.Base == notationQName && !hasEnumeration(tt.Restriction.Facets) {
+			c.errs = append(c.errs, typeError(t, "enumeration-required-notation",
+				"simple type '%s' restricts NOTATION without an enumeration facet", name))
+		}
+		if tt.List != nil && tt.List.ItemType == notationQName {
+			c.errs = append(c.errs, typeError(t, "enumeration-required-notation",
+				"simple type '%s' uses NOTATION as its item type", name))
+		}
+		if tt.Union != nil {
+			for _, member := range tt.Union.MemberTypes {
+				if member == notationQName {
+					c.errs = append(c.errs, typeError(t, "enumeration-required-notation",
+						"simple type '%s' uses NOTATION as a member type", name))
+				}
+			}
+		}
+	case *ComplexType:
+		for _, attr := range tt.Attributes {
+			c.checkAttribute(attr)
+		}
+		c.checkContent(tt.Content)
+	}
+}
+
+// checkUse checks the type of a declaration, which must not be NOTATION
+// itself. Anonymous types are checked as part of their declaration.
+func (c *notationChecker) checkUse(t Type, kind string, name QName) {
+	if t == nil {
+		return
+	}
+	if t.Name() == notationQName {
+		c.errs = append(c.errs, fmt.Errorf(
+			"enumeration-required-notation: %s '%s' has type NOTATION; use a type derived from it with an enumeration facet",
+			kind, name.Local))
+		return
+	}
+	if t.Name().Local == "_anonymous" {
+		c.checkDefinition(t)
+	}
+}
+
+// checkElement checks an element declaration
+func (c *notationChecker) checkElement(decl *ElementDecl) {
+	if c.seen[decl] {
+		return
+	}
+	c.seen[decl] = true
+	c.checkUse(decl.Type, "element", decl.Name)
+}
+
+// checkAttribute checks an attribute declaration
+func (c *notationChecker) checkAttribute(attr *AttributeDecl) {
+	if c.seen[attr] {
+		return
+	}
+	c.seen[attr] = true
+	c.checkUse(attr.Type, "attribute", attr.Name)
+}
+
+// checkContent checks the local element declarations of a content model
+func (c *notationChecker) checkContent(content Content) {
+	switch cc := content.(type) {
+	case *ModelGroup:
+		if c.seen[cc] {
+			return
+		}
+		c.seen[cc] = true
+		for _, particle := range cc.Particles {
+			switch p := particle.(type) {
+			case *ElementDecl:
+				c.checkElement(p)
+			case *ModelGroup:
+				c.checkContent(p)
+			}
+		}
+	case *ComplexContent:
+		if cc.Extension != nil {
+			c.checkContent(cc.Extension.Content)
+		}
+		if cc.Restriction != nil {
+			c.checkContent(cc.Restriction.Content)
+		}
+	}
+}
+
+// hasEnumeration reports whether a facet list contains an enumeration
+func hasEnumeration(facets []FacetValidator) bool {
+	for _, facet := range facets {
+		if _, ok := facet.(*EnumerationFacet); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,133 @@
+package xsd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/agentflare-ai/go-xmldom"
+)
+
+func TestNotationDeclarations(t *testing.T) {
+	schema, err := parseTestSchema(t, `<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"
+           xmlns:n="urn:notes" targetNamespace="urn:notes" elementFormDefault="qualified">
+	<xs:notation name="jpeg" public="image/jpeg" system="viewer.exe"/>
+	<xs:notation name="png" public="image/png"/>
+
+	<xs:simpleType name="format">
+		<xs:restriction base="xs:NOTATION">
+			<xs:enumeration value="n:jpeg"/>
+			<xs:enumeration value="n:png"/>
+			<xs:enumeration value="n:gif"/>
+		</xs:restriction>
+	</xs:simpleType>
+
+	<xs:element name="picture">
+		<xs:complexType>
+			<xs:sequence>
+				<xs:element name="preview" type="n:format" minOccurs="0"/>
+			</xs:sequence>
+			<xs:attribute name="format" type="n:format"/>
+		</xs:complexType>
+	</xs:element>
+</xs:schema>`)
+	if err != nil {
+		t.Fatalf("Failed to parse schema: %v", err)
+	}
+
+	jpeg := schema.Notations[QName{Namespace: "urn:notes", Local: "jpeg"}]
+	if jpeg == nil || jpeg.Public != "image/jpeg" || jpeg.System != "viewer.exe" {
+		t.Fatalf("Expected notation jpeg to be declared, got %+v", jpeg)
+	}
+
+	tests := []struct {
+		name     string
+		instance string
+		codes    []string
+	}{
+		{
+			name:     "declared notations",
+			instance: `<n:picture xmlns:n="urn:notes" format="n:jpeg"><n:preview>n:png</n:preview></n:picture>`,
+		},
+		{
+			name:     "declared notations under another prefix",
+			instance: `<p:picture xmlns:p="urn:notes" format="p:jpeg"><p:preview xmlns:q="urn:notes">q:png</p:preview></p:picture>`,
+		},
+		{
+			name:     "name outside the enumeration",
+			instance: `<n:picture xmlns:n="urn:notes"><n:preview>n:bmp</n:preview></n:picture>`,
+			codes:    []string{"cvc-datatype-valid.1"},
+		},
+		{
+			name:     "enumerated but undeclared notation",
+			instance: `<n:picture xmlns:n="urn:notes" format="n:gif"/>`,
+			codes:    []string{"cvc-datatype-valid.1.2.1"},
+		},
+		{
+			name:     "prefix bound to another namespace",
+			instance: `<p:picture xmlns:p="urn:notes"><p:preview xmlns:n="urn:other">n:png</p:preview></p:picture>`,
+			codes:    []string{"cvc-datatype-valid.1"},
+		},
+		{
+			name:     "unbound prefix",
+			instance: `<p:picture xmlns:p="urn:notes" format="n:jpeg"/>`,
+			codes:    []string{"cvc-datatype-valid.1.2.1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := xmldom.Decode(strings.NewReader(tt.instance))
+			if err != nil {
+				t.Fatalf("Failed to parse instance: %v", err)
+			}
+
+			var codes []string
+			for _, v := range NewValidator(schema).Validate(doc) {
+				codes = append(codes, v.Code)
+			}
+			if strings.Join(codes, " ") != strings.Join(tt.codes, " ") {
+				t.Errorf("Expected violations %v, got %v", tt.codes, codes)
+			}
+		})
+	}
+}
+
+func TestNotationRequiresEnumeration(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{
+			name: "restriction without enumeration",
+			body: `<xs:simpleType name="format"><xs:restriction base="xs:NOTATION"/></xs:simpleType>`,
+		},
+		{
+			name: "attribute typed as NOTATION",
+			body: `<xs:element name="picture">
+					<xs:complexType><xs:attribute name="format" type="xs:NOTATION"/></xs:complexType>
+				</xs:element>`,
+		},
+		{
+			name: "element typed as NOTATION",
+			body: `<xs:element name="format" type="xs:NOTATION"/>`,
+		},
+		{
+			name: "list of NOTATION",
+			body: `<xs:simpleType name="formats"><xs:list itemType="xs:NOTATION"/></xs:simpleType>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseTestSchema(t, `<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+	<xs:notation name="jpeg" public="image/jpeg"/>
+	`+tt.body+`
+</xs:schema>`)
+			if err == nil || !strings.Contains(err.Error(), "enumeration-required-notation") {
+				t.Errorf("Expected enumeration-required-notation error, got %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,214 @@
+package xsd
+
+import (
+	"strings"
+
+	"github.com/agentflare-ai/go-xmldom"
+)
+
+// OpenContentMode says where the elements of an open content may appear
+type OpenContentMode string
+
+const (
+	OpenContentNone       OpenContentMode = "none"       // The content model is closed
+	OpenContentInterleave OpenContentMode = "interleave" // Anywhere among the content model's elements
+	OpenContentSuffix     OpenContentMode = "suffix"     // After the content model's elements
+)
+
+// OpenContent is an XSD 1.1 xs:openContent or xs:defaultOpenContent: the
+// elements its wildcard matches may appear in content in addition to those
+// of the content model
+type OpenContent struct {
+	Mode           OpenContentMode
+	Any            *AnyElement // nil when Mode is none
+	AppliesToEmpty bool        // A default open content also opens types with empty content
+}
+
+// parseOpenContent parses an xs:openContent or xs:defaultOpenContent
+// element. XSD 1.0 has no open content, so it returns nil there.
+func (s *Schema) parseOpenContent(elem xmldom.Element) *OpenContent {
+	if !s.xsd11() {
+		return nil
+	}
+	oc := &OpenContent{
+		Mode:           OpenContentMode(elem.GetAttribute("mode")),
+		AppliesToEmpty: booleanAttribute(elem, "appliesToEmpty", false),
+	}
+	if oc.Mode == "" {
+		oc.Mode = OpenContentInterleave
+	}
+
+	children := s.schemaChildren(elem)
+	for i := uint(0); i < children.Length(); i++ {
+		child := children.Item(i)
+		if child != nil && string(child.NamespaceURI()) == XSDNamespace && string(child.LocalName()) == "any" {
+			oc.Any = s.parseAnyElement(child)
+			oc.Any.MinOcc, oc.Any.MaxOcc = 0, -1
+		}
+	}
+	if oc.Mode == OpenContentNone {
+		oc.Any = nil
+	}
+	return oc
+}
+
+// parseDefaultOpenContent parses the xs:defaultOpenContent of a schema
+// document, or returns nil when it has none
+func (s *Schema) parseDefaultOpenContent(root xmldom.Element) *OpenContent {
+	children := s.schemaChildren(root)
+	for i := uint(0); i < children.Length(); i++ {
+		child := children.Item(i)
+		if child != nil && string(child.NamespaceURI()) == XSDNamespace && string(child.LocalName()) == "defaultOpenContent" {
+			return s.parseOpenContent(child)
+		}
+	}
+	return nil
+}
+
+// declaredOpenContent returns the xs:openContent of a complex type
+// definition, including one inside its complex content derivation
+func declaredOpenContent(ct *ComplexType) *OpenContent {
+	if cc, ok := declaredContent(ct).(*ComplexContent); ok {
+		if cc.Extension != nil && cc.Extension.OpenContent != nil {
+			return cc.Extension.OpenContent
+		}
+		if cc.Restriction != nil && cc.Restriction.OpenContent != nil {
+			return cc.Restriction.OpenContent
+		}
+	}
+	return ct.OpenContent
+}
+
+// OpenContent returns the open content of a complex type, or nil when its
+// content model is closed. A type's own xs:openContent takes precedence over
+// the default of its schema document, which applies to types with empty
+// content only when appliesToEmpty is set. Extensions keep the open content
+// of their base type, with the wildcards of both combined.
+func (s *Schema) OpenContent(ct *ComplexType) *OpenContent {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.openContentLocked(ct, make(map[*ComplexType]bool))
+}
+
+func (s *Schema) openContentLocked(ct *ComplexType, visited map[*ComplexType]bool) *OpenContent {
+	if ct == nil || visited[ct] {
+		return nil
+	}
+	visited[ct] = true
+
+	if _, ok := ct.Content.(*SimpleContent); ok {
+		return nil
+	}
+
+	own := declaredOpenContent(ct)
+	if own == nil && ct.defaultOpenContent != nil &&
+		(declaredParticle(ct) != nil || ct.defaultOpenContent.AppliesToEmpty) {
+		own = ct.defaultOpenContent
+	}
+	if own != nil && own.Any == nil {
+		return nil
+	}
+
+	if ct.DerivedBy != DerivationExtension {
+		return own
+	}
+	base, _ := s.lookupTypeLocked(ct.BaseType).(*ComplexType)
+	inherited := s.openContentLocked(base, visited)
+	if inherited == nil {
+		return own
+	}
+	if own == nil {
+		return inherited
+	}
+	return &OpenContent{Mode: own.Mode, Any: s.elementWildcardUnion(inherited.Any, own.Any)}
+}
+
+// elementWildcardUnion returns a wildcard matching the elements either
+// wildcard matches, with the process contents of the second. A name stays
+// excluded only when neither wildcard matches it.
+func (s *Schema) elementWildcardUnion(a, b *AnyElement) *AnyElement {
+	union := &AnyElement{ProcessContents: b.ProcessContents, MaxOcc: -1}
+	if a.NotNamespace == "" && b.NotNamespace == "" {
+		union.Namespace = s.wildcardUnion(
+			&AnyAttribute{Namespace: a.Namespace},
+			&AnyAttribute{Namespace: b.Namespace}).Namespace
+	} else {
+		// Either side excludes a list of namespaces; the union excludes
+		// those of them the other side does not allow
+		var excluded []string
+		for _, pair := range [][2]*AnyElement{{a, b}, {b, a}} {
+			notNamespace := &WildcardNamespaceConstraint{Mode: "list", Namespaces: strings.Fields(pair[0].NotNamespace)}
+			for _, ns := range s.wildcardNamespaces(notNamespace) {
+				if !pair[1].admitsName(QName{Namespace: ns}, s) {
+					excluded = append(excluded, ns)
+				}
+			}
+		}
+		if len(excluded) > 0 {
+			union.NotNamespace = s.formatWildcardNamespaces(excluded)
+		}
+	}
+
+	for _, pair := range [][2]*AnyElement{{a, b}, {b, a}} {
+		for _, name := range pair[0].NotQName {
+			if !pair[1].admitsName(name, s) {
+				union.NotQName = append(union.NotQName, name)
+			}
+		}
+	}
+	union.NotDefined = a.NotDefined && b.NotDefined
+	union.NotDefinedSibling = a.NotDefinedSibling && b.NotDefinedSibling
+	return union
+}
+
+// validateContent validates the children of an element against the content
+// model of its complex type, with the type's open content
+func (s *Schema) validateContent(element xmldom.Element, ct *ComplexType) []Violation {
+	open := s.OpenContent(ct)
+	if open == nil {
+		if ct.Content == nil {
+			return nil
+		}
+		return ct.Content.Validate(element, s)
+	}
+
+	var mg *ModelGroup
+	switch p := s.contentParticle(ct).(type) {
+	case *ModelGroup:
+		mg = p
+	case nil:
+		// Empty content, opened by the default open content
+		mg = &ModelGroup{Kind: SequenceGroup, MinOcc: 1, MaxOcc: 1}
+	default:
+		// A single particle, such as an element reference or a wildcard,
+		// opened as the only particle of a sequence
+		mg = &ModelGroup{Kind: SequenceGroup, Particles: []Particle{p}, MinOcc: 1, MaxOcc: 1}
+	}
+	return mg.validateOpen(element, open, s)
+}
+
+// splitOpenContent separates the children the open content absorbs from
+// those the content model validates. In interleave mode, each child is
+// offered to the model first and absorbed when the model cannot take it at
+// that point but the wildcard matches it; in suffix mode, wildcard matches
+// after the elements the model consumes are.
+func (mg *ModelGroup) splitOpenContent(children []xmldom.Element, open *OpenContent, schema *Schema) (model, absorbed []xmldom.Element) {
+	start := 0
+	if open.Mode == OpenContentSuffix {
+		start = mg.countConsumedByGroup(mg, children, schema)
+		model = append(model, children[:start]...)
+	}
+	for _, child := range children[start:] {
+		matches := open.Any.admits(child, schema, mg)
+		if open.Mode == OpenContentInterleave && matches {
+			candidate := append(model[:len(model):len(model)], child)
+			matches = mg.countConsumedByGroup(mg, candidate, schema) < len(candidate)
+		}
+		if matches {
+			absorbed = append(absorbed, child)
+		} else {
+			model = append(model, child)
+		}
+	}
+	return model, absorbed
+}
@@ -0,0 +1,361 @@
+package xsd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/agentflare-ai/go-xmldom"
+)
+
+func TestOpenContent(t *testing.T) {
+	schema, err := parseTestSchema11(t, `<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"
+           xmlns:t="http://example.com/open" targetNamespace="http://example.com/open"
+           xmlns:x="urn:x" elementFormDefault="qualified">
+	<xs:complexType name="interleaved">
+		<xs:openContent>
+			<xs:any namespace="##other" processContents="lax"/>
+		</xs:openContent>
+		<xs:sequence>
+			<xs:element name="a" type="xs:string"/>
+			<xs:element name="b" type="xs:string"/>
+		</xs:sequence>
+	</xs:complexType>
+	<xs:complexType name="suffixed">
+		<xs:openContent mode="suffix">
+			<xs:any namespace="##any" processContents="skip"/>
+		</xs:openContent>
+		<xs:sequence>
+			<xs:element name="a" type="xs:string"/>
+			<xs:element name="b" type="xs:string" minOccurs="0"/>
+		</xs:sequence>
+	</xs:complexType>
+	<xs:complexType name="extended">
+		<xs:complexContent>
+			<xs:extension base="t:interleaved">
+				<xs:sequence>
+					<xs:element name="c" type="xs:string"/>
+				</xs:sequence>
+			</xs:extension>
+		</xs:complexContent>
+	</xs:complexType>
+	<xs:complexType name="anywhere">
+		<xs:openContent>
+			<xs:any namespace="##any" processContents="skip"/>
+		</xs:openContent>
+		<xs:sequence>
+			<xs:element name="x" type="xs:string"/>
+			<xs:element name="y" type="xs:string"/>
+		</xs:sequence>
+	</xs:complexType>
+	<xs:complexType name="closed">
+		<xs:sequence>
+			<xs:element name="a" type="xs:string"/>
+		</xs:sequence>
+	</xs:complexType>
+	<xs:complexType name="excluding">
+		<xs:openContent>
+			<xs:any notNamespace="urn:banned" notQName="x:secret ##definedSibling" processContents="skip"/>
+		</xs:openContent>
+		<xs:sequence>
+			<xs:element name="a" type="xs:string"/>
+		</xs:sequence>
+	</xs:complexType>
+	<xs:complexType name="excludingExtended">
+		<xs:complexContent>
+			<xs:extension base="t:excluding">
+				<xs:openContent>
+					<xs:any namespace="urn:banned" processContents="skip"/>
+				</xs:openContent>
+				<xs:sequence>
+					<xs:element name="b" type="xs:string"/>
+				</xs:sequence>
+			</xs:extension>
+		</xs:complexContent>
+	</xs:complexType>
+	<xs:group name="pair">
+		<xs:sequence>
+			<xs:element name="a" type="xs:string"/>
+			<xs:element name="b" type="xs:string"/>
+		</xs:sequence>
+	</xs:group>
+	<xs:complexType name="grouped">
+		<xs:complexContent>
+			<xs:restriction base="xs:anyType">
+				<xs:openContent>
+					<xs:any namespace="##other" processContents="skip"/>
+				</xs:openContent>
+				<xs:group ref="t:pair"/>
+			</xs:restriction>
+		</xs:complexContent>
+	</xs:complexType>
+	<xs:element name="interleaved" type="t:interleaved"/>
+	<xs:element name="excluding" type="t:excluding"/>
+	<xs:element name="excludingExtended" type="t:excludingExtended"/>
+	<xs:element name="grouped" type="t:grouped"/>
+	<xs:element name="suffixed" type="t:suffixed"/>
+	<xs:element name="extended" type="t:extended"/>
+	<xs:element name="anywhere" type="t:anywhere"/>
+	<xs:element name="closed" type="t:closed"/>
+	<xs:element name="wrapper">
+		<xs:complexType>
+			<xs:sequence>
+				<xs:element name="inner" type="t:interleaved"/>
+			</xs:sequence>
+		</xs:complexType>
+	</xs:element>
+	<xs:element name="strict">
+		<xs:complexType>
+			<xs:openContent>
+				<xs:any namespace="##targetNamespace" processContents="strict"/>
+			</xs:openContent>
+			<xs:sequence>
+				<xs:element name="a" type="xs:string"/>
+			</xs:sequence>
+		</xs:complexType>
+	</xs:element>
+	<xs:simpleType name="number">
+		<xs:restriction base="xs:int"/>
+	</xs:simpleType>
+	<xs:element name="count" type="t:number"/>
+</xs:schema>`)
+	if err != nil {
+		t.Fatalf("Failed to parse schema: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		instance string
+		codes    []string
+	}{
+		{
+			name:     "interleaved extension elements",
+			instance: `<interleaved xmlns="http://example.com/open" xmlns:x="urn:x"><x:new/><a/><x:more/><b/><x:last/></interleaved>`,
+		},
+		{
+			name:     "interleave still checks the content model",
+			instance: `<interleaved xmlns="http://example.com/open" xmlns:x="urn:x"><x:new/><a/><x:more/></interleaved>`,
+			codes:    []string{"cvc-complex-type.2.4.b"},
+		},
+		{
+			name:     "namespace constraint of the open content",
+			instance: `<interleaved xmlns="http://example.com/open"><a/><b/><c/></interleaved>`,
+			codes:    []string{"cvc-complex-type.2.4.d"},
+		},
+		{
+			name:     "declared names the model cannot take at that point",
+			instance: `<anywhere xmlns="http://example.com/open"><x/><y/><x/></anywhere>`,
+		},
+		{
+			name:     "declared names before the model takes them",
+			instance: `<anywhere xmlns="http://example.com/open"><x/><x/><y/></anywhere>`,
+		},
+		{
+			name:     "suffix after the content model",
+			instance: `<suffixed xmlns="http://example.com/open" xmlns:x="urn:x"><a/><b/><x:new/><c/></suffixed>`,
+		},
+		{
+			name:     "suffix after optional particles",
+			instance: `<suffixed xmlns="http://example.com/open"><a/><c/></suffixed>`,
+		},
+		{
+			name:     "suffix does not interleave",
+			instance: `<suffixed xmlns="http://example.com/open" xmlns:x="urn:x"><x:new/><a/></suffixed>`,
+			codes:    []string{"cvc-complex-type.2.4.b"},
+		},
+		{
+			name:     "extensions inherit open content",
+			instance: `<extended xmlns="http://example.com/open" xmlns:x="urn:x"><a/><x:new/><b/><c/><x:last/></extended>`,
+		},
+		{
+			name:     "closed content",
+			instance: `<closed xmlns="http://example.com/open" xmlns:x="urn:x"><a/><x:new/></closed>`,
+			codes:    []string{"cvc-complex-type.2.4.d"},
+		},
+		{
+			name:     "nested element with open content",
+			instance: `<wrapper xmlns="http://example.com/open" xmlns:x="urn:x"><inner><a/><x:new/><b/></inner></wrapper>`,
+		},
+		{
+			name:     "notNamespace and notQName leave other elements open",
+			instance: `<excluding xmlns="http://example.com/open" xmlns:x="urn:x"><x:new/><a/><other/></excluding>`,
+		},
+		{
+			name:     "notNamespace excludes a namespace",
+			instance: `<excluding xmlns="http://example.com/open" xmlns:b="urn:banned"><a/><b:new/></excluding>`,
+			codes:    []string{"cvc-complex-type.2.4.d"},
+		},
+		{
+			name:     "notQName excludes a name",
+			instance: `<excluding xmlns="http://example.com/open" xmlns:x="urn:x"><a/><x:secret/></excluding>`,
+			codes:    []string{"cvc-complex-type.2.4.d"},
+		},
+		{
+			name:     "notQName excludes the names of the content model",
+			instance: `<excluding xmlns="http://example.com/open"><a/><a/></excluding>`,
+			codes:    []string{"cvc-complex-type.2.4.d"},
+		},
+		{
+			name:     "extension wildcards allow what the base excludes",
+			instance: `<excludingExtended xmlns="http://example.com/open" xmlns:b="urn:banned"><a/><b:new/><b/></excludingExtended>`,
+		},
+		{
+			name:     "extension wildcards keep other exclusions",
+			instance: `<excludingExtended xmlns="http://example.com/open" xmlns:x="urn:x"><a/><b/><x:secret/></excludingExtended>`,
+			codes:    []string{"cvc-complex-type.2.4.d"},
+		},
+		{
+			name:     "open content around a group reference",
+			instance: `<grouped xmlns="http://example.com/open" xmlns:x="urn:x"><a/><x:new/><b/><x:last/></grouped>`,
+		},
+		{
+			name:     "strict matches are validated",
+			instance: `<strict xmlns="http://example.com/open"><count>1</count><a/><count>two</count></strict>`,
+			codes:    []string{"cvc-datatype-valid.1"},
+		},
+		{
+			name:     "strict matches need a declaration",
+			instance: `<strict xmlns="http://example.com/open"><a/><unknown/></strict>`,
+			codes:    []string{"cvc-assess-elt.1.1.1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := xmldom.Decode(strings.NewReader(tt.instance))
+			if err != nil {
+				t.Fatalf("Failed to parse instance: %v", err)
+			}
+
+			var codes []string
+			for _, v := range NewValidator(schema).Validate(doc) {
+				codes = append(codes, v.Code)
+			}
+			if strings.Join(codes, " ") != strings.Join(tt.codes, " ") {
+				t.Errorf("Expected violations %v, got %v", tt.codes, codes)
+			}
+		})
+	}
+}
+
+func TestDefaultOpenContent(t *testing.T) {
+	schema, err := parseTestSchema11(t, `<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"
+           xmlns:t="http://example.com/open" targetNamespace="http://example.com/open"
+           elementFormDefault="qualified">
+	<xs:defaultOpenContent mode="suffix">
+		<xs:any namespace="##other" processContents="lax"/>
+	</xs:defaultOpenContent>
+	<xs:complexType name="payload">
+		<xs:sequence>
+			<xs:element name="id" type="xs:string"/>
+		</xs:sequence>
+	</xs:complexType>
+	<xs:complexType name="sealed">
+		<xs:openContent mode="none"/>
+		<xs:sequence>
+			<xs:element name="id" type="xs:string"/>
+		</xs:sequence>
+	</xs:complexType>
+	<xs:complexType name="empty"/>
+	<xs:element name="payload" type="t:payload"/>
+	<xs:element name="sealed" type="t:sealed"/>
+	<xs:element name="empty" type="t:empty"/>
+</xs:schema>`)
+	if err != nil {
+		t.Fatalf("Failed to parse schema: %v", err)
+	}
+
+	tests := []struct {
+		instance string
+		codes    []string
+	}{
+		{instance: `<payload xmlns="http://example.com/open" xmlns:v2="urn:v2"><id/><v2:extra/></payload>`},
+		{instance: `<sealed xmlns="http://example.com/open" xmlns:v2="urn:v2"><id/><v2:extra/></sealed>`, codes: []string{"cvc-complex-type.2.4.d"}},
+		{instance: `<empty xmlns="http://example.com/open" xmlns:v2="urn:v2"><v2:extra/></empty>`, codes: []string{"cvc-complex-type.2.1"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.instance, func(t *testing.T) {
+			doc, err := xmldom.Decode(strings.NewReader(tt.instance))
+			if err != nil {
+				t.Fatalf("Failed to parse instance: %v", err)
+			}
+
+			var codes []string
+			for _, v := range NewValidator(schema).Validate(doc) {
+				codes = append(codes, v.Code)
+			}
+			if strings.Join(codes, " ") != strings.Join(tt.codes, " ") {
+				t.Errorf("Expected violations %v, got %v", tt.codes, codes)
+			}
+		})
+	}
+
+	if oc := schema.OpenContent(schema.TypeDefs[QName{Namespace: "http://example.com/open", Local: "payload"}].(*ComplexType)); oc == nil || oc.Mode != OpenContentSuffix {
+		t.Errorf("Expected the default open content to apply, got %+v", oc)
+	}
+
+	// appliesToEmpty is an xs:boolean
+	schema, err = parseTestSchema11(t, `<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"
+           xmlns:t="http://example.com/open" targetNamespace="http://example.com/open">
+	<xs:defaultOpenContent appliesToEmpty="1">
+		<xs:any namespace="##other" processContents="lax"/>
+	</xs:defaultOpenContent>
+	<xs:complexType name="empty"/>
+</xs:schema>`)
+	if err != nil {
+		t.Fatalf("Failed to parse schema: %v", err)
+	}
+	if oc := schema.OpenContent(schema.TypeDefs[QName{Namespace: "http://example.com/open", Local: "empty"}].(*ComplexType)); oc == nil {
+		t.Error("Expected appliesToEmpty=\"1\" to open the empty type")
+	}
+}
+
+func TestOpenContentXSD10(t *testing.T) {
+	// xs:openContent and xs:defaultOpenContent are XSD 1.1; a 1.0 schema
+	// ignores them and keeps content models closed
+	schema, err := parseTestSchema(t, `<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"
+           xmlns:t="http://example.com/open" targetNamespace="http://example.com/open"
+           elementFormDefault="qualified">
+	<xs:defaultOpenContent mode="suffix">
+		<xs:any namespace="##other" processContents="lax"/>
+	</xs:defaultOpenContent>
+	<xs:complexType name="payload">
+		<xs:sequence>
+			<xs:element name="id" type="xs:string"/>
+		</xs:sequence>
+	</xs:complexType>
+	<xs:complexType name="open">
+		<xs:openContent>
+			<xs:any namespace="##other" processContents="lax"/>
+		</xs:openContent>
+		<xs:sequence>
+			<xs:element name="id" type="xs:string"/>
+		</xs:sequence>
+	</xs:complexType>
+	<xs:element name="payload" type="t:payload"/>
+	<xs:element name="open" type="t:open"/>
+</xs:schema>`)
+	if err != nil {
+		t.Fatalf("Failed to parse schema: %v", err)
+	}
+	if schema.DefaultOpenContent != nil {
+		t.Errorf("Expected no default open content under XSD 1.0, got %+v", schema.DefaultOpenContent)
+	}
+
+	for _, instance := range []string{
+		`<payload xmlns="http://example.com/open" xmlns:v2="urn:v2"><id/><v2:extra/></payload>`,
+		`<open xmlns="http://example.com/open" xmlns:v2="urn:v2"><v2:extra/><id/></open>`,
+	} {
+		doc, err := xmldom.Decode(strings.NewReader(instance))
+		if err != nil {
+			t.Fatalf("Failed to parse instance: %v", err)
+		}
+		if violations := NewValidator(schema).Validate(doc); len(violations) == 0 {
+			t.Errorf("%s: expected the extra element to be rejected under XSD 1.0", instance)
+		}
+	}
+}
@@ -1,11 +1,13 @@
 package xsd
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 
 	"github.com/agentflare-ai/go-xmldom"
 )
@@ -15,16 +17,26 @@ const XSDNamespace = "http://www.w3.org/2001/XMLSchema"
 
 // Schema represents a compiled XSD schema
 type Schema struct {
-	mu                 sync.RWMutex
-	TargetNamespace    string
-	ElementDecls       map[QName]*ElementDecl
-	TypeDefs           map[QName]Type
-	AttributeGroups    map[QName]*AttributeGroup
-	Groups             map[QName]*ModelGroup
-	Imports            []*Import
-	ImportedSchemas    map[string]*Schema // Map of imported schemas by location
-	SubstitutionGroups map[QName][]QName  // Maps head element to list of substitutable elements
-	doc                xmldom.Document
+	mu                   sync.RWMutex
+	TargetNamespace      string
+	ElementFormDefault   Form          // Form of local element declarations without a form attribute
+	AttributeFormDefault Form          // Form of local attribute declarations without a form attribute
+	BlockDefault         DerivationSet // Block of declarations and types without a block attribute
+	FinalDefault         DerivationSet // Final of declarations and types without a final attribute
+	DefaultOpenContent   *OpenContent  // xs:defaultOpenContent of the schema document
+	DefaultAttributes    QName         // Attribute group named by defaultAttributes, zero when none
+	Version              Version       // XSD version whose rules apply, XSD 1.0 when empty
+	ElementDecls         map[QName]*ElementDecl
+	AttributeDecls       map[QName]*AttributeDecl // Global attribute declarations
+	TypeDefs             map[QName]Type
+	AttributeGroups      map[QName]*AttributeGroup
+	Groups               map[QName]*ModelGroup
+	Imports              []*Import
+	ImportedSchemas      map[string]*Schema // Map of imported schemas by location
+	SubstitutionGroups   map[QName][]QName  // Maps head element to list of substitutable elements
+	Notations            map[QName]*Notation
+	doc                  xmldom.Document
+	resolveErrors        []error // QName references with unbound prefixes found while parsing
 }
 
 // QName represents a qualified XML name
@@ -41,6 +53,22 @@ func (q QName) String() string {
 	return fmt.Sprintf("{%s}%s", q.Namespace, q.Local)
 }
 
+// SchemaError is a schema component constraint violation, located at the
+// definition in the schema document that breaks it
+type SchemaError struct {
+	Code    string // Schema component constraint, e.g. cos-ct-extends.1.4
+	Message string
+	Line    int // Position of the definition, 0 when unknown
+	Column  int
+}
+
+func (e *SchemaError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("%s: %s (line %d, column %d)", e.Code, e.Message, e.Line, e.Column)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
 // ElementDecl represents an element declaration
 type ElementDecl struct {
 	Name              QName
@@ -53,6 +81,10 @@ type ElementDecl struct {
 	Default           string
 	Fixed             string
 	Constraints       []*IdentityConstraint // Identity constraints (key, keyref, unique)
+	Form              Form                  // Form of a local declaration, empty for global ones
+	Block             DerivationSet         // Substitutions disallowed for this element
+	Final             DerivationSet         // Derivations disallowed for substitution group members
+	Alternatives      []*TypeAlternative    // Conditional type assignment (xs:alternative), in order
 }
 
 // Type is the interface for all XSD types
@@ -68,6 +100,16 @@ type SimpleType struct {
 	Restriction *Restriction
 	List        *List
 	Union       *Union
+	Final       DerivationSet  // Derivations disallowed from this type
+	source      xmldom.Element // Definition in the schema document
+
+	// Effective facets of a restriction chain, computed when the schema is
+	// compiled
+	facets         []FacetValidator
+	builtin        QName
+	facetsCompiled bool
+
+	whiteSpace string // Whitespace processing of values, set by compileWhiteSpace
 }
 
 // ComplexType represents an XSD complex type
@@ -79,6 +121,27 @@ type ComplexType struct {
 	AnyAttribute   *AnyAttribute
 	Mixed          bool
 	Abstract       bool
+	Block          DerivationSet    // Derived types disallowed in place of this type
+	Final          DerivationSet    // Derivations disallowed from this type
+	BaseType       QName            // Base type of a simpleContent or complexContent derivation
+	DerivedBy      DerivationMethod // Method of that derivation, empty otherwise
+	source         xmldom.Element   // Definition in the schema document
+	declared       Content          // Content before an extension merged in its base
+
+	// Effective attribute uses and wildcard, computed by compileAttributeUses
+	attributeUses      []*AttributeDecl
+	attributeWildcard  *AnyAttribute
+	attributesCompiled bool
+
+	whiteSpace string // Whitespace processing of simple content, set by compileWhiteSpace
+
+	Assertions []*Assertion // xs:assert children, for types without simple or complex content
+
+	OpenContent        *OpenContent // xs:openContent child, for types without complex content
+	defaultOpenContent *OpenContent // xs:defaultOpenContent of the schema document
+
+	DefaultAttributesApply bool  // Whether the defaultAttributes of the schema document apply
+	defaultAttributes      QName // defaultAttributes of the schema document
 }
 
 // Content represents element content model
@@ -105,8 +168,10 @@ type ComplexContent struct {
 type ModelGroup struct {
 	Kind      ModelGroupKind // sequence, choice, all
 	Particles []Particle
-	MinOcc    int // Renamed to avoid conflict with method
-	MaxOcc    int // Renamed to avoid conflict with method
+	MinOcc    int            // Renamed to avoid conflict with method
+	MaxOcc    int            // Renamed to avoid conflict with method
+	source    xmldom.Element // Definition in the schema document
+	declared  []Particle     // Particles before group references were resolved
 }
 
 // ModelGroupKind represents the kind of model group
@@ -141,10 +206,14 @@ type GroupRef struct {
 
 // AnyElement represents xs:any wildcard
 type AnyElement struct {
-	Namespace       string
-	ProcessContents string
-	MinOcc          int
-	MaxOcc          int
+	Namespace         string
+	NotNamespace      string // XSD 1.1 namespaces the wildcard excludes
+	NotQName          []QName
+	NotDefined        bool // notQName has ##defined: names of global element declarations are excluded
+	NotDefinedSibling bool // notQName has ##definedSibling: names declared in the content model are excluded
+	ProcessContents   string
+	MinOcc            int
+	MaxOcc            int
 }
 
 // AttributeDecl represents an attribute declaration
@@ -154,6 +223,8 @@ type AttributeDecl struct {
 	Use     AttributeUse
 	Default string
 	Fixed   string
+	Form    Form  // Whether the attribute is namespace-qualified in instances
+	Ref     QName // Global declaration an attribute use refers to, zero for local declarations
 }
 
 // AttributeUse represents attribute use
@@ -165,10 +236,29 @@ const (
 	ProhibitedUse AttributeUse = "prohibited"
 )
 
+// Form represents whether a local declaration's name is namespace-qualified
+// in instance documents
+type Form string
+
+const (
+	QualifiedForm   Form = "qualified"
+	UnqualifiedForm Form = "unqualified"
+)
+
+// Version is a version of XML Schema a schema is processed by
+type Version string
+
+const (
+	Version10 Version = "1.0"
+	Version11 Version = "1.1"
+)
+
 // AttributeGroup represents a group of attributes
 type AttributeGroup struct {
-	Name       QName
-	Attributes []*AttributeDecl
+	Name           QName
+	Attributes     []*AttributeDecl
+	AttributeGroup []QName       // Nested attribute group references
+	AnyAttribute   *AnyAttribute // Attribute wildcard of the group
 }
 
 // Restriction represents a restriction on a type
@@ -176,9 +266,21 @@ type Restriction struct {
 	Base         QName
 	Facets       []FacetValidator
 	// For complexContent restrictions
-	Content      Content
-	Attributes   []*AttributeDecl
-	AnyAttribute *AnyAttribute
+	Content        Content
+	Attributes     []*AttributeDecl
+	AttributeGroup []QName
+	AnyAttribute   *AnyAttribute
+	Assertions     []*Assertion
+	OpenContent    *OpenContent
+
+	facetValues map[string]facetValue // Lexical values of the facets other than pattern and enumeration
+}
+
+// facetValue is the value of a constraining facet as declared, and whether
+// it is fixed for types derived from the restriction
+type facetValue struct {
+	value string
+	fixed bool
 }
 
 // Facet represents a constraining facet (deprecated - use FacetValidator from facets.go)
@@ -198,10 +300,13 @@ type Union struct {
 
 // Extension represents type extension
 type Extension struct {
-	Base         QName
-	Attributes   []*AttributeDecl
-	Content      Content
-	AnyAttribute *AnyAttribute
+	Base           QName
+	Attributes     []*AttributeDecl
+	AttributeGroup []QName
+	Content        Content
+	AnyAttribute   *AnyAttribute
+	Assertions     []*Assertion
+	OpenContent    *OpenContent
 }
 
 // AnyAttribute represents xs:anyAttribute
@@ -227,6 +332,12 @@ type Violation struct {
 	Message   string
 	Expected  []string
 	Actual    string
+	// Alternative is the type alternative that selected the type the
+	// element was validated against, if any
+	Alternative *TypeAlternative
+	// Related is a second element the violation involves, such as the
+	// first element with a duplicate key or the scope of a keyref
+	Related xmldom.Element
 }
 
 // LoadSchema loads and parses an XSD schema from a file
@@ -254,6 +365,17 @@ func LoadSchema(filename string) (*Schema, error) {
 
 // Parse parses an XSD schema from an XML document
 func Parse(doc xmldom.Document) (*Schema, error) {
+	return ParseVersion(doc, Version10)
+}
+
+// xsd11 reports whether the rules of XSD 1.1 apply to the schema
+func (s *Schema) xsd11() bool {
+	return s.Version == Version11
+}
+
+// ParseVersion parses a schema document with the rules of an XSD version,
+// which decides for instance what xs:all groups may contain
+func ParseVersion(doc xmldom.Document, version Version) (*Schema, error) {
 	if doc == nil {
 		return nil, fmt.Errorf("nil document")
 	}
@@ -270,11 +392,14 @@ func Parse(doc xmldom.Document) (*Schema, error) {
 
 	schema := &Schema{
 		ElementDecls:       make(map[QName]*ElementDecl),
+		AttributeDecls:     make(map[QName]*AttributeDecl),
 		TypeDefs:           make(map[QName]Type),
 		AttributeGroups:    make(map[QName]*AttributeGroup),
 		Groups:             make(map[QName]*ModelGroup),
 		ImportedSchemas:    make(map[string]*Schema),
 		SubstitutionGroups: make(map[QName][]QName),
+		Notations:          make(map[QName]*Notation),
+		Version:            version,
 		doc:                doc,
 	}
 
@@ -283,8 +408,30 @@ func Parse(doc xmldom.Document) (*Schema, error) {
 		schema.TargetNamespace = string(tns)
 	}
 
+	// Local declarations are unqualified unless the schema says otherwise
+	schema.ElementFormDefault = UnqualifiedForm
+	if form := Form(root.GetAttribute("elementFormDefault")); form == QualifiedForm {
+		schema.ElementFormDefault = form
+	}
+	schema.AttributeFormDefault = UnqualifiedForm
+	if form := Form(root.GetAttribute("attributeFormDefault")); form == QualifiedForm {
+		schema.AttributeFormDefault = form
+	}
+
+	schema.BlockDefault = ParseDerivationSet(string(root.GetAttribute("blockDefault")),
+		DerivationExtension, DerivationRestriction, DerivationSubstitution)
+	schema.FinalDefault = ParseDerivationSet(string(root.GetAttribute("finalDefault")),
+		DerivationExtension, DerivationRestriction, DerivationList, DerivationUnion)
+
+	// The default open content applies to the complex types of this
+	// document, wherever they are defined in it
+	schema.DefaultOpenContent = schema.parseDefaultOpenContent(root)
+	if ref := string(root.GetAttribute("defaultAttributes")); ref != "" && schema.xsd11() {
+		schema.DefaultAttributes = schema.parseQName(root, ref)
+	}
+
 	// Parse schema components
-	children := root.Children()
+	children := schema.schemaChildren(root)
 	for i := uint(0); i < children.Length(); i++ {
 		child := children.Item(i)
 		if child == nil {
@@ -300,6 +447,10 @@ func Parse(doc xmldom.Document) (*Schema, error) {
 			if err := schema.parseElement(child); err != nil {
 				return nil, err
 			}
+		case "attribute":
+			if err := schema.parseGlobalAttribute(child); err != nil {
+				return nil, err
+			}
 		case "simpleType":
 			if err := schema.parseSimpleType(child); err != nil {
 				return nil, err
@@ -320,12 +471,31 @@ func Parse(doc xmldom.Document) (*Schema, error) {
 			if err := schema.parseImport(child); err != nil {
 				return nil, err
 			}
+		case "notation":
+			if err := schema.parseNotation(child); err != nil {
+				return nil, err
+			}
 		}
 	}
 
+	if len(schema.resolveErrors) > 0 {
+		return nil, errors.Join(schema.resolveErrors...)
+	}
+
 	// Second pass: resolve type references
 	schema.resolveReferences()
 
+	errs := append(schema.checkFinalDerivations(), schema.checkNotations()...)
+	errs = append(errs, schema.checkComplexDerivations()...)
+	errs = append(errs, schema.checkModelGroups()...)
+	errs = append(errs, schema.checkFacets()...)
+	errs = append(errs, schema.checkAssertions()...)
+	errs = append(errs, schema.checkTypeAlternatives()...)
+	errs = append(errs, schema.checkIdentityConstraints()...)
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
 	return schema, nil
 }
 
@@ -399,6 +569,15 @@ func (s *Schema) resolveReferences() {
 		}
 	}
 
+	// And in the anonymous complex types of type alternatives
+	for _, elemDecl := range s.elementDeclarations() {
+		for _, alt := range elemDecl.Alternatives {
+			if ct, ok := alt.Type.(*ComplexType); ok && ct.QName.Local == "_anonymous" {
+				s.resolveTypesInComplexType(ct)
+			}
+		}
+	}
+
 	// Also resolve particles in standalone groups
 	for _, group := range s.Groups {
 		group.Particles = s.resolveParticles(group.Particles)
@@ -438,6 +617,11 @@ func (s *Schema) resolveReferences() {
 
 	// Build substitution group registry
 	s.buildSubstitutionGroups()
+
+	// Attribute uses follow derivation chains, so they need resolved types
+	s.compileAttributeUses()
+	s.compileFacets()
+	s.compileWhiteSpace()
 }
 
 // buildSubstitutionGroups builds the substitution group registry
@@ -488,6 +672,11 @@ func (s *Schema) isSubstitutableFor(actualElement, expectedElement QName) bool {
 					return true
 				}
 
+				// The head may block substitution outright or by the derivation used
+				if s.substitutionBlocked(actualDecl, expectedDecl) != "" {
+					return false
+				}
+
 				// Both declarations exist - check type compatibility
 				compatible := s.isTypeCompatible(actualDecl.Type, expectedDecl.Type)
 				if compatible {
@@ -611,6 +800,8 @@ func (s *Schema) parseElementWithContext(elem xmldom.Element, isGlobal bool) err
 		MinOcc:      1,
 		MaxOcc:      1,
 		Constraints: make([]*IdentityConstraint, 0),
+		Block:       s.parseBlock(elem, DerivationExtension, DerivationRestriction, DerivationSubstitution),
+		Final:       s.parseFinal(elem, DerivationExtension, DerivationRestriction),
 	}
 
 	// Parse attributes
@@ -640,7 +831,7 @@ func (s *Schema) parseElementWithContext(elem xmldom.Element, isGlobal bool) err
 
 	// Parse substitutionGroup attribute
 	if substGroup := string(elem.GetAttribute("substitutionGroup")); substGroup != "" {
-		decl.SubstitutionGroup = s.parseQName(substGroup)
+		decl.SubstitutionGroup = s.parseQName(elem, substGroup)
 	}
 
 	decl.Default = string(elem.GetAttribute("default"))
@@ -648,11 +839,11 @@ func (s *Schema) parseElementWithContext(elem xmldom.Element, isGlobal bool) err
 
 	// Parse type
 	if typeName := string(elem.GetAttribute("type")); typeName != "" {
-		decl.Type = s.resolveType(typeName)
+		decl.Type = s.resolveType(elem, typeName)
 	}
 
 	// Parse child elements for inline type definitions and identity constraints
-	children := elem.Children()
+	children := s.schemaChildren(elem)
 	for i := uint(0); i < children.Length(); i++ {
 		child := children.Item(i)
 		if child == nil || string(child.NamespaceURI()) != XSDNamespace {
@@ -672,6 +863,11 @@ func (s *Schema) parseElementWithContext(elem xmldom.Element, isGlobal bool) err
 			if constraint := s.parseIdentityConstraint(child, UniqueConstraint); constraint != nil {
 				decl.Constraints = append(decl.Constraints, constraint)
 			}
+		case "alternative":
+			// Type alternatives are an XSD 1.1 feature
+			if s.xsd11() {
+				decl.Alternatives = append(decl.Alternatives, s.parseAlternative(child))
+			}
 		case "simpleType":
 			// Parse inline simple type
 			st := s.parseInlineSimpleType(child)
@@ -705,14 +901,17 @@ func (s *Schema) parseInlineElement(elem xmldom.Element) *ElementDecl {
 		return nil
 	}
 
+	form := s.parseForm(elem, s.ElementFormDefault)
 	decl := &ElementDecl{
 		Name: QName{
-			Namespace: s.TargetNamespace,
+			Namespace: s.formNamespace(form),
 			Local:     name,
 		},
 		MinOcc:      s.parseOccurs(elem, "minOccurs", 1),
 		MaxOcc:      s.parseOccurs(elem, "maxOccurs", 1),
 		Constraints: make([]*IdentityConstraint, 0),
+		Form:        form,
+		Block:       s.parseBlock(elem, DerivationExtension, DerivationRestriction, DerivationSubstitution),
 	}
 
 	// Parse attributes
@@ -726,7 +925,7 @@ func (s *Schema) parseInlineElement(elem xmldom.Element) *ElementDecl {
 
 	// Parse substitutionGroup attribute (for inline elements too)
 	if substGroup := string(elem.GetAttribute("substitutionGroup")); substGroup != "" {
-		decl.SubstitutionGroup = s.parseQName(substGroup)
+		decl.SubstitutionGroup = s.parseQName(elem, substGroup)
 	}
 
 	decl.Default = string(elem.GetAttribute("default"))
@@ -734,11 +933,11 @@ func (s *Schema) parseInlineElement(elem xmldom.Element) *ElementDecl {
 
 	// Parse type
 	if typeName := string(elem.GetAttribute("type")); typeName != "" {
-		decl.Type = s.resolveType(typeName)
+		decl.Type = s.resolveType(elem, typeName)
 	}
 
 	// Parse child elements for inline type definitions
-	children := elem.Children()
+	children := s.schemaChildren(elem)
 	for i := uint(0); i < children.Length(); i++ {
 		child := children.Item(i)
 		if child == nil || string(child.NamespaceURI()) != XSDNamespace {
@@ -770,12 +969,30 @@ func (s *Schema) parseInlineElement(elem xmldom.Element) *ElementDecl {
 			if constraint := s.parseIdentityConstraint(child, UniqueConstraint); constraint != nil {
 				decl.Constraints = append(decl.Constraints, constraint)
 			}
+		case "alternative":
+			if s.xsd11() {
+				decl.Alternatives = append(decl.Alternatives, s.parseAlternative(child))
+			}
 		}
 	}
 
 	return decl
 }
 
+// anonymousTypes numbers the names of anonymous base, item and member
+// types, which share the type definitions of the schemas they are
+// included into
+var anonymousTypes atomic.Uint64
+
+// anonymousTypeName returns a unique name for an anonymous base, item or
+// member type
+func (s *Schema) anonymousTypeName(kind string) QName {
+	return QName{
+		Namespace: s.TargetNamespace,
+		Local:     fmt.Sprintf("_%s_%d", kind, anonymousTypes.Add(1)),
+	}
+}
+
 // parseInlineSimpleType parses an inline (anonymous) simple type definition
 func (s *Schema) parseInlineSimpleType(elem xmldom.Element) *SimpleType {
 	st := &SimpleType{
@@ -783,10 +1000,11 @@ func (s *Schema) parseInlineSimpleType(elem xmldom.Element) *SimpleType {
 			Namespace: s.TargetNamespace,
 			Local:     "_anonymous",
 		},
+		source: elem,
 	}
 
 	// Parse restriction, list, or union
-	children := elem.Children()
+	children := s.schemaChildren(elem)
 	for i := uint(0); i < children.Length(); i++ {
 		child := children.Item(i)
 		if child == nil || string(child.NamespaceURI()) != XSDNamespace {
@@ -818,10 +1036,12 @@ func (s *Schema) parseSimpleType(elem xmldom.Element) error {
 			Namespace: s.TargetNamespace,
 			Local:     name,
 		},
+		Final:  s.parseFinal(elem, DerivationExtension, DerivationRestriction, DerivationList, DerivationUnion),
+		source: elem,
 	}
 
 	// Parse restriction, list, or union
-	children := elem.Children()
+	children := s.schemaChildren(elem)
 	for i := uint(0); i < children.Length(); i++ {
 		child := children.Item(i)
 		if child == nil || string(child.NamespaceURI()) != XSDNamespace {
@@ -852,7 +1072,12 @@ func (s *Schema) parseInlineComplexType(elem xmldom.Element) *ComplexType {
 			Namespace: s.TargetNamespace,
 			Local:     "_anonymous",
 		},
-		Attributes: make([]*AttributeDecl, 0),
+		Attributes:         make([]*AttributeDecl, 0),
+		source:             elem,
+		defaultOpenContent: s.DefaultOpenContent,
+		defaultAttributes:  s.DefaultAttributes,
+		// Default attribute groups apply unless the type opts out
+		DefaultAttributesApply: booleanAttribute(elem, "defaultAttributesApply", true),
 	}
 
 	if mixed := string(elem.GetAttribute("mixed")); mixed == "true" {
@@ -864,7 +1089,7 @@ func (s *Schema) parseInlineComplexType(elem xmldom.Element) *ComplexType {
 	}
 
 	// Parse content and attributes
-	children := elem.Children()
+	children := s.schemaChildren(elem)
 	for i := uint(0); i < children.Length(); i++ {
 		child := children.Item(i)
 		if child == nil || string(child.NamespaceURI()) != XSDNamespace {
@@ -875,6 +1100,7 @@ func (s *Schema) parseInlineComplexType(elem xmldom.Element) *ComplexType {
 		case "simpleContent":
 			sc := s.parseSimpleContent(child)
 			ct.Content = sc
+			ct.BaseType, ct.DerivedBy = contentDerivation(sc)
 			// Transfer attributes from simpleContent extension to the ComplexType
 			if sc.Extension != nil {
 				ct.Attributes = append(ct.Attributes, sc.Extension.Attributes...)
@@ -885,13 +1111,14 @@ func (s *Schema) parseInlineComplexType(elem xmldom.Element) *ComplexType {
 			}
 		case "complexContent":
 			ct.Content = s.parseComplexContent(child)
+			ct.BaseType, ct.DerivedBy = contentDerivation(ct.Content)
 		case "sequence", "choice", "all":
 			ct.Content = s.parseModelGroup(child)
 		case "group":
 			// Handle group references for content models
 			if ref := string(child.GetAttribute("ref")); ref != "" {
 				ct.Content = &GroupRef{
-					Ref:    s.parseQName(ref),
+					Ref:    s.parseQName(child, ref),
 					MinOcc: s.parseOccurs(child, "minOccurs", 1),
 					MaxOcc: s.parseOccurs(child, "maxOccurs", 1),
 				}
@@ -903,11 +1130,18 @@ func (s *Schema) parseInlineComplexType(elem xmldom.Element) *ComplexType {
 		case "attributeGroup":
 			// Handle attribute group references
 			if ref := string(child.GetAttribute("ref")); ref != "" {
-				qname := s.parseQName(ref)
+				qname := s.parseQName(child, ref)
 				ct.AttributeGroup = append(ct.AttributeGroup, qname)
 			}
 		case "anyAttribute":
 			ct.AnyAttribute = s.parseAnyAttribute(child)
+		case "assert":
+			// Assertions are an XSD 1.1 feature
+			if s.xsd11() {
+				ct.Assertions = append(ct.Assertions, s.parseAssertion(child))
+			}
+		case "openContent":
+			ct.OpenContent = s.parseOpenContent(child)
 		}
 	}
 
@@ -926,7 +1160,14 @@ func (s *Schema) parseComplexType(elem xmldom.Element) error {
 			Namespace: s.TargetNamespace,
 			Local:     name,
 		},
-		Attributes: make([]*AttributeDecl, 0),
+		Attributes:         make([]*AttributeDecl, 0),
+		Block:              s.parseBlock(elem, DerivationExtension, DerivationRestriction),
+		Final:              s.parseFinal(elem, DerivationExtension, DerivationRestriction),
+		source:             elem,
+		defaultOpenContent: s.DefaultOpenContent,
+		defaultAttributes:  s.DefaultAttributes,
+		// Default attribute groups apply unless the type opts out
+		DefaultAttributesApply: booleanAttribute(elem, "defaultAttributesApply", true),
 	}
 
 	if mixed := string(elem.GetAttribute("mixed")); mixed == "true" {
@@ -938,7 +1179,7 @@ func (s *Schema) parseComplexType(elem xmldom.Element) error {
 	}
 
 	// Parse content and attributes
-	children := elem.Children()
+	children := s.schemaChildren(elem)
 	for i := uint(0); i < children.Length(); i++ {
 		child := children.Item(i)
 		if child == nil || string(child.NamespaceURI()) != XSDNamespace {
@@ -949,6 +1190,7 @@ func (s *Schema) parseComplexType(elem xmldom.Element) error {
 		case "simpleContent":
 			sc := s.parseSimpleContent(child)
 			ct.Content = sc
+			ct.BaseType, ct.DerivedBy = contentDerivation(sc)
 			// Transfer attributes from simpleContent extension to the ComplexType
 			if sc.Extension != nil {
 				ct.Attributes = append(ct.Attributes, sc.Extension.Attributes...)
@@ -959,6 +1201,7 @@ func (s *Schema) parseComplexType(elem xmldom.Element) error {
 			}
 		case "complexContent":
 			ct.Content = s.parseComplexContent(child)
+			ct.BaseType, ct.DerivedBy = contentDerivation(ct.Content)
 		case "sequence", "choice", "all":
 			ct.Content = s.parseModelGroup(child)
 		case "group":
@@ -966,7 +1209,7 @@ func (s *Schema) parseComplexType(elem xmldom.Element) error {
 			if ref := string(child.GetAttribute("ref")); ref != "" {
 				// Create a group reference particle
 				ct.Content = &GroupRef{
-					Ref:    s.parseQName(ref),
+					Ref:    s.parseQName(child, ref),
 					MinOcc: s.parseOccurs(child, "minOccurs", 1),
 					MaxOcc: s.parseOccurs(child, "maxOccurs", 1),
 				}
@@ -978,11 +1221,17 @@ func (s *Schema) parseComplexType(elem xmldom.Element) error {
 		case "attributeGroup":
 			// Handle attribute group references
 			if ref := string(child.GetAttribute("ref")); ref != "" {
-				qname := s.parseQName(ref)
+				qname := s.parseQName(child, ref)
 				ct.AttributeGroup = append(ct.AttributeGroup, qname)
 			}
 		case "anyAttribute":
 			ct.AnyAttribute = s.parseAnyAttribute(child)
+		case "assert":
+			if s.xsd11() {
+				ct.Assertions = append(ct.Assertions, s.parseAssertion(child))
+			}
+		case "openContent":
+			ct.OpenContent = s.parseOpenContent(child)
 		}
 	}
 
@@ -1002,10 +1251,10 @@ func (s *Schema) parseRestriction(elem xmldom.Element) *Restriction {
 	}
 
 	if base := string(elem.GetAttribute("base")); base != "" {
-		r.Base = s.parseQName(base)
+		r.Base = s.parseQName(elem, base)
 	}
 
-	children := elem.Children()
+	children := s.schemaChildren(elem)
 	for i := uint(0); i < children.Length(); i++ {
 		child := children.Item(i)
 		if child == nil || string(child.NamespaceURI()) != XSDNamespace {
@@ -1020,11 +1269,7 @@ func (s *Schema) parseRestriction(elem xmldom.Element) *Restriction {
 			st := s.parseInlineSimpleType(child)
 			if st != nil {
 				// Generate a unique name for this anonymous type
-				uniqName := fmt.Sprintf("_restriction_base_%d", i)
-				st.QName = QName{
-					Namespace: s.TargetNamespace,
-					Local:     uniqName,
-				}
+				st.QName = s.anonymousTypeName("restriction_base")
 				// Store the type
 				s.mu.Lock()
 				s.TypeDefs[st.QName] = st
@@ -1043,7 +1288,7 @@ func (s *Schema) parseRestriction(elem xmldom.Element) *Restriction {
 		case "group":
 			if ref := string(child.GetAttribute("ref")); ref != "" {
 				r.Content = &GroupRef{
-					Ref:    s.parseQName(ref),
+					Ref:    s.parseQName(child, ref),
 					MinOcc: 1,
 					MaxOcc: 1,
 				}
@@ -1054,12 +1299,30 @@ func (s *Schema) parseRestriction(elem xmldom.Element) *Restriction {
 				r.Attributes = append(r.Attributes, attr)
 			}
 			continue
+		case "attributeGroup":
+			if ref := string(child.GetAttribute("ref")); ref != "" {
+				r.AttributeGroup = append(r.AttributeGroup, s.parseQName(child, ref))
+			}
+			continue
 		case "anyAttribute":
 			r.AnyAttribute = &AnyAttribute{
 				Namespace:       string(child.GetAttribute("namespace")),
 				ProcessContents: string(child.GetAttribute("processContents")),
 			}
 			continue
+		case "assert":
+			if s.xsd11() {
+				r.Assertions = append(r.Assertions, s.parseAssertion(child))
+			}
+			continue
+		case "openContent":
+			r.OpenContent = s.parseOpenContent(child)
+			continue
+		case "assertion":
+			if s.xsd11() {
+				r.Facets = append(r.Facets, &AssertionFacet{Assertion: s.parseAssertion(child)})
+			}
+			continue
 		}
 
 		// Parse facets (for simpleType/simpleContent restrictions)
@@ -1075,15 +1338,23 @@ func (s *Schema) parseRestriction(elem xmldom.Element) *Restriction {
 				for _, existing := range r.Facets {
 					if enum, ok := existing.(*EnumerationFacet); ok {
 						enum.Values = append(enum.Values, value)
+						enum.contexts = append(enum.contexts, child)
 						found = true
 						break
 					}
 				}
 				if !found {
+					facet.(*EnumerationFacet).contexts = []xmldom.Element{child}
 					r.Facets = append(r.Facets, facet)
 				}
 			} else {
 				r.Facets = append(r.Facets, facet)
+				if facetName != "pattern" {
+					if r.facetValues == nil {
+						r.facetValues = make(map[string]facetValue)
+					}
+					r.facetValues[facetName] = facetValue{value: value, fixed: booleanAttribute(child, "fixed", false)}
+				}
 			}
 		}
 	}
@@ -1096,10 +1367,10 @@ func (s *Schema) parseList(elem xmldom.Element) *List {
 
 	// Parse itemType attribute if present
 	if itemType := string(elem.GetAttribute("itemType")); itemType != "" {
-		list.ItemType = s.parseQName(itemType)
+		list.ItemType = s.parseQName(elem, itemType)
 	} else {
 		// Look for inline simpleType child
-		children := elem.Children()
+		children := s.schemaChildren(elem)
 		for i := uint(0); i < children.Length(); i++ {
 			child := children.Item(i)
 			if child == nil || string(child.NamespaceURI()) != XSDNamespace {
@@ -1111,11 +1382,7 @@ func (s *Schema) parseList(elem xmldom.Element) *List {
 				st := s.parseInlineSimpleType(child)
 				if st != nil {
 					// Generate a unique name for this anonymous type
-					uniqName := fmt.Sprintf("_list_item_%d", i)
-					st.QName = QName{
-						Namespace: s.TargetNamespace,
-						Local:     uniqName,
-					}
+					st.QName = s.anonymousTypeName("list_item")
 					// Store the type
 					s.mu.Lock()
 					s.TypeDefs[st.QName] = st
@@ -1140,12 +1407,12 @@ func (s *Schema) parseUnion(elem xmldom.Element) *Union {
 	if memberTypes := string(elem.GetAttribute("memberTypes")); memberTypes != "" {
 		types := strings.Fields(memberTypes)
 		for _, t := range types {
-			u.MemberTypes = append(u.MemberTypes, s.parseQName(t))
+			u.MemberTypes = append(u.MemberTypes, s.parseQName(elem, t))
 		}
 	}
 
 	// Parse inline simpleType children
-	children := elem.Children()
+	children := s.schemaChildren(elem)
 	for i := uint(0); i < children.Length(); i++ {
 		child := children.Item(i)
 		if child == nil || string(child.NamespaceURI()) != XSDNamespace {
@@ -1157,11 +1424,7 @@ func (s *Schema) parseUnion(elem xmldom.Element) *Union {
 			st := s.parseInlineSimpleType(child)
 			if st != nil {
 				// Generate a unique name for this anonymous type
-				uniqName := fmt.Sprintf("_union_member_%d", i)
-				st.QName = QName{
-					Namespace: s.TargetNamespace,
-					Local:     uniqName,
-				}
+				st.QName = s.anonymousTypeName("union_member")
 				// Store the type
 				s.mu.Lock()
 				s.TypeDefs[st.QName] = st
@@ -1178,7 +1441,7 @@ func (s *Schema) parseUnion(elem xmldom.Element) *Union {
 func (s *Schema) parseSimpleContent(elem xmldom.Element) *SimpleContent {
 	sc := &SimpleContent{}
 
-	children := elem.Children()
+	children := s.schemaChildren(elem)
 	for i := uint(0); i < children.Length(); i++ {
 		child := children.Item(i)
 		if child == nil || string(child.NamespaceURI()) != XSDNamespace {
@@ -1203,7 +1466,7 @@ func (s *Schema) parseComplexContent(elem xmldom.Element) *ComplexContent {
 		cc.Mixed = true
 	}
 
-	children := elem.Children()
+	children := s.schemaChildren(elem)
 	for i := uint(0); i < children.Length(); i++ {
 		child := children.Item(i)
 		if child == nil || string(child.NamespaceURI()) != XSDNamespace {
@@ -1226,6 +1489,7 @@ func (s *Schema) parseModelGroup(elem xmldom.Element) *ModelGroup {
 		MinOcc:    s.parseOccurs(elem, "minOccurs", 1),
 		MaxOcc:    s.parseOccurs(elem, "maxOccurs", 1),
 		Particles: make([]Particle, 0),
+		source:    elem,
 	}
 
 	switch string(elem.LocalName()) {
@@ -1238,7 +1502,7 @@ func (s *Schema) parseModelGroup(elem xmldom.Element) *ModelGroup {
 	}
 
 	// Parse particles
-	children := elem.Children()
+	children := s.schemaChildren(elem)
 	for i := uint(0); i < children.Length(); i++ {
 		child := children.Item(i)
 		if child == nil || string(child.NamespaceURI()) != XSDNamespace {
@@ -1251,7 +1515,7 @@ func (s *Schema) parseModelGroup(elem xmldom.Element) *ModelGroup {
 			if ref := string(child.GetAttribute("ref")); ref != "" {
 				// Element reference
 				mg.Particles = append(mg.Particles, &ElementRef{
-					Ref:    s.parseQName(ref),
+					Ref:    s.parseQName(child, ref),
 					MinOcc: s.parseOccurs(child, "minOccurs", 1),
 					MaxOcc: s.parseOccurs(child, "maxOccurs", 1),
 				})
@@ -1267,7 +1531,7 @@ func (s *Schema) parseModelGroup(elem xmldom.Element) *ModelGroup {
 			// Parse group reference
 			if ref := string(child.GetAttribute("ref")); ref != "" {
 				mg.Particles = append(mg.Particles, &GroupRef{
-					Ref:    s.parseQName(ref),
+					Ref:    s.parseQName(child, ref),
 					MinOcc: s.parseOccurs(child, "minOccurs", 1),
 					MaxOcc: s.parseOccurs(child, "maxOccurs", 1),
 				})
@@ -1278,18 +1542,40 @@ func (s *Schema) parseModelGroup(elem xmldom.Element) *ModelGroup {
 			mg.Particles = append(mg.Particles, nested)
 		case "any":
 			// Parse xs:any wildcard
-			mg.Particles = append(mg.Particles, &AnyElement{
-				Namespace:       string(child.GetAttribute("namespace")),
-				ProcessContents: string(child.GetAttribute("processContents")),
-				MinOcc:          s.parseOccurs(child, "minOccurs", 1),
-				MaxOcc:          s.parseOccurs(child, "maxOccurs", 1),
-			})
+			mg.Particles = append(mg.Particles, s.parseAnyElement(child))
 		}
 	}
 
+	mg.declared = mg.Particles
 	return mg
 }
 
+// parseAnyElement parses an xs:any wildcard. The notNamespace and notQName
+// attributes are XSD 1.1 and are ignored in XSD 1.0 schemas.
+func (s *Schema) parseAnyElement(elem xmldom.Element) *AnyElement {
+	ae := &AnyElement{
+		Namespace:       string(elem.GetAttribute("namespace")),
+		ProcessContents: string(elem.GetAttribute("processContents")),
+		MinOcc:          s.parseOccurs(elem, "minOccurs", 1),
+		MaxOcc:          s.parseOccurs(elem, "maxOccurs", 1),
+	}
+	if !s.xsd11() {
+		return ae
+	}
+	ae.NotNamespace = string(elem.GetAttribute("notNamespace"))
+	for _, name := range strings.Fields(string(elem.GetAttribute("notQName"))) {
+		switch name {
+		case "##defined":
+			ae.NotDefined = true
+		case "##definedSibling":
+			ae.NotDefinedSibling = true
+		default:
+			ae.NotQName = append(ae.NotQName, s.parseQName(elem, name))
+		}
+	}
+	return ae
+}
+
 // parseOccurs parses minOccurs/maxOccurs attributes
 func (s *Schema) parseOccurs(elem xmldom.Element, attr string, defaultValue int) int {
 	value := string(elem.GetAttribute(xmldom.DOMString(attr)))
@@ -1306,18 +1592,50 @@ func (s *Schema) parseOccurs(elem xmldom.Element, attr string, defaultValue int)
 	return defaultValue
 }
 
-func (s *Schema) parseAttribute(elem xmldom.Element) *AttributeDecl {
-	name := string(elem.GetAttribute("name"))
-	if name == "" {
-		return nil // Could be a reference
+// parseForm returns the form attribute of a local declaration, or the
+// schema's default when it has none
+func (s *Schema) parseForm(elem xmldom.Element, defaultForm Form) Form {
+	switch form := Form(elem.GetAttribute("form")); form {
+	case QualifiedForm, UnqualifiedForm:
+		return form
 	}
+	if defaultForm == "" {
+		return UnqualifiedForm
+	}
+	return defaultForm
+}
 
-	attr := &AttributeDecl{
-		Name: QName{
-			Namespace: s.TargetNamespace,
-			Local:     name,
-		},
-		Use: OptionalUse,
+// formNamespace returns the namespace of a local declaration with the given form
+func (s *Schema) formNamespace(form Form) string {
+	if form == QualifiedForm {
+		return s.TargetNamespace
+	}
+	return ""
+}
+
+func (s *Schema) parseAttribute(elem xmldom.Element) *AttributeDecl {
+	var attr *AttributeDecl
+	if ref := string(elem.GetAttribute("ref")); ref != "" {
+		// Attribute reference; its type is resolved with the attribute uses
+		name := s.parseQName(elem, ref)
+		attr = &AttributeDecl{
+			Name: name,
+			Use:  OptionalUse,
+			Form: QualifiedForm,
+			Ref:  name,
+		}
+	} else if name := string(elem.GetAttribute("name")); name != "" {
+		form := s.parseForm(elem, s.AttributeFormDefault)
+		attr = &AttributeDecl{
+			Name: QName{
+				Namespace: s.formNamespace(form),
+				Local:     name,
+			},
+			Use:  OptionalUse,
+			Form: form,
+		}
+	} else {
+		return nil
 	}
 
 	if use := string(elem.GetAttribute("use")); use != "" {
@@ -1329,7 +1647,7 @@ func (s *Schema) parseAttribute(elem xmldom.Element) *AttributeDecl {
 
 	// Parse type attribute
 	if typeName := string(elem.GetAttribute("type")); typeName != "" {
-		typeQName := s.parseQName(typeName)
+		typeQName := s.parseQName(elem, typeName)
 		// Look up the type in the schema
 		if t, exists := s.TypeDefs[typeQName]; exists {
 			attr.Type = t
@@ -1342,6 +1660,22 @@ func (s *Schema) parseAttribute(elem xmldom.Element) *AttributeDecl {
 	return attr
 }
 
+// parseGlobalAttribute parses a top-level attribute declaration, whose
+// name is always in the target namespace
+func (s *Schema) parseGlobalAttribute(elem xmldom.Element) error {
+	attr := s.parseAttribute(elem)
+	if attr == nil || attr.Ref != (QName{}) {
+		return nil
+	}
+	attr.Name.Namespace = s.TargetNamespace
+	attr.Form = QualifiedForm
+
+	s.mu.Lock()
+	s.AttributeDecls[attr.Name] = attr
+	s.mu.Unlock()
+	return nil
+}
+
 func (s *Schema) parseAnyAttribute(elem xmldom.Element) *AnyAttribute {
 	return &AnyAttribute{
 		Namespace:       string(elem.GetAttribute("namespace")),
@@ -1356,34 +1690,42 @@ func (s *Schema) parseIdentityConstraint(elem xmldom.Element, kind IdentityConst
 	}
 
 	constraint := &IdentityConstraint{
-		Name:   name,
-		Kind:   kind,
-		Fields: make([]*Field, 0),
+		Name:      name,
+		Kind:      kind,
+		Fields:    make([]*Field, 0),
+		namespace: s.TargetNamespace,
 	}
 
 	// For keyref, get the refer attribute
 	if kind == KeyRefConstraint {
 		if refer := string(elem.GetAttribute("refer")); refer != "" {
-			constraint.Refer = s.parseQName(refer)
+			constraint.Refer = s.parseQName(elem, refer)
 		}
 	}
 
 	// Parse selector and field elements
-	children := elem.Children()
+	children := s.schemaChildren(elem)
 	for i := uint(0); i < children.Length(); i++ {
 		child := children.Item(i)
 		if child == nil || string(child.NamespaceURI()) != XSDNamespace {
 			continue
 		}
 
+		// Selectors and fields are compiled once here, against the
+		// prefixes in scope; checkIdentityConstraints reports failures
+		namespaces, _ := s.xpathNamespacesOf(child)
 		switch string(child.LocalName()) {
 		case "selector":
 			if xpath := string(child.GetAttribute("xpath")); xpath != "" {
-				constraint.Selector = &Selector{XPath: xpath}
+				selector := &Selector{XPath: xpath, source: child}
+				selector.expr, selector.err = compileIdentityXPath(xpath, namespaces, false)
+				constraint.Selector = selector
 			}
 		case "field":
 			if xpath := string(child.GetAttribute("xpath")); xpath != "" {
-				constraint.Fields = append(constraint.Fields, &Field{XPath: xpath})
+				field := &Field{XPath: xpath, source: child}
+				field.expr, field.err = compileIdentityXPath(xpath, namespaces, true)
+				constraint.Fields = append(constraint.Fields, field)
 			}
 		}
 	}
@@ -1393,12 +1735,12 @@ func (s *Schema) parseIdentityConstraint(elem xmldom.Element, kind IdentityConst
 
 func (s *Schema) parseExtension(elem xmldom.Element) *Extension {
 	ext := &Extension{
-		Base:       s.parseQName(string(elem.GetAttribute("base"))),
+		Base:       s.parseQName(elem, string(elem.GetAttribute("base"))),
 		Attributes: make([]*AttributeDecl, 0),
 	}
 
 	// Parse extended content
-	children := elem.Children()
+	children := s.schemaChildren(elem)
 	for i := uint(0); i < children.Length(); i++ {
 		child := children.Item(i)
 		if child == nil || string(child.NamespaceURI()) != XSDNamespace {
@@ -1410,12 +1752,16 @@ func (s *Schema) parseExtension(elem xmldom.Element) *Extension {
 			if attr := s.parseAttribute(child); attr != nil {
 				ext.Attributes = append(ext.Attributes, attr)
 			}
+		case "attributeGroup":
+			if ref := string(child.GetAttribute("ref")); ref != "" {
+				ext.AttributeGroup = append(ext.AttributeGroup, s.parseQName(child, ref))
+			}
 		case "sequence", "choice", "all", "group":
 			if string(child.LocalName()) == "group" {
 				// Handle group reference
 				if ref := string(child.GetAttribute("ref")); ref != "" {
 					ext.Content = &GroupRef{
-						Ref:    s.parseQName(ref),
+						Ref:    s.parseQName(child, ref),
 						MinOcc: 1,
 						MaxOcc: 1,
 					}
@@ -1425,6 +1771,12 @@ func (s *Schema) parseExtension(elem xmldom.Element) *Extension {
 			}
 		case "anyAttribute":
 			ext.AnyAttribute = s.parseAnyAttribute(child)
+		case "assert":
+			if s.xsd11() {
+				ext.Assertions = append(ext.Assertions, s.parseAssertion(child))
+			}
+		case "openContent":
+			ext.OpenContent = s.parseOpenContent(child)
 		}
 	}
 
@@ -1446,17 +1798,24 @@ func (s *Schema) parseAttributeGroup(elem xmldom.Element) error {
 	}
 
 	// Parse attributes
-	children := elem.Children()
+	children := s.schemaChildren(elem)
 	for i := uint(0); i < children.Length(); i++ {
 		child := children.Item(i)
 		if child == nil || string(child.NamespaceURI()) != XSDNamespace {
 			continue
 		}
 
-		if string(child.LocalName()) == "attribute" {
+		switch string(child.LocalName()) {
+		case "attribute":
 			if attr := s.parseAttribute(child); attr != nil {
 				ag.Attributes = append(ag.Attributes, attr)
 			}
+		case "attributeGroup":
+			if ref := string(child.GetAttribute("ref")); ref != "" {
+				ag.AttributeGroup = append(ag.AttributeGroup, s.parseQName(child, ref))
+			}
+		case "anyAttribute":
+			ag.AnyAttribute = s.parseAnyAttribute(child)
 		}
 	}
 
@@ -1474,7 +1833,7 @@ func (s *Schema) parseGroup(elem xmldom.Element) error {
 	}
 
 	// Find the model group child
-	children := elem.Children()
+	children := s.schemaChildren(elem)
 	for i := uint(0); i < children.Length(); i++ {
 		child := children.Item(i)
 		if child == nil || string(child.NamespaceURI()) != XSDNamespace {
@@ -1504,81 +1863,101 @@ func (s *Schema) parseImport(elem xmldom.Element) error {
 	return nil
 }
 
-func (s *Schema) parseQName(name string) QName {
+// booleanAttribute reads an xs:boolean attribute of a schema element: "true"
+// and "1" are true, "false" and "0" are false once whitespace is collapsed,
+// and absent or invalid values give the default
+func booleanAttribute(elem xmldom.Element, name string, def bool) bool {
+	switch strings.TrimSpace(string(elem.GetAttribute(xmldom.DOMString(name)))) {
+	case "true", "1":
+		return true
+	case "false", "0":
+		return false
+	}
+	return def
+}
+
+// parseQName resolves a QName-valued attribute of elem against the
+// namespace declarations in scope at elem. Unprefixed names take the
+// default namespace when one is declared and have no namespace otherwise;
+// unbound prefixes are recorded as src-resolve errors.
+func (s *Schema) parseQName(elem xmldom.Element, name string) QName {
 	if name == "" {
 		return QName{}
 	}
+	if elem == nil && s.doc != nil {
+		elem = s.doc.DocumentElement()
+	}
 
-	// Handle prefixed names
-	parts := strings.SplitN(name, ":", 2)
-	if len(parts) == 2 {
-		prefix := parts[0]
-		local := parts[1]
+	prefix, local, prefixed := strings.Cut(name, ":")
+	if !prefixed {
+		// Unprefixed names are in the default namespace, or in no namespace
+		// when there is none; the target namespace does not apply
+		ns, _ := lookupNamespace(elem, "")
+		return QName{Namespace: ns, Local: name}
+	}
 
-		// Special handling for built-in XML Schema types
-		if prefix == "xs" || prefix == "xsd" {
-			return QName{
-				Namespace: XSDNamespace,
-				Local:     local,
-			}
-		}
+	ns, ok := lookupNamespace(elem, prefix)
+	if !ok {
+		s.resolveErrors = append(s.resolveErrors, fmt.Errorf(
+			"src-resolve: prefix '%s' of QName '%s' on <%s> is not bound to a namespace",
+			prefix, name, localNameOf(elem)))
+		return QName{Local: name}
+	}
+	return QName{Namespace: ns, Local: local}
+}
 
-		// For other prefixes, try to resolve from the schema document
-		if s.doc != nil {
-			root := s.doc.DocumentElement()
-			if root != nil {
-				// Check all attributes for namespace declarations
-				attrs := root.Attributes()
-				for i := uint(0); i < attrs.Length(); i++ {
-					attr := attrs.Item(i)
-					if attr == nil {
-						continue
-					}
+// lookupNamespace returns the namespace bound to prefix at elem, searching
+// its ancestors for the nearest declaration. The empty prefix looks up the
+// default namespace; an empty default namespace declaration undeclares it
+// and is found as the empty namespace.
+func lookupNamespace(elem xmldom.Element, prefix string) (string, bool) {
+	if prefix == "xml" {
+		return "http://www.w3.org/XML/1998/namespace", true
+	}
 
-					attrName := string(attr.NodeName())
-					// Check for xmlns:prefix
-					if attrName == "xmlns:"+prefix {
-						return QName{
-							Namespace: string(attr.NodeValue()),
-							Local:     local,
-						}
-					}
-					// xmldom may present namespace declarations without xmlns: prefix
-					// Check if this attribute name matches our prefix and has a namespace URI as value
-					if attrName == prefix {
-						nsValue := string(attr.NodeValue())
-						// Heuristic: namespace values typically contain "://" or start with specific patterns
-						if strings.Contains(nsValue, "://") || strings.Contains(nsValue, "/") || strings.Contains(nsValue, ".") {
-							return QName{
-								Namespace: nsValue,
-								Local:     local,
-							}
-						}
-					}
-				}
+	for elem != nil {
+		attrs := elem.Attributes()
+		for i := uint(0); i < attrs.Length(); i++ {
+			attr := attrs.Item(i)
+			if attr == nil {
+				continue
+			}
 
-				return QName{
-					Namespace: s.TargetNamespace,
-					Local:     local,
+			attrNS := string(attr.NamespaceURI())
+			attrName := string(attr.NodeName())
+			if prefix == "" {
+				if attrName == "xmlns" {
+					return string(attr.NodeValue()), true
 				}
+				continue
+			}
+			// xmldom presents xmlns:prefix with namespace "xmlns" and the prefix as local name
+			if attrName == "xmlns:"+prefix ||
+				((attrNS == "xmlns" || attrNS == "http://www.w3.org/2000/xmlns/") && string(attr.LocalName()) == prefix) {
+				return string(attr.NodeValue()), true
 			}
 		}
 
-		// If we can't resolve the prefix, it might be an unqualified local name
-		// Don't assume target namespace for prefixed names we can't resolve
-		return QName{
-			Local: name, // Keep the full prefixed name as local
+		parent, ok := elem.ParentNode().(xmldom.Element)
+		if !ok {
+			break
 		}
+		elem = parent
 	}
 
-	return QName{
-		Namespace: s.TargetNamespace,
-		Local:     name,
+	return "", false
+}
+
+// localNameOf returns the local name of an element for error messages
+func localNameOf(elem xmldom.Element) string {
+	if elem == nil {
+		return ""
 	}
+	return string(elem.LocalName())
 }
 
-func (s *Schema) resolveType(name string) Type {
-	qname := s.parseQName(name)
+func (s *Schema) resolveType(elem xmldom.Element, name string) Type {
+	qname := s.parseQName(elem, name)
 
 	s.mu.RLock()
 	if t, ok := s.TypeDefs[qname]; ok {
@@ -1606,17 +1985,10 @@ func (s *Schema) resolveType(name string) Type {
 
 // ResolveAttributeGroups resolves all attribute group references for a complex type
 func (s *Schema) ResolveAttributeGroups(ct *ComplexType) []*AttributeDecl {
-	var attrs []*AttributeDecl
-
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
-	for _, groupRef := range ct.AttributeGroup {
-		if ag, ok := s.AttributeGroups[groupRef]; ok {
-			attrs = append(attrs, ag.Attributes...)
-		}
-	}
-
+	attrs, _ := s.attributeGroupContents(ct.withDefaultAttributes(ct.AttributeGroup), make(map[QName]bool))
 	return attrs
 }
 
@@ -1672,12 +2044,6 @@ func (s *Schema) resolveInlineElementTypes(particles []Particle) {
 					// This is a placeholder - try to resolve the actual type
 					if actualType, exists := s.TypeDefs[st.QName]; exists {
 						pt.Type = actualType
-					} else if st.QName.Namespace == "" && strings.Contains(st.QName.Local, ":") {
-						// The QName wasn't resolved properly - try to re-parse it
-						resolvedQName := s.parseQName(st.QName.Local)
-						if actualType, exists := s.TypeDefs[resolvedQName]; exists {
-							pt.Type = actualType
-						}
 					}
 				}
 			}
@@ -1747,6 +2113,10 @@ func (s *Schema) resolveParticlesWithVisited(particles []Particle, visited map[Q
 
 // resolveExtension resolves type extension/derivation
 func (s *Schema) resolveExtension(ct *ComplexType, ext *Extension) {
+	if ct.declared == nil {
+		ct.declared = ct.Content
+	}
+
 	// Find base type
 	if baseType, exists := s.TypeDefs[ext.Base]; exists {
 		if baseCT, ok := baseType.(*ComplexType); ok {
@@ -1763,7 +2133,18 @@ func (s *Schema) resolveExtension(ct *ComplexType, ext *Extension) {
 			// Handle content model extension
 			if ext.Content != nil {
 				// Extension adds to base content
-				if baseCT.Content != nil {
+				baseAll, baseIsAll := baseCT.Content.(*ModelGroup)
+				extAll, extIsAll := ext.Content.(*ModelGroup)
+				if baseIsAll && extIsAll && baseAll.Kind == AllGroup && extAll.Kind == AllGroup {
+					// An all group extended by an all group (XSD 1.1) is
+					// one all group with the particles of both
+					ct.Content = &ModelGroup{
+						Kind:      AllGroup,
+						MinOcc:    extAll.MinOcc,
+						MaxOcc:    1,
+						Particles: append(append([]Particle(nil), baseAll.Particles...), extAll.Particles...),
+					}
+				} else if baseCT.Content != nil {
 					// If both are ModelGroups, combine their particles in a sequence
 					var particles []Particle
 
@@ -1827,8 +2208,8 @@ func (st *SimpleType) Name() QName {
 func (st *SimpleType) Validate(element xmldom.Element, schema *Schema) []Violation {
 	var violations []Violation
 
-	// Get the text content of the element
-	content := strings.TrimSpace(string(element.TextContent()))
+	// Get the text content of the element, processed as the type prescribes
+	content := NormalizeValue(string(element.TextContent()), st)
 
 	// Validate based on the simple type definition
 	var err error
@@ -1840,11 +2221,14 @@ func (st *SimpleType) Validate(element xmldom.Element, schema *Schema) []Violati
 		// Validate against restriction
 		err = validateSimpleTypeValue(content, st, schema)
 	}
+	if err == nil && schema.derivesFromNotation(st) {
+		err = schema.checkNotationValue(element, content, st)
+	}
 
 	if err != nil {
 		violations = append(violations, Violation{
 			Element: element,
-			Code:    "cvc-datatype-valid.1",
+			Code:    assertionCode(err, "cvc-datatype-valid.1"),
 			Message: err.Error(),
 		})
 	}
@@ -1863,10 +2247,9 @@ func (ct *ComplexType) Validate(element xmldom.Element, schema *Schema) []Violat
 	// fmt.Printf("ComplexType.Validate: %s, Content: %T\n", ct.QName, ct.Content)
 
 	// If the complex type has content, validate it
-	if ct.Content != nil {
-		contentViolations := ct.Content.Validate(element, schema)
-		violations = append(violations, contentViolations...)
-	}
+	violations = append(violations, schema.validateContent(element, ct)...)
+
+	violations = append(violations, schema.validateAssertions(element, ct)...)
 
 	return violations
 }
@@ -1878,8 +2261,8 @@ func (sc *SimpleContent) MaxOccurs() int { return 1 }
 func (sc *SimpleContent) Validate(element xmldom.Element, schema *Schema) []Violation {
 	var violations []Violation
 
-	// Get text content
-	content := strings.TrimSpace(string(element.TextContent()))
+	// Get text content, processed as the simple content's type prescribes
+	content := NormalizeWhiteSpace(string(element.TextContent()), schema.simpleContentWhiteSpace(sc))
 
 	// Validate based on extension/restriction
 	if sc.Extension != nil {
@@ -1941,7 +2324,7 @@ func (sc *SimpleContent) Validate(element xmldom.Element, schema *Schema) []Viol
 			if err != nil {
 				violations = append(violations, Violation{
 					Element: element,
-					Code:    "cvc-facet-valid",
+					Code:    assertionCode(err, "cvc-facet-valid"),
 					Message: err.Error(),
 				})
 			}
@@ -2012,6 +2395,13 @@ func (ed *ElementDecl) Validate(element xmldom.Element, schema *Schema) []Violat
 func (mg *ModelGroup) MinOccurs() int { return mg.MinOcc }
 func (mg *ModelGroup) MaxOccurs() int { return mg.MaxOcc }
 func (mg *ModelGroup) Validate(element xmldom.Element, schema *Schema) []Violation {
+	return mg.validateOpen(element, nil, schema)
+}
+
+// validateOpen validates the children of an element against the group,
+// letting elements that an open content's wildcard matches appear in
+// between or after them. Those are validated against the wildcard.
+func (mg *ModelGroup) validateOpen(element xmldom.Element, open *OpenContent, schema *Schema) []Violation {
 	var violations []Violation
 
 	// Get child elements
@@ -2023,6 +2413,11 @@ func (mg *ModelGroup) Validate(element xmldom.Element, schema *Schema) []Violati
 		}
 	}
 
+	var absorbed []xmldom.Element
+	if open != nil && open.Any != nil {
+		childElements, absorbed = mg.splitOpenContent(childElements, open, schema)
+	}
+
 	switch mg.Kind {
 	case SequenceGroup:
 		violations = mg.validateSequence(childElements, schema)
@@ -2032,6 +2427,10 @@ func (mg *ModelGroup) Validate(element xmldom.Element, schema *Schema) []Violati
 		violations = mg.validateAll(childElements, schema)
 	}
 
+	for _, child := range absorbed {
+		violations = append(violations, ValidateAnyElement(child, open.Any, schema)...)
+	}
+
 	return violations
 }
 
@@ -2118,7 +2517,7 @@ func (mg *ModelGroup) validateSequence(children []xmldom.Element, schema *Schema
 			particleIndex++
 		} else if wildcard, isWildcard := particle.(*AnyElement); isWildcard {
 			// Check if child matches wildcard
-			if MatchesWildcard(child, wildcard.Namespace, schema.TargetNamespace) {
+			if wildcard.admits(child, schema, mg) {
 				// Child matches wildcard, consume as many as possible
 				matched, consumed, wildcardViolations := mg.matchWildcard(wildcard, children[childIndex:], schema)
 				childIndex += consumed
@@ -2208,15 +2607,11 @@ func (mg *ModelGroup) validateSequence(children []xmldom.Element, schema *Schema
 						}
 
 						// Use the actual element's type if found, otherwise fall back to referenced type
-						var typeToValidate Type
-						if exists && actualDecl.Type != nil {
-							typeToValidate = actualDecl.Type
-						} else if refDecl, refExists := schema.ElementDecls[elemRef.Ref]; refExists {
-							typeToValidate = refDecl.Type
+						if exists && actualDecl.Type == nil {
+							declToValidate = schema.ElementDecls[elemRef.Ref]
 						}
-
-						if typeToValidate != nil {
-							typeViolations := typeToValidate.Validate(childElem, schema)
+						if declToValidate != nil && declToValidate.Type != nil {
+							typeViolations := schema.validateElementType(childElem, declToValidate)
 							violations = append(violations, typeViolations...)
 						}
 					}
@@ -2252,7 +2647,7 @@ func (mg *ModelGroup) validateSequence(children []xmldom.Element, schema *Schema
 					for i := 0; i < particleIndex; i++ {
 						if wildcard, isWildcard := mg.Particles[i].(*AnyElement); isWildcard {
 							// Check if element doesn't match wildcard's namespace constraint
-							if !MatchesWildcard(child, wildcard.Namespace, schema.TargetNamespace) {
+							if !wildcard.admits(child, schema, mg) {
 								// Element violates wildcard namespace constraint
 								childNS := string(child.NamespaceURI())
 								childName := string(child.LocalName())
@@ -2303,7 +2698,7 @@ func (mg *ModelGroup) validateSequence(children []xmldom.Element, schema *Schema
 		for _, particle := range mg.Particles {
 			if wildcard, isWildcard := particle.(*AnyElement); isWildcard {
 				// Check if element doesn't match wildcard's namespace constraint
-				if !MatchesWildcard(child, wildcard.Namespace, schema.TargetNamespace) {
+				if !wildcard.admits(child, schema, mg) {
 					// Element violates wildcard namespace constraint
 					childNS := string(child.NamespaceURI())
 					childName := string(child.LocalName())
@@ -2349,7 +2744,7 @@ func (mg *ModelGroup) validateChoice(children []xmldom.Element, schema *Schema)
 				if decl, exists := schema.ElementDecls[elemRef.Ref]; exists && decl.Type != nil {
 					for i := 0; i < consumed; i++ {
 						childElem := children[i]
-						typeViolations := decl.Type.Validate(childElem, schema)
+						typeViolations := schema.validateElementType(childElem, decl)
 						violations = append(violations, typeViolations...)
 					}
 				}
@@ -2369,33 +2764,26 @@ func (mg *ModelGroup) validateChoice(children []xmldom.Element, schema *Schema)
 }
 
 func (mg *ModelGroup) validateAll(children []xmldom.Element, schema *Schema) []Violation {
-	// All particles must appear exactly once in any order
+	// Particles may match children in any order, each as often as its
+	// occurrence bounds allow
 	var violations []Violation
-	matched := make(map[int]bool)
+	particles := schema.allGroupParticles(mg, make(map[*ModelGroup]bool))
+	counts := make([]int, len(particles))
 
 	for _, child := range children {
-		found := false
-		for i, particle := range mg.Particles {
-			if !matched[i] && mg.elementMatchesParticle(child, particle, schema) {
-				matched[i] = true
-				found = true
-
-				// Validate the matched element's type
-				if elemDecl, isElemDecl := particle.(*ElementDecl); isElemDecl && elemDecl.Type != nil {
-					typeViolations := elemDecl.Type.Validate(child, schema)
-					violations = append(violations, typeViolations...)
-				} else if elemRef, isElemRef := particle.(*ElementRef); isElemRef {
-					// For ElementRef, look up the global declaration and validate
-					if decl, exists := schema.ElementDecls[elemRef.Ref]; exists && decl.Type != nil {
-						typeViolations := decl.Type.Validate(child, schema)
-						violations = append(violations, typeViolations...)
-					}
-				}
-
-				break
-			}
-		}
-		if !found {
+		i, full := mg.allGroupSlot(child, particles, counts, schema)
+		switch {
+		case i >= 0:
+			counts[i]++
+			violations = append(violations, mg.validateAllMatch(child, particles[i], schema)...)
+		case full >= 0:
+			violations = append(violations, Violation{
+				Element: child,
+				Code:    "cvc-complex-type.2.4.a",
+				Message: fmt.Sprintf("Expected at most %d occurrence(s) of %s in 'all' group, found another '%s'",
+					particles[full].MaxOccurs(), describeParticle(particles[full]), child.LocalName()),
+			})
+		default:
 			violations = append(violations, Violation{
 				Element: child,
 				Code:    "cvc-complex-type.2.4.a",
@@ -2404,12 +2792,13 @@ func (mg *ModelGroup) validateAll(children []xmldom.Element, schema *Schema) []V
 		}
 	}
 
-	// Check all required particles were found
-	for i, particle := range mg.Particles {
-		if !matched[i] && particle.MinOccurs() > 0 {
+	// Check the minimum occurrences of every particle
+	for i, particle := range particles {
+		if counts[i] < particle.MinOccurs() {
 			violations = append(violations, Violation{
-				Code:    "cvc-complex-type.2.4.a",
-				Message: "Required element missing in 'all' group",
+				Code: "cvc-complex-type.2.4.a",
+				Message: fmt.Sprintf("Expected at least %d occurrence(s) of %s in 'all' group, found %d",
+					particle.MinOccurs(), describeParticle(particle), counts[i]),
 			})
 		}
 	}
@@ -2417,6 +2806,81 @@ func (mg *ModelGroup) validateAll(children []xmldom.Element, schema *Schema) []V
 	return violations
 }
 
+// allGroupParticles returns the particles of an all group, with the
+// particles of the all groups it refers to (XSD 1.1) in place of the
+// references
+func (s *Schema) allGroupParticles(mg *ModelGroup, visited map[*ModelGroup]bool) []Particle {
+	if visited[mg] {
+		return nil
+	}
+	visited[mg] = true
+
+	var particles []Particle
+	for _, particle := range mg.Particles {
+		switch p := particle.(type) {
+		case *ModelGroup:
+			if p.Kind == AllGroup {
+				particles = append(particles, s.allGroupParticles(p, visited)...)
+				continue
+			}
+		case *GroupRef:
+			if group, ok := s.Groups[p.Ref]; ok && group.Kind == AllGroup {
+				particles = append(particles, s.allGroupParticles(group, visited)...)
+				continue
+			}
+		}
+		particles = append(particles, particle)
+	}
+	return particles
+}
+
+// allGroupSlot returns the index of the particle of an all group that
+// takes a child, given how often each particle has matched so far.
+// Element particles take precedence over wildcards. When no particle
+// takes the child, full is the index of a matching particle whose
+// maxOccurs has been reached, or -1.
+func (mg *ModelGroup) allGroupSlot(child xmldom.Element, particles []Particle, counts []int, schema *Schema) (index, full int) {
+	full = -1
+	for _, wildcards := range []bool{false, true} {
+		for i, particle := range particles {
+			if _, isWildcard := particle.(*AnyElement); isWildcard != wildcards ||
+				!mg.elementMatchesParticle(child, particle, schema) {
+				continue
+			}
+			if maxOcc := particle.MaxOccurs(); maxOcc == -1 || counts[i] < maxOcc {
+				return i, -1
+			}
+			if full < 0 {
+				full = i
+			}
+		}
+	}
+	return -1, full
+}
+
+// validateAllMatch validates a child against the particle of an all group
+// that took it
+func (mg *ModelGroup) validateAllMatch(child xmldom.Element, particle Particle, schema *Schema) []Violation {
+	switch p := particle.(type) {
+	case *ElementDecl:
+		if p.Type != nil {
+			return schema.validateElementType(child, p)
+		}
+	case *ElementRef:
+		// The declaration of a substitute, or the referenced one
+		actual := QName{Namespace: string(child.NamespaceURI()), Local: string(child.LocalName())}
+		if decl, exists := schema.ElementDecls[actual]; exists && decl.Type != nil {
+			return schema.validateElementType(child, decl)
+		}
+		if decl, exists := schema.ElementDecls[p.Ref]; exists && decl.Type != nil {
+			return schema.validateElementType(child, decl)
+		}
+	case *AnyElement:
+		return ValidateAnyElement(child, p, schema)
+	}
+	return nil
+}
+
 // matchChoiceGroup handles a choice group as a particle in a sequence
 // It consumes children that match any particle in the choice, respecting occurrence constraints
 func (mg *ModelGroup) matchChoiceGroup(choiceGroup *ModelGroup, children []xmldom.Element, schema *Schema) (consumed int, violations []Violation) {
@@ -2434,7 +2898,7 @@ func (mg *ModelGroup) matchChoiceGroup(choiceGroup *ModelGroup, children []xmldo
 
 				// Validate the matched element's type
 				if elemDecl, isElemDecl := particle.(*ElementDecl); isElemDecl && elemDecl.Type != nil {
-					typeViolations := elemDecl.Type.Validate(child, schema)
+					typeViolations := schema.validateElementType(child, elemDecl)
 					violations = append(violations, typeViolations...)
 				} else if elemRef, isElemRef := particle.(*ElementRef); isElemRef {
 					// Look up the element declaration and validate
@@ -2443,10 +2907,10 @@ func (mg *ModelGroup) matchChoiceGroup(choiceGroup *ModelGroup, children []xmldo
 						Local:     string(child.LocalName()),
 					}
 					if decl, exists := schema.ElementDecls[actualQName]; exists && decl.Type != nil {
-						typeViolations := decl.Type.Validate(child, schema)
+						typeViolations := schema.validateElementType(child, decl)
 						violations = append(violations, typeViolations...)
 					} else if decl, exists := schema.ElementDecls[elemRef.Ref]; exists && decl.Type != nil {
-						typeViolations := decl.Type.Validate(child, schema)
+						typeViolations := schema.validateElementType(child, decl)
 						violations = append(violations, typeViolations...)
 					}
 				} else if wildcard, isWildcard := particle.(*AnyElement); isWildcard {
@@ -2537,16 +3001,17 @@ func (mg *ModelGroup) countConsumedByGroup(group *ModelGroup, children []xmldom.
 		}
 
 	case AllGroup:
-		// For an all group, count all elements that match any particle
-		matchedParticles := make(map[int]bool)
-		for i := 0; i < len(children); i++ {
-			for j, particle := range group.Particles {
-				if !matchedParticles[j] && mg.elementMatchesParticle(children[i], particle, schema) {
-					matchedParticles[j] = true
-					consumed++
-					break
-				}
+		// For an all group, count the elements its particles take, up to
+		// the first one they do not
+		particles := schema.allGroupParticles(group, make(map[*ModelGroup]bool))
+		counts := make([]int, len(particles))
+		for _, child := range children {
+			i, _ := mg.allGroupSlot(child, particles, counts, schema)
+			if i < 0 {
+				break
 			}
+			counts[i]++
+			consumed++
 		}
 	}
 
@@ -2623,7 +3088,7 @@ func (mg *ModelGroup) matchParticle(particle Particle, children []xmldom.Element
 
 				// Validate against its type
 				if actualDecl.Type != nil {
-					typeViolations := actualDecl.Type.Validate(child, schema)
+					typeViolations := schema.validateElementType(child, actualDecl)
 					violations = append(violations, typeViolations...)
 				}
 
@@ -2660,7 +3125,7 @@ func (mg *ModelGroup) matchWildcard(wildcard *AnyElement, children []xmldom.Elem
 		child := children[i]
 
 		// Check if element matches wildcard namespace constraint
-		if !MatchesWildcard(child, wildcard.Namespace, schema.TargetNamespace) {
+		if !wildcard.admits(child, schema, mg) {
 			// Element doesn't match wildcard
 			if matched >= wildcard.MinOcc || wildcard.MinOcc == 0 {
 				// We've satisfied min occurrences or wildcard is optional
@@ -2721,7 +3186,7 @@ func (mg *ModelGroup) elementMatchesParticle(elem xmldom.Element, particle Parti
 		}
 	case *AnyElement:
 		// Check if element matches the wildcard's namespace constraint
-		return MatchesWildcard(elem, p.Namespace, schema.TargetNamespace)
+		return p.admits(elem, schema, mg)
 	case *ModelGroup:
 		// For a nested group in a choice, recursively check if element matches any particle in the group
 		// Don't just validate, because optional particles validate successfully even when not matching
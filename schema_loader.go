@@ -1,6 +1,7 @@
 package xsd
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
@@ -37,6 +38,10 @@ type SchemaLoaderConfig struct {
 
 	// Pattern-based loaders for namespace resolution
 	Loaders []PatternLoader
+
+	// XSD version whose rules apply to the loaded schemas (optional,
+	// defaults to XSD 1.0)
+	Version Version
 }
 
 // SchemaLoader handles loading schemas with import/include support
@@ -59,6 +64,9 @@ type SchemaLoader struct {
 	// Pattern-based loaders for namespace resolution
 	loaders []*PatternLoader
 
+	// XSD version whose rules apply to the loaded schemas
+	version Version
+
 	mu sync.Mutex
 }
 
@@ -70,6 +78,10 @@ func NewSchemaLoader(config SchemaLoaderConfig) (*SchemaLoader, error) {
 		loading:    make(map[string]bool),
 		httpClient: config.HTTPClient,
 		loaders:    make([]*PatternLoader, 0, len(config.Loaders)),
+		version:    config.Version,
+	}
+	if loader.version == "" {
+		loader.version = Version10
 	}
 
 	// Use default HTTP client if not provided
@@ -114,11 +126,14 @@ func (sl *SchemaLoader) LoadSchemaWithImports(location string) (*Schema, error)
 	// Initialize combined schema
 	sl.combined = &Schema{
 		ElementDecls:       make(map[QName]*ElementDecl),
+		AttributeDecls:     make(map[QName]*AttributeDecl),
 		TypeDefs:           make(map[QName]Type),
 		AttributeGroups:    make(map[QName]*AttributeGroup),
 		Groups:             make(map[QName]*ModelGroup),
 		ImportedSchemas:    make(map[string]*Schema),
 		SubstitutionGroups: make(map[QName][]QName),
+		Notations:          make(map[QName]*Notation),
+		Version:            sl.version,
 	}
 
 	// Load the main schema
@@ -146,6 +161,17 @@ func (sl *SchemaLoader) LoadSchemaWithImports(location string) (*Schema, error)
 	// Resolve all references in the combined schema
 	sl.combined.resolveReferences()
 
+	// Derivations across documents can only be checked once they are merged
+	errs := append(sl.combined.checkFinalDerivations(), sl.combined.checkComplexDerivations()...)
+	errs = append(errs, sl.combined.checkModelGroups()...)
+	errs = append(errs, sl.combined.checkFacets()...)
+	errs = append(errs, sl.combined.checkAssertions()...)
+	errs = append(errs, sl.combined.checkTypeAlternatives()...)
+	errs = append(errs, sl.combined.checkIdentityConstraints()...)
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
 	return sl.combined, nil
 }
 
@@ -180,7 +206,7 @@ func (sl *SchemaLoader) loadSchemaRecursive(location string) (*Schema, error) {
 	}
 
 	// Parse the schema
-	schema, err := Parse(doc)
+	schema, err := ParseVersion(doc, sl.version)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse schema from %s: %w", absLocation, err)
 	}
@@ -220,6 +246,20 @@ func (sl *SchemaLoader) loadSchemaRecursive(location string) (*Schema, error) {
 	return schema, nil
 }
 
+// Sources returns the locations of every schema document the loader has read,
+// i.e. the main schema plus all of its includes and imports
+func (sl *SchemaLoader) Sources() []string {
+	sl.mu.Lock()
+	defer sl.mu.Unlock()
+
+	sources := make([]string, 0, len(sl.loaded))
+	for location := range sl.loaded {
+		sources = append(sources, location)
+	}
+	slices.Sort(sources)
+	return sources
+}
+
 // findIncludes finds all xs:include elements in the document
 func (sl *SchemaLoader) findIncludes(doc xmldom.Document) []string {
 	var includes []string
@@ -229,7 +269,8 @@ func (sl *SchemaLoader) findIncludes(doc xmldom.Document) []string {
 		return includes
 	}
 
-	children := root.Children()
+	// Includes guarded by vc: attributes this version doesn't meet are skipped
+	children := includedChildren(root, sl.version)
 	for i := uint(0); i < children.Length(); i++ {
 		child := children.Item(i)
 		if child == nil {
@@ -372,6 +413,13 @@ func (sl *SchemaLoader) mergeComponents(source, target *Schema) {
 		}
 	}
 
+	// Merge attribute declarations
+	for qname, attr := range source.AttributeDecls {
+		if _, exists := target.AttributeDecls[qname]; !exists {
+			target.AttributeDecls[qname] = attr
+		}
+	}
+
 	// Merge type definitions
 	for qname, typ := range source.TypeDefs {
 		if _, exists := target.TypeDefs[qname]; !exists {
@@ -393,6 +441,13 @@ func (sl *SchemaLoader) mergeComponents(source, target *Schema) {
 		}
 	}
 
+	// Merge notations
+	for qname, notation := range source.Notations {
+		if _, exists := target.Notations[qname]; !exists {
+			target.Notations[qname] = notation
+		}
+	}
+
 	// Merge substitution groups
 	for headQName, members := range source.SubstitutionGroups {
 		// Append members to existing substitution group (avoiding duplicates)
@@ -541,11 +596,13 @@ func (sl *SchemaLoader) LoadSchemasFromNamespaces(namespaces map[string]Namespac
 	// Initialize combined schema
 	sl.combined = &Schema{
 		ElementDecls:       make(map[QName]*ElementDecl),
+		AttributeDecls:     make(map[QName]*AttributeDecl),
 		TypeDefs:           make(map[QName]Type),
 		AttributeGroups:    make(map[QName]*AttributeGroup),
 		Groups:             make(map[QName]*ModelGroup),
 		ImportedSchemas:    make(map[string]*Schema),
 		SubstitutionGroups: make(map[QName][]QName),
+		Notations:          make(map[QName]*Notation),
 	}
 
 	var mainSchema *Schema
@@ -20,7 +20,7 @@ func TestSchemaImport(t *testing.T) {
 	// Create main schema file
 	mainSchema := `<?xml version="1.0" encoding="UTF-8"?>
 <xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"
-           targetNamespace="http://example.com/main"
+           targetNamespace="http://example.com/main" elementFormDefault="qualified"
            xmlns:main="http://example.com/main"
            xmlns:types="http://example.com/types">
     
@@ -48,7 +48,7 @@ func TestSchemaImport(t *testing.T) {
 	// Create imported types schema
 	typesSchema := `<?xml version="1.0" encoding="UTF-8"?>
 <xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"
-           targetNamespace="http://example.com/types"
+           targetNamespace="http://example.com/types" elementFormDefault="qualified"
            xmlns:types="http://example.com/types">
     
     <xs:complexType name="personType">
@@ -175,7 +175,7 @@ func TestSchemaInclude(t *testing.T) {
 	// Create main schema file with include
 	mainSchema := `<?xml version="1.0" encoding="UTF-8"?>
 <xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"
-           targetNamespace="http://example.com/app"
+           targetNamespace="http://example.com/app" elementFormDefault="qualified"
            xmlns:app="http://example.com/app">
     
     <xs:include schemaLocation="common-types.xsd"/>
@@ -201,7 +201,7 @@ func TestSchemaInclude(t *testing.T) {
 	// Create included common types schema (same namespace)
 	commonTypes := `<?xml version="1.0" encoding="UTF-8"?>
 <xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"
-           targetNamespace="http://example.com/app"
+           targetNamespace="http://example.com/app" elementFormDefault="qualified"
            xmlns:app="http://example.com/app">
     
     <xs:simpleType name="idType">
@@ -335,7 +335,7 @@ func TestCircularImports(t *testing.T) {
 	// Create schema A that imports B
 	schemaA := `<?xml version="1.0" encoding="UTF-8"?>
 <xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"
-           targetNamespace="http://example.com/a"
+           targetNamespace="http://example.com/a" elementFormDefault="qualified"
            xmlns:a="http://example.com/a"
            xmlns:b="http://example.com/b">
     <xs:import namespace="http://example.com/b" schemaLocation="b.xsd"/>
@@ -349,7 +349,7 @@ func TestCircularImports(t *testing.T) {
 	// Create schema B that imports A (circular)
 	schemaB := `<?xml version="1.0" encoding="UTF-8"?>
 <xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"
-           targetNamespace="http://example.com/b"
+           targetNamespace="http://example.com/b" elementFormDefault="qualified"
            xmlns:b="http://example.com/b"
            xmlns:a="http://example.com/a">
     <xs:import namespace="http://example.com/a" schemaLocation="a.xsd"/>
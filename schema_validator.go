@@ -10,8 +10,9 @@ import (
 
 // SchemaValidator validates that an XSD schema document conforms to XSD rules
 type SchemaValidator struct {
-	errors []error
-	idMap  map[string]xmldom.Element // Track ID values for uniqueness
+	Version Version // XSD version whose rules apply, XSD 1.0 when empty
+	errors  []error
+	idMap   map[string]xmldom.Element // Track ID values for uniqueness
 }
 
 // NewSchemaValidator creates a new schema validator
@@ -433,7 +434,7 @@ func (sv *SchemaValidator) validateModelGroup(elem xmldom.Element) {
 
 		// Children of xs:all must have maxOccurs 0 or 1 (in XSD 1.0)
 		children := elem.Children()
-		for i := uint(0); i < children.Length(); i++ {
+		for i := uint(0); i < children.Length() && sv.Version != Version11; i++ {
 			child := children.Item(i)
 			if child != nil && string(child.LocalName()) == "element" {
 				childMax := child.GetAttribute("maxOccurs")
@@ -29,7 +29,8 @@ func ValidateUnionType(value string, union *Union, schema *Schema) error {
 		} else {
 			// Check if it's a built-in type
 			if validator := GetBuiltinTypeValidator(memberType.Local); validator != nil {
-				err := validator(value)
+				// Each member applies its own whitespace processing
+				err := validator(NormalizeWhiteSpace(value, builtinTypeWhiteSpace(memberType.Local)))
 				if err == nil {
 					// Valid against this built-in type
 					return nil
@@ -115,6 +116,14 @@ func validateValueAgainstType(value string, t Type, schema *Schema) error {
 
 // validateSimpleTypeValue validates a value against a simple type
 func validateSimpleTypeValue(value string, st *SimpleType, schema *Schema) error {
+	// Union members process whitespace themselves, so this runs per member
+	value = NormalizeValue(value, st)
+
+	// Atomic restriction chains carry the facets of every step
+	if st.facetsCompiled {
+		return validateEffectiveFacets(value, st)
+	}
+
 	// Handle restriction
 	if st.Restriction != nil {
 		// First validate against base type if it exists
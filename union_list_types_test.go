@@ -11,7 +11,7 @@ func TestUnionTypes(t *testing.T) {
 	// Schema with union types
 	schemaXML := `<?xml version="1.0" encoding="UTF-8"?>
 	<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema" 
-	           targetNamespace="http://example.com" 
+	           targetNamespace="http://example.com" elementFormDefault="qualified" 
 	           xmlns:ex="http://example.com">
 		
 		<!-- Union of integer and string -->
@@ -167,7 +167,7 @@ func TestListTypes(t *testing.T) {
 	// Schema with list types
 	schemaXML := `<?xml version="1.0" encoding="UTF-8"?>
 	<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema" 
-	           targetNamespace="http://example.com" 
+	           targetNamespace="http://example.com" elementFormDefault="qualified" 
 	           xmlns:ex="http://example.com">
 		
 		<!-- List of integers -->
@@ -337,7 +337,7 @@ func TestComplexUnionList(t *testing.T) {
 	// Schema with complex union and list combinations
 	schemaXML := `<?xml version="1.0" encoding="UTF-8"?>
 	<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema" 
-	           targetNamespace="http://example.com" 
+	           targetNamespace="http://example.com" elementFormDefault="qualified" 
 	           xmlns:ex="http://example.com">
 		
 		<!-- List of unions -->
@@ -14,6 +14,15 @@ type Validator struct {
 	ids           map[string]xmldom.Element
 	violations    []Violation
 	idConstraints *IdentityConstraintValidator // Identity constraints validator
+
+	// Set by SchemaRegistry when this validator covers one namespace island
+	// of a compound document
+	skip        func(elem xmldom.Element) bool // Subtrees validated by another validator
+	deferIDREFs bool                           // IDREFs are resolved across all islands by the caller
+
+	lenientNamespaces bool // Look up unqualified elements in the target namespace too
+
+	entities map[string]*UnparsedEntity // Unparsed entities of the DTD, nil when unknown
 }
 
 // NewValidator creates a new validator for a schema
@@ -25,17 +34,21 @@ func NewValidator(schema *Schema) *Validator {
 		violations:    make([]Violation, 0),
 		idConstraints: NewIdentityConstraintValidator(),
 	}
-
-	// Collect all identity constraints from element declarations
-	for _, decl := range schema.ElementDecls {
-		for _, constraint := range decl.Constraints {
-			v.idConstraints.AddConstraint(constraint)
-		}
-	}
+	// Identity constraints are evaluated within each instance of the
+	// global or local element declaring them
+	v.idConstraints.schema = schema
 
 	return v
 }
 
+// SetLenientNamespaces controls whether elements without a namespace are
+// also looked up in the schema's target namespace when no declaration
+// matches their name. This accepts instances that omit the namespace of
+// global elements, which strict validation reports as cvc-elt.1.
+func (v *Validator) SetLenientNamespaces(lenient bool) {
+	v.lenientNamespaces = lenient
+}
+
 // Validate validates an XML document against the schema
 func (v *Validator) Validate(doc xmldom.Document) []Violation {
 	if doc == nil {
@@ -53,47 +66,70 @@ func (v *Validator) Validate(doc xmldom.Document) []Violation {
 		}}
 	}
 
+	return v.ValidateElement(root)
+}
+
+// ValidateElement validates the subtree rooted at an element as if the
+// element were the document element
+func (v *Validator) ValidateElement(root xmldom.Element) []Violation {
+	if root == nil {
+		return []Violation{{
+			Code:    "xsd-no-root",
+			Message: "Document has no root element",
+		}}
+	}
+
 	// Reset state
 	v.violations = make([]Violation, 0)
 	v.ids = make(map[string]xmldom.Element)
 	v.idRefs = make(map[string]xmldom.Element)
 
 	// Collect all IDs and IDREFs first
-	v.collectIDsAndRefs(root)
+	v.collectIDsAndRefs(root, nil)
 
 	// Validate root element
 	v.validateElement(root, nil)
 
 	// Check IDREF constraints
-	v.validateIDREFs()
+	if !v.deferIDREFs {
+		v.validateIDREFs()
+	}
 
 	// Validate identity constraints (key, keyref, unique)
 	if v.idConstraints != nil {
-		idViolations := v.idConstraints.Validate(doc)
+		idViolations := v.idConstraints.ValidateElement(root)
 		v.violations = append(v.violations, idViolations...)
 	}
 
 	return v.violations
 }
 
-// collectIDsAndRefs collects all ID and IDREF attributes in the document
-func (v *Validator) collectIDsAndRefs(elem xmldom.Element) {
+// collectIDsAndRefs collects all ID and IDREF attributes in the document.
+// Each element is matched to its declaration in the content model of its
+// parent's type, so attributes and content are read with the type the
+// element is validated against.
+func (v *Validator) collectIDsAndRefs(elem xmldom.Element, parentType Type) {
 	// Get the element's type from schema
 	elemNS := string(elem.NamespaceURI())
 	elemLocal := string(elem.LocalName())
 	qname := QName{Namespace: elemNS, Local: elemLocal}
 
-	v.schema.mu.RLock()
-	decl, found := v.schema.ElementDecls[qname]
-	v.schema.mu.RUnlock()
+	decl := v.schema.childDeclaration(parentType, elem)
 
-	if !found && elemNS == "" && v.schema.TargetNamespace != "" {
+	if decl == nil && v.lenientNamespaces && elemNS == "" && v.schema.TargetNamespace != "" {
 		qname.Namespace = v.schema.TargetNamespace
 		v.schema.mu.RLock()
 		decl = v.schema.ElementDecls[qname]
 		v.schema.mu.RUnlock()
 	}
 
+	// The type xsi:type or type alternatives select; violations are
+	// reported when the element itself is validated
+	var elemType Type
+	if decl != nil {
+		elemType, _ = v.schema.elementType(elem, decl)
+	}
+
 	// Pre-defined types to avoid allocations in hot path
 	var (
 		idType = &SimpleType{
@@ -127,7 +163,7 @@ func (v *Validator) collectIDsAndRefs(elem xmldom.Element) {
 		attrValue := string(attr.NodeValue())
 
 		// Get attribute type from schema
-		attrType := v.getAttributeType(decl, attrName)
+		attrType := v.getAttributeType(elemType, QName{Namespace: attrNS, Local: attrName})
 		if attrType == nil {
 			// Fallback to name-based detection for backward compatibility
 			if attrName == "id" || attrName == "ID" {
@@ -143,6 +179,8 @@ func (v *Validator) collectIDsAndRefs(elem xmldom.Element) {
 		}
 
 		if attrType != nil {
+			attrValue = NormalizeValue(attrValue, attrType)
+
 			// Check if type derives from xs:ID
 			if v.derivesFromBuiltinType(attrType, "ID") {
 				if _, exists := v.ids[attrValue]; exists {
@@ -167,41 +205,42 @@ func (v *Validator) collectIDsAndRefs(elem xmldom.Element) {
 					}
 				}
 			}
+
+			v.checkEntities(elem, attrName, attrType, attrValue)
 		}
 	}
 
+	// Elements of a simple type or with simple content can carry ENTITY
+	// values too
+	if hasSimpleValue(elemType) {
+		v.checkEntities(elem, "", elemType, getElementTextContent(elem))
+	}
+
 	// Recurse through children
 	children := elem.Children()
 	for i := uint(0); i < children.Length(); i++ {
-		if child := children.Item(i); child != nil {
-			v.collectIDsAndRefs(child)
+		if child := children.Item(i); child != nil && !v.skipped(child) {
+			v.collectIDsAndRefs(child, elemType)
 		}
 	}
 }
 
-// getAttributeType returns the type of an attribute from the element declaration
-func (v *Validator) getAttributeType(elemDecl *ElementDecl, attrName string) Type {
-	if elemDecl == nil || elemDecl.Type == nil {
-		return nil
-	}
+// skipped reports whether a subtree is validated by another validator
+func (v *Validator) skipped(elem xmldom.Element) bool {
+	return v.skip != nil && v.skip(elem)
+}
 
-	// Check if element type is a complex type
-	ct, ok := elemDecl.Type.(*ComplexType)
+// getAttributeType returns the type of an attribute among the attribute
+// uses of an element's type
+func (v *Validator) getAttributeType(elemType Type, attrName QName) Type {
+	// Only complex types have attributes
+	ct, ok := elemType.(*ComplexType)
 	if !ok {
 		return nil
 	}
 
-	// Check direct attributes
-	for _, attr := range ct.Attributes {
-		if attr.Name.Local == attrName {
-			return attr.Type
-		}
-	}
-
-	// Check attribute groups
-	groupAttrs := v.schema.ResolveAttributeGroups(ct)
-	for _, attr := range groupAttrs {
-		if attr.Name.Local == attrName {
+	for _, attr := range v.schema.AttributeUses(ct) {
+		if attr.Name == attrName {
 			return attr.Type
 		}
 	}
@@ -265,7 +304,7 @@ func (v *Validator) validateElement(elem xmldom.Element, parentType Type) {
 	decl, found := v.schema.ElementDecls[qname]
 	v.schema.mu.RUnlock()
 
-	if !found && elemNS == "" && v.schema.TargetNamespace != "" {
+	if !found && v.lenientNamespaces && elemNS == "" && v.schema.TargetNamespace != "" {
 		// Try with target namespace
 		qname.Namespace = v.schema.TargetNamespace
 		v.schema.mu.RLock()
@@ -284,7 +323,7 @@ func (v *Validator) validateElement(elem xmldom.Element, parentType Type) {
 		// This allows detecting errors in nested elements
 		children := elem.Children()
 		for i := uint(0); i < children.Length(); i++ {
-			if child := children.Item(i); child != nil {
+			if child := children.Item(i); child != nil && !v.skipped(child) {
 				v.validateElement(child, nil)
 			}
 		}
@@ -298,9 +337,25 @@ func (v *Validator) validateElement(elem xmldom.Element, parentType Type) {
 			nil, elemLocal)
 	}
 
+	// Violations of the element itself name the type alternative that
+	// selected its type
+	alternative := v.schema.selectAlternative(elem, decl)
+	first := len(v.violations)
+	defer func() {
+		withAlternative(v.violations[first:], elem, alternative)
+	}()
+
+	// The type to validate against, possibly selected by a type alternative
+	// or overridden by xsi:type
+	elemType, typeViolations := v.schema.selectedElementType(elem, decl, alternative)
+	v.violations = append(v.violations, typeViolations...)
+	if elemType == nil && alternative != nil && alternative.IsError() {
+		return
+	}
+
 	// Check if element's type is abstract
-	if decl.Type != nil {
-		if ct, ok := decl.Type.(*ComplexType); ok && ct.Abstract {
+	if elemType != nil {
+		if ct, ok := elemType.(*ComplexType); ok && ct.Abstract {
 			v.addViolation(elem, "", "cvc-type.2",
 				fmt.Sprintf("Element '%s' has abstract type '%s' which cannot be used directly",
 					elemLocal, ct.QName.Local),
@@ -339,9 +394,9 @@ func (v *Validator) validateElement(elem xmldom.Element, parentType Type) {
 
 	// Validate against type (but skip content validation for ComplexType,
 	// as that will be done in validateChildren to avoid duplication)
-	if decl.Type != nil {
-		if _, isComplexType := decl.Type.(*ComplexType); !isComplexType {
-			violations := decl.Type.Validate(elem, v.schema)
+	if elemType != nil {
+		if _, isComplexType := elemType.(*ComplexType); !isComplexType {
+			violations := elemType.Validate(elem, v.schema)
 			v.violations = append(v.violations, violations...)
 		}
 
@@ -352,14 +407,17 @@ func (v *Validator) validateElement(elem xmldom.Element, parentType Type) {
 			content = decl.Default
 		}
 		if content != "" {
+			// Lexical and facet checks see the value after whitespace processing
+			content = NormalizeValue(content, elemType)
+
 			// Validate built-in type
-			if err := v.validateBuiltinType(content, decl.Type); err != nil {
+			if err := v.validateBuiltinType(content, elemType); err != nil {
 				v.addViolation(elem, "", "cvc-datatype-valid.1",
 					err.Error(), nil, content)
 			}
 
 			// Validate facets for simple types
-			if st, ok := decl.Type.(*SimpleType); ok {
+			if st, ok := elemType.(*SimpleType); ok {
 				if err := v.validateSimpleTypeFacets(content, st); err != nil {
 					v.addViolation(elem, "", "cvc-facet-valid",
 						err.Error(), nil, content)
@@ -369,10 +427,15 @@ func (v *Validator) validateElement(elem xmldom.Element, parentType Type) {
 	}
 
 	// Validate attributes
-	v.validateAttributes(elem, decl.Type)
+	v.validateAttributes(elem, elemType)
 
 	// Validate children
-	v.validateChildren(elem, decl.Type)
+	v.validateChildren(elem, elemType)
+
+	// Assertions of complex types see the element with all its content
+	if ct, ok := elemType.(*ComplexType); ok {
+		v.violations = append(v.violations, v.schema.validateAssertions(elem, ct)...)
+	}
 }
 
 // validateAttributes validates element attributes
@@ -382,18 +445,15 @@ func (v *Validator) validateAttributes(elem xmldom.Element, elemType Type) {
 	var anyAttr *AnyAttribute
 
 	if ct, ok := elemType.(*ComplexType); ok {
-		expectedAttrs = ct.Attributes
-		anyAttr = ct.AnyAttribute
-
-		// Also resolve attribute groups
-		groupAttrs := v.schema.ResolveAttributeGroups(ct)
-		expectedAttrs = append(expectedAttrs, groupAttrs...)
+		// Effective uses include inherited attributes and attribute groups
+		expectedAttrs = v.schema.AttributeUses(ct)
+		anyAttr = v.schema.AttributeWildcard(ct)
 	}
 
-	// Build map of expected attributes
-	expected := make(map[string]*AttributeDecl)
+	// Build map of expected attributes, matched by their qualified name
+	expected := make(map[QName]*AttributeDecl)
 	for _, attr := range expectedAttrs {
-		expected[attr.Name.Local] = attr
+		expected[attr.Name] = attr
 	}
 
 	// Check all attributes on element
@@ -414,19 +474,25 @@ func (v *Validator) validateAttributes(elem xmldom.Element, elemType Type) {
 			continue
 		}
 
+		// xsi:type, xsi:nil and friends are allowed on every element
+		if attrNS == xsiNamespace {
+			continue
+		}
+
 		// Check if attribute is expected
-		if decl, ok := expected[attrLocal]; ok {
+		attrQName := QName{Namespace: attrNS, Local: attrLocal}
+		if decl, ok := expected[attrQName]; ok {
 			// Validate fixed and default values
 			fixedDefaultViolations := ValidateAttributeFixedDefault(attr, decl, elem)
 			v.violations = append(v.violations, fixedDefaultViolations...)
 
 			// Validate attribute value against type
 			if decl.Type != nil {
-				attrValue := string(attr.NodeValue())
+				attrValue := NormalizeValue(string(attr.NodeValue()), decl.Type)
 				typeViolations := v.validateAttributeType(elem, attrLocal, attrValue, decl.Type)
 				v.violations = append(v.violations, typeViolations...)
 			}
-			delete(expected, attrLocal) // Mark as found
+			delete(expected, attrQName) // Mark as found
 		} else {
 			// Check if allowed by anyAttribute
 			if anyAttr != nil {
@@ -452,7 +518,8 @@ func (v *Validator) validateAttributes(elem xmldom.Element, elemType Type) {
 	}
 
 	// Check for required attributes that are missing
-	for name, decl := range expected {
+	for qname, decl := range expected {
+		name := qname.Local
 		// Check fixed value for missing attributes
 		if decl.Fixed != "" {
 			// Missing attribute with fixed value - validate that it would have the fixed value
@@ -512,10 +579,39 @@ func (v *Validator) validateAttributeType(elem xmldom.Element, attrName string,
 		return violations
 	}
 
+	// Restrictions of list types count items, as for element content
+	if v.schema.restrictsList(simpleType) {
+		if err := validateSimpleTypeValue(value, simpleType, v.schema); err != nil {
+			violations = append(violations, Violation{
+				Element:   elem,
+				Code:      "cvc-datatype-valid.1.2.1",
+				Message:   fmt.Sprintf("Attribute '%s': %s", attrName, err.Error()),
+				Attribute: attrName,
+				Actual:    value,
+			})
+		}
+		return violations
+	}
+
 	// If it has a restriction, validate against facets
 	if simpleType.Restriction != nil {
-		for _, facet := range simpleType.Restriction.Facets {
-			err := facet.Validate(value, simpleType)
+		facets, baseType := simpleType.Restriction.Facets, Type(simpleType)
+		if simpleType.facetsCompiled {
+			// The facets of a restriction chain apply in the value space of
+			// the built-in type it starts from
+			facets, baseType = simpleType.facets, &SimpleType{QName: simpleType.builtin}
+			if err := validateBuiltinValue(value, simpleType.builtin); err != nil {
+				return append(violations, Violation{
+					Element:   elem,
+					Code:      "cvc-datatype-valid.1.2.1",
+					Message:   fmt.Sprintf("Attribute '%s': %s", attrName, err.Error()),
+					Attribute: attrName,
+					Actual:    value,
+				})
+			}
+		}
+		for _, facet := range facets {
+			err := facet.Validate(value, baseType)
 			if err != nil {
 				// Create violation based on facet type
 				code := "cvc-datatype-valid.1.2.1"
@@ -539,6 +635,19 @@ func (v *Validator) validateAttributeType(elem xmldom.Element, attrName string,
 		}
 	}
 
+	// NOTATION values must name a declared notation
+	if len(violations) == 0 && v.schema.derivesFromNotation(simpleType) {
+		if err := v.schema.checkNotationValue(elem, value, simpleType); err != nil {
+			violations = append(violations, Violation{
+				Element:   elem,
+				Code:      "cvc-datatype-valid.1.2.1",
+				Message:   fmt.Sprintf("Attribute '%s': %s", attrName, err.Error()),
+				Attribute: attrName,
+				Actual:    value,
+			})
+		}
+	}
+
 	return violations
 }
 
@@ -597,10 +706,10 @@ func (v *Validator) validateChildren(elem xmldom.Element, elemType Type) {
 		}
 	}
 
-	// Get content model
-	if ct.Content != nil {
+	// Get content model, which open content may extend
+	if ct.Content != nil || v.schema.OpenContent(ct) != nil {
 		// Validate against content model
-		violations := ct.Content.Validate(elem, v.schema)
+		violations := v.schema.validateContent(elem, ct)
 		for _, violation := range violations {
 			// Set element if not already set
 			if violation.Element == nil {
@@ -809,6 +918,11 @@ func (v *Validator) validateBuiltinType(value string, elemType Type) error {
 		return builtinType.Validator(value)
 	}
 
+	// Restriction chains are validated against the built-in they start from
+	if st, ok := elemType.(*SimpleType); ok && st.facetsCompiled {
+		return validateBuiltinValue(value, st.builtin)
+	}
+
 	// If it's a simple type, check its base type
 	if st, ok := elemType.(*SimpleType); ok && st.Restriction != nil {
 		if baseType := GetBuiltinType(st.Restriction.Base.Local); baseType != nil {
@@ -835,6 +949,11 @@ func (v *Validator) validateSimpleTypeFacets(value string, simpleType *SimpleTyp
 		return ValidateListType(value, simpleType.List, v.schema)
 	}
 
+	// Restriction chains carry the facets inherited from every step
+	if simpleType.facetsCompiled {
+		return ValidateFacets(value, simpleType.facets, &SimpleType{QName: simpleType.builtin})
+	}
+
 	// Handle restriction types
 	if simpleType.Restriction != nil && len(simpleType.Restriction.Facets) > 0 {
 		// Get the base type for context
@@ -2,6 +2,7 @@ package xsd
 
 import (
 	"bytes"
+	"strings"
 	"testing"
 
 	"github.com/agentflare-ai/go-xmldom"
@@ -454,3 +455,169 @@ func TestMixedContentValidation(t *testing.T) {
 		})
 	}
 }
+
+// Test QName references resolve against the namespaces in scope where they appear
+func TestQNameResolutionScope(t *testing.T) {
+	schemaDoc, err := xmldom.Decode(bytes.NewReader([]byte(`<?xml version="1.0"?>
+<x:schema xmlns:x="http://www.w3.org/2001/XMLSchema"
+          targetNamespace="http://test.com/order" elementFormDefault="qualified">
+	<x:simpleType name="quantity" xmlns:o="http://test.com/order">
+		<x:restriction base="x:positiveInteger">
+			<x:maxInclusive value="10"/>
+		</x:restriction>
+	</x:simpleType>
+	<x:element name="order" xmlns:ord="http://test.com/order">
+		<x:complexType>
+			<x:sequence>
+				<x:element name="qty" type="ord:quantity"/>
+			</x:sequence>
+		</x:complexType>
+	</x:element>
+</x:schema>`)))
+	if err != nil {
+		t.Fatalf("Failed to parse schema XML: %v", err)
+	}
+	schema, err := Parse(schemaDoc)
+	if err != nil {
+		t.Fatalf("Failed to parse schema: %v", err)
+	}
+
+	qty := schema.ElementDecls[QName{Namespace: "http://test.com/order", Local: "order"}].
+		Type.(*ComplexType).Content.(*ModelGroup).Particles[0].(*ElementDecl)
+	if got := qty.Type.Name(); got != (QName{Namespace: "http://test.com/order", Local: "quantity"}) {
+		t.Errorf("qty type resolved to %v", got)
+	}
+	st := schema.TypeDefs[QName{Namespace: "http://test.com/order", Local: "quantity"}].(*SimpleType)
+	if st.Restriction.Base != (QName{Namespace: XSDNamespace, Local: "positiveInteger"}) {
+		t.Errorf("restriction base resolved to %v", st.Restriction.Base)
+	}
+
+	// The prefix is declared on a sibling, so it is not in scope here
+	unboundDoc, err := xmldom.Decode(bytes.NewReader([]byte(`<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema" targetNamespace="http://test.com/order">
+	<xs:simpleType name="a" xmlns:o="http://test.com/order">
+		<xs:restriction base="xs:string"/>
+	</xs:simpleType>
+	<xs:element name="b" type="o:a"/>
+</xs:schema>`)))
+	if err != nil {
+		t.Fatalf("Failed to parse schema XML: %v", err)
+	}
+	if _, err := Parse(unboundDoc); err == nil || !strings.Contains(err.Error(), "src-resolve") {
+		t.Errorf("Expected src-resolve error for unbound prefix, got %v", err)
+	}
+
+	// Unprefixed names take the default namespace, not the target namespace,
+	// and xmlns="" undeclares it
+	defaultDoc, err := xmldom.Decode(bytes.NewReader([]byte(`<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema" targetNamespace="http://test.com/order">
+	<xs:element name="none" type="code"/>
+	<xs:element name="default" type="code" xmlns="http://test.com/order"/>
+	<xs:element name="undeclared" xmlns="http://test.com/order">
+		<xs:complexType>
+			<xs:sequence>
+				<xs:element name="inner" type="code" xmlns=""/>
+			</xs:sequence>
+		</xs:complexType>
+	</xs:element>
+</xs:schema>`)))
+	if err != nil {
+		t.Fatalf("Failed to parse schema XML: %v", err)
+	}
+	defaultSchema, err := Parse(defaultDoc)
+	if err != nil {
+		t.Fatalf("Failed to parse schema: %v", err)
+	}
+	typeOf := func(local string) QName {
+		return defaultSchema.ElementDecls[QName{Namespace: "http://test.com/order", Local: local}].Type.Name()
+	}
+	if got := typeOf("none"); got != (QName{Local: "code"}) {
+		t.Errorf("Expected an unprefixed name without a default namespace to have no namespace, got %v", got)
+	}
+	if got := typeOf("default"); got != (QName{Namespace: "http://test.com/order", Local: "code"}) {
+		t.Errorf("Expected an unprefixed name to take the default namespace, got %v", got)
+	}
+	inner := defaultSchema.ElementDecls[QName{Namespace: "http://test.com/order", Local: "undeclared"}].
+		Type.(*ComplexType).Content.(*ModelGroup).Particles[0].(*ElementDecl)
+	if got := inner.Type.Name(); got != (QName{Local: "code"}) {
+		t.Errorf("Expected xmlns=\"\" to undeclare the default namespace, got %v", got)
+	}
+}
+
+// Test local declarations follow elementFormDefault, attributeFormDefault and form
+func TestFormQualification(t *testing.T) {
+	schemaDoc, err := xmldom.Decode(bytes.NewReader([]byte(`<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema" targetNamespace="http://test.com/form">
+	<xs:element name="root">
+		<xs:complexType>
+			<xs:sequence>
+				<xs:element name="local" type="xs:string"/>
+				<xs:element name="qualified" type="xs:string" form="qualified"/>
+			</xs:sequence>
+			<xs:attribute name="plain" type="xs:string" use="required"/>
+			<xs:attribute name="prefixed" type="xs:string" form="qualified"/>
+		</xs:complexType>
+	</xs:element>
+</xs:schema>`)))
+	if err != nil {
+		t.Fatalf("Failed to parse schema XML: %v", err)
+	}
+	schema, err := Parse(schemaDoc)
+	if err != nil {
+		t.Fatalf("Failed to parse schema: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		xml     string
+		lenient bool
+		codes   []string
+	}{
+		{
+			name: "names qualified as declared",
+			xml: `<f:root xmlns:f="http://test.com/form" plain="a" f:prefixed="b">
+				<local>x</local><f:qualified>y</f:qualified></f:root>`,
+		},
+		{
+			name: "unqualified local element in target namespace",
+			xml: `<root xmlns="http://test.com/form" plain="a">
+				<local>x</local><qualified>y</qualified></root>`,
+			codes: []string{"cvc-complex-type.2.4.d", "cvc-complex-type.2.4.d", "cvc-complex-type.2.4.b", "cvc-complex-type.2.4.b"},
+		},
+		{
+			name: "qualified attribute without namespace",
+			xml: `<f:root xmlns:f="http://test.com/form" plain="a" prefixed="b">
+				<local>x</local><f:qualified>y</f:qualified></f:root>`,
+			codes: []string{"cvc-complex-type.3.2.2"},
+		},
+		{
+			name:  "root without namespace",
+			xml:   `<root plain="a"><local>x</local></root>`,
+			codes: []string{"cvc-elt.1", "cvc-elt.1"},
+		},
+		{
+			name:    "root without namespace in lenient mode",
+			xml:     `<root plain="a"><local>x</local><f:qualified xmlns:f="http://test.com/form">y</f:qualified></root>`,
+			lenient: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := xmldom.Decode(bytes.NewReader([]byte(tt.xml)))
+			if err != nil {
+				t.Fatalf("Failed to parse XML: %v", err)
+			}
+			validator := NewValidator(schema)
+			validator.SetLenientNamespaces(tt.lenient)
+
+			var codes []string
+			for _, v := range validator.Validate(doc) {
+				codes = append(codes, v.Code)
+			}
+			if strings.Join(codes, " ") != strings.Join(tt.codes, " ") {
+				t.Errorf("Expected violations %v, got %v", tt.codes, codes)
+			}
+		})
+	}
+}
@@ -0,0 +1,747 @@
+package xsd
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/agentflare-ai/go-xmldom"
+)
+
+// Value is a value in the value space of a simple type, as returned by
+// ParseValue. Its dynamic type depends on the type it was parsed against:
+//
+//   - string and the types derived from it: string; anyURI: AnyURI
+//   - boolean: bool
+//   - decimal: *big.Rat; integer and the types derived from it: *big.Int
+//   - float: float32; double: float64. INF, -INF and NaN are the IEEE
+//     values. The value spaces are disjoint, so floats never equal doubles.
+//   - duration: Duration
+//   - dateTime, time, date and the g* types: DateTime
+//   - hexBinary: []byte; base64Binary: Base64Binary
+//   - QName: QName; NOTATION: NotationName
+//   - list types, including IDREFS, ENTITIES and NMTOKENS: []Value
+//   - union types: UnionValue
+//
+// The value spaces of the primitive types are disjoint, so each primitive
+// has its own Go type: a string never equals an anyURI, hexBinary never
+// equals base64Binary, and a QName never equals a NOTATION.
+type Value = any
+
+// AnyURI is a value of xs:anyURI
+type AnyURI string
+
+// Base64Binary is a value of xs:base64Binary: the decoded octets
+type Base64Binary []byte
+
+// NotationName is a value of xs:NOTATION: the name of a notation
+type NotationName QName
+
+// DateTime is a value of one of the date and time types. Components the
+// type does not have are zero.
+type DateTime struct {
+	Kind     string // Primitive type: "dateTime", "time", "date", "gYear", ...
+	Year     int
+	Month    int
+	Day      int
+	Hour     int
+	Minute   int
+	Second   int
+	Fraction *big.Rat // Fractional seconds, exact; nil when the value has none
+	Timezone *int     // Offset from UTC in minutes, nil when the value has none
+}
+
+// Duration is a value of xs:duration: a number of months and a number of
+// seconds, both counted in the direction given by Negative
+type Duration struct {
+	Negative bool
+	Months   *big.Int
+	Seconds  *big.Rat
+}
+
+// UnionValue is a value of a union type, tagged with the member type it
+// was parsed against
+type UnionValue struct {
+	Member Type
+	Value  Value
+}
+
+// ParseValue parses a lexical value of a simple type, or of a complex type
+// with simple content, into its value. The value is processed for
+// whitespace and validated against the type first, as the validator does.
+// Prefixed QName and NOTATION values cannot be resolved without a context;
+// use ParseValueAt for those.
+func (s *Schema) ParseValue(lexical string, t Type) (Value, error) {
+	return s.ParseValueAt(lexical, t, nil)
+}
+
+// ParseValueAt parses a lexical value like ParseValue, resolving QName and
+// NOTATION prefixes against the namespace declarations in scope at an
+// element of the instance
+func (s *Schema) ParseValueAt(lexical string, t Type, context xmldom.Element) (Value, error) {
+	typed, err := s.resolveLexical(lexical, t, make(map[Type]bool))
+	if err != nil {
+		return nil, err
+	}
+	return typed.value(context)
+}
+
+// typedLexical is a lexical value resolved against a type: atomic values
+// with the built-in type they belong to, list values with their items and
+// union values with the member type they are valid for
+type typedLexical struct {
+	lexical     string
+	builtin     string
+	items       []*typedLexical
+	isList      bool
+	member      Type
+	memberValue *typedLexical
+}
+
+// resolveLexical processes a value for whitespace, validates it and
+// resolves it against the built-in types its type is made of
+func (s *Schema) resolveLexical(value string, t Type, visiting map[Type]bool) (*typedLexical, error) {
+	if t == nil {
+		return nil, fmt.Errorf("no type to resolve value '%s' against", value)
+	}
+	if visiting[t] {
+		return nil, fmt.Errorf("type '%s' is defined in terms of itself", typeLabel(t))
+	}
+	visiting[t] = true
+	defer delete(visiting, t)
+
+	switch tt := t.(type) {
+	case *SimpleType:
+		value = NormalizeValue(value, tt)
+		switch {
+		case tt.List != nil:
+			return s.resolveListLexical(value, tt, visiting)
+		case tt.Union != nil:
+			return s.resolveUnionLexical(value, tt, visiting)
+		case tt.Restriction != nil:
+			if err := validateSimpleTypeValue(value, tt, s); err != nil {
+				return nil, err
+			}
+			if tt.facetsCompiled {
+				return &typedLexical{lexical: value, builtin: tt.builtin.Local}, nil
+			}
+			return s.resolveLexical(value, s.resolveTypeName(tt.Restriction.Base), visiting)
+		case tt.QName.Namespace == XSDNamespace:
+			if err := validateBuiltinValue(value, tt.QName); err != nil {
+				return nil, err
+			}
+			return &typedLexical{lexical: value, builtin: tt.QName.Local}, nil
+		}
+		// A reference to a user-defined type that was not resolved
+		if resolved := s.resolveTypeName(tt.QName); resolved != nil && resolved != Type(tt) {
+			return s.resolveLexical(value, resolved, visiting)
+		}
+	case *ComplexType:
+		if sc, ok := declaredContent(tt).(*SimpleContent); ok {
+			if sc.Restriction != nil {
+				if err := ValidateFacets(value, sc.Restriction.Facets, nil); err != nil {
+					return nil, err
+				}
+				return s.resolveLexical(value, s.resolveTypeName(sc.Restriction.Base), visiting)
+			}
+			if sc.Extension != nil {
+				return s.resolveLexical(value, s.resolveTypeName(sc.Extension.Base), visiting)
+			}
+		}
+		return nil, fmt.Errorf("type '%s' does not have simple content", typeLabel(tt))
+	}
+	return nil, fmt.Errorf("cannot resolve values of type '%s'", typeLabel(t))
+}
+
+// resolveListLexical resolves each item of a list value
+func (s *Schema) resolveListLexical(value string, st *SimpleType, visiting map[Type]bool) (*typedLexical, error) {
+	itemType := s.resolveTypeName(st.List.ItemType)
+	if itemType == nil {
+		return nil, fmt.Errorf("unknown item type: %s", st.List.ItemType)
+	}
+	list := &typedLexical{lexical: value, isList: true}
+	for i, item := range strings.Fields(value) {
+		typed, err := s.resolveLexical(item, itemType, visiting)
+		if err != nil {
+			return nil, fmt.Errorf("list item %d ('%s') is invalid: %v", i+1, item, err)
+		}
+		list.items = append(list.items, typed)
+	}
+	return list, nil
+}
+
+// resolveUnionLexical resolves a value against the first member type of a
+// union it is valid for
+func (s *Schema) resolveUnionLexical(value string, st *SimpleType, visiting map[Type]bool) (*typedLexical, error) {
+	for _, member := range st.Union.MemberTypes {
+		memberType := s.resolveTypeName(member)
+		if memberType == nil {
+			continue
+		}
+		if typed, err := s.resolveLexical(value, memberType, visiting); err == nil {
+			return &typedLexical{lexical: value, member: memberType, memberValue: typed}, nil
+		}
+	}
+	return nil, ValidateUnionType(value, st.Union, s)
+}
+
+// resolveTypeName returns the definition of a named type, a placeholder for
+// built-in types, or nil when the type is unknown
+func (s *Schema) resolveTypeName(name QName) Type {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.lookupTypeLocked(name)
+}
+
+// value builds the value of a resolved lexical value
+func (tl *typedLexical) value(context xmldom.Element) (Value, error) {
+	switch {
+	case tl.isList:
+		items := make([]Value, 0, len(tl.items))
+		for _, item := range tl.items {
+			v, err := item.value(context)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+		}
+		return items, nil
+	case tl.member != nil:
+		v, err := tl.memberValue.value(context)
+		if err != nil {
+			return nil, err
+		}
+		return UnionValue{Member: tl.member, Value: v}, nil
+	}
+	return parseBuiltinValue(tl.lexical, tl.builtin, context)
+}
+
+// orderedPrimitives are the primitive types whose values are ordered
+var orderedPrimitives = map[string]bool{
+	"decimal": true, "float": true, "double": true, "duration": true,
+	"dateTime": true, "time": true, "date": true, "gYearMonth": true,
+	"gYear": true, "gMonthDay": true, "gDay": true, "gMonth": true,
+}
+
+// parseBuiltinValue parses a whitespace-processed value of a built-in type
+func parseBuiltinValue(lexical, name string, context xmldom.Element) (Value, error) {
+	if err := validateBuiltinValue(lexical, QName{Namespace: XSDNamespace, Local: name}); err != nil {
+		return nil, err
+	}
+
+	if builtinListTypes[name] {
+		items := make([]Value, 0)
+		for _, item := range strings.Fields(lexical) {
+			items = append(items, item)
+		}
+		return items, nil
+	}
+
+	switch primitive := primitiveType(name); primitive {
+	case "boolean":
+		return lexical == "true" || lexical == "1", nil
+	case "decimal":
+		canonical, err := canonicalDecimal(lexical)
+		if err != nil {
+			return nil, err
+		}
+		if builtinDerivesFrom(name, "integer") {
+			n, ok := new(big.Int).SetString(canonical, 10)
+			if !ok {
+				return nil, fmt.Errorf("invalid integer value: %s", lexical)
+			}
+			return n, nil
+		}
+		r, ok := new(big.Rat).SetString(canonical)
+		if !ok {
+			return nil, fmt.Errorf("invalid decimal value: %s", lexical)
+		}
+		return r, nil
+	case "float":
+		f, err := parseFloatValue(lexical, primitive)
+		if err != nil {
+			return nil, err
+		}
+		return float32(f), nil
+	case "double":
+		return parseFloatValue(lexical, primitive)
+	case "duration":
+		return parseDuration(lexical)
+	case "dateTime", "time", "date", "gYearMonth", "gYear", "gMonthDay", "gDay", "gMonth":
+		return parseDateTime(lexical, primitive)
+	case "hexBinary":
+		data, err := hex.DecodeString(lexical)
+		if err != nil {
+			return nil, fmt.Errorf("invalid hexBinary value: %s", lexical)
+		}
+		return data, nil
+	case "base64Binary":
+		data, err := decodeBase64Binary(lexical)
+		if err != nil {
+			return nil, err
+		}
+		return Base64Binary(data), nil
+	case "QName":
+		return resolveQNameValue(lexical, context)
+	case "NOTATION":
+		name, err := resolveQNameValue(lexical, context)
+		if err != nil {
+			return nil, err
+		}
+		return NotationName(name), nil
+	case "anyURI":
+		return AnyURI(lexical), nil
+	}
+	return lexical, nil
+}
+
+// parseFloatValue parses a float or double, including INF, -INF and NaN
+func parseFloatValue(lexical, primitive string) (float64, error) {
+	switch lexical {
+	case "INF", "+INF":
+		return math.Inf(1), nil
+	case "-INF":
+		return math.Inf(-1), nil
+	case "NaN":
+		return math.NaN(), nil
+	}
+	bitSize := 64
+	if primitive == "float" {
+		bitSize = 32
+	}
+	f, err := strconv.ParseFloat(lexical, bitSize)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s value: %s", primitive, lexical)
+	}
+	return f, nil
+}
+
+// resolveQNameValue resolves the prefix of a QName value against the
+// namespace declarations in scope at an element
+func resolveQNameValue(lexical string, context xmldom.Element) (QName, error) {
+	prefix, local, prefixed := strings.Cut(lexical, ":")
+	if !prefixed {
+		prefix, local = "", lexical
+	}
+	if context == nil {
+		if prefixed {
+			return QName{}, fmt.Errorf("cannot resolve prefix '%s' of QName '%s' without a context element", prefix, lexical)
+		}
+		return QName{Local: local}, nil
+	}
+	namespace, bound := lookupNamespace(context, prefix)
+	if !bound && prefixed {
+		return QName{}, fmt.Errorf("QName '%s' uses an undeclared prefix '%s'", lexical, prefix)
+	}
+	return QName{Namespace: namespace, Local: local}, nil
+}
+
+var durationPattern = regexp.MustCompile(`^(-)?P(?:(\d+)Y)?(?:(\d+)M)?(?:(\d+)D)?(?:T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+
+// parseDuration parses a duration lexical value
+func parseDuration(value string) (Duration, error) {
+	if err := validateDuration(value); err != nil {
+		return Duration{}, err
+	}
+	m := durationPattern.FindStringSubmatch(value)
+	if m == nil || strings.HasSuffix(value, "T") {
+		return Duration{}, fmt.Errorf("invalid duration value: %s", value)
+	}
+
+	number := func(s string) *big.Int {
+		n, _ := new(big.Int).SetString("0"+s, 10)
+		return n
+	}
+	d := Duration{Negative: m[1] == "-"}
+	d.Months = new(big.Int).Add(new(big.Int).Mul(number(m[2]), big.NewInt(12)), number(m[3]))
+
+	seconds := new(big.Int).Mul(number(m[4]), big.NewInt(24))
+	seconds.Add(seconds, number(m[5])).Mul(seconds, big.NewInt(60))
+	seconds.Add(seconds, number(m[6])).Mul(seconds, big.NewInt(60))
+	d.Seconds = new(big.Rat).SetInt(seconds)
+	if m[7] != "" {
+		fraction, _ := new(big.Rat).SetString(m[7])
+		d.Seconds.Add(d.Seconds, fraction)
+	}
+	if d.Months.Sign() == 0 && d.Seconds.Sign() == 0 {
+		d.Negative = false
+	}
+	return d, nil
+}
+
+// signed returns the months and seconds of a duration with its sign
+func (d Duration) signed() (*big.Int, *big.Rat) {
+	months, seconds := new(big.Int).Set(d.Months), new(big.Rat).Set(d.Seconds)
+	if d.Negative {
+		months.Neg(months)
+		seconds.Neg(seconds)
+	}
+	return months, seconds
+}
+
+// Lexical forms of the date and time types
+var dateTimePatterns = map[string]*regexp.Regexp{
+	"dateTime":   regexp.MustCompile(`^(-?\d{4,})-(\d{2})-(\d{2})T(\d{2}):(\d{2}):(\d{2})(\.\d+)?(Z|[+-]\d{2}:\d{2})?$`),
+	"time":       regexp.MustCompile(`^()()()(\d{2}):(\d{2}):(\d{2})(\.\d+)?(Z|[+-]\d{2}:\d{2})?$`),
+	"date":       regexp.MustCompile(`^(-?\d{4,})-(\d{2})-(\d{2})()()()()(Z|[+-]\d{2}:\d{2})?$`),
+	"gYearMonth": regexp.MustCompile(`^(-?\d{4,})-(\d{2})()()()()()(Z|[+-]\d{2}:\d{2})?$`),
+	"gYear":      regexp.MustCompile(`^(-?\d{4,})()()()()()()(Z|[+-]\d{2}:\d{2})?$`),
+	"gMonthDay":  regexp.MustCompile(`^()--(\d{2})-(\d{2})()()()()(Z|[+-]\d{2}:\d{2})?$`),
+	"gDay":       regexp.MustCompile(`^()()---(\d{2})()()()()(Z|[+-]\d{2}:\d{2})?$`),
+	"gMonth":     regexp.MustCompile(`^()--(\d{2})()()()()()(Z|[+-]\d{2}:\d{2})?$`),
+}
+
+// parseDateTime parses a value of one of the date and time primitive
+// types. Fractional seconds are kept exactly, whatever their number of
+// digits.
+func parseDateTime(value, kind string) (DateTime, error) {
+	pattern, ok := dateTimePatterns[kind]
+	if !ok {
+		return DateTime{}, fmt.Errorf("%s is not a date or time type", kind)
+	}
+	if err := validateBuiltinValue(value, QName{Namespace: XSDNamespace, Local: kind}); err != nil {
+		return DateTime{}, err
+	}
+	m := pattern.FindStringSubmatch(value)
+	if m == nil {
+		return DateTime{}, fmt.Errorf("invalid %s value: %s", kind, value)
+	}
+
+	number := func(s string) int {
+		n, _ := strconv.Atoi(s)
+		return n
+	}
+	d := DateTime{
+		Kind:   kind,
+		Year:   number(m[1]),
+		Month:  number(m[2]),
+		Day:    number(m[3]),
+		Hour:   number(m[4]),
+		Minute: number(m[5]),
+		Second: number(m[6]),
+	}
+	if fraction, ok := new(big.Rat).SetString("0" + m[7]); ok && fraction.Sign() != 0 {
+		d.Fraction = fraction
+	}
+	if tz := m[8]; tz != "" {
+		offset := 0
+		if tz != "Z" {
+			offset = number(tz[1:3])*60 + number(tz[4:6])
+			if tz[0] == '-' {
+				offset = -offset
+			}
+		}
+		if offset < -14*60 || offset > 14*60 {
+			return DateTime{}, fmt.Errorf("invalid timezone in %s value: %s", kind, value)
+		}
+		d.Timezone = &offset
+	}
+	return d, nil
+}
+
+// instant returns the point in time of a date or time value with the given
+// timezone offset, to the whole second. Components the type does not have
+// are taken from the reference date 1972-01-01, a leap year.
+func (d DateTime) instant(offset int) time.Time {
+	year, month, day := d.Year, d.Month, d.Day
+	switch d.Kind {
+	case "time":
+		year, month, day = 1972, 1, 1
+	case "gMonthDay", "gMonth", "gDay":
+		year = 1972
+	}
+	if month == 0 {
+		month = 1
+	}
+	if day == 0 {
+		day = 1
+	}
+	return time.Date(year, time.Month(month), day, d.Hour, d.Minute, d.Second, 0, time.UTC).
+		Add(-time.Duration(offset) * time.Minute)
+}
+
+// fraction returns the fractional seconds of a date or time value, zero
+// when it has none
+func (d DateTime) fraction() *big.Rat {
+	if d.Fraction == nil {
+		return new(big.Rat)
+	}
+	return d.Fraction
+}
+
+// compareInstants orders the points in time of two date or time values
+// with the given timezone offsets, down to their fractional seconds
+func compareInstants(a DateTime, aOffset int, b DateTime, bOffset int) int {
+	if order := a.instant(aOffset).Compare(b.instant(bOffset)); order != 0 {
+		return order
+	}
+	return a.fraction().Cmp(b.fraction())
+}
+
+// Equal reports whether two values are equal in the sense of XSD: values
+// of different primitive types, such as a string and a number, are never
+// equal, and NaN is not equal to itself
+func Equal(a, b Value) bool {
+	if ua, ok := a.(UnionValue); ok {
+		a = ua.Value
+	}
+	if ub, ok := b.(UnionValue); ok {
+		b = ub.Value
+	}
+
+	switch av := a.(type) {
+	case []Value:
+		bv, ok := b.([]Value)
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for i := range av {
+			if !Equal(av[i], bv[i]) {
+				return false
+			}
+		}
+		return true
+	case []byte:
+		bv, ok := b.([]byte)
+		return ok && bytes.Equal(av, bv)
+	case Base64Binary:
+		bv, ok := b.(Base64Binary)
+		return ok && bytes.Equal(av, bv)
+	case string, AnyURI, bool, QName, NotationName:
+		return a == b
+	case float32:
+		bv, ok := b.(float32)
+		return ok && av == bv
+	case float64:
+		bv, ok := b.(float64)
+		return ok && av == bv
+	case Duration:
+		bv, ok := b.(Duration)
+		if !ok {
+			return false
+		}
+		am, as := av.signed()
+		bm, bs := bv.signed()
+		return am.Cmp(bm) == 0 && as.Cmp(bs) == 0
+	}
+	order, ok := Compare(a, b)
+	return ok && order == 0
+}
+
+// valueKey returns a string that is the same for values that are Equal,
+// for hashing values. Values with the same key may still differ, so
+// callers compare them with Equal.
+func valueKey(v Value) string {
+	if u, ok := v.(UnionValue); ok {
+		v = u.Value
+	}
+	switch val := v.(type) {
+	case []Value:
+		keys := make([]string, len(val))
+		for i, item := range val {
+			keys[i] = valueKey(item)
+		}
+		return "list(" + strings.Join(keys, " ") + ")"
+	case []byte:
+		return "hexBinary:" + hex.EncodeToString(val)
+	case Base64Binary:
+		return "base64Binary:" + hex.EncodeToString(val)
+	case string:
+		return "string:" + val
+	case AnyURI:
+		return "anyURI:" + string(val)
+	case bool:
+		return "boolean:" + strconv.FormatBool(val)
+	case QName:
+		return "QName:{" + val.Namespace + "}" + val.Local
+	case NotationName:
+		return "NOTATION:{" + val.Namespace + "}" + val.Local
+	case float32:
+		if val == 0 {
+			val = 0 // -0 equals 0
+		}
+		return "float:" + strconv.FormatFloat(float64(val), 'g', -1, 32)
+	case float64:
+		if val == 0 {
+			val = 0
+		}
+		return "double:" + strconv.FormatFloat(val, 'g', -1, 64)
+	case *big.Int:
+		return "decimal:" + val.String()
+	case *big.Rat:
+		return "decimal:" + val.RatString()
+	case Duration:
+		months, seconds := val.signed()
+		return "duration:" + months.String() + "," + seconds.RatString()
+	case DateTime:
+		if val.Timezone != nil {
+			return val.Kind + "@" + val.instant(*val.Timezone).Format(time.RFC3339) + "+" + val.fraction().RatString()
+		}
+		return val.Kind + ":" + val.String()
+	}
+	return fmt.Sprintf("%T:%v", v, v)
+}
+
+// Compare orders two values of an ordered type: numbers, date and time
+// values and durations. It returns -1, 0 or 1 and true, or false when the
+// values are incomparable: they belong to different or unordered types,
+// one is NaN, or the XSD partial order leaves them indeterminate, as for
+// a date with a timezone and one without that are less than 14 hours
+// apart. Equal values of unordered types compare as 0.
+func Compare(a, b Value) (int, bool) {
+	if ua, ok := a.(UnionValue); ok {
+		a = ua.Value
+	}
+	if ub, ok := b.(UnionValue); ok {
+		b = ub.Value
+	}
+
+	if ar, ok := decimalValue(a); ok {
+		br, ok := decimalValue(b)
+		if !ok {
+			return 0, false
+		}
+		return ar.Cmp(br), true
+	}
+
+	switch av := a.(type) {
+	case float32:
+		bv, ok := b.(float32)
+		if !ok {
+			return 0, false
+		}
+		return compareFloats(float64(av), float64(bv))
+	case float64:
+		bv, ok := b.(float64)
+		if !ok {
+			return 0, false
+		}
+		return compareFloats(av, bv)
+	case DateTime:
+		bv, ok := b.(DateTime)
+		if !ok || av.Kind != bv.Kind {
+			return 0, false
+		}
+		return compareDateTimes(av, bv)
+	case Duration:
+		bv, ok := b.(Duration)
+		if !ok {
+			return 0, false
+		}
+		return compareDurations(av, bv)
+	}
+
+	if Equal(a, b) {
+		return 0, true
+	}
+	return 0, false
+}
+
+// compareFloats orders two floating-point values of the same type; NaN is
+// incomparable
+func compareFloats(a, b float64) (int, bool) {
+	switch {
+	case math.IsNaN(a) || math.IsNaN(b):
+		return 0, false
+	case a < b:
+		return -1, true
+	case a > b:
+		return 1, true
+	}
+	return 0, true
+}
+
+// decimalValue returns the value of an integer or decimal as a fraction
+func decimalValue(v Value) (*big.Rat, bool) {
+	switch n := v.(type) {
+	case *big.Int:
+		return new(big.Rat).SetInt(n), true
+	case *big.Rat:
+		return n, true
+	}
+	return nil, false
+}
+
+// compareDateTimes orders two date or time values of the same type. When
+// only one has a timezone, the other may lie anywhere between -14:00 and
+// +14:00, and values within that range of each other are indeterminate.
+func compareDateTimes(a, b DateTime) (int, bool) {
+	if (a.Timezone == nil) == (b.Timezone == nil) {
+		return compareInstants(a, offsetOf(a), b, offsetOf(b)), true
+	}
+
+	if a.Timezone == nil {
+		order, ok := compareDateTimes(b, a)
+		return -order, ok
+	}
+	switch {
+	case compareInstants(a, *a.Timezone, b, 14*60) < 0:
+		return -1, true
+	case compareInstants(a, *a.Timezone, b, -14*60) > 0:
+		return 1, true
+	}
+	return 0, false
+}
+
+// offsetOf returns the timezone offset of a value, or 0 when it has none
+func offsetOf(d DateTime) int {
+	if d.Timezone == nil {
+		return 0
+	}
+	return *d.Timezone
+}
+
+// durationReferences are the dateTimes durations are added to when they
+// are compared; together they cover months of every length
+var durationReferences = []time.Time{
+	time.Date(1696, 9, 1, 0, 0, 0, 0, time.UTC),
+	time.Date(1697, 2, 1, 0, 0, 0, 0, time.UTC),
+	time.Date(1903, 3, 1, 0, 0, 0, 0, time.UTC),
+	time.Date(1903, 7, 1, 0, 0, 0, 0, time.UTC),
+}
+
+// compareDurations orders two durations by adding each to the reference
+// dateTimes; the order is indeterminate when the results disagree, as for
+// P1M and P30D
+func compareDurations(a, b Duration) (int, bool) {
+	am, as := a.signed()
+	bm, bs := b.signed()
+	if am.Cmp(bm) == 0 {
+		return as.Cmp(bs), true
+	}
+
+	order := 0
+	for i, ref := range durationReferences {
+		o := addDuration(ref, am, as).Compare(addDuration(ref, bm, bs))
+		if i > 0 && o != order {
+			return 0, false
+		}
+		order = o
+	}
+	return order, true
+}
+
+// addDuration adds signed months and seconds to a dateTime, keeping the
+// day within the resulting month
+func addDuration(t time.Time, months *big.Int, seconds *big.Rat) time.Time {
+	total := new(big.Int).Add(months, big.NewInt(int64(t.Year())*12+int64(t.Month())-1))
+	year, month := new(big.Int).DivMod(total, big.NewInt(12), new(big.Int))
+	day := t.Day()
+	if last := time.Date(int(year.Int64()), time.Month(month.Int64()+2), 0, 0, 0, 0, 0, time.UTC).Day(); day > last {
+		day = last
+	}
+	t = time.Date(int(year.Int64()), time.Month(month.Int64()+1), day, t.Hour(), t.Minute(), t.Second(), t.Nanosecond(), time.UTC)
+
+	whole := new(big.Int).Div(seconds.Num(), seconds.Denom())
+	days, rest := new(big.Int).DivMod(whole, big.NewInt(86400), new(big.Int))
+	fraction := new(big.Rat).Sub(seconds, new(big.Rat).SetInt(whole))
+	nanos, _ := new(big.Rat).Mul(fraction, big.NewRat(int64(time.Second), 1)).Float64()
+	return t.AddDate(0, 0, int(days.Int64())).
+		Add(time.Duration(rest.Int64())*time.Second + time.Duration(nanos))
+}
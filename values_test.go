@@ -0,0 +1,292 @@
+package xsd
+
+import (
+	"math"
+	"math/big"
+	"strings"
+	"testing"
+
+	"github.com/agentflare-ai/go-xmldom"
+)
+
+func TestParseValue(t *testing.T) {
+	schema, err := parseTestSchema(t, `<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"
+           xmlns:t="http://example.com/values" targetNamespace="http://example.com/values">
+	<xs:simpleType name="sizes"><xs:list itemType="xs:int"/></xs:simpleType>
+	<xs:simpleType name="amount">
+		<xs:union memberTypes="xs:int t:word"/>
+	</xs:simpleType>
+	<xs:simpleType name="word">
+		<xs:restriction base="xs:token"><xs:enumeration value="none"/></xs:restriction>
+	</xs:simpleType>
+	<xs:simpleType name="percent">
+		<xs:restriction base="xs:decimal"><xs:maxInclusive value="100"/></xs:restriction>
+	</xs:simpleType>
+</xs:schema>`)
+	if err != nil {
+		t.Fatalf("Failed to parse schema: %v", err)
+	}
+
+	builtin := func(local string) Type {
+		return &SimpleType{QName: QName{Namespace: XSDNamespace, Local: local}}
+	}
+	typ := func(local string) Type {
+		return schema.TypeDefs[QName{Namespace: "http://example.com/values", Local: local}]
+	}
+	parse := func(value string, typ Type) Value {
+		t.Helper()
+		v, err := schema.ParseValue(value, typ)
+		if err != nil {
+			t.Fatalf("ParseValue(%q) failed: %v", value, err)
+		}
+		return v
+	}
+
+	if v, ok := parse(" +042 ", builtin("int")).(*big.Int); !ok || v.Int64() != 42 {
+		t.Errorf("Expected *big.Int 42, got %v", v)
+	}
+	if v, ok := parse("12.50", typ("percent")).(*big.Rat); !ok || v.Cmp(big.NewRat(25, 2)) != 0 {
+		t.Errorf("Expected *big.Rat 25/2, got %v", v)
+	}
+	if v, ok := parse("-INF", builtin("double")).(float64); !ok || !math.IsInf(v, -1) {
+		t.Errorf("Expected -Inf, got %v", v)
+	}
+	if v, ok := parse("1", builtin("boolean")).(bool); !ok || !v {
+		t.Errorf("Expected true, got %v", v)
+	}
+	if v, ok := parse("0FA0", builtin("hexBinary")).([]byte); !ok || string(v) != "\x0f\xa0" {
+		t.Errorf("Expected bytes 0FA0, got %v", v)
+	}
+
+	dt, ok := parse("2024-02-29T10:30:00.25+05:30", builtin("dateTime")).(DateTime)
+	if !ok || dt.Year != 2024 || dt.Month != 2 || dt.Day != 29 || dt.Fraction == nil || dt.Fraction.Cmp(big.NewRat(1, 4)) != 0 ||
+		dt.Timezone == nil || *dt.Timezone != 330 {
+		t.Errorf("Unexpected dateTime value %+v", dt)
+	}
+	if dt, ok := parse("--12-25", builtin("gMonthDay")).(DateTime); !ok || dt.Timezone != nil {
+		t.Errorf("Expected gMonthDay without timezone, got %+v", dt)
+	}
+
+	d, ok := parse("-P1Y2MT1.5S", builtin("duration")).(Duration)
+	if !ok || !d.Negative || d.Months.Int64() != 14 || d.Seconds.Cmp(big.NewRat(3, 2)) != 0 {
+		t.Errorf("Unexpected duration value %+v", d)
+	}
+
+	list, ok := parse(" 1  2 3 ", typ("sizes")).([]Value)
+	if !ok || len(list) != 3 || !Equal(list[2], big.NewInt(3)) {
+		t.Errorf("Unexpected list value %v", list)
+	}
+
+	u, ok := parse("none", typ("amount")).(UnionValue)
+	if !ok || u.Member.Name().Local != "word" || u.Value != "none" {
+		t.Errorf("Expected union value of member word, got %+v", u)
+	}
+	u, ok = parse("7", typ("amount")).(UnionValue)
+	if !ok || u.Member.Name().Local != "int" || !Equal(u, big.NewInt(7)) {
+		t.Errorf("Expected union value of member int, got %+v", u)
+	}
+
+	for _, tt := range []struct {
+		value string
+		t     Type
+	}{
+		{"101", typ("percent")},
+		{"1 x", typ("sizes")},
+		{"some", typ("amount")},
+		{"p:name", builtin("QName")},
+	} {
+		if _, err := schema.ParseValue(tt.value, tt.t); err == nil {
+			t.Errorf("Expected ParseValue(%q) to fail", tt.value)
+		}
+	}
+
+	doc, err := xmldom.Decode(strings.NewReader(`<root xmlns="urn:default" xmlns:p="urn:p"/>`))
+	if err != nil {
+		t.Fatalf("Failed to parse instance: %v", err)
+	}
+	context := doc.DocumentElement()
+	for value, want := range map[string]QName{
+		"p:name": {Namespace: "urn:p", Local: "name"},
+		"name":   {Namespace: "urn:default", Local: "name"},
+	} {
+		v, err := schema.ParseValueAt(value, builtin("QName"), context)
+		if err != nil || v != want {
+			t.Errorf("Expected %v for %q, got %v (%v)", want, value, v, err)
+		}
+	}
+	if _, err := schema.ParseValueAt("q:name", builtin("QName"), context); err == nil {
+		t.Error("Expected an undeclared prefix to be rejected")
+	}
+}
+
+func TestCompareValues(t *testing.T) {
+	schema, err := parseTestSchema(t, `<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"/>`)
+	if err != nil {
+		t.Fatalf("Failed to parse schema: %v", err)
+	}
+
+	tests := []struct {
+		typ   string
+		a, b  string
+		order int
+		ok    bool
+	}{
+		{"decimal", "1.0", "1", 0, true},
+		{"decimal", "-0.5", "0.25", -1, true},
+		{"double", "0", "-0", 0, true},
+		{"double", "INF", "1E308", 1, true},
+		{"double", "NaN", "NaN", 0, false},
+		{"float", "1.5", "1.50E0", 0, true},
+		{"float", "0.1", "0.2", -1, true},
+		{"dateTime", "2024-01-01T12:00:00Z", "2024-01-01T13:00:00+01:00", 0, true},
+		{"dateTime", "2024-01-01T12:00:00Z", "2024-01-01T12:00:00", 0, false},
+		{"dateTime", "2024-01-01T12:00:00Z", "2024-01-02T12:00:00", -1, true},
+		{"dateTime", "2024-01-01T12:00:00.1234567891Z", "2024-01-01T12:00:00.1234567892Z", -1, true},
+		{"dateTime", "2024-01-01T12:00:00.12345678910Z", "2024-01-01T13:00:00.1234567891+01:00", 0, true},
+		{"dateTime", "2024-01-01T12:00:00.0000000001Z", "2024-01-01T12:00:00", 0, false},
+		{"time", "12:00:00.9999999999", "12:00:01", -1, true},
+		{"time", "23:00:00-02:00", "00:30:00Z", 1, true},
+		{"gMonth", "--02", "--03", -1, true},
+		{"duration", "P1Y", "P12M", 0, true},
+		{"duration", "P1M", "P30D", 0, false},
+		{"duration", "P1M", "P32D", -1, true},
+		{"duration", "PT36H", "P1D", 1, true},
+		{"dayTimeDuration", "PT24H", "P1D", 0, true},
+		{"dayTimeDuration", "P1D", "PT23H", 1, true},
+		{"yearMonthDuration", "P1Y", "P13M", -1, true},
+		{"dateTimeStamp", "2024-01-01T12:00:00Z", "2024-01-01T13:00:00+01:00", 0, true},
+		{"boolean", "1", "true", 0, true},
+		{"boolean", "0", "true", 0, false},
+		{"base64Binary", "AQID", "AQ ID", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.typ+" "+tt.a+" "+tt.b, func(t *testing.T) {
+			typ := &SimpleType{QName: QName{Namespace: XSDNamespace, Local: tt.typ}}
+			a, err := schema.ParseValue(tt.a, typ)
+			if err != nil {
+				t.Fatalf("Failed to parse %q: %v", tt.a, err)
+			}
+			b, err := schema.ParseValue(tt.b, typ)
+			if err != nil {
+				t.Fatalf("Failed to parse %q: %v", tt.b, err)
+			}
+
+			order, ok := Compare(a, b)
+			if ok != tt.ok || (ok && order != tt.order) {
+				t.Errorf("Compare(%s, %s) = %d, %v; want %d, %v", tt.a, tt.b, order, ok, tt.order, tt.ok)
+			}
+			if equal := ok && order == 0; Equal(a, b) != equal {
+				t.Errorf("Equal(%s, %s) = %v; want %v", tt.a, tt.b, !equal, equal)
+			}
+			if Equal(a, b) && valueKey(a) != valueKey(b) {
+				t.Errorf("Expected equal values to have the same key, got %q and %q", valueKey(a), valueKey(b))
+			}
+		})
+	}
+
+	if Equal(big.NewInt(1), "1") || Equal(1.0, big.NewInt(1)) {
+		t.Error("Expected values of different types to be unequal")
+	}
+
+	// float and double have disjoint value spaces
+	float, _ := schema.ParseValue("1.5", &SimpleType{QName: QName{Namespace: XSDNamespace, Local: "float"}})
+	double, _ := schema.ParseValue("1.5", &SimpleType{QName: QName{Namespace: XSDNamespace, Local: "double"}})
+	if Equal(float, double) || valueKey(float) == valueKey(double) {
+		t.Errorf("Expected float %v and double %v to be unequal", float, double)
+	}
+	if _, ok := Compare(float, double); ok {
+		t.Error("Expected float and double to be incomparable")
+	}
+
+	// So do the other primitives whose values share a lexical space
+	context, err := xmldom.Decode(strings.NewReader(`<root xmlns:p="urn:p"/>`))
+	if err != nil {
+		t.Fatalf("Failed to parse context: %v", err)
+	}
+	pairs := []struct{ a, aLexical, b, bLexical string }{
+		{"string", "urn:example", "anyURI", "urn:example"},
+		{"hexBinary", "000000", "base64Binary", "AAAA"},
+		{"QName", "p:gif", "NOTATION", "p:gif"},
+	}
+	for _, pair := range pairs {
+		a, err := schema.ParseValueAt(pair.aLexical, &SimpleType{QName: QName{Namespace: XSDNamespace, Local: pair.a}}, context.DocumentElement())
+		if err != nil {
+			t.Fatalf("Failed to parse %s %q: %v", pair.a, pair.aLexical, err)
+		}
+		b, err := schema.ParseValueAt(pair.bLexical, &SimpleType{QName: QName{Namespace: XSDNamespace, Local: pair.b}}, context.DocumentElement())
+		if err != nil {
+			t.Fatalf("Failed to parse %s %q: %v", pair.b, pair.bLexical, err)
+		}
+		if Equal(a, b) || valueKey(a) == valueKey(b) {
+			t.Errorf("Expected %s %v and %s %v to be unequal", pair.a, a, pair.b, b)
+		}
+		if !Equal(a, a) || !Equal(b, b) {
+			t.Errorf("Expected %s and %s values to equal themselves", pair.a, pair.b)
+		}
+	}
+}
+
+func TestValueSpaceFacets(t *testing.T) {
+	schema, err := parseTestSchema(t, `<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+	<xs:element name="start">
+		<xs:simpleType>
+			<xs:restriction base="xs:dateTime">
+				<xs:minInclusive value="2024-01-01T00:00:00Z"/>
+			</xs:restriction>
+		</xs:simpleType>
+	</xs:element>
+	<xs:element name="rate">
+		<xs:simpleType>
+			<xs:restriction base="xs:decimal">
+				<xs:enumeration value="0.5"/>
+				<xs:enumeration value="1"/>
+			</xs:restriction>
+		</xs:simpleType>
+	</xs:element>
+	<xs:element name="timeout">
+		<xs:simpleType>
+			<xs:restriction base="xs:duration">
+				<xs:maxExclusive value="PT1H"/>
+			</xs:restriction>
+		</xs:simpleType>
+	</xs:element>
+</xs:schema>`)
+	if err != nil {
+		t.Fatalf("Failed to parse schema: %v", err)
+	}
+
+	tests := []struct {
+		instance string
+		codes    []string
+	}{
+		{instance: `<start>2024-01-01T01:00:00+01:00</start>`},
+		{instance: `<start>2023-12-31T23:30:00-01:00</start>`},
+		{instance: `<start>2023-12-31T23:00:00Z</start>`, codes: []string{"cvc-datatype-valid.1", "cvc-facet-valid"}},
+		{instance: `<rate>1.00</rate>`},
+		{instance: `<rate>0.50</rate>`},
+		{instance: `<rate>2</rate>`, codes: []string{"cvc-datatype-valid.1", "cvc-facet-valid"}},
+		{instance: `<timeout>PT59M</timeout>`},
+		{instance: `<timeout>PT60M</timeout>`, codes: []string{"cvc-datatype-valid.1", "cvc-facet-valid"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.instance, func(t *testing.T) {
+			doc, err := xmldom.Decode(strings.NewReader(tt.instance))
+			if err != nil {
+				t.Fatalf("Failed to parse instance: %v", err)
+			}
+
+			var codes []string
+			for _, v := range NewValidator(schema).Validate(doc) {
+				codes = append(codes, v.Code)
+			}
+			if strings.Join(codes, " ") != strings.Join(tt.codes, " ") {
+				t.Errorf("Expected violations %v, got %v", tt.codes, codes)
+			}
+		})
+	}
+}
@@ -0,0 +1,225 @@
+package xsd
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultWatchInterval is the polling interval used when none is given
+const DefaultWatchInterval = 2 * time.Second
+
+// ReloadEvent describes the outcome of a background schema rebuild
+type ReloadEvent struct {
+	Location string   // Location of the main schema document
+	Changed  []string // Contributing files whose change triggered the rebuild
+	Schema   *Schema  // Schema now in use (the previous one if the rebuild failed)
+	Previous *Schema  // Schema in use before the rebuild
+	Err      error    // Compile error, nil if the new schema was swapped in
+}
+
+// ReloadFunc is called after every background rebuild
+type ReloadFunc func(event ReloadEvent)
+
+// WatchedSchema is a handle to a schema that is rebuilt when its files change.
+// Schema always returns a complete compiled schema; a validation that already
+// holds the previous version keeps using it until it finishes.
+type WatchedSchema struct {
+	location string
+	current  atomic.Pointer[Schema]
+	sources  []fileStamp // Owned by the watcher goroutine
+}
+
+// Schema returns the current compiled schema
+func (ws *WatchedSchema) Schema() *Schema {
+	return ws.current.Load()
+}
+
+// Location returns the location of the main schema document
+func (ws *WatchedSchema) Location() string {
+	return ws.location
+}
+
+// SchemaWatcher polls the files that contributed to compiled schemas
+// (main documents plus their includes and imports) and rebuilds a schema
+// in the background when one of them changes
+type SchemaWatcher struct {
+	cache    *SchemaCache
+	interval time.Duration
+
+	mu          sync.Mutex
+	watched     map[string]*WatchedSchema
+	subscribers map[int]ReloadFunc
+	nextID      int
+
+	pollMu sync.Mutex // Serializes polls
+	stop   chan struct{}
+	done   chan struct{}
+	closed sync.Once
+}
+
+// Watch starts a watcher that polls every interval and rebuilds changed schemas
+// through this cache. Call Close to stop it.
+func (sc *SchemaCache) Watch(interval time.Duration) *SchemaWatcher {
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+
+	w := &SchemaWatcher{
+		cache:       sc,
+		interval:    interval,
+		watched:     make(map[string]*WatchedSchema),
+		subscribers: make(map[int]ReloadFunc),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// Add loads a schema through the cache and starts watching its files.
+// Adding the same location twice returns the same handle.
+func (w *SchemaWatcher) Add(location string) (*WatchedSchema, error) {
+	key := w.cache.resolvePath(location)
+
+	w.mu.Lock()
+	if ws, ok := w.watched[key]; ok {
+		w.mu.Unlock()
+		return ws, nil
+	}
+	w.mu.Unlock()
+
+	// Take the stamps from the loaded entry itself, which may already have
+	// been evicted from the cache
+	entry := w.cache.entry(key)
+	schema, err := w.cache.wait(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	ws := &WatchedSchema{
+		location: key,
+		sources:  w.cache.sourceStamps(entry),
+	}
+	ws.current.Store(schema)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if existing, ok := w.watched[key]; ok {
+		return existing, nil
+	}
+	w.watched[key] = ws
+	return ws, nil
+}
+
+// Remove stops watching a schema
+func (w *SchemaWatcher) Remove(location string) {
+	key := w.cache.resolvePath(location)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	delete(w.watched, key)
+}
+
+// Subscribe registers a callback for reload outcomes and returns a function
+// that removes it. Callbacks run on the watcher goroutine in registration order.
+func (w *SchemaWatcher) Subscribe(fn ReloadFunc) (unsubscribe func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	id := w.nextID
+	w.nextID++
+	w.subscribers[id] = fn
+
+	return func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		delete(w.subscribers, id)
+	}
+}
+
+// Poll checks all watched schemas immediately and rebuilds the changed ones
+func (w *SchemaWatcher) Poll() {
+	w.pollMu.Lock()
+	defer w.pollMu.Unlock()
+
+	w.mu.Lock()
+	watched := make([]*WatchedSchema, 0, len(w.watched))
+	for _, ws := range w.watched {
+		watched = append(watched, ws)
+	}
+	w.mu.Unlock()
+
+	for _, ws := range watched {
+		if changed := changedSources(ws.sources); len(changed) > 0 {
+			w.reload(ws, changed)
+		}
+	}
+}
+
+// Close stops the watcher; watched handles keep their last schema
+func (w *SchemaWatcher) Close() {
+	w.closed.Do(func() {
+		close(w.stop)
+		<-w.done
+	})
+}
+
+// run polls until the watcher is closed
+func (w *SchemaWatcher) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			w.Poll()
+		}
+	}
+}
+
+// reload rebuilds a schema, swaps it in on success and notifies subscribers
+func (w *SchemaWatcher) reload(ws *WatchedSchema, changed []string) {
+	previous := ws.Schema()
+	schema, stamps, err := w.cache.loadSchema(ws.location)
+
+	// Track the files of the attempted build so a broken edit is not retried
+	// until one of them changes again
+	if len(stamps) > 0 {
+		ws.sources = stamps
+	}
+
+	event := ReloadEvent{
+		Location: ws.location,
+		Changed:  changed,
+		Schema:   previous,
+		Previous: previous,
+		Err:      err,
+	}
+	if err == nil {
+		ws.current.Store(schema)
+		w.cache.store(ws.location, schema, stamps)
+		event.Schema = schema
+	}
+
+	w.mu.Lock()
+	ids := make([]int, 0, len(w.subscribers))
+	for id := range w.subscribers {
+		ids = append(ids, id)
+	}
+	w.mu.Unlock()
+	slices.Sort(ids)
+
+	for _, id := range ids {
+		w.mu.Lock()
+		fn, ok := w.subscribers[id]
+		w.mu.Unlock()
+		if ok {
+			fn(event)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package xsd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/agentflare-ai/go-xmldom"
+)
+
+func TestSchemaWatcherReload(t *testing.T) {
+	dir := t.TempDir()
+	types := filepath.Join(dir, "types.xsd")
+	main := filepath.Join(dir, "main.xsd")
+
+	writeFile := func(path, content string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", path, err)
+		}
+	}
+	writeTypes := func(base string) {
+		writeFile(types, `<?xml version="1.0" encoding="UTF-8"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+	<xs:simpleType name="qty"><xs:restriction base="`+base+`"/></xs:simpleType>
+</xs:schema>`)
+	}
+	writeTypes("xs:string")
+	writeFile(main, `<?xml version="1.0" encoding="UTF-8"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+	<xs:include schemaLocation="types.xsd"/>
+	<xs:element name="qty" type="qty"/>
+</xs:schema>`)
+
+	cache := NewSchemaCacheWithConfig(SchemaCacheConfig{CheckInterval: -1})
+	watcher := cache.Watch(time.Hour) // Polled manually below
+	defer watcher.Close()
+
+	ws, err := watcher.Add(main)
+	if err != nil {
+		t.Fatalf("Add failed: %v", err)
+	}
+
+	var events []ReloadEvent
+	watcher.Subscribe(func(event ReloadEvent) {
+		events = append(events, event)
+	})
+
+	validate := func(schema *Schema, xml string) int {
+		doc, err := xmldom.Decode(bytes.NewReader([]byte(xml)))
+		if err != nil {
+			t.Fatalf("Failed to parse XML: %v", err)
+		}
+		return len(NewValidator(schema).Validate(doc))
+	}
+
+	original := ws.Schema()
+	if n := validate(original, `<qty>abc</qty>`); n != 0 {
+		t.Fatalf("Expected the original schema to accept a string, got %d violations", n)
+	}
+
+	// Nothing changed: no rebuild
+	watcher.Poll()
+	if len(events) != 0 {
+		t.Fatalf("Expected no reload, got %d events", len(events))
+	}
+
+	// Change the included file
+	writeTypes("xs:int")
+	watcher.Poll()
+
+	if len(events) != 1 {
+		t.Fatalf("Expected 1 reload event, got %d", len(events))
+	}
+	if events[0].Err != nil {
+		t.Fatalf("Expected a successful reload, got %v", events[0].Err)
+	}
+	if len(events[0].Changed) != 1 || events[0].Changed[0] != types {
+		t.Errorf("Expected the include to be reported as changed, got %v", events[0].Changed)
+	}
+	if events[0].Previous != original || ws.Schema() == original {
+		t.Error("Expected the rebuilt schema to be swapped in")
+	}
+	if n := validate(ws.Schema(), `<qty>abc</qty>`); n == 0 {
+		t.Error("Expected the rebuilt schema to reject a non-integer")
+	}
+	if n := validate(original, `<qty>abc</qty>`); n != 0 {
+		t.Error("Expected the previous schema to be left untouched")
+	}
+	if cached, _ := cache.Get(main); cached != ws.Schema() {
+		t.Error("Expected the cache to hold the rebuilt schema")
+	}
+
+	// A broken edit keeps the last good schema and reports the compile error
+	rebuilt := ws.Schema()
+	writeFile(types, `<xs:schema`)
+	watcher.Poll()
+
+	if len(events) != 2 || events[1].Err == nil {
+		t.Fatalf("Expected a failed reload event, got %+v", events)
+	}
+	if ws.Schema() != rebuilt || events[1].Schema != rebuilt {
+		t.Error("Expected the last good schema to stay in use after a failed reload")
+	}
+
+	// The broken file is not rebuilt again until it changes
+	watcher.Poll()
+	if len(events) != 2 {
+		t.Errorf("Expected no further reloads, got %d events", len(events))
+	}
+}
+
+func TestSchemaWatcherStampsOfEvictedEntry(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "main.xsd")
+	if err := os.WriteFile(path, []byte(`<?xml version="1.0" encoding="UTF-8"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+	<xs:element name="qty" type="xs:string"/>
+</xs:schema>`), 0644); err != nil {
+		t.Fatalf("Failed to write schema: %v", err)
+	}
+
+	// The stamps a watcher starts from belong to the loaded entry, even when
+	// it is evicted before the watcher reads them
+	cache := NewSchemaCacheWithConfig(SchemaCacheConfig{CheckInterval: -1})
+	entry := cache.entry(path)
+	if _, err := cache.wait(entry); err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	cache.Clear()
+
+	stamps := cache.sourceStamps(entry)
+	if len(stamps) != 1 || stamps[0].Path != path {
+		t.Errorf("Expected the stamp of %s, got %+v", path, stamps)
+	}
+}
+
+func TestSchemaRegistryWatch(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "orders.xsd")
+	write := func(element string) {
+		t.Helper()
+		content := `<?xml version="1.0" encoding="UTF-8"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema" targetNamespace="urn:orders">
+	<xs:element name="` + element + `" type="xs:string"/>
+</xs:schema>`
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write schema: %v", err)
+		}
+	}
+	write("order")
+
+	registry := NewSchemaRegistry()
+	if err := registry.RegisterFile("urn:orders", path); err != nil {
+		t.Fatalf("RegisterFile failed: %v", err)
+	}
+	watcher, err := registry.Watch(time.Hour)
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	defer watcher.Close()
+
+	write("purchaseOrder")
+	watcher.Poll()
+
+	schema, ok := registry.GetForNamespace("urn:orders")
+	if !ok {
+		t.Fatal("Expected a schema for urn:orders")
+	}
+	if _, found := schema.ElementDecls[QName{Namespace: "urn:orders", Local: "purchaseOrder"}]; !found {
+		t.Error("Expected the registry to use the rebuilt schema")
+	}
+}
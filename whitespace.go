@@ -0,0 +1,229 @@
+package xsd
+
+import (
+	"strings"
+
+	"github.com/agentflare-ai/go-xmldom"
+)
+
+// WhiteSpace returns the whitespace processing applied to values of a type:
+// "preserve", "replace" or "collapse". Types without simple values, such as
+// complex types with element content, return "".
+func WhiteSpace(t Type) string {
+	switch tt := t.(type) {
+	case *SimpleType:
+		if tt.whiteSpace != "" {
+			return tt.whiteSpace
+		}
+		switch {
+		case tt.List != nil:
+			return "collapse"
+		case tt.Union != nil:
+			return "preserve"
+		case tt.Restriction == nil && tt.QName.Namespace == XSDNamespace:
+			return builtinTypeWhiteSpace(tt.QName.Local)
+		}
+		return "preserve"
+	case *ComplexType:
+		return tt.whiteSpace
+	}
+	return ""
+}
+
+// NormalizeValue applies the whitespace processing of a type to a value.
+// Values of types without simple values only lose leading and trailing
+// whitespace.
+func NormalizeValue(value string, t Type) string {
+	whiteSpace := WhiteSpace(t)
+	if whiteSpace == "" {
+		return strings.TrimSpace(value)
+	}
+	return NormalizeWhiteSpace(value, whiteSpace)
+}
+
+// builtinTypeWhiteSpace returns the whitespace processing of a built-in
+// type, including anySimpleType and the list types
+func builtinTypeWhiteSpace(name string) string {
+	switch {
+	case builtinListTypes[name]:
+		return "collapse"
+	case primitiveType(name) == "":
+		return "preserve"
+	}
+	return builtinWhiteSpace(name)
+}
+
+// compileWhiteSpace records the whitespace processing of every simple type
+// and of every complex type with simple content. Union members process
+// values themselves, so unions preserve them. The caller must hold the
+// schema's lock.
+func (s *Schema) compileWhiteSpace() {
+	for _, t := range s.typeDefinitions() {
+		switch tt := t.(type) {
+		case *SimpleType:
+			tt.whiteSpace = s.typeWhiteSpace(tt, make(map[Type]bool))
+		case *ComplexType:
+			tt.whiteSpace = s.typeWhiteSpace(tt, make(map[Type]bool))
+		}
+	}
+}
+
+// typeWhiteSpace computes the whitespace processing of a type from its
+// derivation, or "" when its values are not simple
+func (s *Schema) typeWhiteSpace(t Type, visiting map[Type]bool) string {
+	if t == nil || visiting[t] {
+		return ""
+	}
+	visiting[t] = true
+
+	switch tt := t.(type) {
+	case *SimpleType:
+		switch {
+		case tt.facetsCompiled:
+			whiteSpace := builtinTypeWhiteSpace(tt.builtin.Local)
+			for _, facet := range tt.facets {
+				if ws, ok := facet.(*WhiteSpaceFacet); ok {
+					whiteSpace = ws.Value
+				}
+			}
+			return whiteSpace
+		case tt.List != nil:
+			return "collapse"
+		case tt.Union != nil:
+			return "preserve"
+		case tt.Restriction != nil:
+			return s.restrictionWhiteSpace(tt.Restriction, visiting)
+		case tt.QName.Namespace == XSDNamespace:
+			return builtinTypeWhiteSpace(tt.QName.Local)
+		}
+		return s.typeWhiteSpace(s.lookupTypeLocked(tt.QName), visiting)
+	case *ComplexType:
+		if content, ok := declaredContent(tt).(*SimpleContent); ok {
+			return s.contentWhiteSpace(content, visiting)
+		}
+	}
+	return ""
+}
+
+// simpleContentWhiteSpace returns the whitespace processing of simple
+// content
+func (s *Schema) simpleContentWhiteSpace(sc *SimpleContent) string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.contentWhiteSpace(sc, make(map[Type]bool))
+}
+
+// contentWhiteSpace returns the whitespace processing of simple content
+// from its derivation
+func (s *Schema) contentWhiteSpace(sc *SimpleContent, visiting map[Type]bool) string {
+	switch {
+	case sc.Restriction != nil:
+		return s.restrictionWhiteSpace(sc.Restriction, visiting)
+	case sc.Extension != nil:
+		return s.baseWhiteSpace(sc.Extension.Base, visiting)
+	}
+	return "preserve"
+}
+
+// restrictionWhiteSpace returns the whiteSpace facet of a restriction, or
+// the whitespace processing of its base when it has none
+func (s *Schema) restrictionWhiteSpace(r *Restriction, visiting map[Type]bool) string {
+	whiteSpace := ""
+	for _, facet := range r.Facets {
+		if ws, ok := facet.(*WhiteSpaceFacet); ok {
+			whiteSpace = ws.Value
+		}
+	}
+	if whiteSpace != "" {
+		return whiteSpace
+	}
+	return s.baseWhiteSpace(r.Base, visiting)
+}
+
+// baseWhiteSpace returns the whitespace processing of a named base type
+func (s *Schema) baseWhiteSpace(base QName, visiting map[Type]bool) string {
+	if base.Namespace == XSDNamespace {
+		return builtinTypeWhiteSpace(base.Local)
+	}
+	if whiteSpace := s.typeWhiteSpace(s.lookupTypeLocked(base), visiting); whiteSpace != "" {
+		return whiteSpace
+	}
+	return "preserve"
+}
+
+// instanceType returns the type governing an instance element. Each
+// ancestor is matched to a global declaration with its name, or to the
+// local declaration with its name in the content model of its parent's
+// type; xsi:type attributes are honoured. It returns nil when the element
+// has no declaration, for example when it matched a wildcard.
+func (s *Schema) instanceType(elem xmldom.Element) Type {
+	var path []xmldom.Element
+	for node := xmldom.Node(elem); node != nil; node = node.ParentNode() {
+		if e, ok := node.(xmldom.Element); ok {
+			path = append(path, e)
+		}
+	}
+
+	var t Type
+	for i := len(path) - 1; i >= 0; i-- {
+		decl := s.childDeclaration(t, path[i])
+		if decl == nil {
+			t = nil
+			continue
+		}
+		t, _ = s.elementType(path[i], decl)
+	}
+	return t
+}
+
+// childDeclaration returns the declaration of an element whose parent has
+// the given type, falling back to global declarations
+func (s *Schema) childDeclaration(parentType Type, elem xmldom.Element) *ElementDecl {
+	name := QName{Namespace: string(elem.NamespaceURI()), Local: string(elem.LocalName())}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if ct, ok := parentType.(*ComplexType); ok {
+		if decl := findElementDecl(s.restrictionParticle(s.contentParticle(ct)), name); decl != nil {
+			return decl
+		}
+	}
+	return s.ElementDecls[name]
+}
+
+// findElementDecl returns the element declaration with a name in a
+// normalized particle
+func findElementDecl(particle Particle, name QName) *ElementDecl {
+	switch p := particle.(type) {
+	case *ElementDecl:
+		if p.Name == name {
+			return p
+		}
+	case *ModelGroup:
+		for _, child := range p.Particles {
+			if decl := findElementDecl(child, name); decl != nil {
+				return decl
+			}
+		}
+	}
+	return nil
+}
+
+// instanceAttributeType returns the type of an attribute, named as in the
+// instance, among the attribute uses of an element's type
+func (s *Schema) instanceAttributeType(elemType Type, attrName string) Type {
+	ct, ok := elemType.(*ComplexType)
+	if !ok {
+		return nil
+	}
+	if _, local, prefixed := strings.Cut(attrName, ":"); prefixed {
+		attrName = local
+	}
+	for _, attr := range s.AttributeUses(ct) {
+		if attr.Name.Local == attrName {
+			return attr.Type
+		}
+	}
+	return nil
+}
@@ -0,0 +1,161 @@
+package xsd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/agentflare-ai/go-xmldom"
+)
+
+func TestWhiteSpace(t *testing.T) {
+	schema, err := parseTestSchema(t, `<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"
+           xmlns:t="http://example.com/ws" targetNamespace="http://example.com/ws">
+	<xs:simpleType name="code"><xs:restriction base="xs:normalizedString"/></xs:simpleType>
+	<xs:simpleType name="label">
+		<xs:restriction base="xs:string"><xs:whiteSpace value="collapse"/></xs:restriction>
+	</xs:simpleType>
+	<xs:simpleType name="codes"><xs:list itemType="xs:int"/></xs:simpleType>
+	<xs:complexType name="price">
+		<xs:simpleContent><xs:extension base="xs:decimal"/></xs:simpleContent>
+	</xs:complexType>
+</xs:schema>`)
+	if err != nil {
+		t.Fatalf("Failed to parse schema: %v", err)
+	}
+
+	typ := func(local string) Type {
+		return schema.TypeDefs[QName{Namespace: "http://example.com/ws", Local: local}]
+	}
+	builtin := func(local string) Type {
+		return &SimpleType{QName: QName{Namespace: XSDNamespace, Local: local}}
+	}
+
+	tests := []struct {
+		name string
+		t    Type
+		want string
+	}{
+		{"string", builtin("string"), "preserve"},
+		{"normalizedString", builtin("normalizedString"), "replace"},
+		{"token", builtin("token"), "collapse"},
+		{"int", builtin("int"), "collapse"},
+		{"anySimpleType", builtin("anySimpleType"), "preserve"},
+		{"inherited from the base", typ("code"), "replace"},
+		{"own facet", typ("label"), "collapse"},
+		{"list", typ("codes"), "collapse"},
+		{"simple content", typ("price"), "collapse"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := WhiteSpace(tt.t); got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestWhiteSpaceValidation(t *testing.T) {
+	schema, err := parseTestSchema(t, `<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"
+           xmlns:t="http://example.com/ws" targetNamespace="http://example.com/ws"
+           elementFormDefault="qualified">
+	<xs:simpleType name="size">
+		<xs:restriction base="xs:token">
+			<xs:enumeration value="extra large"/>
+			<xs:enumeration value="small"/>
+		</xs:restriction>
+	</xs:simpleType>
+	<xs:simpleType name="word">
+		<xs:restriction base="xs:string"><xs:enumeration value="a"/></xs:restriction>
+	</xs:simpleType>
+	<xs:simpleType name="flag">
+		<xs:union memberTypes="xs:int xs:boolean"/>
+	</xs:simpleType>
+	<xs:element name="qty" type="xs:int"/>
+	<xs:element name="flag" type="t:flag"/>
+	<xs:element name="order">
+		<xs:complexType>
+			<xs:sequence>
+				<xs:element name="item" maxOccurs="unbounded">
+					<xs:complexType>
+						<xs:sequence>
+							<xs:element name="qty" type="xs:int"/>
+							<xs:element name="size" type="t:size" minOccurs="0"/>
+							<xs:element name="word" type="t:word" minOccurs="0"/>
+							<xs:element name="unit" type="xs:token" fixed="kg" minOccurs="0"/>
+						</xs:sequence>
+						<xs:attribute name="sku" type="xs:token"/>
+						<xs:attribute name="id" type="xs:ID"/>
+						<xs:attribute name="next" type="xs:IDREF"/>
+					</xs:complexType>
+				</xs:element>
+			</xs:sequence>
+			<xs:attribute name="count" type="xs:int"/>
+		</xs:complexType>
+		<xs:unique name="uniqueSku">
+			<xs:selector xpath="t:item"/>
+			<xs:field xpath="@sku"/>
+		</xs:unique>
+	</xs:element>
+</xs:schema>`)
+	if err != nil {
+		t.Fatalf("Failed to parse schema: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		instance string
+		codes    []string
+	}{
+		{
+			name:     "collapsed root value",
+			instance: `<qty xmlns="http://example.com/ws"> 5 </qty>`,
+		},
+		{
+			name:     "union members collapse their values",
+			instance: `<flag xmlns="http://example.com/ws"> true </flag>`,
+		},
+		{
+			name: "collapsed child values",
+			instance: `<order xmlns="http://example.com/ws" count=" 2 ">
+				<item sku="p" id=" a " next="b"><qty>
+					3
+				</qty><size> extra
+					large </size><unit> kg </unit></item>
+				<item sku="q" id="b"><qty>4</qty></item>
+			</order>`,
+		},
+		{
+			name:     "preserved string value",
+			instance: `<order xmlns="http://example.com/ws"><item><qty>1</qty><word> a </word></item></order>`,
+			codes:    []string{"cvc-datatype-valid.1"},
+		},
+		{
+			name: "identity values compared after whitespace processing",
+			instance: `<order xmlns="http://example.com/ws">
+				<item sku=" x1 "><qty>1</qty></item>
+				<item sku="x1"><qty>2</qty></item>
+			</order>`,
+			codes: []string{"cvc-identity-constraint.4.1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := xmldom.Decode(strings.NewReader(tt.instance))
+			if err != nil {
+				t.Fatalf("Failed to parse instance: %v", err)
+			}
+
+			var codes []string
+			for _, v := range NewValidator(schema).Validate(doc) {
+				codes = append(codes, v.Code)
+			}
+			if strings.Join(codes, " ") != strings.Join(tt.codes, " ") {
+				t.Errorf("Expected violations %v, got %v", tt.codes, codes)
+			}
+		})
+	}
+}
@@ -97,6 +97,15 @@ func ValidateAnyElement(elem xmldom.Element, wildcard *AnyElement, schema *Schem
 		})
 		return violations
 	}
+	if !wildcard.admits(elem, schema, nil) {
+		violations = append(violations, Violation{
+			Element: elem,
+			Code:    "cvc-wildcard.2",
+			Message: fmt.Sprintf("Element '{%s}%s' is excluded by the wildcard's notNamespace or notQName",
+				elemNS, elemName),
+		})
+		return violations
+	}
 
 	// Handle processContents
 	mode := ProcessContentsMode(wildcard.ProcessContents)
@@ -111,7 +120,7 @@ func ValidateAnyElement(elem xmldom.Element, wildcard *AnyElement, schema *Schem
 		if decl, found := schema.ElementDecls[qname]; found {
 			// Validate element against its declaration
 			if decl.Type != nil {
-				typeViolations := decl.Type.Validate(elem, schema)
+				typeViolations := schema.validateElementType(elem, decl)
 				violations = append(violations, typeViolations...)
 			}
 		} else {
@@ -130,7 +139,7 @@ func ValidateAnyElement(elem xmldom.Element, wildcard *AnyElement, schema *Schem
 		if decl, found := schema.ElementDecls[qname]; found {
 			// Found declaration, validate against it
 			if decl.Type != nil {
-				typeViolations := decl.Type.Validate(elem, schema)
+				typeViolations := schema.validateElementType(elem, decl)
 				violations = append(violations, typeViolations...)
 			}
 		}
@@ -216,6 +225,74 @@ func MatchesWildcard(elem xmldom.Element, namespace string, targetNamespace stri
 	return constraint.Matches(elemNS, targetNamespace)
 }
 
+// admits reports whether a wildcard matches an element: its namespace is
+// allowed and neither notNamespace nor notQName excludes its name. The names
+// ##definedSibling excludes are those declared in group, and are not
+// checked when group is nil.
+func (ae *AnyElement) admits(elem xmldom.Element, schema *Schema, group *ModelGroup) bool {
+	name := QName{Namespace: string(elem.NamespaceURI()), Local: string(elem.LocalName())}
+	if !ae.admitsName(name, schema) {
+		return false
+	}
+	return !ae.NotDefinedSibling || group == nil || !group.declaresElement(name, schema, make(map[*ModelGroup]bool))
+}
+
+// admitsName reports whether a wildcard matches an element name, leaving
+// out ##definedSibling
+func (ae *AnyElement) admitsName(name QName, schema *Schema) bool {
+	if !ParseNamespaceConstraint(ae.Namespace).Matches(name.Namespace, schema.TargetNamespace) {
+		return false
+	}
+	if ae.NotNamespace != "" {
+		// notNamespace is always a list, which may hold ##targetNamespace and ##local
+		excluded := &WildcardNamespaceConstraint{Mode: "list", Namespaces: strings.Fields(ae.NotNamespace)}
+		if excluded.Matches(name.Namespace, schema.TargetNamespace) {
+			return false
+		}
+	}
+	for _, excluded := range ae.NotQName {
+		if excluded == name {
+			return false
+		}
+	}
+	if ae.NotDefined {
+		if _, defined := schema.ElementDecls[name]; defined {
+			return false
+		}
+	}
+	return true
+}
+
+// declaresElement reports whether a model group, or a group nested in it,
+// has an element declaration or reference with the given name
+func (mg *ModelGroup) declaresElement(name QName, schema *Schema, visited map[*ModelGroup]bool) bool {
+	if mg == nil || visited[mg] {
+		return false
+	}
+	visited[mg] = true
+	for _, particle := range mg.Particles {
+		switch p := particle.(type) {
+		case *ElementDecl:
+			if p.Name == name {
+				return true
+			}
+		case *ElementRef:
+			if p.Ref == name {
+				return true
+			}
+		case *ModelGroup:
+			if p.declaresElement(name, schema, visited) {
+				return true
+			}
+		case *GroupRef:
+			if schema.Groups[p.Ref].declaresElement(name, schema, visited) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // CountWildcardMatches counts how many elements match a wildcard
 func CountWildcardMatches(elements []xmldom.Element, wildcard *AnyElement, targetNamespace string) int {
 	count := 0
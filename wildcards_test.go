@@ -12,7 +12,7 @@ func TestAnyElementValidation(t *testing.T) {
 	// Create a schema with xs:any wildcard
 	schemaXML := `<?xml version="1.0" encoding="UTF-8"?>
 	<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema" 
-	           targetNamespace="http://example.com" 
+	           targetNamespace="http://example.com" elementFormDefault="qualified" 
 	           xmlns:ex="http://example.com">
 		<xs:element name="container">
 			<xs:complexType>
@@ -117,11 +117,62 @@ func TestAnyElementValidation(t *testing.T) {
 	}
 }
 
+func TestAnyElementExclusions(t *testing.T) {
+	content := `<?xml version="1.0"?>
+<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"
+           targetNamespace="http://example.com" elementFormDefault="qualified"
+           xmlns:ex="http://example.com" xmlns:o="http://other.com">
+	<xs:element name="container">
+		<xs:complexType>
+			<xs:sequence>
+				<xs:element name="header" type="xs:string"/>
+				<xs:any notNamespace="http://banned.com" notQName="o:secret ##defined"
+				        processContents="skip" minOccurs="0" maxOccurs="unbounded"/>
+			</xs:sequence>
+		</xs:complexType>
+	</xs:element>
+</xs:schema>`
+	schema11, err := parseTestSchema11(t, content)
+	if err != nil {
+		t.Fatalf("Failed to parse schema: %v", err)
+	}
+	schema10, err := parseTestSchema(t, content)
+	if err != nil {
+		t.Fatalf("Failed to parse schema: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		instance string
+		valid    bool // Under XSD 1.1; XSD 1.0 ignores notNamespace and notQName
+	}{
+		{"other names", `<container xmlns="http://example.com" xmlns:o="http://other.com"><header/><o:open/><extra/></container>`, true},
+		{"excluded namespace", `<container xmlns="http://example.com" xmlns:b="http://banned.com"><header/><b:extra/></container>`, false},
+		{"excluded name", `<container xmlns="http://example.com" xmlns:o="http://other.com"><header/><o:secret/></container>`, false},
+		{"globally declared name", `<container xmlns="http://example.com"><header/><container><header/></container></container>`, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			doc, err := xmldom.Decode(strings.NewReader(tt.instance))
+			if err != nil {
+				t.Fatalf("Failed to parse instance: %v", err)
+			}
+			if violations := NewValidator(schema11).Validate(doc); (len(violations) == 0) != tt.valid {
+				t.Errorf("XSD 1.1: expected valid=%v, got %v", tt.valid, violations)
+			}
+			if violations := NewValidator(schema10).Validate(doc); len(violations) != 0 {
+				t.Errorf("XSD 1.0: expected no violations, got %v", violations)
+			}
+		})
+	}
+}
+
 func TestAnyAttributeValidation(t *testing.T) {
 	// Create a schema with xs:anyAttribute wildcard
 	schemaXML := `<?xml version="1.0" encoding="UTF-8"?>
 	<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema" 
-	           targetNamespace="http://example.com" 
+	           targetNamespace="http://example.com" elementFormDefault="qualified" 
 	           xmlns:ex="http://example.com">
 		<xs:element name="element">
 			<xs:complexType>
@@ -303,7 +354,7 @@ func TestProcessContentsMode(t *testing.T) {
 	// Create schemas with different processContents modes
 	schemaWithStrict := `<?xml version="1.0" encoding="UTF-8"?>
 	<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema" 
-	           targetNamespace="http://example.com">
+	           targetNamespace="http://example.com" elementFormDefault="qualified">
 		<xs:element name="known" type="xs:string"/>
 		<xs:element name="container">
 			<xs:complexType>
@@ -0,0 +1,2100 @@
+package xsd
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/agentflare-ai/go-xmldom"
+)
+
+// The XPath 2.0 subset used by assertions covers path expressions over the
+// child, attribute, self, parent, descendant, ancestor and sibling axes
+// with predicates; general (=, <, ...) and value (eq, lt, ...) comparisons;
+// arithmetic; and, or; if/then/else; some/every quantified expressions;
+// sequences, ranges (to) and unions (|); variables; the constructor
+// functions of the built-in types (xs:date('2024-01-01')); and a core set
+// of functions: count, exists, empty, not, boolean, true, false, string,
+// number, data, sum, string-length, concat, contains, starts-with,
+// ends-with, normalize-space, position and last. Type alternatives use a
+// restricted form whose only steps are attribute steps, as in @kind = 'a'.
+//
+// Items of a sequence are xmldom nodes or atomic values, which use the Go
+// types of Value. Nodes without a known type atomize to untypedAtomic.
+
+// xpathFunctionsNamespace is the namespace of the XPath functions
+const xpathFunctionsNamespace = "http://www.w3.org/2005/xpath-functions"
+
+// untypedAtomic is the atomized value of a node without a known type
+type untypedAtomic string
+
+// xpathExpression is a compiled XPath expression
+type xpathExpression struct {
+	source string
+	root   xpathExpr
+}
+
+// xpathNamespaces resolves the names used in an expression: prefixes in
+// scope, and the namespace of unprefixed element names
+type xpathNamespaces struct {
+	resolve        func(prefix string) (string, bool)
+	defaultElement string
+}
+
+// xpathEnv is the environment an expression is evaluated in
+type xpathEnv struct {
+	root    xmldom.Node                           // Root of the tree paths can reach
+	atomize func(node xmldom.Node) ([]any, error) // Typed value of a node, nil for untypedAtomic
+	order   map[xmldom.Node]int
+}
+
+// xpathContext is the dynamic context of a subexpression
+type xpathContext struct {
+	item     any
+	position int
+	size     int
+	vars     map[string][]any
+	env      *xpathEnv
+}
+
+// with returns a copy of the context focused on another item
+func (c *xpathContext) with(item any, position, size int) *xpathContext {
+	next := *c
+	next.item, next.position, next.size = item, position, size
+	return &next
+}
+
+// xpathExpr is a node of a compiled expression
+type xpathExpr interface {
+	eval(ctx *xpathContext) ([]any, error)
+}
+
+// compileXPath parses an expression, resolving its prefixes
+func compileXPath(source string, namespaces xpathNamespaces) (*xpathExpression, error) {
+	return parseXPath(source, namespaces, false)
+}
+
+// compileRestrictedXPath parses an expression of the restricted subset used
+// by type alternatives, whose only steps are attribute steps of the context
+// element
+func compileRestrictedXPath(source string, namespaces xpathNamespaces) (*xpathExpression, error) {
+	return parseXPath(source, namespaces, true)
+}
+
+func parseXPath(source string, namespaces xpathNamespaces, restricted bool) (*xpathExpression, error) {
+	tokens, err := tokenizeXPath(source)
+	if err != nil {
+		return nil, err
+	}
+	p := &xpathParser{tokens: tokens, namespaces: namespaces, restricted: restricted}
+	root, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != xpathTokenEOF {
+		return nil, fmt.Errorf("unexpected '%s' at offset %d", tok.value, tok.pos)
+	}
+	return &xpathExpression{source: source, root: root}, nil
+}
+
+// evaluate evaluates an expression with a context item, which may be nil,
+// and variable bindings
+func (x *xpathExpression) evaluate(env *xpathEnv, item any, vars map[string][]any) ([]any, error) {
+	ctx := &xpathContext{item: item, position: 1, size: 1, vars: vars, env: env}
+	return x.root.eval(ctx)
+}
+
+// test evaluates an expression to its effective boolean value
+func (x *xpathExpression) test(env *xpathEnv, item any, vars map[string][]any) (bool, error) {
+	result, err := x.evaluate(env, item, vars)
+	if err != nil {
+		return false, err
+	}
+	return effectiveBoolean(result)
+}
+
+// Tokens
+
+type xpathTokenKind int
+
+const (
+	xpathTokenEOF xpathTokenKind = iota
+	xpathTokenName
+	xpathTokenNumber
+	xpathTokenString
+	xpathTokenVariable
+	xpathTokenSymbol
+)
+
+type xpathToken struct {
+	kind  xpathTokenKind
+	value string
+	pos   int
+}
+
+// tokenizeXPath splits an expression into names, numbers, string literals,
+// variable references and symbols, skipping comments
+func tokenizeXPath(source string) ([]xpathToken, error) {
+	var tokens []xpathToken
+	runes := []rune(source)
+	isNameStart := func(r rune) bool { return unicode.IsLetter(r) || r == '_' }
+	isNameChar := func(r rune) bool {
+		return isNameStart(r) || unicode.IsDigit(r) || r == '-' || r == '.' || r == '_'
+	}
+	readNCName := func(i int) int {
+		for i < len(runes) && isNameChar(runes[i]) {
+			i++
+		}
+		return i
+	}
+	at := func(i int) rune {
+		if i < len(runes) {
+			return runes[i]
+		}
+		return 0
+	}
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(' && at(i+1) == ':':
+			depth := 0
+			for ; i < len(runes); i++ {
+				if runes[i] == '(' && at(i+1) == ':' {
+					depth++
+					i++
+				} else if runes[i] == ':' && at(i+1) == ')' {
+					depth--
+					i++
+					if depth == 0 {
+						i++
+						break
+					}
+				}
+			}
+			if depth != 0 {
+				return nil, fmt.Errorf("unterminated comment")
+			}
+		case r == '"' || r == '\'':
+			var b strings.Builder
+			j := i + 1
+			for {
+				if j >= len(runes) {
+					return nil, fmt.Errorf("unterminated string literal at offset %d", i)
+				}
+				if runes[j] == r {
+					if at(j+1) == r {
+						b.WriteRune(r)
+						j += 2
+						continue
+					}
+					break
+				}
+				b.WriteRune(runes[j])
+				j++
+			}
+			tokens = append(tokens, xpathToken{xpathTokenString, b.String(), i})
+			i = j + 1
+		case unicode.IsDigit(r) || (r == '.' && unicode.IsDigit(at(i+1))):
+			j := i
+			for j < len(runes) && unicode.IsDigit(runes[j]) {
+				j++
+			}
+			if at(j) == '.' {
+				j++
+				for j < len(runes) && unicode.IsDigit(runes[j]) {
+					j++
+				}
+			}
+			if at(j) == 'e' || at(j) == 'E' {
+				k := j + 1
+				if at(k) == '+' || at(k) == '-' {
+					k++
+				}
+				if unicode.IsDigit(at(k)) {
+					for k < len(runes) && unicode.IsDigit(runes[k]) {
+						k++
+					}
+					j = k
+				}
+			}
+			tokens = append(tokens, xpathToken{xpathTokenNumber, string(runes[i:j]), i})
+			i = j
+		case r == '$':
+			j := i + 1
+			if !isNameStart(at(j)) {
+				return nil, fmt.Errorf("expected a variable name at offset %d", i)
+			}
+			j = readNCName(j)
+			if at(j) == ':' && isNameStart(at(j+1)) {
+				j = readNCName(j + 1)
+			}
+			tokens = append(tokens, xpathToken{xpathTokenVariable, string(runes[i+1 : j]), i})
+			i = j
+		case isNameStart(r):
+			j := readNCName(i)
+			// A prefixed name or prefix:*, but not an axis separator
+			if at(j) == ':' && at(j+1) != ':' {
+				if isNameStart(at(j + 1)) {
+					j = readNCName(j + 1)
+				} else if at(j+1) == '*' {
+					j += 2
+				}
+			}
+			tokens = append(tokens, xpathToken{xpathTokenName, string(runes[i:j]), i})
+			i = j
+		case r == '*' && at(i+1) == ':' && isNameStart(at(i+2)):
+			j := readNCName(i + 2)
+			tokens = append(tokens, xpathToken{xpathTokenName, string(runes[i:j]), i})
+			i = j
+		default:
+			symbol := string(r)
+			if two := string(r) + string(at(i+1)); two == "//" || two == ".." || two == "::" ||
+				two == "!=" || two == "<=" || two == ">=" {
+				symbol = two
+			}
+			if !strings.Contains("()[],/@.:=!<>+-*|", string(r)) || symbol == "!" || symbol == ":" {
+				return nil, fmt.Errorf("unexpected character '%c' at offset %d", r, i)
+			}
+			tokens = append(tokens, xpathToken{xpathTokenSymbol, symbol, i})
+			i += len([]rune(symbol))
+		}
+	}
+	return append(tokens, xpathToken{xpathTokenEOF, "end of expression", len(runes)}), nil
+}
+
+// Parser
+
+type xpathParser struct {
+	tokens     []xpathToken
+	pos        int
+	namespaces xpathNamespaces
+	restricted bool // Only attribute steps are allowed
+}
+
+func (p *xpathParser) peek() xpathToken {
+	return p.tokens[p.pos]
+}
+
+func (p *xpathParser) peekAt(offset int) xpathToken {
+	if p.pos+offset < len(p.tokens) {
+		return p.tokens[p.pos+offset]
+	}
+	return p.tokens[len(p.tokens)-1]
+}
+
+func (p *xpathParser) next() xpathToken {
+	tok := p.tokens[p.pos]
+	if tok.kind != xpathTokenEOF {
+		p.pos++
+	}
+	return tok
+}
+
+// isSymbol reports whether the next token is one of the given symbols
+func (p *xpathParser) isSymbol(symbols ...string) bool {
+	tok := p.peek()
+	if tok.kind != xpathTokenSymbol {
+		return false
+	}
+	for _, s := range symbols {
+		if tok.value == s {
+			return true
+		}
+	}
+	return false
+}
+
+// isKeyword reports whether the next token is one of the given names, in
+// operator position
+func (p *xpathParser) isKeyword(keywords ...string) bool {
+	tok := p.peek()
+	if tok.kind != xpathTokenName {
+		return false
+	}
+	for _, k := range keywords {
+		if tok.value == k {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *xpathParser) expect(symbol string) error {
+	if tok := p.next(); tok.kind != xpathTokenSymbol || tok.value != symbol {
+		return fmt.Errorf("expected '%s' at offset %d, found '%s'", symbol, tok.pos, tok.value)
+	}
+	return nil
+}
+
+func (p *xpathParser) expectKeyword(keyword string) error {
+	if tok := p.next(); tok.kind != xpathTokenName || tok.value != keyword {
+		return fmt.Errorf("expected '%s' at offset %d, found '%s'", keyword, tok.pos, tok.value)
+	}
+	return nil
+}
+
+// parseExpr parses a comma-separated sequence of expressions
+func (p *xpathParser) parseExpr() (xpathExpr, error) {
+	first, err := p.parseExprSingle()
+	if err != nil {
+		return nil, err
+	}
+	if !p.isSymbol(",") {
+		return first, nil
+	}
+	seq := &xpathSequenceExpr{items: []xpathExpr{first}}
+	for p.isSymbol(",") {
+		p.next()
+		item, err := p.parseExprSingle()
+		if err != nil {
+			return nil, err
+		}
+		seq.items = append(seq.items, item)
+	}
+	return seq, nil
+}
+
+func (p *xpathParser) parseExprSingle() (xpathExpr, error) {
+	tok, after := p.peek(), p.peekAt(1)
+	switch {
+	case tok.kind == xpathTokenName && tok.value == "if" && after.kind == xpathTokenSymbol && after.value == "(":
+		return p.parseIf()
+	case tok.kind == xpathTokenName && (tok.value == "some" || tok.value == "every") && after.kind == xpathTokenVariable:
+		return p.parseQuantified()
+	}
+	return p.parseOr()
+}
+
+func (p *xpathParser) parseIf() (xpathExpr, error) {
+	p.next()
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	cond, err := p.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	if err := p.expectKeyword("then"); err != nil {
+		return nil, err
+	}
+	then, err := p.parseExprSingle()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expectKeyword("else"); err != nil {
+		return nil, err
+	}
+	els, err := p.parseExprSingle()
+	if err != nil {
+		return nil, err
+	}
+	return &xpathIf{cond: cond, then: then, els: els}, nil
+}
+
+func (p *xpathParser) parseQuantified() (xpathExpr, error) {
+	q := &xpathQuantified{every: p.next().value == "every"}
+	for {
+		tok := p.next()
+		if tok.kind != xpathTokenVariable {
+			return nil, fmt.Errorf("expected a variable at offset %d, found '%s'", tok.pos, tok.value)
+		}
+		if err := p.expectKeyword("in"); err != nil {
+			return nil, err
+		}
+		seq, err := p.parseExprSingle()
+		if err != nil {
+			return nil, err
+		}
+		q.vars = append(q.vars, tok.value)
+		q.seqs = append(q.seqs, seq)
+		if !p.isSymbol(",") {
+			break
+		}
+		p.next()
+	}
+	if err := p.expectKeyword("satisfies"); err != nil {
+		return nil, err
+	}
+	satisfies, err := p.parseExprSingle()
+	if err != nil {
+		return nil, err
+	}
+	q.satisfies = satisfies
+	return q, nil
+}
+
+// parseBinary parses a left-associative chain of operators
+func (p *xpathParser) parseBinary(operand func() (xpathExpr, error), symbols []string, keywords []string) (xpathExpr, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for p.isSymbol(symbols...) || p.isKeyword(keywords...) {
+		op := p.next().value
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		left = &xpathBinary{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *xpathParser) parseOr() (xpathExpr, error) {
+	return p.parseBinary(p.parseAnd, nil, []string{"or"})
+}
+
+func (p *xpathParser) parseAnd() (xpathExpr, error) {
+	return p.parseBinary(p.parseComparison, nil, []string{"and"})
+}
+
+func (p *xpathParser) parseComparison() (xpathExpr, error) {
+	left, err := p.parseRange()
+	if err != nil {
+		return nil, err
+	}
+	if p.isSymbol("=", "!=", "<", "<=", ">", ">=") || p.isKeyword("eq", "ne", "lt", "le", "gt", "ge") {
+		op := p.next().value
+		right, err := p.parseRange()
+		if err != nil {
+			return nil, err
+		}
+		return &xpathBinary{op: op, left: left, right: right}, nil
+	}
+	return left, nil
+}
+
+func (p *xpathParser) parseRange() (xpathExpr, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	if p.isKeyword("to") {
+		p.next()
+		right, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		return &xpathBinary{op: "to", left: left, right: right}, nil
+	}
+	return left, nil
+}
+
+func (p *xpathParser) parseAdditive() (xpathExpr, error) {
+	return p.parseBinary(p.parseMultiplicative, []string{"+", "-"}, nil)
+}
+
+func (p *xpathParser) parseMultiplicative() (xpathExpr, error) {
+	return p.parseBinary(p.parseUnion, []string{"*"}, []string{"div", "idiv", "mod"})
+}
+
+func (p *xpathParser) parseUnion() (xpathExpr, error) {
+	return p.parseBinary(p.parseUnary, []string{"|"}, []string{"union"})
+}
+
+func (p *xpathParser) parseUnary() (xpathExpr, error) {
+	if p.isSymbol("-", "+") {
+		negate := p.next().value == "-"
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &xpathUnary{negate: negate, operand: operand}, nil
+	}
+	return p.parsePath()
+}
+
+// parsePath parses an absolute or relative path; a single step that is
+// not an axis step is returned as it is
+func (p *xpathParser) parsePath() (xpathExpr, error) {
+	path := &xpathPath{}
+	if p.restricted && p.isSymbol("/", "//") {
+		return nil, fmt.Errorf("paths are not allowed in a restricted expression, found '%s' at offset %d",
+			p.peek().value, p.peek().pos)
+	}
+	switch {
+	case p.isSymbol("/"):
+		p.next()
+		path.absolute = true
+		if !p.startsStep() {
+			return path, nil
+		}
+	case p.isSymbol("//"):
+		p.next()
+		path.absolute = true
+		path.steps = append(path.steps, descendantOrSelfStep())
+	}
+
+	step, err := p.parseStep()
+	if err != nil {
+		return nil, err
+	}
+	path.steps = append(path.steps, step)
+	for p.isSymbol("/", "//") {
+		if p.restricted {
+			return nil, fmt.Errorf("paths are not allowed in a restricted expression, found '%s' at offset %d",
+				p.peek().value, p.peek().pos)
+		}
+		if p.next().value == "//" {
+			path.steps = append(path.steps, descendantOrSelfStep())
+		}
+		step, err := p.parseStep()
+		if err != nil {
+			return nil, err
+		}
+		path.steps = append(path.steps, step)
+	}
+
+	if len(path.steps) == 1 && !path.absolute {
+		if _, isAxisStep := step.(*xpathStep); !isAxisStep {
+			return step, nil
+		}
+	}
+	return path, nil
+}
+
+// startsStep reports whether the next token can begin a step
+func (p *xpathParser) startsStep() bool {
+	tok := p.peek()
+	switch tok.kind {
+	case xpathTokenName, xpathTokenNumber, xpathTokenString, xpathTokenVariable:
+		return true
+	case xpathTokenSymbol:
+		return tok.value == "@" || tok.value == "." || tok.value == ".." || tok.value == "*" || tok.value == "("
+	}
+	return false
+}
+
+func descendantOrSelfStep() xpathExpr {
+	return &xpathStep{axis: "descendant-or-self", test: xpathNodeTest{kind: "node"}}
+}
+
+// parseStep parses an axis step or a filter expression, with predicates
+func (p *xpathParser) parseStep() (xpathExpr, error) {
+	tok, after := p.peek(), p.peekAt(1)
+
+	var step xpathExpr
+	switch {
+	case tok.kind == xpathTokenSymbol && tok.value == "..":
+		p.next()
+		step = &xpathStep{axis: "parent", test: xpathNodeTest{kind: "node"}}
+	case tok.kind == xpathTokenSymbol && tok.value == "@":
+		p.next()
+		test, err := p.parseNodeTest(true)
+		if err != nil {
+			return nil, err
+		}
+		step = &xpathStep{axis: "attribute", test: test}
+	case tok.kind == xpathTokenName && after.kind == xpathTokenSymbol && after.value == "::":
+		axis := p.next().value
+		p.next()
+		switch axis {
+		case "child", "attribute", "self", "parent", "descendant", "descendant-or-self",
+			"ancestor", "ancestor-or-self", "following-sibling", "preceding-sibling":
+		default:
+			return nil, fmt.Errorf("unsupported axis '%s' at offset %d", axis, tok.pos)
+		}
+		test, err := p.parseNodeTest(axis == "attribute")
+		if err != nil {
+			return nil, err
+		}
+		step = &xpathStep{axis: axis, test: test}
+	case tok.kind == xpathTokenSymbol && tok.value == "*",
+		tok.kind == xpathTokenName && !(after.kind == xpathTokenSymbol && after.value == "(") || isKindTest(tok, after):
+		test, err := p.parseNodeTest(false)
+		if err != nil {
+			return nil, err
+		}
+		step = &xpathStep{axis: "child", test: test}
+	default:
+		primary, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		step = primary
+	}
+	if axisStep, ok := step.(*xpathStep); ok && p.restricted && axisStep.axis != "attribute" {
+		return nil, fmt.Errorf("only attribute steps are allowed in a restricted expression, found '%s' at offset %d",
+			tok.value, tok.pos)
+	}
+
+	var predicates []xpathExpr
+	for p.isSymbol("[") {
+		p.next()
+		predicate, err := p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("]"); err != nil {
+			return nil, err
+		}
+		predicates = append(predicates, predicate)
+	}
+	if len(predicates) == 0 {
+		return step, nil
+	}
+	if axisStep, ok := step.(*xpathStep); ok {
+		axisStep.predicates = predicates
+		return axisStep, nil
+	}
+	return &xpathFilter{primary: step, predicates: predicates}, nil
+}
+
+// isKindTest reports whether a name followed by a parenthesis is a node
+// kind test rather than a function call
+func isKindTest(tok, after xpathToken) bool {
+	if tok.kind != xpathTokenName || after.kind != xpathTokenSymbol || after.value != "(" {
+		return false
+	}
+	switch tok.value {
+	case "node", "text", "element", "attribute":
+		return true
+	}
+	return false
+}
+
+// parseNodeTest parses a name test or a kind test; unprefixed names are
+// in the default element namespace, except on the attribute axis
+func (p *xpathParser) parseNodeTest(attribute bool) (xpathNodeTest, error) {
+	tok := p.next()
+	if tok.kind == xpathTokenSymbol && tok.value == "*" {
+		return xpathNodeTest{kind: "name", namespace: "*", local: "*"}, nil
+	}
+	if tok.kind != xpathTokenName {
+		return xpathNodeTest{}, fmt.Errorf("expected a name test at offset %d, found '%s'", tok.pos, tok.value)
+	}
+
+	if p.isSymbol("(") && isKindTest(tok, p.peek()) {
+		p.next()
+		test := xpathNodeTest{kind: tok.value}
+		if (tok.value == "element" || tok.value == "attribute") && !p.isSymbol(")") {
+			name, err := p.parseNodeTest(tok.value == "attribute")
+			if err != nil {
+				return xpathNodeTest{}, err
+			}
+			test.namespace, test.local = name.namespace, name.local
+		}
+		if err := p.expect(")"); err != nil {
+			return xpathNodeTest{}, err
+		}
+		return test, nil
+	}
+
+	prefix, local, prefixed := strings.Cut(tok.value, ":")
+	if !prefixed {
+		namespace := p.namespaces.defaultElement
+		if attribute {
+			namespace = ""
+		}
+		return xpathNodeTest{kind: "name", namespace: namespace, local: tok.value}, nil
+	}
+	if prefix == "*" {
+		return xpathNodeTest{kind: "name", namespace: "*", local: local}, nil
+	}
+	namespace, err := p.resolvePrefix(prefix, tok)
+	if err != nil {
+		return xpathNodeTest{}, err
+	}
+	return xpathNodeTest{kind: "name", namespace: namespace, local: local}, nil
+}
+
+func (p *xpathParser) resolvePrefix(prefix string, tok xpathToken) (string, error) {
+	if p.namespaces.resolve != nil {
+		if namespace, ok := p.namespaces.resolve(prefix); ok {
+			return namespace, nil
+		}
+	}
+	return "", fmt.Errorf("undeclared prefix '%s' at offset %d", prefix, tok.pos)
+}
+
+// parsePrimary parses a literal, variable reference, parenthesized
+// expression, context item or function call
+func (p *xpathParser) parsePrimary() (xpathExpr, error) {
+	tok := p.next()
+	switch tok.kind {
+	case xpathTokenString:
+		return &xpathLiteral{value: tok.value}, nil
+	case xpathTokenNumber:
+		value, err := parseXPathNumber(tok.value)
+		if err != nil {
+			return nil, err
+		}
+		return &xpathLiteral{value: value}, nil
+	case xpathTokenVariable:
+		return &xpathVariableRef{name: tok.value}, nil
+	case xpathTokenSymbol:
+		switch tok.value {
+		case ".":
+			return &xpathContextItem{}, nil
+		case "(":
+			if p.isSymbol(")") {
+				p.next()
+				return &xpathSequenceExpr{}, nil
+			}
+			expr, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return expr, nil
+		}
+	case xpathTokenName:
+		if p.isSymbol("(") {
+			return p.parseFunctionCall(tok)
+		}
+	}
+	return nil, fmt.Errorf("unexpected '%s' at offset %d", tok.value, tok.pos)
+}
+
+func (p *xpathParser) parseFunctionCall(name xpathToken) (xpathExpr, error) {
+	p.next()
+	var args []xpathExpr
+	for !p.isSymbol(")") {
+		arg, err := p.parseExprSingle()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if !p.isSymbol(",") {
+			break
+		}
+		p.next()
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+
+	namespace := xpathFunctionsNamespace
+	local := name.value
+	if prefix, l, prefixed := strings.Cut(name.value, ":"); prefixed {
+		local = l
+		resolved, err := p.resolvePrefix(prefix, name)
+		switch {
+		case err == nil:
+			namespace = resolved
+		case prefix != "fn":
+			// fn is understood without a declaration
+			return nil, err
+		}
+	}
+
+	switch namespace {
+	case XSDNamespace:
+		if len(args) != 1 {
+			return nil, fmt.Errorf("constructor function xs:%s takes one argument", local)
+		}
+		if local == "anyAtomicType" || local == "NOTATION" || local == "QName" ||
+			(GetBuiltinType(local) == nil && primitiveType(local) == "" && !builtinListTypes[local] && local != "untypedAtomic") {
+			return nil, fmt.Errorf("unsupported constructor function xs:%s", local)
+		}
+		return &xpathConstructor{typeName: local, arg: args[0]}, nil
+	case xpathFunctionsNamespace:
+		arity, ok := xpathFunctionArity[local]
+		if !ok {
+			return nil, fmt.Errorf("unknown function %s()", local)
+		}
+		if len(args) < arity[0] || (arity[1] >= 0 && len(args) > arity[1]) {
+			return nil, fmt.Errorf("wrong number of arguments to %s()", local)
+		}
+		return &xpathFunction{name: local, args: args}, nil
+	}
+	return nil, fmt.Errorf("unknown function %s()", name.value)
+}
+
+// parseXPathNumber parses an integer, decimal or double literal
+func parseXPathNumber(lexical string) (any, error) {
+	switch {
+	case strings.ContainsAny(lexical, "eE"):
+		f, err := strconv.ParseFloat(lexical, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number '%s'", lexical)
+		}
+		return f, nil
+	case strings.Contains(lexical, "."):
+		r, ok := new(big.Rat).SetString(lexical)
+		if !ok {
+			return nil, fmt.Errorf("invalid number '%s'", lexical)
+		}
+		return r, nil
+	}
+	n, ok := new(big.Int).SetString(lexical, 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid number '%s'", lexical)
+	}
+	return n, nil
+}
+
+// Expressions
+
+type xpathLiteral struct{ value any }
+
+func (e *xpathLiteral) eval(*xpathContext) ([]any, error) {
+	return []any{e.value}, nil
+}
+
+type xpathVariableRef struct{ name string }
+
+func (e *xpathVariableRef) eval(ctx *xpathContext) ([]any, error) {
+	value, ok := ctx.vars[e.name]
+	if !ok {
+		return nil, fmt.Errorf("variable $%s is not bound", e.name)
+	}
+	return value, nil
+}
+
+type xpathContextItem struct{}
+
+func (e *xpathContextItem) eval(ctx *xpathContext) ([]any, error) {
+	if ctx.item == nil {
+		return nil, fmt.Errorf("the context item is absent")
+	}
+	return []any{ctx.item}, nil
+}
+
+type xpathSequenceExpr struct{ items []xpathExpr }
+
+func (e *xpathSequenceExpr) eval(ctx *xpathContext) ([]any, error) {
+	var result []any
+	for _, item := range e.items {
+		value, err := item.eval(ctx)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, value...)
+	}
+	return result, nil
+}
+
+type xpathIf struct{ cond, then, els xpathExpr }
+
+func (e *xpathIf) eval(ctx *xpathContext) ([]any, error) {
+	cond, err := e.cond.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := effectiveBoolean(cond)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return e.then.eval(ctx)
+	}
+	return e.els.eval(ctx)
+}
+
+type xpathQuantified struct {
+	every     bool
+	vars      []string
+	seqs      []xpathExpr
+	satisfies xpathExpr
+}
+
+func (e *xpathQuantified) eval(ctx *xpathContext) ([]any, error) {
+	result, err := e.bind(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+	return []any{result}, nil
+}
+
+// bind iterates over the binding sequence of the i-th variable
+func (e *xpathQuantified) bind(ctx *xpathContext, i int) (bool, error) {
+	if i == len(e.vars) {
+		value, err := e.satisfies.eval(ctx)
+		if err != nil {
+			return false, err
+		}
+		return effectiveBoolean(value)
+	}
+	seq, err := e.seqs[i].eval(ctx)
+	if err != nil {
+		return false, err
+	}
+	for _, item := range seq {
+		vars := make(map[string][]any, len(ctx.vars)+1)
+		for name, value := range ctx.vars {
+			vars[name] = value
+		}
+		vars[e.vars[i]] = []any{item}
+		inner := *ctx
+		inner.vars = vars
+		ok, err := e.bind(&inner, i+1)
+		if err != nil {
+			return false, err
+		}
+		if ok != e.every {
+			return ok, nil
+		}
+	}
+	return e.every, nil
+}
+
+type xpathUnary struct {
+	negate  bool
+	operand xpathExpr
+}
+
+func (e *xpathUnary) eval(ctx *xpathContext) ([]any, error) {
+	operand, err := e.operand.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	value, empty, err := singleAtomic(ctx, operand)
+	if err != nil || empty {
+		return nil, err
+	}
+	if !e.negate {
+		return []any{numericOperand(value)}, nil
+	}
+	result, err := arithmetic("-", big.NewInt(0), value)
+	if err != nil {
+		return nil, err
+	}
+	return []any{result}, nil
+}
+
+type xpathBinary struct {
+	op          string
+	left, right xpathExpr
+}
+
+func (e *xpathBinary) eval(ctx *xpathContext) ([]any, error) {
+	left, err := e.left.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// and and or do not evaluate their right operand when the left decides
+	if e.op == "and" || e.op == "or" {
+		l, err := effectiveBoolean(left)
+		if err != nil {
+			return nil, err
+		}
+		if l == (e.op == "or") {
+			return []any{l}, nil
+		}
+		right, err := e.right.eval(ctx)
+		if err != nil {
+			return nil, err
+		}
+		r, err := effectiveBoolean(right)
+		if err != nil {
+			return nil, err
+		}
+		return []any{r}, nil
+	}
+
+	right, err := e.right.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	switch e.op {
+	case "=", "!=", "<", "<=", ">", ">=":
+		result, err := generalCompare(ctx, e.op, left, right)
+		if err != nil {
+			return nil, err
+		}
+		return []any{result}, nil
+	case "eq", "ne", "lt", "le", "gt", "ge":
+		l, lEmpty, err := singleAtomic(ctx, left)
+		if err != nil {
+			return nil, err
+		}
+		r, rEmpty, err := singleAtomic(ctx, right)
+		if err != nil {
+			return nil, err
+		}
+		if lEmpty || rEmpty {
+			return nil, nil
+		}
+		result, err := valueCompare(e.op, untypedToString(l), untypedToString(r))
+		if err != nil {
+			return nil, err
+		}
+		return []any{result}, nil
+	case "|", "union":
+		nodes := make([]any, 0, len(left)+len(right))
+		for _, item := range append(append([]any(nil), left...), right...) {
+			if _, ok := item.(xmldom.Node); !ok {
+				return nil, fmt.Errorf("operands of a union must be nodes")
+			}
+			nodes = append(nodes, item)
+		}
+		return ctx.env.documentOrder(nodes), nil
+	case "to":
+		return rangeSequence(ctx, left, right)
+	}
+
+	l, lEmpty, err := singleAtomic(ctx, left)
+	if err != nil {
+		return nil, err
+	}
+	r, rEmpty, err := singleAtomic(ctx, right)
+	if err != nil {
+		return nil, err
+	}
+	if lEmpty || rEmpty {
+		return nil, nil
+	}
+	result, err := arithmetic(e.op, l, r)
+	if err != nil {
+		return nil, err
+	}
+	return []any{result}, nil
+}
+
+// rangeSequence returns the integers from one operand to the other
+func rangeSequence(ctx *xpathContext, left, right []any) ([]any, error) {
+	l, lEmpty, err := singleAtomic(ctx, left)
+	if err != nil {
+		return nil, err
+	}
+	r, rEmpty, err := singleAtomic(ctx, right)
+	if err != nil {
+		return nil, err
+	}
+	if lEmpty || rEmpty {
+		return nil, nil
+	}
+	from, ok1 := integerOperand(l)
+	to, ok2 := integerOperand(r)
+	if !ok1 || !ok2 {
+		return nil, fmt.Errorf("operands of 'to' must be integers")
+	}
+	if !from.IsInt64() || !to.IsInt64() || to.Int64()-from.Int64() > 1_000_000 {
+		return nil, fmt.Errorf("range %s to %s is too large", from, to)
+	}
+	var result []any
+	for i := from.Int64(); i <= to.Int64(); i++ {
+		result = append(result, big.NewInt(i))
+	}
+	return result, nil
+}
+
+// integerOperand returns an integer, or an untypedAtomic holding one
+func integerOperand(v any) (*big.Int, bool) {
+	switch n := v.(type) {
+	case *big.Int:
+		return n, true
+	case untypedAtomic:
+		i, ok := new(big.Int).SetString(strings.TrimSpace(string(n)), 10)
+		return i, ok
+	}
+	return nil, false
+}
+
+// Paths
+
+type xpathPath struct {
+	absolute bool
+	steps    []xpathExpr
+}
+
+func (e *xpathPath) eval(ctx *xpathContext) ([]any, error) {
+	var current []any
+	if e.absolute {
+		if ctx.env.root == nil {
+			return nil, fmt.Errorf("a path cannot start at the root here")
+		}
+		current = []any{ctx.env.root}
+	} else {
+		if ctx.item == nil {
+			return nil, fmt.Errorf("the context item is absent")
+		}
+		current = []any{ctx.item}
+	}
+
+	for i, step := range e.steps {
+		var next []any
+		allNodes := true
+		for j, item := range current {
+			if _, ok := item.(xmldom.Node); !ok {
+				return nil, fmt.Errorf("a path step requires a node as its context item")
+			}
+			result, err := step.eval(ctx.with(item, j+1, len(current)))
+			if err != nil {
+				return nil, err
+			}
+			for _, r := range result {
+				if _, ok := r.(xmldom.Node); !ok {
+					allNodes = false
+				}
+			}
+			next = append(next, result...)
+		}
+		if allNodes {
+			next = ctx.env.documentOrder(next)
+		} else if i < len(e.steps)-1 {
+			return nil, fmt.Errorf("only the last step of a path can return atomic values")
+		}
+		current = next
+	}
+	return current, nil
+}
+
+type xpathStep struct {
+	axis       string
+	test       xpathNodeTest
+	predicates []xpathExpr
+}
+
+func (e *xpathStep) eval(ctx *xpathContext) ([]any, error) {
+	node, ok := ctx.item.(xmldom.Node)
+	if !ok {
+		return nil, fmt.Errorf("an axis step requires a node as its context item")
+	}
+	var result []any
+	for _, candidate := range ctx.env.axis(e.axis, node) {
+		if e.test.matches(candidate, e.axis == "attribute") {
+			result = append(result, candidate)
+		}
+	}
+	return applyPredicates(ctx, result, e.predicates)
+}
+
+type xpathFilter struct {
+	primary    xpathExpr
+	predicates []xpathExpr
+}
+
+func (e *xpathFilter) eval(ctx *xpathContext) ([]any, error) {
+	seq, err := e.primary.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return applyPredicates(ctx, seq, e.predicates)
+}
+
+// applyPredicates filters a sequence: numeric predicates select by
+// position, others by their effective boolean value
+func applyPredicates(ctx *xpathContext, seq []any, predicates []xpathExpr) ([]any, error) {
+	for _, predicate := range predicates {
+		var kept []any
+		for i, item := range seq {
+			value, err := predicate.eval(ctx.with(item, i+1, len(seq)))
+			if err != nil {
+				return nil, err
+			}
+			keep := false
+			if len(value) == 1 && isNumeric(value[0]) {
+				keep = numericEquals(value[0], i+1)
+			} else if keep, err = effectiveBoolean(value); err != nil {
+				return nil, err
+			}
+			if keep {
+				kept = append(kept, item)
+			}
+		}
+		seq = kept
+	}
+	return seq, nil
+}
+
+// numericEquals reports whether a number equals a position
+func numericEquals(v any, position int) bool {
+	ok, err := valueCompare("eq", v, big.NewInt(int64(position)))
+	return err == nil && ok
+}
+
+// xpathNodeTest is a name test, with "*" for wildcards, or a kind test
+type xpathNodeTest struct {
+	kind      string // "name", "node", "text", "element" or "attribute"
+	namespace string
+	local     string
+}
+
+// matches reports whether a node passes the test; name tests select the
+// principal node kind of the axis
+func (t xpathNodeTest) matches(node xmldom.Node, attributeAxis bool) bool {
+	nodeType := node.NodeType()
+	switch t.kind {
+	case "node":
+		return true
+	case "text":
+		return nodeType == xmldom.TEXT_NODE || nodeType == xmldom.CDATA_SECTION_NODE
+	case "element":
+		if nodeType != xmldom.ELEMENT_NODE {
+			return false
+		}
+	case "attribute":
+		if nodeType != xmldom.ATTRIBUTE_NODE {
+			return false
+		}
+	default:
+		principal := xmldom.NodeType(xmldom.ELEMENT_NODE)
+		if attributeAxis {
+			principal = xmldom.ATTRIBUTE_NODE
+		}
+		if nodeType != principal {
+			return false
+		}
+	}
+	if t.local == "" {
+		return true
+	}
+	local := string(node.LocalName())
+	if local == "" {
+		local = string(node.NodeName())
+	}
+	return (t.local == "*" || t.local == local) &&
+		(t.namespace == "*" || t.namespace == string(node.NamespaceURI()))
+}
+
+// axis returns the nodes along an axis from a node, in axis order, without
+// leaving the tree rooted at env.root
+func (env *xpathEnv) axis(axis string, node xmldom.Node) []xmldom.Node {
+	var nodes []xmldom.Node
+	switch axis {
+	case "self":
+		nodes = append(nodes, node)
+	case "child":
+		nodes = xpathChildren(node)
+	case "attribute":
+		nodes = xpathAttributes(node)
+	case "parent":
+		if parent := env.parent(node); parent != nil {
+			nodes = append(nodes, parent)
+		}
+	case "descendant", "descendant-or-self":
+		if axis == "descendant-or-self" {
+			nodes = append(nodes, node)
+		}
+		var walk func(n xmldom.Node)
+		walk = func(n xmldom.Node) {
+			for _, child := range xpathChildren(n) {
+				nodes = append(nodes, child)
+				walk(child)
+			}
+		}
+		walk(node)
+	case "ancestor", "ancestor-or-self":
+		if axis == "ancestor-or-self" {
+			nodes = append(nodes, node)
+		}
+		for parent := env.parent(node); parent != nil; parent = env.parent(parent) {
+			nodes = append(nodes, parent)
+		}
+	case "following-sibling", "preceding-sibling":
+		parent := env.parent(node)
+		if parent == nil || node.NodeType() == xmldom.ATTRIBUTE_NODE {
+			break
+		}
+		siblings := xpathChildren(parent)
+		for i, sibling := range siblings {
+			if sibling != node {
+				continue
+			}
+			if axis == "following-sibling" {
+				nodes = append(nodes, siblings[i+1:]...)
+			} else {
+				for j := i - 1; j >= 0; j-- {
+					nodes = append(nodes, siblings[j])
+				}
+			}
+			break
+		}
+	}
+	return nodes
+}
+
+// parent returns the parent of a node, or nil at the root of the tree
+func (env *xpathEnv) parent(node xmldom.Node) xmldom.Node {
+	if env.root != nil && node == env.root {
+		return nil
+	}
+	if attr, ok := node.(xmldom.Attr); ok {
+		if owner := attr.OwnerElement(); owner != nil {
+			return owner
+		}
+		return nil
+	}
+	parent := node.ParentNode()
+	if parent == nil || parent.NodeType() == xmldom.DOCUMENT_NODE && env.root != nil {
+		return nil
+	}
+	return parent
+}
+
+// xpathChildren returns the element and text children of a node
+func xpathChildren(node xmldom.Node) []xmldom.Node {
+	var children []xmldom.Node
+	list := node.ChildNodes()
+	if list == nil {
+		return nil
+	}
+	for i := uint(0); i < list.Length(); i++ {
+		child := list.Item(i)
+		if child == nil {
+			continue
+		}
+		switch child.NodeType() {
+		case xmldom.ELEMENT_NODE, xmldom.TEXT_NODE, xmldom.CDATA_SECTION_NODE:
+			children = append(children, child)
+		}
+	}
+	return children
+}
+
+// xpathAttributes returns the attributes of an element, without namespace
+// declarations
+func xpathAttributes(node xmldom.Node) []xmldom.Node {
+	if node.NodeType() != xmldom.ELEMENT_NODE {
+		return nil
+	}
+	attrs := node.Attributes()
+	if attrs == nil {
+		return nil
+	}
+	var nodes []xmldom.Node
+	for i := uint(0); i < attrs.Length(); i++ {
+		attr := attrs.Item(i)
+		if attr == nil || isNamespaceDeclaration(attr) {
+			continue
+		}
+		nodes = append(nodes, attr)
+	}
+	return nodes
+}
+
+// isNamespaceDeclaration reports whether an attribute declares a namespace
+func isNamespaceDeclaration(attr xmldom.Node) bool {
+	ns := string(attr.NamespaceURI())
+	name := string(attr.NodeName())
+	return name == "xmlns" || strings.HasPrefix(name, "xmlns:") || string(attr.Prefix()) == "xmlns" ||
+		ns == "xmlns" || ns == "http://www.w3.org/2000/xmlns/"
+}
+
+// documentOrder removes duplicate nodes from a sequence and sorts it in
+// document order
+func (env *xpathEnv) documentOrder(nodes []any) []any {
+	if len(nodes) < 2 {
+		return nodes
+	}
+	if env.order == nil {
+		env.order = make(map[xmldom.Node]int)
+		root := env.root
+		if root == nil {
+			if n, ok := nodes[0].(xmldom.Node); ok {
+				root = n
+				for p := root.ParentNode(); p != nil; p = p.ParentNode() {
+					root = p
+				}
+			}
+		}
+		var walk func(n xmldom.Node)
+		walk = func(n xmldom.Node) {
+			env.order[n] = len(env.order)
+			for _, attr := range xpathAttributes(n) {
+				env.order[attr] = len(env.order)
+			}
+			for _, child := range xpathChildren(n) {
+				walk(child)
+			}
+		}
+		if root != nil {
+			walk(root)
+		}
+	}
+
+	seen := make(map[xmldom.Node]bool, len(nodes))
+	unique := make([]any, 0, len(nodes))
+	for _, item := range nodes {
+		node := item.(xmldom.Node)
+		if !seen[node] {
+			seen[node] = true
+			unique = append(unique, item)
+		}
+	}
+	sort.SliceStable(unique, func(i, j int) bool {
+		return env.order[unique[i].(xmldom.Node)] < env.order[unique[j].(xmldom.Node)]
+	})
+	return unique
+}
+
+// Functions
+
+// xpathFunctionArity holds the minimum and maximum number of arguments of
+// each function, with -1 for no maximum
+var xpathFunctionArity = map[string][2]int{
+	"count": {1, 1}, "exists": {1, 1}, "empty": {1, 1}, "not": {1, 1}, "boolean": {1, 1},
+	"true": {0, 0}, "false": {0, 0}, "string": {0, 1}, "number": {0, 1}, "data": {1, 1},
+	"sum": {1, 2}, "string-length": {0, 1}, "concat": {2, -1}, "contains": {2, 2},
+	"starts-with": {2, 2}, "ends-with": {2, 2}, "normalize-space": {0, 1},
+	"position": {0, 0}, "last": {0, 0},
+}
+
+type xpathFunction struct {
+	name string
+	args []xpathExpr
+}
+
+func (e *xpathFunction) eval(ctx *xpathContext) ([]any, error) {
+	args := make([][]any, len(e.args))
+	for i, arg := range e.args {
+		value, err := arg.eval(ctx)
+		if err != nil {
+			return nil, err
+		}
+		args[i] = value
+	}
+	// Functions that default to the context item
+	contextArg := func() ([]any, error) {
+		if len(args) > 0 {
+			return args[0], nil
+		}
+		if ctx.item == nil {
+			return nil, fmt.Errorf("%s() requires a context item", e.name)
+		}
+		return []any{ctx.item}, nil
+	}
+	stringArg := func(seq []any) (string, error) {
+		if len(seq) == 0 {
+			return "", nil
+		}
+		if len(seq) > 1 {
+			return "", fmt.Errorf("%s() expects a single item", e.name)
+		}
+		return xpathString(seq[0]), nil
+	}
+
+	switch e.name {
+	case "count":
+		return []any{big.NewInt(int64(len(args[0])))}, nil
+	case "exists":
+		return []any{len(args[0]) > 0}, nil
+	case "empty":
+		return []any{len(args[0]) == 0}, nil
+	case "not", "boolean":
+		value, err := effectiveBoolean(args[0])
+		if err != nil {
+			return nil, err
+		}
+		return []any{value != (e.name == "not")}, nil
+	case "true", "false":
+		return []any{e.name == "true"}, nil
+	case "position":
+		return []any{big.NewInt(int64(ctx.position))}, nil
+	case "last":
+		return []any{big.NewInt(int64(ctx.size))}, nil
+	case "data":
+		return atomizeSequence(ctx, args[0])
+	case "string", "string-length", "normalize-space":
+		seq, err := contextArg()
+		if err != nil {
+			return nil, err
+		}
+		s, err := stringArg(seq)
+		if err != nil {
+			return nil, err
+		}
+		switch e.name {
+		case "string-length":
+			return []any{big.NewInt(int64(len([]rune(s))))}, nil
+		case "normalize-space":
+			return []any{strings.Join(strings.Fields(s), " ")}, nil
+		}
+		return []any{s}, nil
+	case "number":
+		seq, err := contextArg()
+		if err != nil {
+			return nil, err
+		}
+		atoms, err := atomizeSequence(ctx, seq)
+		if err != nil || len(atoms) != 1 {
+			return []any{math.NaN()}, nil
+		}
+		return []any{toDouble(atoms[0])}, nil
+	case "sum":
+		atoms, err := atomizeSequence(ctx, args[0])
+		if err != nil {
+			return nil, err
+		}
+		if len(atoms) == 0 {
+			if len(args) > 1 {
+				return args[1], nil
+			}
+			return []any{big.NewInt(0)}, nil
+		}
+		total := numericOperand(atoms[0])
+		for _, atom := range atoms[1:] {
+			if total, err = arithmetic("+", total, atom); err != nil {
+				return nil, err
+			}
+		}
+		if !isNumeric(total) {
+			return nil, fmt.Errorf("sum() expects numbers")
+		}
+		return []any{total}, nil
+	case "concat":
+		var b strings.Builder
+		for _, arg := range args {
+			s, err := stringArg(arg)
+			if err != nil {
+				return nil, err
+			}
+			b.WriteString(s)
+		}
+		return []any{b.String()}, nil
+	case "contains", "starts-with", "ends-with":
+		s, err := stringArg(args[0])
+		if err != nil {
+			return nil, err
+		}
+		sub, err := stringArg(args[1])
+		if err != nil {
+			return nil, err
+		}
+		switch e.name {
+		case "contains":
+			return []any{strings.Contains(s, sub)}, nil
+		case "starts-with":
+			return []any{strings.HasPrefix(s, sub)}, nil
+		}
+		return []any{strings.HasSuffix(s, sub)}, nil
+	}
+	return nil, fmt.Errorf("unknown function %s()", e.name)
+}
+
+// xpathConstructor casts its argument to a built-in type, as in
+// xs:date('2024-01-01')
+type xpathConstructor struct {
+	typeName string
+	arg      xpathExpr
+}
+
+func (e *xpathConstructor) eval(ctx *xpathContext) ([]any, error) {
+	arg, err := e.arg.eval(ctx)
+	if err != nil {
+		return nil, err
+	}
+	value, empty, err := singleAtomic(ctx, arg)
+	if err != nil || empty {
+		return nil, err
+	}
+	return castAtomic(value, e.typeName)
+}
+
+// castAtomic casts an atomic value to a built-in type. Numbers cast to
+// integer types are truncated; other values go through their string value.
+func castAtomic(value any, typeName string) ([]any, error) {
+	switch typeName {
+	case "untypedAtomic":
+		return []any{untypedAtomic(xpathString(value))}, nil
+	case "string":
+		return []any{xpathString(value)}, nil
+	}
+
+	primitive := primitiveType(typeName)
+	if isNumeric(value) {
+		switch {
+		case primitive == "float" || primitive == "double":
+			f := toDouble(value)
+			if primitive == "float" {
+				f = float64(float32(f))
+			}
+			return []any{f}, nil
+		case builtinDerivesFrom(typeName, "integer"):
+			if f, ok := value.(float64); ok && (math.IsNaN(f) || math.IsInf(f, 0)) {
+				return nil, fmt.Errorf("cannot cast %s to xs:%s", xpathString(value), typeName)
+			}
+			n := truncate(value)
+			if err := validateBuiltinValue(n.String(), QName{Namespace: XSDNamespace, Local: typeName}); err != nil {
+				return nil, err
+			}
+			return []any{n}, nil
+		case primitive == "decimal":
+			r, ok := decimalValue(value)
+			if !ok {
+				f := value.(float64)
+				if math.IsNaN(f) || math.IsInf(f, 0) {
+					return nil, fmt.Errorf("cannot cast %s to xs:%s", xpathString(value), typeName)
+				}
+				r = new(big.Rat).SetFloat64(f)
+			}
+			return []any{r}, nil
+		}
+	}
+
+	lexical := NormalizeWhiteSpace(xpathString(value), builtinTypeWhiteSpace(typeName))
+	parsed, err := parseBuiltinValue(lexical, typeName, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot cast '%s' to xs:%s: %v", lexical, typeName, err)
+	}
+	return flattenValue(parsed), nil
+}
+
+// truncate returns the integer part of a number
+func truncate(value any) *big.Int {
+	switch n := value.(type) {
+	case *big.Int:
+		return n
+	case *big.Rat:
+		return new(big.Int).Quo(n.Num(), n.Denom())
+	case float64:
+		i, _ := new(big.Float).SetFloat64(math.Trunc(n)).Int(nil)
+		return i
+	}
+	return big.NewInt(0)
+}
+
+// Values
+
+// flattenValue turns a Value into a sequence of atomic items: list values
+// contribute their items, union values their member value and floats their
+// value as a double
+func flattenValue(v Value) []any {
+	switch value := v.(type) {
+	case []Value:
+		var items []any
+		for _, item := range value {
+			items = append(items, flattenValue(item)...)
+		}
+		return items
+	case UnionValue:
+		return flattenValue(value.Value)
+	case float32:
+		return []any{float64(value)}
+	case AnyURI:
+		// anyURI is promoted to string
+		return []any{string(value)}
+	}
+	return []any{v}
+}
+
+// atomizeSequence replaces the nodes of a sequence by their typed values
+func atomizeSequence(ctx *xpathContext, seq []any) ([]any, error) {
+	var atoms []any
+	for _, item := range seq {
+		node, ok := item.(xmldom.Node)
+		if !ok {
+			atoms = append(atoms, item)
+			continue
+		}
+		if ctx.env.atomize != nil {
+			typed, err := ctx.env.atomize(node)
+			if err != nil {
+				return nil, err
+			}
+			if typed != nil {
+				atoms = append(atoms, typed...)
+				continue
+			}
+		}
+		atoms = append(atoms, untypedAtomic(nodeStringValue(node)))
+	}
+	return atoms, nil
+}
+
+// singleAtomic atomizes a sequence that must hold at most one item
+func singleAtomic(ctx *xpathContext, seq []any) (value any, empty bool, err error) {
+	atoms, err := atomizeSequence(ctx, seq)
+	if err != nil {
+		return nil, false, err
+	}
+	switch len(atoms) {
+	case 0:
+		return nil, true, nil
+	case 1:
+		return atoms[0], false, nil
+	}
+	return nil, false, fmt.Errorf("expected a single value, got a sequence of %d", len(atoms))
+}
+
+// nodeStringValue returns the string value of a node
+func nodeStringValue(node xmldom.Node) string {
+	if attr, ok := node.(xmldom.Attr); ok {
+		return string(attr.Value())
+	}
+	return string(node.TextContent())
+}
+
+// xpathString returns the string value of an item
+func xpathString(item any) string {
+	switch v := item.(type) {
+	case xmldom.Node:
+		return nodeStringValue(v)
+	case string:
+		return v
+	case untypedAtomic:
+		return string(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case *big.Int:
+		return v.String()
+	case *big.Rat:
+		if v.IsInt() {
+			return v.Num().String()
+		}
+		return strings.TrimRight(v.FloatString(18), "0")
+	case float64:
+		return formatDouble(v)
+	case DateTime:
+		return v.String()
+	case Duration:
+		return v.String()
+	case QName:
+		if v.Namespace == "" {
+			return v.Local
+		}
+		return v.String()
+	case NotationName:
+		return xpathString(QName(v))
+	case []byte:
+		return strings.ToUpper(fmt.Sprintf("%x", v))
+	case Base64Binary:
+		return base64.StdEncoding.EncodeToString(v)
+	}
+	return fmt.Sprint(item)
+}
+
+// formatDouble writes a double as XPath does: decimal notation between
+// 1e-6 and 1e6, scientific notation otherwise
+func formatDouble(f float64) string {
+	switch {
+	case math.IsNaN(f):
+		return "NaN"
+	case math.IsInf(f, 1):
+		return "INF"
+	case math.IsInf(f, -1):
+		return "-INF"
+	case f == 0:
+		if math.Signbit(f) {
+			return "-0"
+		}
+		return "0"
+	case math.Abs(f) >= 1e-6 && math.Abs(f) < 1e6:
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	s, _ := canonicalFloat(strconv.FormatFloat(f, 'E', -1, 64), 64)
+	return s
+}
+
+// effectiveBoolean returns the effective boolean value of a sequence
+func effectiveBoolean(seq []any) (bool, error) {
+	if len(seq) == 0 {
+		return false, nil
+	}
+	if _, ok := seq[0].(xmldom.Node); ok {
+		return true, nil
+	}
+	if len(seq) > 1 {
+		return false, fmt.Errorf("the effective boolean value of a sequence of %d atomic values is undefined", len(seq))
+	}
+	switch v := seq[0].(type) {
+	case bool:
+		return v, nil
+	case string:
+		return v != "", nil
+	case untypedAtomic:
+		return v != "", nil
+	case *big.Int:
+		return v.Sign() != 0, nil
+	case *big.Rat:
+		return v.Sign() != 0, nil
+	case float64:
+		return v != 0 && !math.IsNaN(v), nil
+	}
+	return false, fmt.Errorf("the effective boolean value of %s is undefined", xpathString(seq[0]))
+}
+
+// Comparisons
+
+// generalCompare compares two sequences existentially: the result is true
+// when some pair of their atomized items compares as the operator says.
+// Untyped values are compared as numbers against numbers, as strings
+// against strings and untyped values, and as the other type otherwise.
+func generalCompare(ctx *xpathContext, op string, left, right []any) (bool, error) {
+	valueOp := map[string]string{"=": "eq", "!=": "ne", "<": "lt", "<=": "le", ">": "gt", ">=": "ge"}[op]
+	l, err := atomizeSequence(ctx, left)
+	if err != nil {
+		return false, err
+	}
+	r, err := atomizeSequence(ctx, right)
+	if err != nil {
+		return false, err
+	}
+	for _, a := range l {
+		for _, b := range r {
+			a, b, err := promoteUntyped(a, b)
+			if err != nil {
+				return false, err
+			}
+			ok, err := valueCompare(valueOp, a, b)
+			if err != nil {
+				return false, err
+			}
+			if ok {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// promoteUntyped casts untyped operands of a general comparison
+func promoteUntyped(a, b any) (any, any, error) {
+	ua, aUntyped := a.(untypedAtomic)
+	ub, bUntyped := b.(untypedAtomic)
+	switch {
+	case aUntyped && bUntyped:
+		return string(ua), string(ub), nil
+	case aUntyped:
+		cast, err := castUntypedLike(ua, b)
+		return cast, b, err
+	case bUntyped:
+		cast, err := castUntypedLike(ub, a)
+		return a, cast, err
+	}
+	return a, b, nil
+}
+
+// castUntypedLike casts an untyped value to the type of another value
+func castUntypedLike(u untypedAtomic, other any) (any, error) {
+	var typeName string
+	switch v := other.(type) {
+	case *big.Int, *big.Rat, float64:
+		return toDouble(u), nil
+	case string, QName, NotationName:
+		return string(u), nil
+	case bool:
+		typeName = "boolean"
+	case DateTime:
+		typeName = v.Kind
+	case Duration:
+		typeName = "duration"
+	case []byte:
+		return []byte(u), nil
+	case Base64Binary:
+		typeName = "base64Binary"
+	default:
+		return string(u), nil
+	}
+	value, err := parseBuiltinValue(strings.TrimSpace(string(u)), typeName, nil)
+	if err != nil {
+		return nil, fmt.Errorf("cannot compare '%s' with a value of type xs:%s", u, typeName)
+	}
+	return value, nil
+}
+
+// untypedToString treats untyped operands of value comparisons as strings
+func untypedToString(v any) any {
+	if u, ok := v.(untypedAtomic); ok {
+		return string(u)
+	}
+	return v
+}
+
+// valueCompare compares two atomic values with eq, ne, lt, le, gt or ge
+func valueCompare(op string, a, b any) (bool, error) {
+	var order int
+	switch {
+	case isNumeric(a) && isNumeric(b):
+		if _, ok := a.(float64); ok {
+			b = toDouble(b)
+		} else if _, ok := b.(float64); ok {
+			a = toDouble(a)
+		}
+		cmp, ok := Compare(a, b)
+		if !ok {
+			// NaN is unequal to everything
+			return op == "ne", nil
+		}
+		order = cmp
+	default:
+		switch av := a.(type) {
+		case string:
+			bv, ok := b.(string)
+			if !ok {
+				return false, incomparable(a, b)
+			}
+			order = strings.Compare(av, bv)
+		case bool:
+			bv, ok := b.(bool)
+			if !ok {
+				return false, incomparable(a, b)
+			}
+			switch {
+			case av == bv:
+				order = 0
+			case !av:
+				order = -1
+			default:
+				order = 1
+			}
+		case DateTime:
+			bv, ok := b.(DateTime)
+			if !ok || av.Kind != bv.Kind {
+				return false, incomparable(a, b)
+			}
+			// Values without a timezone are taken to be in UTC
+			order = compareInstants(av, offsetOf(av), bv, offsetOf(bv))
+		case Duration:
+			bv, ok := b.(Duration)
+			if !ok {
+				return false, incomparable(a, b)
+			}
+			cmp, ok := Compare(av, bv)
+			if !ok {
+				if op == "eq" || op == "ne" {
+					return op == "ne", nil
+				}
+				return false, fmt.Errorf("durations %s and %s are not ordered", av, bv)
+			}
+			order = cmp
+		case QName, NotationName, []byte, Base64Binary:
+			if op != "eq" && op != "ne" {
+				return false, fmt.Errorf("values of this type can only be compared with eq and ne")
+			}
+			if !sameKind(a, b) {
+				return false, incomparable(a, b)
+			}
+			return Equal(a, b) == (op == "eq"), nil
+		default:
+			return false, incomparable(a, b)
+		}
+	}
+
+	switch op {
+	case "eq":
+		return order == 0, nil
+	case "ne":
+		return order != 0, nil
+	case "lt":
+		return order < 0, nil
+	case "le":
+		return order <= 0, nil
+	case "gt":
+		return order > 0, nil
+	}
+	return order >= 0, nil
+}
+
+// sameKind reports whether two values have the same Go type
+func sameKind(a, b any) bool {
+	return fmt.Sprintf("%T", a) == fmt.Sprintf("%T", b)
+}
+
+func incomparable(a, b any) error {
+	return fmt.Errorf("cannot compare %s with %s", xpathString(a), xpathString(b))
+}
+
+// Arithmetic
+
+// isNumeric reports whether a value is an integer, decimal or double
+func isNumeric(v any) bool {
+	switch v.(type) {
+	case *big.Int, *big.Rat, float64:
+		return true
+	}
+	return false
+}
+
+// numericOperand casts untyped operands of arithmetic to double
+func numericOperand(v any) any {
+	if u, ok := v.(untypedAtomic); ok {
+		return toDouble(u)
+	}
+	return v
+}
+
+// toDouble converts a value to a double, NaN when it is not a number
+func toDouble(v any) float64 {
+	switch n := v.(type) {
+	case float64:
+		return n
+	case *big.Int:
+		f, _ := new(big.Float).SetInt(n).Float64()
+		return f
+	case *big.Rat:
+		f, _ := n.Float64()
+		return f
+	case bool:
+		if n {
+			return 1
+		}
+		return 0
+	}
+	f, err := parseFloatValue(strings.TrimSpace(xpathString(v)), "double")
+	if err != nil {
+		return math.NaN()
+	}
+	return f
+}
+
+// arithmetic applies +, -, *, div, idiv or mod to two numbers. Integer
+// operands give integers (but div gives a decimal), decimal operands give
+// decimals and a double operand gives a double.
+func arithmetic(op string, a, b any) (any, error) {
+	a, b = numericOperand(a), numericOperand(b)
+	if !isNumeric(a) || !isNumeric(b) {
+		return nil, fmt.Errorf("arithmetic on %s and %s is not supported", xpathString(a), xpathString(b))
+	}
+
+	_, aDouble := a.(float64)
+	_, bDouble := b.(float64)
+	if aDouble || bDouble {
+		x, y := toDouble(a), toDouble(b)
+		switch op {
+		case "+":
+			return x + y, nil
+		case "-":
+			return x - y, nil
+		case "*":
+			return x * y, nil
+		case "div":
+			return x / y, nil
+		case "mod":
+			return math.Mod(x, y), nil
+		case "idiv":
+			if y == 0 || math.IsNaN(x) || math.IsNaN(y) || math.IsInf(x, 0) {
+				return nil, fmt.Errorf("invalid integer division")
+			}
+			return truncate(x / y), nil
+		}
+	}
+
+	x, _ := decimalValue(a)
+	y, _ := decimalValue(b)
+	if (op == "div" || op == "idiv" || op == "mod") && y.Sign() == 0 {
+		return nil, fmt.Errorf("division by zero")
+	}
+	var result *big.Rat
+	switch op {
+	case "+":
+		result = new(big.Rat).Add(x, y)
+	case "-":
+		result = new(big.Rat).Sub(x, y)
+	case "*":
+		result = new(big.Rat).Mul(x, y)
+	case "div":
+		return new(big.Rat).Quo(x, y), nil
+	case "idiv":
+		return truncate(new(big.Rat).Quo(x, y)), nil
+	case "mod":
+		quotient := new(big.Rat).SetInt(truncate(new(big.Rat).Quo(x, y)))
+		result = new(big.Rat).Sub(x, quotient.Mul(quotient, y))
+	default:
+		return nil, fmt.Errorf("unknown operator '%s'", op)
+	}
+
+	_, aInt := a.(*big.Int)
+	_, bInt := b.(*big.Int)
+	if aInt && bInt {
+		return new(big.Int).Set(result.Num()), nil
+	}
+	return result, nil
+}
@@ -0,0 +1,121 @@
+package xsd
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/agentflare-ai/go-xmldom"
+)
+
+func TestXPathEvaluation(t *testing.T) {
+	doc, err := xmldom.Decode(strings.NewReader(`<order xmlns:p="urn:p" id="7" status="open">
+	<item sku="a" qty="2"><price>1.50</price></item>
+	<item sku="b" qty="3"><price>2.25</price></item>
+	<p:note>rush</p:note>
+</order>`))
+	if err != nil {
+		t.Fatalf("Failed to parse instance: %v", err)
+	}
+	root := doc.DocumentElement()
+	namespaces := xpathNamespaces{
+		resolve: func(prefix string) (string, bool) {
+			switch prefix {
+			case "p":
+				return "urn:p", true
+			case "xs":
+				return XSDNamespace, true
+			}
+			return "", false
+		},
+	}
+
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{"count(item)", "2"},
+		{"count(//price)", "2"},
+		{"count(*)", "3"},
+		{"count(p:*)", "1"},
+		{"string(p:note)", "rush"},
+		{"@id = 7", "true"},
+		{"@id eq '7'", "true"},
+		{"@status = ('open', 'closed')", "true"},
+		{"item[2]/@sku", "b"},
+		{"item[@qty > 2]/@sku", "b"},
+		{"item[last()]/price", "2.25"},
+		{"sum(item/@qty)", "5"},
+		{"item/price * 2", ""},
+		{"item[1]/price * 2", "3"},
+		{"xs:decimal(item[1]/price) + 1", "2.5"},
+		{"7 idiv 2, 7 mod 2, 7 div 2", "3 1 3.5"},
+		{"-item[1]/@qty", "-2"},
+		{"1 to 3", "1 2 3"},
+		{"if (@status = 'open') then 'yes' else 'no'", "yes"},
+		{"some $i in item satisfies $i/@qty = 3", "true"},
+		{"every $i in item satisfies $i/price > 2", "false"},
+		{"every $i in item, $j in $i/price satisfies $j > 1", "true"},
+		{"exists(item/@missing) or empty(item/@missing)", "true"},
+		{"not(item[3])", "true"},
+		{"item[1]/price/..[@sku = 'a']/@qty", "2"},
+		{"count(item/following-sibling::item)", "1"},
+		{"count(item[2]/preceding-sibling::*)", "1"},
+		{"count(item[1]/price/ancestor::*)", "2"},
+		{"count(..)", "0"},
+		{"count(item | item[1])", "2"},
+		{"xs:date('2024-01-01Z') lt xs:date('2024-01-02Z')", "true"},
+		{"xs:dateTime('2024-01-01T12:00:00Z') = xs:dateTime('2024-01-01T13:00:00+01:00')", "true"},
+		{"xs:duration('P1Y') eq xs:duration('P12M')", "true"},
+		{"string-length(concat(@status, '-', @id))", "6"},
+		{"contains(p:note, 'us') and starts-with(p:note, 'r') and ends-with(p:note, 'h')", "true"},
+		{"number('x') = number('x')", "false"},
+		{"(: comment :) 1 + 2", "3"},
+		{"1.5e1", "15"},
+		{"'it''s'", "it's"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			expr, err := compileXPath(tt.expr, namespaces)
+			if err != nil {
+				t.Fatalf("Failed to compile: %v", err)
+			}
+			result, err := expr.evaluate(&xpathEnv{root: root}, root, nil)
+			if tt.want == "" {
+				if err == nil {
+					t.Errorf("Expected an error, got %v", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Failed to evaluate: %v", err)
+			}
+			var got []string
+			for _, item := range result {
+				got = append(got, xpathString(item))
+			}
+			if strings.Join(got, " ") != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, strings.Join(got, " "))
+			}
+		})
+	}
+}
+
+func TestXPathSyntaxErrors(t *testing.T) {
+	for _, expr := range []string{
+		"count(",
+		"item[",
+		"q:item",
+		"unknown()",
+		"if (1) then 2",
+		"some $x in item",
+		"'unterminated",
+		"1 +",
+		"foo::item",
+		"xs:nosuchtype('1')",
+	} {
+		if _, err := compileXPath(expr, xpathNamespaces{}); err == nil {
+			t.Errorf("Expected %q to be rejected", expr)
+		}
+	}
+}